@@ -9,21 +9,17 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/aton/atonWeb/api/internal/config"
-	"github.com/aton/atonWeb/api/internal/model"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/repository"
 )
 
 func main() {
 	cfg := config.Load()
 
-	// Connect to database
-	db, err := gorm.Open(postgres.Open(cfg.PostgresDSN), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect database: %v", err)
-	}
-
 	// Get username and password from args
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: create-user <username> <password> [email]")
+		fmt.Println("Usage: create-user <username> <password> [email] [role]")
+		fmt.Println("Role defaults to owner for the first user and viewer afterwards.")
 		os.Exit(1)
 	}
 
@@ -34,26 +30,48 @@ func main() {
 		email = os.Args[3]
 	}
 
+	// Connect to database
+	db, err := gorm.Open(postgres.Open(cfg.PostgresDSN), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect database: %v", err)
+	}
+	users := repository.NewUserRepository(db)
+
 	// Check if user exists
-	var existingUser model.User
-	if err := db.Where("username = ?", username).First(&existingUser).Error; err == nil {
+	exists, err := users.UsernameExists(username)
+	if err != nil {
+		log.Fatalf("Failed to check user: %v", err)
+	}
+	if exists {
 		log.Fatalf("User '%s' already exists", username)
 	}
 
+	count, err := users.Count()
+	if err != nil {
+		log.Fatalf("Failed to count users: %v", err)
+	}
+	role := domain.InitialRole(count)
+	if len(os.Args) >= 5 {
+		role = domain.Role(os.Args[4])
+		if !role.Valid() {
+			log.Fatalf("Invalid role '%s': must be owner, admin, editor or viewer", role)
+		}
+	}
+
 	// Create user
-	user := &model.User{
+	user := &domain.User{
 		Username: username,
 		Email:    email,
-		Role:     "admin",
+		Role:     role,
 	}
 
 	if err := user.HashPassword(password); err != nil {
 		log.Fatalf("Failed to hash password: %v", err)
 	}
 
-	if err := db.Create(user).Error; err != nil {
+	if err := users.Create(user); err != nil {
 		log.Fatalf("Failed to create user: %v", err)
 	}
 
-	fmt.Printf("✅ Admin user '%s' created successfully!\n", username)
-}
\ No newline at end of file
+	fmt.Printf("✅ User '%s' created successfully with role %s!\n", username, role)
+}
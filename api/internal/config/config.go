@@ -2,10 +2,31 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/aton/atonWeb/api/internal/domain"
 )
 
+// DefaultJWTSecret is only for local development; production refuses to sign with it
+const DefaultJWTSecret = "change-me-in-production"
+
+// placeholderJWTSecrets are well-known values, including the one in .env.example
+var placeholderJWTSecrets = map[string]bool{
+	DefaultJWTSecret:                       true,
+	"your-secret-key-change-in-production": true,
+}
+
+// minJWTSecretLength is the shortest HS256 secret accepted in production
+const minJWTSecretLength = 32
+
+// maxSignedURLTTL is the longest expiry S3 accepts for a presigned URL
+const maxSignedURLTTL = 7 * 24 * time.Hour
+
 type Config struct {
 	Env         string
 	AppHost     string
@@ -13,31 +34,266 @@ type Config struct {
 	PostgresDSN string
 	JWTSecret   string
 
+	// JWT 非对称签名: 设置私钥 (RSA 或 Ed25519, PEM) 后使用 RS256/EdDSA 签名, 否则退回 HS256 + JWTSecret
+	// 轮换密钥时把旧密钥 (私钥或公钥) 放入 JWTPreviousKeyFiles, 旧令牌在过期前仍可验证
+	JWTPrivateKeyFile   string
+	JWTPreviousKeyFiles []string
+
+	// 访问令牌短期有效, 通过刷新令牌续期
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// 过期会话按 SessionPurgeInterval 在后台删除, 不占用登录请求
+	SessionPurgeInterval time.Duration
+
+	// 登录失败锁定: 连续失败次数上限与锁定时长
+	LoginMaxFailures     int
+	LoginLockoutDuration time.Duration
+
+	// 两步验证: 验证器应用中显示的发行方
+	MFAIssuer string
+
+	// 单点登录 (OIDC 授权码 + PKCE): 设置 OIDCIssuerURL 后启用
+	// OIDCRedirectURL 为前端回调页, 由前端把 code 与 state 提交到 /auth/oidc/callback
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	OIDCProviderName string
+	// 用户名取自 OIDCUsernameClaim; 设置 OIDCRoleClaim 后每次登录按 OIDCRoleMapping (组=角色) 同步角色
+	OIDCUsernameClaim string
+	OIDCRoleClaim     string
+	OIDCRoleMapping   map[string]string
+	OIDCDefaultRole   string
+	// 首次单点登录时是否自动创建账号, 默认关闭
+	OIDCAutoCreate bool
+	// 是否把邮箱已验证且匹配的现有账号关联到身份提供方, 默认关闭; 所有者与管理员账号从不自动关联
+	OIDCLinkByEmail bool
+	// 关闭后只能通过单点登录进入, 密码登录与密码重置均被拒绝
+	PasswordLoginEnabled bool
+
+	// 密码重置: 邮件中的链接地址 (附加 ?token=) 与令牌有效期
+	PasswordResetURL string
+	PasswordResetTTL time.Duration
+
+	// 邮件配置: MAIL_DRIVER 为 smtp 或 log (不记录正文, 需要查看时写入 MAIL_DIR, 用于本地开发)
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPTLS      string
+
 	// CORS 配置
 	CORSOrigins []string
 
-	// MinIO/OSS 配置
+	// 反向代理: 只有来自这些地址 (IP 或 CIDR) 的 X-Forwarded-For 才被采信,
+	// 为空时客户端 IP 取连接地址, 防止伪造 IP 绕过登录限流或写入审计日志
+	TrustedProxies []string
+
+	// 对象存储: STORAGE_DRIVER 为 s3 (MinIO/OSS 等 S3 兼容存储), local (本地目录) 或 memory (仅内存, 重启即丢失, 用于测试)
+	// 未设置时配置了 OSS_ENDPOINT 则为 s3, 否则为 local; local 与 memory 的文件由 API 在 /api/v1/blobs 下提供
+	StorageDriver   string
+	StorageLocalDir string
+	// API 的对外地址, 用于生成 local/memory 存储的文件地址; 上传地址用 StorageURLSecret 签名, 留空时每次启动随机生成
+	APIPublicURL     string
+	StorageURLSecret string
+	// 返回给客户端的文件地址在响应时由对象键生成, 数据库只保存对象键
+	// StoragePublicURL 为 CDN 域名 (留空时直接使用存储地址); 私有存储设置 StorageSignedURLTTL, 改为返回有效期内的预签名地址
+	StoragePublicURL    string
+	StorageSignedURLTTL time.Duration
+
+	// MinIO/OSS 配置 (STORAGE_DRIVER=s3)
 	OSSEndpoint        string
 	OSSBucket          string
 	OSSAccessKeyID     string
 	OSSAccessKeySecret string
 	OSSUseSSL          bool
+
+	// 上传限制: 单个文件的字节上限与像素上限 (宽 x 高, 防止解压炸弹)
+	UploadMaxBytes  int64
+	UploadMaxPixels int64
+	// 分片上传面向大文件, 字节上限单独设置
+	MultipartMaxBytes int64
+	// 上传后需在 UploadPendingTTL 内调用 complete 生成照片, 过期未完成的对象按 UploadCleanupInterval 定期清理
+	UploadPendingTTL      time.Duration
+	UploadCleanupInterval time.Duration
+
+	// 图片处理配置
+	ImageVariantWidths  []int
+	ImageVariantFormats []string
+
+	// 回收站配置: 软删除的照片保留多久后被清理
+	PhotoTrashRetention time.Duration
+	PhotoPurgeInterval  time.Duration
 }
 
 func Load() Config {
 	return Config{
-		Env:                getEnv("ENV", "development"),
-		AppHost:            getEnv("API_HOST", "0.0.0.0"),
-		AppPort:            getEnv("API_PORT", "8080"),
-		PostgresDSN:        buildPostgresDSN(),
-		JWTSecret:          getEnv("JWT_SECRET", "change-me-in-production"),
-		CORSOrigins:        parseCORSOrigins(getEnv("CORS_ORIGINS", "http://localhost:3000,http://127.0.0.1:3000")),
-		OSSEndpoint:        getEnv("OSS_ENDPOINT", ""),
-		OSSBucket:          getEnv("OSS_BUCKET", ""),
-		OSSAccessKeyID:     getEnv("OSS_ACCESS_KEY_ID", ""),
-		OSSAccessKeySecret: getEnv("OSS_ACCESS_KEY_SECRET", ""),
-		OSSUseSSL:          getEnv("OSS_USE_SSL", "false") == "true",
+		Env:                   getEnv("ENV", "development"),
+		AppHost:               getEnv("API_HOST", "0.0.0.0"),
+		AppPort:               getEnv("API_PORT", "8080"),
+		PostgresDSN:           buildPostgresDSN(),
+		JWTSecret:             getEnv("JWT_SECRET", DefaultJWTSecret),
+		JWTPrivateKeyFile:     getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTPreviousKeyFiles:   parseList(getEnv("JWT_PREVIOUS_KEY_FILES", "")),
+		AccessTokenTTL:        parseDuration(getEnv("ACCESS_TOKEN_TTL", "15m"), 15*time.Minute),
+		RefreshTokenTTL:       parseDuration(getEnv("REFRESH_TOKEN_TTL", "720h"), 30*24*time.Hour),
+		SessionPurgeInterval:  parseDuration(getEnv("SESSION_PURGE_INTERVAL", "1h"), time.Hour),
+		LoginMaxFailures:      parsePositiveInt(getEnv("LOGIN_MAX_FAILURES", "5"), 5),
+		LoginLockoutDuration:  parseDuration(getEnv("LOGIN_LOCKOUT_DURATION", "15m"), 15*time.Minute),
+		MFAIssuer:             getEnv("MFA_ISSUER", "atonWeb"),
+		OIDCIssuerURL:         getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:          getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:      getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:       getEnv("OIDC_REDIRECT_URL", "http://localhost:3000/admin/oidc/callback"),
+		OIDCScopes:            parseList(getEnv("OIDC_SCOPES", "openid,profile,email")),
+		OIDCProviderName:      getEnv("OIDC_PROVIDER_NAME", "SSO"),
+		OIDCUsernameClaim:     getEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+		OIDCRoleClaim:         getEnv("OIDC_ROLE_CLAIM", ""),
+		OIDCRoleMapping:       parseMapping(getEnv("OIDC_ROLE_MAPPING", "")),
+		OIDCDefaultRole:       getEnv("OIDC_DEFAULT_ROLE", "viewer"),
+		OIDCAutoCreate:        getEnv("OIDC_AUTO_CREATE", "false") == "true",
+		OIDCLinkByEmail:       getEnv("OIDC_LINK_BY_EMAIL", "false") == "true",
+		PasswordLoginEnabled:  getEnv("PASSWORD_LOGIN_ENABLED", "true") == "true",
+		PasswordResetURL:      getEnv("PASSWORD_RESET_URL", "http://localhost:3000/admin/reset-password"),
+		PasswordResetTTL:      parseDuration(getEnv("PASSWORD_RESET_TTL", "30m"), 30*time.Minute),
+		MailDriver:            getEnv("MAIL_DRIVER", "log"),
+		MailFrom:              getEnv("MAIL_FROM", "atonWeb <no-reply@localhost>"),
+		MailDir:               getEnv("MAIL_DIR", ""),
+		SMTPHost:              getEnv("SMTP_HOST", ""),
+		SMTPPort:              parsePositiveInt(getEnv("SMTP_PORT", "0"), 0),
+		SMTPUsername:          getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:          getEnv("SMTP_PASSWORD", ""),
+		SMTPTLS:               getEnv("SMTP_TLS", "starttls"),
+		CORSOrigins:           parseCORSOrigins(getEnv("CORS_ORIGINS", "http://localhost:3000,http://127.0.0.1:3000")),
+		TrustedProxies:        parseList(getEnv("TRUSTED_PROXIES", "")),
+		StorageDriver:         getEnv("STORAGE_DRIVER", defaultStorageDriver()),
+		StorageLocalDir:       getEnv("STORAGE_LOCAL_DIR", "data/storage"),
+		APIPublicURL:          strings.TrimRight(getEnv("API_PUBLIC_URL", "http://localhost:8080"), "/"),
+		StorageURLSecret:      getEnv("STORAGE_URL_SECRET", ""),
+		StoragePublicURL:      strings.TrimRight(getEnv("STORAGE_PUBLIC_URL", ""), "/"),
+		StorageSignedURLTTL:   parseDuration(getEnv("STORAGE_SIGNED_URL_TTL", "0"), 0),
+		OSSEndpoint:           getEnv("OSS_ENDPOINT", ""),
+		OSSBucket:             getEnv("OSS_BUCKET", ""),
+		OSSAccessKeyID:        getEnv("OSS_ACCESS_KEY_ID", ""),
+		OSSAccessKeySecret:    getEnv("OSS_ACCESS_KEY_SECRET", ""),
+		OSSUseSSL:             getEnv("OSS_USE_SSL", "false") == "true",
+		UploadMaxBytes:        int64(parsePositiveInt(getEnv("UPLOAD_MAX_BYTES", "52428800"), 50<<20)),
+		MultipartMaxBytes:     int64(parsePositiveInt(getEnv("UPLOAD_MULTIPART_MAX_BYTES", "268435456"), 256<<20)),
+		UploadMaxPixels:       int64(parsePositiveInt(getEnv("UPLOAD_MAX_PIXELS", "100000000"), 100_000_000)),
+		UploadPendingTTL:      parseDuration(getEnv("UPLOAD_PENDING_TTL", "24h"), 24*time.Hour),
+		UploadCleanupInterval: parseDuration(getEnv("UPLOAD_CLEANUP_INTERVAL", "1h"), time.Hour),
+		ImageVariantWidths:    parseIntList(getEnv("IMAGE_VARIANT_WIDTHS", "320,640,1280,2048")),
+		ImageVariantFormats:   parseList(getEnv("IMAGE_VARIANT_FORMATS", "webp,jpeg")),
+		PhotoTrashRetention:   parseDuration(getEnv("PHOTO_TRASH_RETENTION", "720h"), 30*24*time.Hour),
+		PhotoPurgeInterval:    parseDuration(getEnv("PHOTO_PURGE_INTERVAL", "1h"), time.Hour),
+	}
+}
+
+// Validate 检查不能带入生产环境的配置
+func (c Config) Validate() error {
+	if err := c.validateOIDC(); err != nil {
+		return err
+	}
+	if err := c.validateStorage(); err != nil {
+		return err
+	}
+	if c.Env != "production" || c.JWTPrivateKeyFile != "" {
+		return nil
+	}
+	if placeholderJWTSecrets[c.JWTSecret] {
+		return fmt.Errorf("JWT_SECRET is still the default value; set JWT_PRIVATE_KEY_FILE or a random JWT_SECRET")
+	}
+	if len(c.JWTSecret) < minJWTSecretLength {
+		return fmt.Errorf("JWT_SECRET must be at least %d characters", minJWTSecretLength)
+	}
+	return nil
+}
+
+// validateOIDC 检查单点登录配置完整, 且关闭密码登录后仍有登录方式
+func (c Config) validateOIDC() error {
+	if c.OIDCIssuerURL == "" {
+		if !c.PasswordLoginEnabled {
+			return fmt.Errorf("PASSWORD_LOGIN_ENABLED=false requires OIDC_ISSUER_URL")
+		}
+		return nil
+	}
+	if c.OIDCClientID == "" || c.OIDCRedirectURL == "" {
+		return fmt.Errorf("OIDC_ISSUER_URL requires OIDC_CLIENT_ID and OIDC_REDIRECT_URL")
+	}
+	if !domain.Role(c.OIDCDefaultRole).Valid() {
+		return fmt.Errorf("OIDC_DEFAULT_ROLE: unknown role %q", c.OIDCDefaultRole)
+	}
+	for group, role := range c.OIDCRoleMapping {
+		if !domain.Role(role).Valid() {
+			return fmt.Errorf("OIDC_ROLE_MAPPING: unknown role %q for %q", role, group)
+		}
+	}
+	return nil
+}
+
+// validateStorage 检查文件地址配置; 预签名地址绑定存储自身的域名, 不能再换成 CDN 域名
+func (c Config) validateStorage() error {
+	if c.Env == "production" {
+		if err := c.validateProductionStorage(); err != nil {
+			return err
+		}
+	}
+	if c.StorageSignedURLTTL > 0 && c.StoragePublicURL != "" {
+		return fmt.Errorf("STORAGE_PUBLIC_URL cannot be combined with STORAGE_SIGNED_URL_TTL")
+	}
+	if c.StorageSignedURLTTL > maxSignedURLTTL {
+		return fmt.Errorf("STORAGE_SIGNED_URL_TTL must not exceed %s", maxSignedURLTTL)
+	}
+	return nil
+}
+
+// validateProductionStorage 要求生产环境显式选择存储, 避免漏配 OSS 时悄悄落到本地目录;
+// local 由 API 提供文件, 需要固定的签名密钥与客户端可访问的 API 地址
+func (c Config) validateProductionStorage() error {
+	switch c.StorageDriver {
+	case "":
+		return fmt.Errorf("STORAGE_DRIVER must be set in production (s3 or local)")
+	case "memory":
+		return fmt.Errorf("STORAGE_DRIVER=memory loses every upload on restart; use s3 or local in production")
+	case "local":
+		if c.StorageURLSecret == "" {
+			return fmt.Errorf("STORAGE_DRIVER=local requires STORAGE_URL_SECRET in production")
+		}
+		if isLocalURL(c.APIPublicURL) {
+			return fmt.Errorf("STORAGE_DRIVER=local requires API_PUBLIC_URL to be an address clients can reach, not %q", c.APIPublicURL)
+		}
 	}
+	return nil
+}
+
+// isLocalURL 判断地址是否缺失或指向本机
+func isLocalURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return true
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// defaultStorageDriver 保持旧配置可用: 配置了 OSS 时仍使用 S3 兼容存储;
+// 生产环境不推断, 由 validateStorage 要求显式配置
+func defaultStorageDriver() string {
+	if getEnv("ENV", "development") == "production" {
+		return ""
+	}
+	if os.Getenv("OSS_ENDPOINT") != "" {
+		return "s3"
+	}
+	return "local"
 }
 
 func (c Config) Addr() string {
@@ -62,16 +318,64 @@ func buildPostgresDSN() string {
 }
 
 func parseCORSOrigins(originsStr string) []string {
-	if originsStr == "" {
+	return parseList(originsStr)
+}
+
+// parseList splits a comma-separated value, dropping empty entries
+func parseList(value string) []string {
+	if value == "" {
 		return []string{}
 	}
-	origins := strings.Split(originsStr, ",")
-	result := make([]string, 0, len(origins))
-	for _, origin := range origins {
-		trimmed := strings.TrimSpace(origin)
+	items := strings.Split(value, ",")
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		trimmed := strings.TrimSpace(item)
 		if trimmed != "" {
 			result = append(result, trimmed)
 		}
 	}
 	return result
 }
+
+// parseMapping parses "key=value" pairs separated by commas, ignoring malformed entries
+func parseMapping(value string) map[string]string {
+	result := make(map[string]string)
+	for _, item := range parseList(value) {
+		key, val, ok := strings.Cut(item, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if ok && key != "" && val != "" {
+			result[key] = val
+		}
+	}
+	return result
+}
+
+// parseIntList parses a comma-separated list of positive integers, ignoring invalid entries
+func parseIntList(value string) []int {
+	items := parseList(value)
+	result := make([]int, 0, len(items))
+	for _, item := range items {
+		if n, err := strconv.Atoi(item); err == nil && n > 0 {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// parseDuration parses a Go duration such as "720h", falling back on invalid or non-positive values
+func parseDuration(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// parsePositiveInt parses a positive integer, falling back on invalid values
+func parsePositiveInt(value string, fallback int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProductionStorageMustBeExplicit(t *testing.T) {
+	t.Setenv("ENV", "production")
+	t.Setenv("STORAGE_DRIVER", "")
+	// Before, a missing OSS_ENDPOINT silently meant local storage
+	t.Setenv("OSS_ENDPOINT", "")
+
+	cfg := Load()
+	if cfg.StorageDriver != "" {
+		t.Fatalf("production storage driver defaulted to %q", cfg.StorageDriver)
+	}
+	if err := cfg.validateStorage(); err == nil || !strings.Contains(err.Error(), "STORAGE_DRIVER") {
+		t.Fatalf("validate: got %v, want STORAGE_DRIVER error", err)
+	}
+}
+
+func TestProductionLocalStorageNeedsSecretAndPublicURL(t *testing.T) {
+	valid := Config{
+		Env:              "production",
+		StorageDriver:    "local",
+		StorageURLSecret: "a-long-random-secret",
+		APIPublicURL:     "https://api.example.com",
+	}
+	if err := valid.validateStorage(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	cases := map[string]func(c *Config){
+		"memory":             func(c *Config) { c.StorageDriver = "memory" },
+		"no secret":          func(c *Config) { c.StorageURLSecret = "" },
+		"localhost":          func(c *Config) { c.APIPublicURL = "http://localhost:8080" },
+		"loopback":           func(c *Config) { c.APIPublicURL = "http://127.0.0.1:8080" },
+		"ipv6 loopback":      func(c *Config) { c.APIPublicURL = "http://[::1]:8080" },
+		"unspecified":        func(c *Config) { c.APIPublicURL = "http://0.0.0.0:8080" },
+		"no public url":      func(c *Config) { c.APIPublicURL = "" },
+		"no host in the url": func(c *Config) { c.APIPublicURL = "api.example.com" },
+	}
+	for name, change := range cases {
+		cfg := valid
+		change(&cfg)
+		if err := cfg.validateStorage(); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	// Development keeps working with the defaults from .env.example
+	dev := Config{Env: "development", StorageDriver: "local", APIPublicURL: "http://localhost:8080"}
+	if err := dev.validateStorage(); err != nil {
+		t.Fatalf("development: %v", err)
+	}
+}
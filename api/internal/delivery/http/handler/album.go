@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
+	"github.com/aton/atonWeb/api/internal/usecase"
+)
+
+type AlbumHandler struct {
+	service usecase.AlbumService
+}
+
+func NewAlbumHandler(service usecase.AlbumService) *AlbumHandler {
+	return &AlbumHandler{service: service}
+}
+
+// List returns all albums including drafts
+// GET /api/v1/albums
+func (h *AlbumHandler) List(c *gin.Context) {
+	albums, err := h.service.List()
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": albums})
+}
+
+// ListPublished returns published albums
+// This is a public endpoint that doesn't require authentication
+// GET /api/v1/albums/published
+func (h *AlbumHandler) ListPublished(c *gin.Context) {
+	albums, err := h.service.ListPublished()
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": albums})
+}
+
+// GetBySlug returns a published album with its published photos
+// GET /api/v1/albums/:slug
+func (h *AlbumHandler) GetBySlug(c *gin.Context) {
+	album, err := h.service.GetPublishedBySlug(c.Param("slug"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, album)
+}
+
+// Preview returns any album with all of its photos, for the admin panel
+// GET /api/v1/albums/:slug/preview
+func (h *AlbumHandler) Preview(c *gin.Context) {
+	album, err := h.service.GetBySlug(c.Param("slug"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, album)
+}
+
+// Create creates a new album
+// POST /api/v1/albums
+func (h *AlbumHandler) Create(c *gin.Context) {
+	var req domain.CreateAlbumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	album, err := h.service.Create(&req, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, album)
+}
+
+// Update updates an existing album
+// PUT /api/v1/albums/:id
+func (h *AlbumHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid album ID"})
+		return
+	}
+
+	var req domain.UpdateAlbumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	album, err := h.service.Update(uint(id), &req, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, album)
+}
+
+// Delete deletes an album; its photos are kept
+// DELETE /api/v1/albums/:id
+func (h *AlbumHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid album ID"})
+		return
+	}
+
+	if err := h.service.Delete(uint(id), auditActor(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "Album deleted successfully")
+}
+
+// SetPhotos replaces the album membership with the given ordered photo IDs
+// PUT /api/v1/albums/:id/photos
+func (h *AlbumHandler) SetPhotos(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid album ID"})
+		return
+	}
+
+	var req domain.AlbumPhotosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	album, err := h.service.SetPhotos(uint(id), req.PhotoIDs, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, album)
+}
+
+// AddPhotos appends photos to the end of an album
+// POST /api/v1/albums/:id/photos
+func (h *AlbumHandler) AddPhotos(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid album ID"})
+		return
+	}
+
+	var req domain.AlbumPhotosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	album, err := h.service.AddPhotos(uint(id), req.PhotoIDs, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, album)
+}
+
+// RemovePhoto removes a photo from an album
+// DELETE /api/v1/albums/:id/photos/:photoId
+func (h *AlbumHandler) RemovePhoto(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid album ID"})
+		return
+	}
+	photoID, err := strconv.ParseUint(c.Param("photoId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return
+	}
+
+	if err := h.service.RemovePhoto(uint(id), uint(photoID), auditActor(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "Photo removed from album successfully")
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
+	"github.com/aton/atonWeb/api/internal/usecase"
+)
+
+type APITokenHandler struct {
+	service usecase.APITokenService
+}
+
+func NewAPITokenHandler(service usecase.APITokenService) *APITokenHandler {
+	return &APITokenHandler{service: service}
+}
+
+// List returns the current user's API tokens, without their secrets
+// GET /api/v1/user/api-tokens
+func (h *APITokenHandler) List(c *gin.Context) {
+	tokens, err := h.service.List(c.GetUint("userID"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": tokens})
+}
+
+// Create issues a scoped API token; the secret is only returned here
+// POST /api/v1/user/api-tokens
+func (h *APITokenHandler) Create(c *gin.Context) {
+	var req domain.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := h.service.Create(c.GetUint("userID"), &req, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, created)
+}
+
+// Revoke permanently disables an API token
+// DELETE /api/v1/user/api-tokens/:id
+func (h *APITokenHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	if err := h.service.Revoke(c.GetUint("userID"), uint(id), auditActor(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "API token revoked successfully")
+}
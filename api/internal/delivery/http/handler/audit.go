@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/delivery/http/middleware"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
+	"github.com/aton/atonWeb/api/internal/repository"
+	"github.com/aton/atonWeb/api/internal/usecase"
+)
+
+type AuditHandler struct {
+	service usecase.AuditService
+}
+
+func NewAuditHandler(service usecase.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// List returns audit events newest first, filtered by actor_id, action,
+// entity_type, entity_id and an RFC 3339 from/to range.
+// Pass the last id as before_id to load older entries.
+// GET /api/v1/audit
+func (h *AuditHandler) List(c *gin.Context) {
+	filters := repository.AuditFilters{
+		Action:     domain.AuditAction(c.Query("action")),
+		EntityType: domain.AuditEntityType(c.Query("entity_type")),
+		EntityID:   c.Query("entity_id"),
+	}
+	if actorID, err := strconv.ParseUint(c.Query("actor_id"), 10, 32); err == nil {
+		filters.ActorID = uint(actorID)
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil {
+		filters.Limit = limit
+	}
+	if beforeID, err := strconv.ParseUint(c.Query("before_id"), 10, 32); err == nil {
+		filters.BeforeID = uint(beforeID)
+	}
+	for param, target := range map[string]**time.Time{"from": &filters.From, "to": &filters.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " time, expected RFC 3339"})
+			return
+		}
+		*target = &t
+	}
+
+	events, err := h.service.List(filters)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": events})
+}
+
+// auditActor identifies who is making the request for the audit log
+func auditActor(c *gin.Context) domain.Actor {
+	actor := domain.Actor{
+		UserID:    c.GetUint("userID"),
+		Username:  c.GetString("username"),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if token := middleware.CurrentAPIToken(c); token != nil {
+		actor.APITokenID = token.ID
+	}
+	return actor
+}
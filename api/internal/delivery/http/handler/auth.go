@@ -2,9 +2,12 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
 	"github.com/aton/atonWeb/api/internal/pkg/response"
 	"github.com/aton/atonWeb/api/internal/usecase"
 )
@@ -24,10 +27,6 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-type LoginResponse struct {
-	Token string `json:"token"`
-}
-
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -35,13 +34,177 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.service.Login(req.Username, req.Password)
+	result, err := h.service.Login(req.Username, req.Password, sessionClient(c))
+	if err != nil {
+		loginError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// VerifyMFA completes a login that returned mfaRequired
+// POST /api/v1/auth/mfa/verify
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req domain.VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.service.VerifyMFA(req.ChallengeToken, req.Code, sessionClient(c))
+	if err != nil {
+		loginError(c, err)
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+// loginError adds a Retry-After header to throttled login and reset responses
+func loginError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		if retry, ok := appErr.Details.(usecase.RetryAfter); ok {
+			c.Header("Retry-After", strconv.Itoa(retry.Seconds))
+		}
+	}
+	response.Error(c, err)
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token
+// POST /api/v1/auth/refresh
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req domain.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.service.Refresh(req.RefreshToken, sessionClient(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+// Logout revokes the session the refresh token belongs to
+// POST /api/v1/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req domain.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Logout(req.RefreshToken); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "Logged out successfully")
+}
+
+// ListSessions returns the current user's active sessions
+// GET /api/v1/user/sessions
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.service.ListSessions(c.GetUint("userID"), c.GetUint("sessionID"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": sessions})
+}
+
+// RevokeSession signs out one of the current user's devices
+// DELETE /api/v1/user/sessions/:id
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := h.service.RevokeSession(c.GetUint("userID"), uint(id), auditActor(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "Session revoked successfully")
+}
+
+// SetupTOTP starts two-factor enrollment and returns the secret and otpauth URL
+// POST /api/v1/user/mfa/totp/setup
+func (h *AuthHandler) SetupTOTP(c *gin.Context) {
+	setup, err := h.service.SetupTOTP(c.GetUint("userID"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, setup)
+}
+
+// EnableTOTP confirms enrollment with a code and returns the recovery codes
+// POST /api/v1/user/mfa/totp/enable
+func (h *AuthHandler) EnableTOTP(c *gin.Context) {
+	var req domain.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.EnableTOTP(c.GetUint("userID"), req.Code, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, codes)
+}
+
+// DisableTOTP turns two-factor login off; requires the password and a code
+// POST /api/v1/user/mfa/totp/disable
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	var req domain.DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.DisableTOTP(c.GetUint("userID"), req.Password, req.Code, auditActor(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "Two-factor authentication disabled")
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes
+// POST /api/v1/user/mfa/recovery-codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req domain.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(c.GetUint("userID"), req.Code, auditActor(c))
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, LoginResponse{Token: token})
+	response.Success(c, codes)
+}
+
+func sessionClient(c *gin.Context) domain.SessionClient {
+	return domain.SessionClient{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
 }
 
 type CreateUserRequest struct {
@@ -57,7 +220,7 @@ func (h *AuthHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.CreateUser(req.Username, req.Password, req.Email)
+	user, err := h.service.CreateUser(req.Username, req.Password, req.Email, auditActor(c))
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -84,7 +247,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.ChangePasswordByUserID(userID.(uint), req.OldPassword, req.NewPassword)
+	user, err := h.service.ChangePasswordByUserID(userID.(uint), c.GetUint("sessionID"), req.OldPassword, req.NewPassword, auditActor(c))
 	if err != nil {
 		response.Error(c, err)
 		return
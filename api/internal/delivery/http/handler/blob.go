@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/infrastructure/storage"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/usecase"
+)
+
+// BlobHandler serves objects of the local and memory storage backends,
+// standing in for the bucket endpoint an S3 backend would provide
+type BlobHandler struct {
+	blob        storage.Blob
+	signer      *storage.URLSigner
+	maxBytes    int64
+	signedReads bool
+}
+
+// NewBlobHandler serves reads publicly unless signedReads is set, in which
+// case only URLs from Blob.PresignGet are honoured, like a private bucket
+func NewBlobHandler(blob storage.Blob, signer *storage.URLSigner, maxUploadBytes int64, signedReads bool) *BlobHandler {
+	return &BlobHandler{blob: blob, signer: signer, maxBytes: maxUploadBytes, signedReads: signedReads}
+}
+
+// Get serves an object. Public reads behave like a public-read bucket; object
+// keys are random, so they cannot be listed or guessed.
+// GET /api/v1/blobs/*key
+func (h *BlobHandler) Get(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	if h.signedReads {
+		if err := h.signer.Verify(http.MethodGet, key, c.Request.URL.Query()); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	info, err := h.blob.Stat(c.Request.Context(), key)
+	if err != nil {
+		h.storageError(c, key, err)
+		return
+	}
+	reader, err := h.blob.Get(c.Request.Context(), key)
+	if err != nil {
+		h.storageError(c, key, err)
+		return
+	}
+	defer reader.Close()
+
+	// Uploaded bytes are never rendered as anything but the type of their key
+	c.Header("Content-Type", info.ContentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	if h.signedReads {
+		c.Header("Cache-Control", "private, max-age=300")
+	} else {
+		c.Header("Cache-Control", "public, max-age=86400")
+	}
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, path.Base(key), info.LastModified, seeker)
+		return
+	}
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, reader, nil)
+}
+
+// Put stores the request body under a URL signed by the storage service,
+// the way a presigned S3 PUT does
+// PUT /api/v1/blobs/*key
+func (h *BlobHandler) Put(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	if err := h.signer.Verify(http.MethodPut, key, c.Request.URL.Query()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBytes)
+	// The content type follows the key, whatever the client claims
+	if err := h.blob.Put(c.Request.Context(), key, c.Request.Body, c.Request.ContentLength, ""); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": usecase.ErrFileTooLarge.Error()})
+			return
+		}
+		h.storageError(c, key, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *BlobHandler) storageError(c *gin.Context, key string, err error) {
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Object not found"})
+		return
+	}
+	logger.Error("blob storage request failed", "key", key, "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage error"})
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/infrastructure/storage"
+)
+
+// newBlobRouter serves a MemoryBlob the way the server mounts local and memory storage
+func newBlobRouter(signedReads bool) (*gin.Engine, *storage.MemoryBlob) {
+	gin.SetMode(gin.TestMode)
+	signer := storage.NewURLSigner("http://api.test/api/v1/blobs", []byte("secret"))
+	blob := storage.NewMemoryBlob(signer)
+	h := NewBlobHandler(blob, signer, 16, signedReads)
+
+	router := gin.New()
+	router.GET("/api/v1/blobs/*key", h.Get)
+	router.HEAD("/api/v1/blobs/*key", h.Get)
+	router.PUT("/api/v1/blobs/*key", h.Put)
+	return router, blob
+}
+
+func serve(router *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestMemoryBlobUploadAndPublicRead(t *testing.T) {
+	router, blob := newBlobRouter(false)
+	ctx := context.Background()
+
+	uploadURL, err := blob.PresignPut(ctx, "photos/a.jpg", time.Minute)
+	if err != nil {
+		t.Fatalf("presign put: %v", err)
+	}
+	if w := serve(router, http.MethodPut, uploadURL, "jpeg bytes"); w.Code != http.StatusOK {
+		t.Fatalf("put: %d %s", w.Code, w.Body)
+	}
+
+	w := serve(router, http.MethodGet, blob.URL("photos/a.jpg"), "")
+	if w.Code != http.StatusOK || w.Body.String() != "jpeg bytes" {
+		t.Fatalf("get: %d %q", w.Code, w.Body)
+	}
+	if w.Header().Get("Content-Type") != "image/jpeg" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("unexpected headers: %v", w.Header())
+	}
+
+	if w := serve(router, http.MethodGet, blob.URL("photos/missing.jpg"), ""); w.Code != http.StatusNotFound {
+		t.Fatalf("get missing: %d", w.Code)
+	}
+}
+
+func TestMemoryBlobRefusesUnsignedAndOversizedUploads(t *testing.T) {
+	router, blob := newBlobRouter(false)
+	ctx := context.Background()
+
+	// The signature covers the key, so it cannot be reused for another object
+	uploadURL, err := blob.PresignPut(ctx, "photos/a.jpg", time.Minute)
+	if err != nil {
+		t.Fatalf("presign put: %v", err)
+	}
+	if w := serve(router, http.MethodPut, strings.Replace(uploadURL, "photos/a.jpg", "photos/b.jpg", 1), "x"); w.Code != http.StatusForbidden {
+		t.Fatalf("put with another key's signature: %d", w.Code)
+	}
+	if w := serve(router, http.MethodPut, blob.URL("photos/a.jpg"), "x"); w.Code != http.StatusForbidden {
+		t.Fatalf("unsigned put: %d", w.Code)
+	}
+
+	expired, err := blob.PresignPut(ctx, "photos/a.jpg", -time.Minute)
+	if err != nil {
+		t.Fatalf("presign put: %v", err)
+	}
+	if w := serve(router, http.MethodPut, expired, "x"); w.Code != http.StatusForbidden {
+		t.Fatalf("expired put: %d", w.Code)
+	}
+
+	if w := serve(router, http.MethodPut, uploadURL, strings.Repeat("x", 17)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized put: %d", w.Code)
+	}
+	if _, err := blob.Stat(ctx, "photos/a.jpg"); err == nil {
+		t.Fatal("rejected upload was stored")
+	}
+}
+
+func TestMemoryBlobSignedReads(t *testing.T) {
+	router, blob := newBlobRouter(true)
+	ctx := context.Background()
+	if err := blob.Put(ctx, "photos/a.jpg", strings.NewReader("jpeg bytes"), -1, ""); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	if w := serve(router, http.MethodGet, blob.URL("photos/a.jpg"), ""); w.Code != http.StatusForbidden {
+		t.Fatalf("unsigned get of a private store: %d", w.Code)
+	}
+
+	readURL, err := blob.PresignGet(ctx, "photos/a.jpg", time.Minute)
+	if err != nil {
+		t.Fatalf("presign get: %v", err)
+	}
+	w := serve(router, http.MethodGet, readURL, "")
+	if w.Code != http.StatusOK || w.Body.String() != "jpeg bytes" {
+		t.Fatalf("signed get: %d %q", w.Code, w.Body)
+	}
+	if !strings.HasPrefix(w.Header().Get("Cache-Control"), "private") {
+		t.Fatalf("signed read cached publicly: %q", w.Header().Get("Cache-Control"))
+	}
+
+	// A read signature does not allow writes
+	if w := serve(router, http.MethodPut, readURL, "x"); w.Code != http.StatusForbidden {
+		t.Fatalf("put with a read signature: %d", w.Code)
+	}
+}
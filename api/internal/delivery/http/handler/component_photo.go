@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
 	"github.com/aton/atonWeb/api/internal/usecase"
 	"github.com/gin-gonic/gin"
 )
@@ -26,7 +27,7 @@ func (h *ComponentPhotoHandler) AssignPhotoToComponent(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.AssignPhotoToComponent(req); err != nil {
+	if err := h.service.AssignPhotoToComponent(req, auditActor(c)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -34,7 +35,26 @@ func (h *ComponentPhotoHandler) AssignPhotoToComponent(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "Photo assigned to component successfully"})
 }
 
-// UpdateComponentPhoto updates a component photo
+// GetComponentPhoto returns a single component photo assignment
+// GET /api/v1/component-photos/:id
+func (h *ComponentPhotoHandler) GetComponentPhoto(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	componentPhoto, err := h.service.GetComponentPhoto(uint(id))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(componentPhoto.Version))
+	c.JSON(http.StatusOK, gin.H{"data": componentPhoto})
+}
+
+// UpdateComponentPhoto updates a component photo, honoring If-Match
 // PUT /api/v1/component-photos/:id
 func (h *ComponentPhotoHandler) UpdateComponentPhoto(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -43,18 +63,26 @@ func (h *ComponentPhotoHandler) UpdateComponentPhoto(c *gin.Context) {
 		return
 	}
 
+	ifMatch, err := parseIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var req domain.UpdateComponentPhotoRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.service.UpdateComponentPhoto(uint(id), req); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	componentPhoto, err := h.service.UpdateComponentPhoto(uint(id), req, ifMatch, auditActor(c))
+	if err != nil {
+		updateError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Component photo updated successfully"})
+	c.Header("ETag", etag(componentPhoto.Version))
+	c.JSON(http.StatusOK, gin.H{"data": componentPhoto})
 }
 
 // RemovePhotoFromComponent removes a photo from a component
@@ -66,8 +94,8 @@ func (h *ComponentPhotoHandler) RemovePhotoFromComponent(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.RemovePhotoFromComponent(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.RemovePhotoFromComponent(uint(id), auditActor(c)); err != nil {
+		response.Error(c, err)
 		return
 	}
 
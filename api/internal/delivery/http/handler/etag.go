@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
+)
+
+var errInvalidIfMatch = errors.New("If-Match must be * or a list of quoted entity tags")
+
+// etag formats a resource version as a strong entity tag
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch returns the versions listed in If-Match. nil means the header is
+// absent or "*", i.e. no precondition. Weak tags never match a strong comparison
+// and are skipped, so a header with only weak tags always fails.
+func parseIfMatch(c *gin.Context) ([]int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, errInvalidIfMatch
+		}
+		version, err := strconv.Atoi(tag[1 : len(tag)-1])
+		if err != nil {
+			// A tag we never issued cannot match the current version
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// updateError renders a failed conditional update, sending the current ETag with a 412
+func updateError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok && appErr.StatusCode == http.StatusPreconditionFailed {
+		switch current := appErr.Details.(type) {
+		case *domain.Photo:
+			c.Header("ETag", etag(current.Version))
+		case *domain.ComponentPhotoResponse:
+			c.Header("ETag", etag(current.Version))
+		}
+	}
+	response.Error(c, err)
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/infrastructure/jwt"
+)
+
+type JWKSHandler struct {
+	jwtManager *jwt.JWTManager
+}
+
+func NewJWKSHandler(jwtManager *jwt.JWTManager) *JWKSHandler {
+	return &JWKSHandler{jwtManager: jwtManager}
+}
+
+// JWKS publishes the public keys access tokens are verified with.
+// Clients should refetch when they see an unknown kid.
+// GET /.well-known/jwks.json
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtManager.JWKS())
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
+)
+
+// AuthMethods lists the sign-in options the login page should offer
+// GET /api/v1/auth/methods
+func (h *AuthHandler) AuthMethods(c *gin.Context) {
+	response.Success(c, h.service.AuthMethods())
+}
+
+// OIDCAuthorize starts a single sign-on and returns the provider URL to redirect to
+// POST /api/v1/auth/oidc/authorize
+func (h *AuthHandler) OIDCAuthorize(c *gin.Context) {
+	authorization, err := h.service.StartOIDCLogin(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, authorization)
+}
+
+// OIDCCallback completes a single sign-on with the code and state the provider
+// redirected back with. The response matches Login, including MFA challenges.
+// POST /api/v1/auth/oidc/callback
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	var req domain.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.CompleteOIDCLogin(c.Request.Context(), req.Code, req.State, sessionClient(c))
+	if err != nil {
+		loginError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
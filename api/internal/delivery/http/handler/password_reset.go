@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
+	"github.com/aton/atonWeb/api/internal/usecase"
+)
+
+type PasswordResetHandler struct {
+	service usecase.PasswordResetService
+}
+
+func NewPasswordResetHandler(service usecase.PasswordResetService) *PasswordResetHandler {
+	return &PasswordResetHandler{service: service}
+}
+
+// ForgotPassword mails a reset link. The response is the same whether or not
+// the address belongs to an account; clients sending too many get a 429.
+// POST /api/v1/auth/forgot-password
+func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
+	var req domain.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ForgotPassword(req.Email, sessionClient(c)); err != nil {
+		loginError(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "If the address belongs to an account, a reset link has been sent")
+}
+
+// ResetPassword sets a new password using the token from the reset link
+// POST /api/v1/auth/reset-password
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	var req domain.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ResetPassword(req.Token, req.NewPassword, sessionClient(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "Password has been reset, please sign in again")
+}
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -25,19 +28,13 @@ func (h *PhotoHandler) List(c *gin.Context) {
 	filters := repository.PhotoFilters{
 		Status:   c.Query("status"),
 		Category: c.Query("category"),
-		OrderBy:  c.DefaultQuery("order_by", "display_order ASC"),
+		Query:    strings.TrimSpace(c.Query("q")),
 	}
 
-	// Parse pagination
-	if limit := c.Query("limit"); limit != "" {
-		if l, err := strconv.Atoi(limit); err == nil {
-			filters.Limit = l
-		}
-	}
-	if offset := c.Query("offset"); offset != "" {
-		if o, err := strconv.Atoi(offset); err == nil {
-			filters.Offset = o
-		}
+	parsePagination(c, &filters)
+	if err := parseSort(c, &filters); err != nil {
+		response.Error(c, err)
+		return
 	}
 
 	// Parse featured filter
@@ -47,16 +44,22 @@ func (h *PhotoHandler) List(c *gin.Context) {
 		}
 	}
 
-	photos, total, err := h.service.List(filters)
+	if err := parseMetadataFilters(c, &filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := parseTagFilters(c, &filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.service.List(filters)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, gin.H{
-		"data":  photos,
-		"total": total,
-	})
+	response.Success(c, pageResponse(page))
 }
 
 // GetByID returns a single photo by ID
@@ -73,9 +76,28 @@ func (h *PhotoHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", etag(photo.Version))
 	response.Success(c, photo)
 }
 
+// Metadata returns the full EXIF record of a photo, including GPS location
+// GET /api/v1/photos/:id/metadata
+func (h *PhotoHandler) Metadata(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return
+	}
+
+	metadata, err := h.service.GetMetadata(uint(id))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, metadata)
+}
+
 // Create creates a new photo
 func (h *PhotoHandler) Create(c *gin.Context) {
 	var req domain.CreatePhotoRequest
@@ -84,7 +106,7 @@ func (h *PhotoHandler) Create(c *gin.Context) {
 		return
 	}
 
-	photo, err := h.service.Create(&req)
+	photo, err := h.service.Create(&req, auditActor(c))
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -101,18 +123,25 @@ func (h *PhotoHandler) Update(c *gin.Context) {
 		return
 	}
 
+	ifMatch, err := parseIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var req domain.UpdatePhotoRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	photo, err := h.service.Update(uint(id), &req)
+	photo, err := h.service.Update(uint(id), &req, ifMatch, auditActor(c))
 	if err != nil {
-		response.Error(c, err)
+		updateError(c, err)
 		return
 	}
 
+	c.Header("ETag", etag(photo.Version))
 	response.Success(c, photo)
 }
 
@@ -124,18 +153,20 @@ func (h *PhotoHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Delete(uint(id)); err != nil {
+	if err := h.service.Delete(uint(id), auditActor(c)); err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Message(c, http.StatusOK, "Photo deleted successfully")
+	response.Message(c, http.StatusOK, "Photo moved to trash")
 }
 
-// BatchUpdateDisplayOrder updates display order for multiple photos
+// BatchUpdateDisplayOrder updates display order for multiple photos and
+// returns the resulting sequence so the client can reconcile its view
 func (h *PhotoHandler) BatchUpdateDisplayOrder(c *gin.Context) {
 	var req struct {
-		Updates []repository.DisplayOrderUpdate `json:"updates" binding:"required"`
+		Updates  []repository.DisplayOrderUpdate `json:"updates"`
+		Renumber bool                            `json:"renumber"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -143,20 +174,26 @@ func (h *PhotoHandler) BatchUpdateDisplayOrder(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.BatchUpdateDisplayOrder(req.Updates); err != nil {
+	order, err := h.service.BatchUpdateDisplayOrder(req.Updates, req.Renumber, auditActor(c))
+	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Message(c, http.StatusOK, "Display order updated successfully")
+	response.Success(c, gin.H{"data": order})
 }
 
-// ListPublished returns only published photos, ordered by displayOrder
+// ListPublished returns only published photos, ordered by displayOrder unless sorted
 // This is a public endpoint that doesn't require authentication
 func (h *PhotoHandler) ListPublished(c *gin.Context) {
 	filters := repository.PhotoFilters{
-		Status:  "published",
-		OrderBy: "display_order ASC",
+		Status: "published",
+		Query:  strings.TrimSpace(c.Query("q")),
+	}
+	parsePagination(c, &filters)
+	if err := parseSort(c, &filters); err != nil {
+		response.Error(c, err)
+		return
 	}
 
 	// Optional featured filter
@@ -166,14 +203,163 @@ func (h *PhotoHandler) ListPublished(c *gin.Context) {
 		}
 	}
 
-	photos, total, err := h.service.List(filters)
+	if err := parseMetadataFilters(c, &filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := parseTagFilters(c, &filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.service.List(filters)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, pageResponse(page))
+}
+
+// Process (re)generates thumbnails and responsive variants for a photo
+func (h *PhotoHandler) Process(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return
+	}
+
+	if err := h.service.Reprocess(uint(id), auditActor(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusAccepted, "Photo processing scheduled")
+}
+
+// Trash returns photos that were deleted but not yet purged, most recently
+// deleted first. Pass the last id as before_id to load the next page.
+// GET /api/v1/photos/trash
+func (h *PhotoHandler) Trash(c *gin.Context) {
+	var beforeID uint
+	if id, err := strconv.ParseUint(c.Query("before_id"), 10, 32); err == nil {
+		beforeID = uint(id)
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	photos, err := h.service.ListTrash(beforeID, limit)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, gin.H{
-		"data":  photos,
-		"total": total,
-	})
+	response.Success(c, gin.H{"data": photos})
+}
+
+// Restore moves a photo out of the trash
+// POST /api/v1/photos/:id/restore
+func (h *PhotoHandler) Restore(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return
+	}
+
+	photo, err := h.service.Restore(uint(id), auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(photo.Version))
+	response.Success(c, photo)
+}
+
+// parsePagination reads limit, cursor and with_count for keyset pagination
+func parsePagination(c *gin.Context, filters *repository.PhotoFilters) {
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			filters.Limit = l
+		}
+	}
+	filters.Cursor = c.Query("cursor")
+	if withCount := c.Query("with_count"); withCount != "" {
+		filters.WithCount, _ = strconv.ParseBool(withCount)
+	}
+}
+
+// parseSort reads ?sort=-createdAt,displayOrder against the allowed sort fields
+func parseSort(c *gin.Context, filters *repository.PhotoFilters) error {
+	spec, err := repository.ParsePhotoSort(c.Query("sort"))
+	if err != nil {
+		return usecase.InvalidSortError(err)
+	}
+	filters.Sort = spec
+	return nil
+}
+
+// pageResponse renders a photo page; total is only present when requested
+func pageResponse(page *repository.PhotoPage) gin.H {
+	body := gin.H{
+		"data":       page.Photos,
+		"nextCursor": page.NextCursor,
+		"prevCursor": page.PrevCursor,
+	}
+	if page.Total != nil {
+		body["total"] = *page.Total
+	}
+	return body
+}
+
+// parseMetadataFilters reads the EXIF filters (camera, captured_from, captured_to)
+// shared by the admin and public list endpoints
+func parseMetadataFilters(c *gin.Context, filters *repository.PhotoFilters) error {
+	filters.Camera = c.Query("camera")
+
+	if from := c.Query("captured_from"); from != "" {
+		t, err := parseDateParam(from, false)
+		if err != nil {
+			return fmt.Errorf("invalid captured_from: %w", err)
+		}
+		filters.CapturedFrom = &t
+	}
+	if to := c.Query("captured_to"); to != "" {
+		t, err := parseDateParam(to, true)
+		if err != nil {
+			return fmt.Errorf("invalid captured_to: %w", err)
+		}
+		filters.CapturedTo = &t
+	}
+	return nil
+}
+
+// parseTagFilters reads tags=street,night and tags_mode=any|all (default any)
+func parseTagFilters(c *gin.Context, filters *repository.PhotoFilters) error {
+	if tags := c.Query("tags"); tags != "" {
+		filters.Tags = usecase.TagSlugs(strings.Split(tags, ","))
+	}
+
+	switch mode := domain.TagsMode(c.DefaultQuery("tags_mode", string(domain.TagsModeAny))); mode {
+	case domain.TagsModeAny, domain.TagsModeAll:
+		filters.TagsMode = mode
+	default:
+		return fmt.Errorf("invalid tags_mode: must be any or all")
+	}
+	return nil
+}
+
+// parseDateParam accepts RFC3339 timestamps or plain dates (2006-01-02).
+// A plain date used as an upper bound covers the whole day.
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
 }
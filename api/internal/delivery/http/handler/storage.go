@@ -1,19 +1,27 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
 	"github.com/aton/atonWeb/api/internal/usecase"
 )
 
+// multipartOverhead allows for boundaries and part headers around the file
+const multipartOverhead = 1 << 20
+
 type StorageHandler struct {
-	service usecase.StorageService
+	service  usecase.StorageService
+	maxBytes int64
 }
 
-func NewStorageHandler(service usecase.StorageService) *StorageHandler {
-	return &StorageHandler{service: service}
+func NewStorageHandler(service usecase.StorageService, maxUploadBytes int64) *StorageHandler {
+	return &StorageHandler{service: service, maxBytes: maxUploadBytes}
 }
 
 // GenerateUploadToken 生成上传凭证
@@ -28,7 +36,7 @@ func (h *StorageHandler) GenerateUploadToken(c *gin.Context) {
 		return
 	}
 
-	result, err := h.service.GeneratePresignedUploadURL(req.Filename, req.ContentType)
+	result, err := h.service.GeneratePresignedUploadURL(req.Filename, req.ContentType, auditActor(c))
 	if err != nil {
 		if err == usecase.ErrInvalidFileExtension {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file extension. Only images are allowed (jpg, png, gif, webp, bmp)"})
@@ -39,4 +47,126 @@ func (h *StorageHandler) GenerateUploadToken(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, result)
-}
\ No newline at end of file
+}
+
+// Upload streams the multipart "file" field to storage; the request body is
+// never buffered in full. Other fields before the file are skipped.
+// POST /api/v1/storage/upload
+func (h *StorageHandler) Upload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBytes+multipartOverhead)
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file field"})
+			return
+		}
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": usecase.ErrFileTooLarge.Error()})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		result, err := h.service.Upload(c.Request.Context(), part, part.FileName(), auditActor(c))
+		part.Close()
+		if err != nil {
+			response.Error(c, err)
+			return
+		}
+
+		response.Created(c, result)
+		return
+	}
+}
+
+// InitiateMultipart starts a resumable upload for a large file
+// POST /api/v1/storage/multipart
+func (h *StorageHandler) InitiateMultipart(c *gin.Context) {
+	var req domain.InitiateMultipartUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	upload, err := h.service.InitiateMultipartUpload(req.Filename, req.ContentType, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, upload)
+}
+
+// PresignParts returns upload URLs for the requested part numbers
+// POST /api/v1/storage/multipart/:uploadId/parts
+func (h *StorageHandler) PresignParts(c *gin.Context) {
+	var req domain.PresignPartsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parts, err := h.service.PresignMultipartParts(c.Param("uploadId"), req.PartNumbers, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, parts)
+}
+
+// ListParts returns the parts received so far, so an interrupted upload can resume
+// GET /api/v1/storage/multipart/:uploadId/parts
+func (h *StorageHandler) ListParts(c *gin.Context) {
+	parts, err := h.service.ListMultipartParts(c.Param("uploadId"), auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, parts)
+}
+
+// CompleteMultipart assembles the uploaded parts into the object.
+// The JSON body is optional; without it every uploaded part is used.
+// POST /api/v1/storage/multipart/:uploadId/complete
+func (h *StorageHandler) CompleteMultipart(c *gin.Context) {
+	var req domain.CompleteMultipartUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.CompleteMultipartUpload(c.Param("uploadId"), req.Parts, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// AbortMultipart cancels an upload and discards its parts
+// DELETE /api/v1/storage/multipart/:uploadId
+func (h *StorageHandler) AbortMultipart(c *gin.Context) {
+	if err := h.service.AbortMultipartUpload(c.Param("uploadId"), auditActor(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "Multipart upload aborted")
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
+	"github.com/aton/atonWeb/api/internal/usecase"
+)
+
+type TagHandler struct {
+	service usecase.TagService
+}
+
+func NewTagHandler(service usecase.TagService) *TagHandler {
+	return &TagHandler{service: service}
+}
+
+// ListPublished returns tags used by published photos with usage counts (tag cloud)
+// This is a public endpoint that doesn't require authentication
+// GET /api/v1/tags
+func (h *TagHandler) ListPublished(c *gin.Context) {
+	tags, err := h.service.ListPublished()
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": tags})
+}
+
+// List returns every tag, including unused ones, with counts across all photos
+// GET /api/v1/tags/all
+func (h *TagHandler) List(c *gin.Context) {
+	tags, err := h.service.List()
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": tags})
+}
+
+// Create creates a new tag
+// POST /api/v1/tags
+func (h *TagHandler) Create(c *gin.Context) {
+	var req domain.CreateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := h.service.Create(&req, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, tag)
+}
+
+// Update renames a tag
+// PUT /api/v1/tags/:id
+func (h *TagHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	var req domain.UpdateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := h.service.Update(uint(id), &req, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, tag)
+}
+
+// Delete deletes a tag and detaches it from all photos
+// DELETE /api/v1/tags/:id
+func (h *TagHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	if err := h.service.Delete(uint(id), auditActor(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "Tag deleted successfully")
+}
+
+// AddToPhotos tags several photos at once, creating unknown tags
+// POST /api/v1/photos/tags/add
+func (h *TagHandler) AddToPhotos(c *gin.Context) {
+	var req domain.BulkPhotoTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags, err := h.service.AddToPhotos(&req, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": tags})
+}
+
+// RemoveFromPhotos removes tags from several photos at once
+// POST /api/v1/photos/tags/remove
+func (h *TagHandler) RemoveFromPhotos(c *gin.Context) {
+	var req domain.BulkPhotoTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.RemoveFromPhotos(&req, auditActor(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "Tags removed successfully")
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
+	"github.com/aton/atonWeb/api/internal/usecase"
+)
+
+type UploadHandler struct {
+	service usecase.UploadService
+}
+
+func NewUploadHandler(service usecase.UploadService) *UploadHandler {
+	return &UploadHandler{service: service}
+}
+
+// Complete creates a draft photo from an uploaded object. Object keys contain
+// slashes, so the route is a catch-all and the key is everything before /complete.
+// The JSON body is optional.
+// POST /api/v1/storage/uploads/:objectKey/complete
+func (h *UploadHandler) Complete(c *gin.Context) {
+	objectKey, ok := strings.CutSuffix(strings.TrimPrefix(c.Param("objectKey"), "/"), "/complete")
+	if !ok || objectKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	var req domain.CompleteUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	photo, err := h.service.Complete(objectKey, &req, auditActor(c))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, photo)
+}
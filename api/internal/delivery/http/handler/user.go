@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/response"
+	"github.com/aton/atonWeb/api/internal/repository"
+	"github.com/aton/atonWeb/api/internal/usecase"
+)
+
+type UserHandler struct {
+	service usecase.UserService
+}
+
+func NewUserHandler(service usecase.UserService) *UserHandler {
+	return &UserHandler{service: service}
+}
+
+// List returns all user accounts
+// GET /api/v1/users
+func (h *UserHandler) List(c *gin.Context) {
+	users, err := h.service.List()
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": users})
+}
+
+// Invite creates a user with a temporary password that is returned only once
+// POST /api/v1/users
+func (h *UserHandler) Invite(c *gin.Context) {
+	var req domain.InviteUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invited, err := h.service.Invite(auditActor(c), &req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, invited)
+}
+
+// UpdateRole changes a user's role
+// PUT /api/v1/users/:id/role
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var req domain.UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.UpdateRole(auditActor(c), id, req.Role)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, user)
+}
+
+// Disable blocks a user from logging in
+// POST /api/v1/users/:id/disable
+func (h *UserHandler) Disable(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+// Enable lets a disabled user log in again
+// POST /api/v1/users/:id/enable
+func (h *UserHandler) Enable(c *gin.Context) {
+	h.setDisabled(c, false)
+}
+
+func (h *UserHandler) setDisabled(c *gin.Context, disabled bool) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.SetDisabled(auditActor(c), id, disabled)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, user)
+}
+
+// Delete removes a user account
+// DELETE /api/v1/users/:id
+func (h *UserHandler) Delete(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(auditActor(c), id); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Message(c, http.StatusOK, "User deleted successfully")
+}
+
+// Unlock clears a lockout caused by repeated failed logins
+// POST /api/v1/users/:id/unlock
+func (h *UserHandler) Unlock(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.Unlock(auditActor(c), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, user)
+}
+
+// ResetMFA disables two-factor login for a user who lost their authenticator
+// POST /api/v1/users/:id/mfa/reset
+func (h *UserHandler) ResetMFA(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.ResetMFA(auditActor(c), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, user)
+}
+
+// LoginAttempts lists recent password logins, filtered by username, ip or result.
+// Pass the last id as before_id to load older entries.
+// GET /api/v1/users/login-attempts
+func (h *UserHandler) LoginAttempts(c *gin.Context) {
+	filters := repository.LoginAttemptFilters{
+		Username: c.Query("username"),
+		IP:       c.Query("ip"),
+		Result:   domain.LoginAttemptResult(c.Query("result")),
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil {
+		filters.Limit = limit
+	}
+	if beforeID, err := strconv.ParseUint(c.Query("before_id"), 10, 32); err == nil {
+		filters.BeforeID = uint(beforeID)
+	}
+
+	attempts, err := h.service.ListLoginAttempts(filters)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"data": attempts})
+}
+
+func parseUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
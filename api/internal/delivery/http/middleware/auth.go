@@ -1,15 +1,55 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
+	"github.com/aton/atonWeb/api/internal/domain"
 	"github.com/aton/atonWeb/api/internal/infrastructure/jwt"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/usecase"
 )
 
-func AuthMiddleware(jwtManager *jwt.JWTManager) gin.HandlerFunc {
+// SessionChecker reports whether the session an access token belongs to is still live
+type SessionChecker interface {
+	IsActive(sessionID, userID uint) (bool, error)
+}
+
+// APITokenAuthenticator resolves an API token presented as a bearer credential
+type APITokenAuthenticator interface {
+	Authenticate(token, ip string) (*domain.APIToken, error)
+}
+
+// PasswordChangeChecker reports whether a user must replace a temporary
+// password before using the API
+type PasswordChangeChecker interface {
+	MustChangePassword(userID uint) (bool, error)
+}
+
+// apiTokenKey holds the *domain.APIToken a request was made with;
+// RequirePermission checks its scopes in addition to the role
+const apiTokenKey = "apiToken"
+
+// AuthMiddleware accepts a JWT access token or an API token in the Authorization
+// header. Accounts that must change their temporary password are refused with
+// 403 password_change_required.
+func AuthMiddleware(jwtManager *jwt.JWTManager, sessions SessionChecker, apiTokens APITokenAuthenticator, passwords PasswordChangeChecker) gin.HandlerFunc {
+	return authenticate(jwtManager, sessions, apiTokens, passwords)
+}
+
+// PasswordChangeAuthMiddleware authenticates like AuthMiddleware but lets
+// accounts with a temporary password through, for the route that changes it
+func PasswordChangeAuthMiddleware(jwtManager *jwt.JWTManager, sessions SessionChecker, apiTokens APITokenAuthenticator) gin.HandlerFunc {
+	return authenticate(jwtManager, sessions, apiTokens, nil)
+}
+
+// authenticate checks the credential and, when passwords is set, that the
+// account does not still have to change its password
+func authenticate(jwtManager *jwt.JWTManager, sessions SessionChecker, apiTokens APITokenAuthenticator, passwords PasswordChangeChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -25,6 +65,14 @@ func AuthMiddleware(jwtManager *jwt.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(parts[1], domain.APITokenPrefix) {
+			if !authenticateAPIToken(c, apiTokens, parts[1]) || !passwordChanged(c, passwords) {
+				return
+			}
+			c.Next()
+			return
+		}
+
 		claims, err := jwtManager.ValidateToken(parts[1])
 		if err != nil {
 			if err == jwt.ErrExpiredToken {
@@ -36,10 +84,104 @@ func AuthMiddleware(jwtManager *jwt.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		// Tokens are bound to a session so logout and revocation take effect immediately
+		if claims.SessionID == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+		active, err := sessions.IsActive(claims.SessionID, claims.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
 
+		if !passwordChanged(c, passwords) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// passwordChanged aborts with 403 while the authenticated account still has a
+// temporary password. A nil checker allows every account.
+func passwordChanged(c *gin.Context, passwords PasswordChangeChecker) bool {
+	if passwords == nil {
+		return true
+	}
+	mustChange, err := passwords.MustChangePassword(c.GetUint("userID"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+		} else {
+			logger.Error("failed to check password status", "userId", c.GetUint("userID"), "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		c.Abort()
+		return false
+	}
+	if mustChange {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Change your temporary password before continuing",
+			"code":  "password_change_required",
+		})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// authenticateAPIToken stores the token's user on the context. It writes the
+// error response itself when it fails.
+func authenticateAPIToken(c *gin.Context, apiTokens APITokenAuthenticator, raw string) bool {
+	token, err := apiTokens.Authenticate(raw, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidAPIToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		} else {
+			logger.Error("failed to authenticate api token", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		c.Abort()
+		return false
+	}
+
+	c.Set("userID", token.UserID)
+	c.Set("username", token.User.Username)
+	c.Set("role", string(token.User.Role))
+	c.Set(apiTokenKey, token)
+	return true
+}
+
+// RejectAPITokens keeps API tokens away from account settings such as passwords,
+// sessions, two-factor login and the tokens themselves
+func RejectAPITokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(apiTokenKey); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API tokens cannot be used for this endpoint"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// CurrentAPIToken returns the API token the request was authenticated with,
+// or nil for interactive sessions
+func CurrentAPIToken(c *gin.Context) *domain.APIToken {
+	if token, ok := c.Get(apiTokenKey); ok {
+		return token.(*domain.APIToken)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+// staticAPITokens accepts any API token as belonging to user
+type staticAPITokens struct {
+	user *domain.User
+}
+
+func (s staticAPITokens) Authenticate(string, string) (*domain.APIToken, error) {
+	return &domain.APIToken{UserID: s.user.ID, User: s.user}, nil
+}
+
+// passwordFlags answers MustChangePassword from a map
+type passwordFlags map[uint]bool
+
+func (f passwordFlags) MustChangePassword(userID uint) (bool, error) {
+	return f[userID], nil
+}
+
+func TestAuthMiddlewareHoldsBackTemporaryPasswords(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := staticAPITokens{user: &domain.User{ID: 7, Username: "invited", Role: domain.RoleEditor}}
+	flags := passwordFlags{7: true}
+
+	router := gin.New()
+	router.GET("/photos", AuthMiddleware(nil, nil, tokens, flags), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/change-password", PasswordChangeAuthMiddleware(nil, nil, tokens), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+domain.APITokenPrefix+"secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodGet, "/photos")
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "password_change_required") {
+		t.Fatalf("GET /photos with a temporary password = %d %s, want 403 password_change_required", rec.Code, rec.Body)
+	}
+	if rec := request(http.MethodPost, "/change-password"); rec.Code != http.StatusOK {
+		t.Fatalf("POST /change-password = %d, want 200", rec.Code)
+	}
+
+	flags[7] = false
+	if rec := request(http.MethodGet, "/photos"); rec.Code != http.StatusOK {
+		t.Fatalf("GET /photos after the change = %d, want 200", rec.Code)
+	}
+}
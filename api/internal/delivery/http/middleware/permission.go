@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// RoleResolver looks up a user's current role, so revoked or changed roles take
+// effect immediately instead of when the JWT expires
+type RoleResolver interface {
+	GetRole(userID uint) (domain.Role, error)
+}
+
+// resolvedRoleKey caches the database role for the rest of the request
+const resolvedRoleKey = "resolvedRole"
+
+// RequirePermission aborts with 403 unless the authenticated user's role grants perm
+// and, for API tokens, the token's scopes include it. It must run after AuthMiddleware.
+func RequirePermission(resolver RoleResolver, perm domain.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := resolveRole(c, resolver)
+		if !ok {
+			return
+		}
+
+		if !role.Can(perm) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":      "You do not have permission to perform this action",
+				"permission": perm,
+			})
+			c.Abort()
+			return
+		}
+		if token, ok := c.Get(apiTokenKey); ok && !token.(*domain.APIToken).Allows(perm) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":      "The API token does not have the required scope",
+				"permission": perm,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// resolveRole loads the role once per request and overwrites the role claim
+// taken from the token. It writes the error response itself when it fails.
+func resolveRole(c *gin.Context, resolver RoleResolver) (domain.Role, bool) {
+	if cached, exists := c.Get(resolvedRoleKey); exists {
+		return cached.(domain.Role), true
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Abort()
+		return "", false
+	}
+
+	role, err := resolver.GetRole(userID.(uint))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+		case errors.Is(err, repository.ErrUserDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		default:
+			logger.Error("failed to resolve user role", "userId", userID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		c.Abort()
+		return "", false
+	}
+
+	c.Set(resolvedRoleKey, role)
+	c.Set("role", string(role))
+	return role, true
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+// scopedAPITokens accepts any API token as token
+type scopedAPITokens struct {
+	token *domain.APIToken
+}
+
+func (s scopedAPITokens) Authenticate(string, string) (*domain.APIToken, error) {
+	return s.token, nil
+}
+
+// storedRoles answers GetRole from a map, like the users table
+type storedRoles map[uint]domain.Role
+
+func (r storedRoles) GetRole(userID uint) (domain.Role, error) {
+	return r[userID], nil
+}
+
+func TestRequirePermissionChecksTokenScopesAndCurrentRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := &domain.User{ID: 7, Username: "bot-owner", Role: domain.RoleAdmin}
+	token := &domain.APIToken{UserID: 7, User: user, Scopes: []domain.Permission{domain.PermPhotosRead, domain.PermPhotosDelete}}
+	roles := storedRoles{7: domain.RoleAdmin}
+
+	router := gin.New()
+	auth := AuthMiddleware(nil, nil, scopedAPITokens{token: token}, passwordFlags{})
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/photos", auth, RequirePermission(roles, domain.PermPhotosRead), ok)
+	router.POST("/photos", auth, RequirePermission(roles, domain.PermPhotosWrite), ok)
+	router.DELETE("/photos", auth, RequirePermission(roles, domain.PermPhotosDelete), ok)
+
+	request := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/photos", nil)
+		req.Header.Set("Authorization", "Bearer "+domain.APITokenPrefix+"secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request(http.MethodGet); rec.Code != http.StatusOK {
+		t.Fatalf("GET within scope = %d, want 200", rec.Code)
+	}
+	// The role allows writing, the token does not
+	if rec := request(http.MethodPost); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "scope") {
+		t.Fatalf("POST outside the token's scopes = %d %s, want 403 for the scope", rec.Code, rec.Body)
+	}
+	if rec := request(http.MethodDelete); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE within scope = %d, want 200", rec.Code)
+	}
+
+	// A demoted owner's token loses what the new role does not grant
+	roles[7] = domain.RoleEditor
+	if rec := request(http.MethodDelete); rec.Code != http.StatusForbidden {
+		t.Fatalf("DELETE after demotion = %d, want 403", rec.Code)
+	}
+}
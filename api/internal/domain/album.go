@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"time"
+)
+
+// AlbumStatus represents the status of an album
+type AlbumStatus string
+
+const (
+	AlbumStatusDraft     AlbumStatus = "draft"
+	AlbumStatusPublished AlbumStatus = "published"
+)
+
+// Album is a curated, ordered collection of photos
+type Album struct {
+	ID           uint        `gorm:"primaryKey" json:"id"`
+	Title        string      `gorm:"size:200;not null" json:"title"`
+	Slug         string      `gorm:"size:200;not null;uniqueIndex" json:"slug"`
+	Description  string      `gorm:"type:text" json:"description"`
+	CoverPhotoID *uint       `gorm:"index" json:"coverPhotoId"`
+	Status       AlbumStatus `gorm:"size:20;default:'draft';index;check:status IN ('draft','published')" json:"status"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+
+	// Filled by list queries only
+	PhotoCount int64 `gorm:"->;-:migration" json:"photoCount"`
+
+	CoverPhoto *Photo       `gorm:"->;foreignKey:CoverPhotoID;references:ID" json:"coverPhoto,omitempty"`
+	Photos     []AlbumPhoto `gorm:"->;foreignKey:AlbumID" json:"photos,omitempty"`
+}
+
+// AlbumPhoto is the ordered membership of a photo in an album
+type AlbumPhoto struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	AlbumID   uint      `gorm:"not null;uniqueIndex:uk_album_photo;index:idx_album_position" json:"albumId"`
+	PhotoID   uint      `gorm:"not null;uniqueIndex:uk_album_photo;index" json:"photoId"`
+	Position  int       `gorm:"not null;default:0;index:idx_album_position" json:"position"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Photo Photo `gorm:"->;foreignKey:PhotoID;references:ID" json:"photo,omitempty"`
+}
+
+// Request/Response structures
+type CreateAlbumRequest struct {
+	Title        string      `json:"title" binding:"required,min=1,max=200"`
+	Slug         string      `json:"slug" binding:"max=200"`
+	Description  string      `json:"description"`
+	CoverPhotoID *uint       `json:"coverPhotoId"`
+	Status       AlbumStatus `json:"status"`
+}
+
+type UpdateAlbumRequest struct {
+	Title        *string      `json:"title"`
+	Slug         *string      `json:"slug"`
+	Description  *string      `json:"description"`
+	CoverPhotoID *uint        `json:"coverPhotoId"`
+	Status       *AlbumStatus `json:"status"`
+}
+
+// HasUpdates checks if the update request has at least one field to update
+func (r *UpdateAlbumRequest) HasUpdates() bool {
+	return r.Title != nil || r.Slug != nil || r.Description != nil ||
+		r.CoverPhotoID != nil || r.Status != nil
+}
+
+// AlbumPhotosRequest lists photo IDs in the desired album order
+type AlbumPhotosRequest struct {
+	PhotoIDs []uint `json:"photoIds" binding:"required"`
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// APITokenPrefix starts every API token so it can be told apart from a JWT
+// and recognised by secret scanners
+const APITokenPrefix = "aton_"
+
+// APIToken is a long-lived credential for scripts. It acts as its owner but
+// only within Scopes, and never beyond the owner's current role.
+// Only a hash of the secret is stored; Prefix lets users recognise a token.
+type APIToken struct {
+	ID         uint                            `gorm:"primaryKey" json:"id"`
+	UserID     uint                            `gorm:"not null;index" json:"-"`
+	User       *User                           `json:"-"`
+	Name       string                          `gorm:"size:100;not null" json:"name"`
+	Prefix     string                          `gorm:"size:20;not null" json:"prefix"`
+	TokenHash  string                          `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes     datatypes.JSONSlice[Permission] `gorm:"type:jsonb;not null" json:"scopes"`
+	ExpiresAt  *time.Time                      `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time                      `json:"lastUsedAt,omitempty"`
+	LastUsedIP string                          `gorm:"size:45" json:"lastUsedIp,omitempty"`
+	RevokedAt  *time.Time                      `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time                       `json:"createdAt"`
+}
+
+// Allows reports whether the token's scopes include perm
+func (t *APIToken) Allows(perm Permission) bool {
+	for _, scope := range t.Scopes {
+		if scope == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenScopes lists the permissions a token may carry. Managing users is
+// left to interactive sessions.
+func APITokenScopes() []Permission {
+	scopes := make([]Permission, 0, len(adminPermissions))
+	for _, p := range adminPermissions {
+		if p != PermUsersManage {
+			scopes = append(scopes, p)
+		}
+	}
+	return scopes
+}
+
+type CreateAPITokenRequest struct {
+	Name      string       `json:"name" binding:"required"`
+	Scopes    []Permission `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time   `json:"expiresAt"`
+}
+
+// CreateAPITokenResponse is the only place the secret is ever shown
+type CreateAPITokenResponse struct {
+	APIToken *APIToken `json:"apiToken"`
+	Token    string    `json:"token"`
+}
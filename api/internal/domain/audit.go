@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// AuditAction names a recorded mutation as "<entity>.<verb>"
+type AuditAction string
+
+const (
+	AuditPhotoCreate    AuditAction = "photo.create"
+	AuditPhotoUpdate    AuditAction = "photo.update"
+	AuditPhotoPublish   AuditAction = "photo.publish"
+	AuditPhotoUnpublish AuditAction = "photo.unpublish"
+	AuditPhotoDelete    AuditAction = "photo.delete"
+	AuditPhotoRestore   AuditAction = "photo.restore"
+	AuditPhotoPurge     AuditAction = "photo.purge"
+	AuditPhotoReorder   AuditAction = "photo.reorder"
+	AuditPhotoReprocess AuditAction = "photo.reprocess"
+
+	AuditComponentPhotoAssign AuditAction = "component_photo.assign"
+	AuditComponentPhotoUpdate AuditAction = "component_photo.update"
+	AuditComponentPhotoRemove AuditAction = "component_photo.remove"
+
+	AuditAlbumCreate         AuditAction = "album.create"
+	AuditAlbumUpdate         AuditAction = "album.update"
+	AuditAlbumDelete         AuditAction = "album.delete"
+	AuditAlbumSetPhotos      AuditAction = "album.set_photos"
+	AuditAlbumAddPhotos      AuditAction = "album.add_photos"
+	AuditAlbumRemovePhoto    AuditAction = "album.remove_photo"
+	AuditTagCreate           AuditAction = "tag.create"
+	AuditTagUpdate           AuditAction = "tag.update"
+	AuditTagDelete           AuditAction = "tag.delete"
+	AuditTagAddToPhotos      AuditAction = "tag.add_to_photos"
+	AuditTagRemoveFromPhotos AuditAction = "tag.remove_from_photos"
+
+	AuditStorageUploadURL         AuditAction = "storage.upload_url"
+	AuditStorageUpload            AuditAction = "storage.upload"
+	AuditStorageExpire            AuditAction = "storage.expire"
+	AuditStorageMultipartInitiate AuditAction = "storage.multipart_initiate"
+	AuditStorageMultipartComplete AuditAction = "storage.multipart_complete"
+	AuditStorageMultipartAbort    AuditAction = "storage.multipart_abort"
+
+	AuditUserCreate       AuditAction = "user.create"
+	AuditUserInvite       AuditAction = "user.invite"
+	AuditUserUpdateRole   AuditAction = "user.update_role"
+	AuditUserDisable      AuditAction = "user.disable"
+	AuditUserEnable       AuditAction = "user.enable"
+	AuditUserUnlock       AuditAction = "user.unlock"
+	AuditUserResetMFA     AuditAction = "user.reset_mfa"
+	AuditUserDelete       AuditAction = "user.delete"
+	AuditUserLinkOIDC     AuditAction = "user.link_oidc"
+	AuditPasswordChange   AuditAction = "auth.password_change"
+	AuditPasswordReset    AuditAction = "auth.password_reset"
+	AuditSessionRevoke    AuditAction = "auth.session_revoke"
+	AuditMFAEnable        AuditAction = "auth.mfa_enable"
+	AuditMFADisable       AuditAction = "auth.mfa_disable"
+	AuditMFARecoveryCodes AuditAction = "auth.mfa_recovery_codes"
+	AuditAPITokenCreate   AuditAction = "api_token.create"
+	AuditAPITokenRevoke   AuditAction = "api_token.revoke"
+)
+
+// AuditEntityType is the kind of record an audit event refers to
+type AuditEntityType string
+
+const (
+	AuditEntityPhoto          AuditEntityType = "photo"
+	AuditEntityComponentPhoto AuditEntityType = "component_photo"
+	AuditEntityAlbum          AuditEntityType = "album"
+	AuditEntityTag            AuditEntityType = "tag"
+	AuditEntityStorageObject  AuditEntityType = "storage_object"
+	AuditEntityUser           AuditEntityType = "user"
+	AuditEntitySession        AuditEntityType = "session"
+	AuditEntityAPIToken       AuditEntityType = "api_token"
+)
+
+// Actor is who performed a mutation and from where. UserID is 0 for
+// background jobs and for unauthenticated requests such as a password reset.
+type Actor struct {
+	UserID     uint
+	Username   string
+	APITokenID uint
+	IP         string
+	UserAgent  string
+}
+
+// SystemActor attributes a mutation to a background job
+func SystemActor(job string) Actor {
+	return Actor{Username: "system:" + job}
+}
+
+// AuditEvent is an append-only record of a mutation. Before and After hold
+// only the top-level fields that changed; a create has no Before and a
+// delete no After.
+type AuditEvent struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	ActorID    *uint           `gorm:"index" json:"actorId,omitempty"`
+	ActorName  string          `gorm:"size:150" json:"actorName"`
+	APITokenID *uint           `json:"apiTokenId,omitempty"`
+	Action     AuditAction     `gorm:"size:50;not null;index" json:"action"`
+	EntityType AuditEntityType `gorm:"size:30;not null;index:idx_audit_events_entity" json:"entityType"`
+	EntityID   string          `gorm:"size:255;index:idx_audit_events_entity" json:"entityId,omitempty"`
+	Before     datatypes.JSON  `gorm:"type:jsonb" json:"before,omitempty"`
+	After      datatypes.JSON  `gorm:"type:jsonb" json:"after,omitempty"`
+	IP         string          `gorm:"size:45" json:"ip"`
+	UserAgent  string          `gorm:"size:500" json:"userAgent"`
+	CreatedAt  time.Time       `gorm:"index" json:"createdAt"`
+}
@@ -13,6 +13,7 @@ type ComponentPhoto struct {
 	PhotoID       uint           `gorm:"not null;uniqueIndex:uk_component_photo" json:"photoId"`
 	Order         int            `gorm:"not null;default:0;index:idx_component_order" json:"order"`
 	Props         datatypes.JSON `gorm:"type:jsonb" json:"props"`
+	Version       int            `gorm:"not null;default:1" json:"version"`
 	CreatedAt     time.Time      `json:"createdAt"`
 	UpdatedAt     time.Time      `json:"updatedAt"`
 
@@ -49,6 +50,7 @@ type ComponentPhotoResponse struct {
 	Order         int                 `json:"order"`
 	Props         ComponentPhotoProps `json:"props"`
 	Photo         *Photo              `json:"photo,omitempty"`
+	Version       int                 `json:"version"`
 	CreatedAt     time.Time           `json:"createdAt"`
 	UpdatedAt     time.Time           `json:"updatedAt"`
 }
@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// LoginAttemptResult records why a login succeeded or failed
+type LoginAttemptResult string
+
+const (
+	LoginSucceeded          LoginAttemptResult = "success"
+	LoginInvalidCredentials LoginAttemptResult = "invalid_credentials"
+	LoginLocked             LoginAttemptResult = "locked"
+	LoginRateLimited        LoginAttemptResult = "rate_limited"
+	LoginDisabled           LoginAttemptResult = "disabled"
+	LoginMFARequired        LoginAttemptResult = "mfa_required"
+	LoginInvalidMFACode     LoginAttemptResult = "invalid_mfa_code"
+	LoginUnknownIdentity    LoginAttemptResult = "unknown_identity"
+)
+
+// LoginAttempt is an append-only record of a password, MFA or single sign-on login step, kept for admins.
+// UserID is nil when the username does not exist.
+type LoginAttempt struct {
+	ID        uint               `gorm:"primaryKey" json:"id"`
+	Username  string             `gorm:"size:150;not null;index" json:"username"`
+	UserID    *uint              `gorm:"index" json:"userId,omitempty"`
+	IP        string             `gorm:"size:45;index" json:"ip"`
+	UserAgent string             `gorm:"size:500" json:"userAgent"`
+	Result    LoginAttemptResult `gorm:"size:30;not null;index" json:"result"`
+	CreatedAt time.Time          `gorm:"index" json:"createdAt"`
+}
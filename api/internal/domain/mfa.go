@@ -0,0 +1,60 @@
+package domain
+
+import "time"
+
+// RecoveryCode is a single-use fallback for a lost authenticator. Only the hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	UserID    uint       `gorm:"not null;index" json:"-"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"-"`
+}
+
+// MFAChallenge is issued after a correct password when the account uses TOTP.
+// It is single-use and expires after a few minutes or too many wrong codes.
+type MFAChallenge struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	IP        string    `gorm:"size:45"`
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// LoginResult is either a token pair or, for MFA accounts, a challenge to
+// complete with POST /auth/mfa/verify. The token pair fields are inlined so
+// clients without MFA see the same response as before.
+type LoginResult struct {
+	*TokenPair
+	MFARequired        bool   `json:"mfaRequired"`
+	ChallengeToken     string `json:"challengeToken,omitempty"`
+	ChallengeExpiresIn int    `json:"challengeExpiresIn,omitempty"` // seconds
+}
+
+// VerifyMFARequest completes a login. Code is a 6-digit TOTP code or a recovery code.
+type VerifyMFARequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TOTPSetupResponse carries the new secret; OTPAuthURL is meant to be shown as a QR code
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauthUrl"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse is the only place recovery codes are ever shown
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
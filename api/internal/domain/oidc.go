@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// OIDCLoginState ties a provider callback to the login that started it.
+// It holds the nonce and PKCE verifier, is single-use and expires after a few minutes.
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"size:64;not null;uniqueIndex"`
+	Nonce        string    `gorm:"size:64;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+// OIDCAuthorization starts a single sign-on. The client keeps State, sends
+// the browser to AuthorizationURL and checks that the callback returns the same state.
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+	ExpiresIn        int    `json:"expiresIn"` // seconds
+}
+
+// OIDCCallbackRequest carries the query parameters the provider redirected back with
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// AuthMethods tells the login page which sign-in options to show
+type AuthMethods struct {
+	Password bool   `json:"password"`
+	OIDC     bool   `json:"oidc"`
+	OIDCName string `json:"oidcName,omitempty"`
+}
@@ -2,6 +2,8 @@ package domain
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // PhotoStatus represents the status of a photo
@@ -12,25 +14,50 @@ const (
 	PhotoStatusPublished PhotoStatus = "published"
 )
 
+// Photo stores object keys rather than URLs for anything kept in storage.
+// ImageURL and ThumbnailURL are only stored for externally hosted images;
+// otherwise they are filled in from ObjectKey and ThumbnailKey per response.
 type Photo struct {
 	ID           uint        `gorm:"primaryKey" json:"id"`
 	Title        string      `gorm:"size:200;not null" json:"title"`
 	Description  string      `gorm:"type:text" json:"description"`
 	ImageURL     string      `gorm:"size:500;not null" json:"imageUrl"`
 	ThumbnailURL string      `gorm:"size:500" json:"thumbnailUrl"`
+	ThumbnailKey string      `gorm:"size:500" json:"thumbnailKey,omitempty"`
 	Category     string      `gorm:"size:50" json:"category"`
 	Location     string      `gorm:"size:200" json:"location"`
 	IsFeatured   bool        `gorm:"default:false" json:"isFeatured"`
 	DisplayOrder int         `gorm:"default:0;index" json:"displayOrder"`
 	Status       PhotoStatus `gorm:"size:20;default:'draft';index;check:status IN ('draft','published')" json:"status"`
+	Version      int         `gorm:"not null;default:1" json:"version"` // bumped on every edit, exposed as ETag
 	CreatedAt    time.Time   `json:"createdAt"`
 	UpdatedAt    time.Time   `json:"updatedAt"`
+
+	// Set when the photo is moved to the trash; purged after the retention period
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+
+	// Original object in the storage bucket, used by the variant pipeline
+	ObjectKey        string                `gorm:"size:500;index" json:"objectKey,omitempty"`
+	Width            int                   `json:"width,omitempty"`
+	Height           int                   `json:"height,omitempty"`
+	ProcessingStatus PhotoProcessingStatus `gorm:"size:20" json:"processingStatus,omitempty"`
+
+	Metadata *PhotoMetadata    `gorm:"foreignKey:PhotoID" json:"metadata,omitempty"`
+	Tags     []Tag             `gorm:"many2many:photo_tags" json:"tags,omitempty"`
+	Variants []PhotoVariant    `gorm:"foreignKey:PhotoID" json:"variants,omitempty"`
+	SrcSet   map[string]string `gorm:"-" json:"srcSet,omitempty"`
+
+	// Full-text search results only; highlights wrap matches in <mark>
+	SearchRank     float64 `gorm:"->;-:migration" json:"searchRank,omitempty"`
+	TitleHighlight string  `gorm:"->;-:migration" json:"titleHighlight,omitempty"`
+	Snippet        string  `gorm:"->;-:migration" json:"snippet,omitempty"`
 }
 
 type CreatePhotoRequest struct {
 	Title        string `json:"title" binding:"required,min=1,max=200"`
 	Description  string `json:"description"`
-	ImageURL     string `json:"imageUrl" binding:"required"`
+	ImageURL     string `json:"imageUrl"`
+	ObjectKey    string `json:"objectKey"`
 	ThumbnailURL string `json:"thumbnailUrl"`
 	Category     string `json:"category"`
 	Location     string `json:"location"`
@@ -42,6 +69,7 @@ type UpdatePhotoRequest struct {
 	Title        *string      `json:"title"`
 	Description  *string      `json:"description"`
 	ImageURL     *string      `json:"imageUrl"`
+	ObjectKey    *string      `json:"objectKey"`
 	ThumbnailURL *string      `json:"thumbnailUrl"`
 	Category     *string      `json:"category"`
 	Location     *string      `json:"location"`
@@ -52,8 +80,7 @@ type UpdatePhotoRequest struct {
 
 // HasUpdates checks if the update request has at least one field to update
 func (r *UpdatePhotoRequest) HasUpdates() bool {
-	return r.Title != nil || r.Description != nil || r.ImageURL != nil ||
+	return r.Title != nil || r.Description != nil || r.ImageURL != nil || r.ObjectKey != nil ||
 		r.ThumbnailURL != nil || r.Category != nil || r.Location != nil ||
 		r.IsFeatured != nil || r.DisplayOrder != nil || r.Status != nil
 }
-
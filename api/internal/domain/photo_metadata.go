@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// PhotoMetadata holds EXIF data extracted from a photo's original object
+type PhotoMetadata struct {
+	ID           uint       `gorm:"primaryKey" json:"-"`
+	PhotoID      uint       `gorm:"not null;uniqueIndex" json:"photoId"`
+	CameraMake   string     `gorm:"size:100" json:"cameraMake,omitempty"`
+	CameraModel  string     `gorm:"size:100;index" json:"cameraModel,omitempty"`
+	LensModel    string     `gorm:"size:150" json:"lensModel,omitempty"`
+	FocalLength  *float64   `json:"focalLength,omitempty"`
+	Aperture     *float64   `json:"aperture,omitempty"`
+	ShutterSpeed string     `gorm:"size:20" json:"shutterSpeed,omitempty"`
+	ISO          *int       `gorm:"column:iso" json:"iso,omitempty"`
+	CapturedAt   *time.Time `gorm:"index" json:"capturedAt,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty"`
+	Altitude     *float64   `json:"altitude,omitempty"`
+	Orientation  int        `gorm:"default:1" json:"orientation,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func (PhotoMetadata) TableName() string {
+	return "photo_metadata"
+}
+
+// WithoutLocation returns a copy without GPS coordinates, for anonymous readers
+func (m *PhotoMetadata) WithoutLocation() *PhotoMetadata {
+	stripped := *m
+	stripped.Latitude = nil
+	stripped.Longitude = nil
+	stripped.Altitude = nil
+	return &stripped
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PhotoProcessingStatus tracks the variant generation pipeline for a photo
+type PhotoProcessingStatus string
+
+const (
+	PhotoProcessingNone       PhotoProcessingStatus = ""
+	PhotoProcessingPending    PhotoProcessingStatus = "pending"
+	PhotoProcessingProcessing PhotoProcessingStatus = "processing"
+	PhotoProcessingReady      PhotoProcessingStatus = "ready"
+	PhotoProcessingFailed     PhotoProcessingStatus = "failed"
+)
+
+// PhotoVariant is a resized/re-encoded rendition of a photo's original object
+type PhotoVariant struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	PhotoID   uint      `gorm:"not null;uniqueIndex:uk_photo_variant" json:"photoId"`
+	Format    string    `gorm:"size:10;not null;uniqueIndex:uk_photo_variant" json:"format"`
+	Width     int       `gorm:"not null;uniqueIndex:uk_photo_variant" json:"width"`
+	Height    int       `gorm:"not null" json:"height"`
+	Size      int64     `json:"size"`
+	ObjectKey string    `gorm:"size:500;not null" json:"objectKey"`
+	URL       string    `gorm:"-" json:"url"` // resolved from ObjectKey per response
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// BuildSrcSet groups variants by format into `srcset` strings
+// e.g. {"jpeg": "a_320w.jpg 320w, a_640w.jpg 640w"}
+func BuildSrcSet(variants []PhotoVariant) map[string]string {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	byFormat := make(map[string][]PhotoVariant)
+	for _, v := range variants {
+		byFormat[v.Format] = append(byFormat[v.Format], v)
+	}
+
+	srcSet := make(map[string]string, len(byFormat))
+	for format, list := range byFormat {
+		sort.Slice(list, func(i, j int) bool { return list[i].Width < list[j].Width })
+		entries := make([]string, len(list))
+		for i, v := range list {
+			entries[i] = fmt.Sprintf("%s %dw", v.URL, v.Width)
+		}
+		srcSet[format] = strings.Join(entries, ", ")
+	}
+	return srcSet
+}
@@ -0,0 +1,109 @@
+package domain
+
+// Role is a user's access level; see rolePermissions for what each role may do
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+// DefaultRole is given to accounts created without an explicit role
+const DefaultRole = RoleViewer
+
+// InitialRole is the role of a new account when userCount accounts exist:
+// the first account owns the site, later ones start with DefaultRole
+func InitialRole(userCount int64) Role {
+	if userCount == 0 {
+		return RoleOwner
+	}
+	return DefaultRole
+}
+
+// Permission names an action guarded by RequirePermission
+type Permission string
+
+const (
+	PermPhotosRead       Permission = "photos:read"
+	PermPhotosWrite      Permission = "photos:write"
+	PermPhotosDelete     Permission = "photos:delete"
+	PermAlbumsRead       Permission = "albums:read"
+	PermAlbumsWrite      Permission = "albums:write"
+	PermAlbumsDelete     Permission = "albums:delete"
+	PermTagsRead         Permission = "tags:read"
+	PermTagsWrite        Permission = "tags:write"
+	PermTagsDelete       Permission = "tags:delete"
+	PermComponentsRead   Permission = "components:read"
+	PermComponentsWrite  Permission = "components:write"
+	PermComponentsDelete Permission = "components:delete"
+	PermStorageUpload    Permission = "storage:upload"
+	PermUsersManage      Permission = "users:manage"
+	PermAdminsManage     Permission = "admins:manage"
+	PermAuditRead        Permission = "audit:read"
+)
+
+var (
+	viewerPermissions = []Permission{
+		PermPhotosRead, PermAlbumsRead, PermTagsRead, PermComponentsRead,
+	}
+
+	// Editors manage content but cannot delete it
+	editorPermissions = append([]Permission{
+		PermPhotosWrite, PermAlbumsWrite, PermTagsWrite, PermComponentsWrite, PermStorageUpload,
+	}, viewerPermissions...)
+
+	adminPermissions = append([]Permission{
+		PermPhotosDelete, PermAlbumsDelete, PermTagsDelete, PermComponentsDelete, PermUsersManage, PermAuditRead,
+	}, editorPermissions...)
+
+	// Only owners manage admin and owner accounts
+	ownerPermissions = append([]Permission{PermAdminsManage}, adminPermissions...)
+
+	rolePermissions = map[Role]map[Permission]bool{
+		RoleOwner:  permissionSet(ownerPermissions),
+		RoleAdmin:  permissionSet(adminPermissions),
+		RoleEditor: permissionSet(editorPermissions),
+		RoleViewer: permissionSet(viewerPermissions),
+	}
+)
+
+func permissionSet(perms []Permission) map[Permission]bool {
+	set := make(map[Permission]bool, len(perms))
+	for _, p := range perms {
+		set[p] = true
+	}
+	return set
+}
+
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Privileged reports whether accounts with the role can manage users
+func (r Role) Privileged() bool {
+	return r == RoleOwner || r == RoleAdmin
+}
+
+// Can reports whether the role grants the permission
+func (r Role) Can(p Permission) bool {
+	return rolePermissions[r][p]
+}
+
+// Permissions lists what the role grants, in matrix order
+func (r Role) Permissions() []Permission {
+	switch r {
+	case RoleOwner:
+		return ownerPermissions
+	case RoleAdmin:
+		return adminPermissions
+	case RoleEditor:
+		return editorPermissions
+	case RoleViewer:
+		return viewerPermissions
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "testing"
+
+func TestOnlyOwnersManageAdmins(t *testing.T) {
+	if !RoleOwner.Can(PermAdminsManage) {
+		t.Fatal("owner cannot manage admins")
+	}
+	for _, role := range []Role{RoleAdmin, RoleEditor, RoleViewer} {
+		if role.Can(PermAdminsManage) {
+			t.Fatalf("%s can manage admins", role)
+		}
+	}
+	for _, p := range RoleAdmin.Permissions() {
+		if !RoleOwner.Can(p) {
+			t.Fatalf("owner lacks admin permission %s", p)
+		}
+	}
+}
+
+func TestInitialRole(t *testing.T) {
+	if got := InitialRole(0); got != RoleOwner {
+		t.Fatalf("first account role = %s, want owner", got)
+	}
+	if got := InitialRole(3); got != DefaultRole {
+		t.Fatalf("later account role = %s, want %s", got, DefaultRole)
+	}
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// Session is one logged-in device. Only hashes of refresh tokens are stored;
+// PreviousTokenHash remembers the last rotated token so its reuse can be detected.
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"not null;index" json:"-"`
+	RefreshTokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	PreviousTokenHash string     `gorm:"size:64;index" json:"-"`
+	UserAgent         string     `gorm:"size:500" json:"userAgent"`
+	IP                string     `gorm:"size:45" json:"ip"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	LastUsedAt        time.Time  `json:"lastUsedAt"`
+	ExpiresAt         time.Time  `gorm:"not null;index" json:"expiresAt"`
+	RevokedAt         *time.Time `json:"revokedAt,omitempty"`
+
+	// Current marks the session the request was made with
+	Current bool `gorm:"-" json:"current"`
+}
+
+// SessionClient describes the device a session was opened from
+type SessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+// TokenPair is returned by login and refresh. Token keeps its original JSON
+// name so existing clients continue to work.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"` // access token lifetime in seconds
+
+	// MustChangePassword is set at login while the account has a temporary
+	// password; until it is changed only POST /user/change-password is allowed
+	MustChangePassword bool `json:"mustChangePassword,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"time"
+)
+
+// TagsMode controls how multiple tag filters are combined
+type TagsMode string
+
+const (
+	TagsModeAny TagsMode = "any"
+	TagsModeAll TagsMode = "all"
+)
+
+// Tag is a free-form label; a photo can carry any number of tags
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:50;not null" json:"name"`
+	Slug      string    `gorm:"size:60;not null;uniqueIndex" json:"slug"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Filled by usage queries only
+	Count int64 `gorm:"->;-:migration" json:"count"`
+}
+
+// PhotoTag is the photo_tags join table between photos and tags
+type PhotoTag struct {
+	PhotoID   uint      `gorm:"primaryKey;autoIncrement:false"`
+	TagID     uint      `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Request/Response structures
+type CreateTagRequest struct {
+	Name string `json:"name" binding:"required,min=1,max=50"`
+}
+
+type UpdateTagRequest struct {
+	Name string `json:"name" binding:"required,min=1,max=50"`
+}
+
+// BulkPhotoTagsRequest adds or removes tags (by name) on several photos at once
+type BulkPhotoTagsRequest struct {
+	PhotoIDs []uint   `json:"photoIds" binding:"required,min=1"`
+	Tags     []string `json:"tags" binding:"required,min=1"`
+}
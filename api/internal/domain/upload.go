@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// PendingUpload tracks an object that was uploaded, or is about to be, but
+// does not belong to a photo yet. Completing the upload turns it into a draft
+// photo; uploads left pending past ExpiresAt are removed from the bucket.
+type PendingUpload struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	ObjectKey string `json:"objectKey" gorm:"size:500;not null;uniqueIndex"`
+	UserID    uint   `json:"userId" gorm:"not null;index"`
+	Filename  string `json:"filename" gorm:"size:255"`
+	// Set while the object is being uploaded in parts
+	MultipartUploadID *string   `json:"multipartUploadId,omitempty" gorm:"size:255;uniqueIndex"`
+	ExpiresAt         time.Time `json:"expiresAt" gorm:"not null;index"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// CompleteUploadRequest describes the draft photo created from an upload.
+// Every field is optional; the title defaults to the uploaded file name.
+type CompleteUploadRequest struct {
+	Title       string `json:"title" binding:"max=200"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Location    string `json:"location"`
+}
+
+// InitiateMultipartUploadRequest starts a resumable upload for a large file
+type InitiateMultipartUploadRequest struct {
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"contentType"`
+}
+
+// PresignPartsRequest asks for upload URLs for the given part numbers (1-10000)
+type PresignPartsRequest struct {
+	PartNumbers []int `json:"partNumbers" binding:"required,min=1,max=1000,dive,min=1,max=10000"`
+}
+
+// CompleteMultipartUploadRequest lists the parts to assemble, with the ETag
+// the bucket returned for each. When empty, every uploaded part is used.
+type CompleteMultipartUploadRequest struct {
+	Parts []CompletedPart `json:"parts" binding:"dive"`
+}
+
+type CompletedPart struct {
+	PartNumber int    `json:"partNumber" binding:"required,min=1,max=10000"`
+	ETag       string `json:"etag" binding:"required"`
+}
@@ -12,9 +12,66 @@ type User struct {
 	Username  string    `json:"username" gorm:"uniqueIndex;not null"`
 	Password  string    `json:"-" gorm:"not null"` // "-" prevents password from being exposed in JSON
 	Email     string    `json:"email" gorm:"uniqueIndex"`
-	Role      string    `json:"role" gorm:"default:'admin'"`
+	Role      Role      `json:"role" gorm:"size:20;default:'viewer';check:role IN ('owner','admin','editor','viewer')"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Disabled accounts cannot log in or pass permission checks
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
+	// Set for invited users until they replace their temporary password
+	MustChangePassword bool `json:"mustChangePassword" gorm:"default:false"`
+
+	// Consecutive failed logins; reaching the limit locks the account until LockedUntil
+	FailedLoginCount  int        `json:"failedLoginCount" gorm:"not null;default:0"`
+	LastFailedLoginAt *time.Time `json:"lastFailedLoginAt,omitempty"`
+	LockedUntil       *time.Time `json:"lockedUntil,omitempty"`
+
+	// TOTP two-factor authentication. The secret is stored during setup and
+	// only takes effect once TOTPEnabledAt is set; TOTPLastStep blocks code replay.
+	TOTPSecret    string     `json:"-" gorm:"size:64"`
+	TOTPEnabledAt *time.Time `json:"totpEnabledAt,omitempty"`
+	TOTPLastStep  int64      `json:"-" gorm:"not null;default:0"`
+
+	// Identity at the OIDC provider, set on first single sign-on; nil for local-only accounts
+	OIDCIssuer  *string `json:"-" gorm:"column:oidc_issuer;size:255;uniqueIndex:idx_users_oidc_identity"`
+	OIDCSubject *string `json:"-" gorm:"column:oidc_subject;size:255;uniqueIndex:idx_users_oidc_identity"`
+}
+
+// OIDCLinked reports whether the account signs in through the identity provider
+func (u *User) OIDCLinked() bool {
+	return u.OIDCSubject != nil
+}
+
+// MFAEnabled reports whether login requires a second factor
+func (u *User) MFAEnabled() bool {
+	return u.TOTPEnabledAt != nil && u.TOTPSecret != ""
+}
+
+// IsLocked reports whether too many failed logins currently block the account
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
+// IsDisabled reports whether the account has been disabled
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// InviteUserRequest creates an account with a temporary password
+type InviteUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Role     Role   `json:"role" binding:"required"`
+}
+
+type UpdateUserRoleRequest struct {
+	Role Role `json:"role" binding:"required"`
+}
+
+// InviteUserResponse is the only place the temporary password is ever shown
+type InviteUserResponse struct {
+	User              *User  `json:"user"`
+	TemporaryPassword string `json:"temporaryPassword"`
 }
 
 // HashPassword hashes the user's password before saving
@@ -0,0 +1,183 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	// Register decoders for the formats we accept as originals
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrInvalidWidth      = errors.New("target width must be positive")
+	ErrTooLargeForWebP   = errors.New("image is too large to encode as WebP")
+)
+
+// Encoder writes an image in a specific output format
+type Encoder interface {
+	Format() string
+	Extension() string
+	ContentType() string
+	Encode(w io.Writer, img image.Image) error
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{}
+)
+
+// RegisterEncoder makes an output format available to the variant pipeline.
+// JPEG, PNG and lossy WebP are built in. The server is built without cgo and
+// there is no pure-Go AVIF encoder among our dependencies, so AVIF is accepted
+// as an original but never generated as a variant.
+func RegisterEncoder(enc Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[strings.ToLower(enc.Format())] = enc
+}
+
+// LookupEncoder returns the encoder registered for the given format
+func LookupEncoder(format string) (Encoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	enc, ok := encoders[strings.ToLower(format)]
+	return enc, ok
+}
+
+// Formats lists the currently registered output formats
+func Formats() []string {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	formats := make([]string, 0, len(encoders))
+	for f := range encoders {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+func init() {
+	RegisterEncoder(jpegEncoder{quality: 82})
+	RegisterEncoder(pngEncoder{})
+	RegisterEncoder(webpEncoder{quality: 80})
+}
+
+type jpegEncoder struct {
+	quality int
+}
+
+func (jpegEncoder) Format() string      { return "jpeg" }
+func (jpegEncoder) Extension() string   { return ".jpg" }
+func (jpegEncoder) ContentType() string { return "image/jpeg" }
+
+func (e jpegEncoder) Encode(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: e.quality})
+}
+
+type pngEncoder struct{}
+
+func (pngEncoder) Format() string      { return "png" }
+func (pngEncoder) Extension() string   { return ".png" }
+func (pngEncoder) ContentType() string { return "image/png" }
+
+func (pngEncoder) Encode(w io.Writer, img image.Image) error {
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	return enc.Encode(w, img)
+}
+
+// webpEncoder writes lossy WebP with the VP8 encoder in vp8.go. Transparent
+// pixels are flattened, as they are for JPEG.
+type webpEncoder struct {
+	quality int
+}
+
+func (webpEncoder) Format() string      { return "webp" }
+func (webpEncoder) Extension() string   { return ".webp" }
+func (webpEncoder) ContentType() string { return "image/webp" }
+
+func (e webpEncoder) Encode(w io.Writer, img image.Image) error {
+	frame, err := encodeVP8(img, e.quality)
+	if err != nil {
+		return err
+	}
+	return writeWebP(w, frame)
+}
+
+// writeWebP wraps a VP8 frame in the RIFF container of a simple lossy WebP
+// file. Chunks are padded to an even size.
+func writeWebP(w io.Writer, frame []byte) error {
+	padding := len(frame) & 1
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+len(frame)+padding))
+	copy(header[8:], "WEBPVP8 ")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(frame)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	if padding != 0 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// Decode reads an image in any of the registered input formats
+func Decode(r io.Reader) (image.Image, string, error) {
+	img, format, err := image.Decode(r)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, "", ErrUnsupportedFormat
+		}
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, format, nil
+}
+
+// DecodeLimited decodes an image after checking its header, so images over
+// maxPixels are rejected before their pixels are allocated
+func DecodeLimited(r io.Reader, maxPixels int64) (image.Image, string, error) {
+	_, replay, err := Inspect(r, maxPixels)
+	if err != nil {
+		return nil, "", err
+	}
+	return Decode(replay)
+}
+
+// Resize scales img down to the given width, preserving the aspect ratio.
+// Images that are already narrower than width are returned unchanged.
+func Resize(img image.Image, width int) (image.Image, error) {
+	if width <= 0 {
+		return nil, ErrInvalidWidth
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() <= width {
+		return img, nil
+	}
+
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst, nil
+}
@@ -0,0 +1,70 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.White)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFormatsOnlyBuiltInEncoders(t *testing.T) {
+	if got, want := Formats(), []string{"jpeg", "png", "webp"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Formats() = %v, want %v", got, want)
+	}
+	if _, ok := LookupEncoder("avif"); ok {
+		t.Error(`LookupEncoder("avif") found an encoder`)
+	}
+}
+
+func TestDecodeLimited(t *testing.T) {
+	data := encodePNG(t, 40, 30)
+
+	img, format, err := DecodeLimited(bytes.NewReader(data), 40*30)
+	if err != nil {
+		t.Fatalf("DecodeLimited at the limit: %v", err)
+	}
+	if format != "png" || img.Bounds().Dx() != 40 || img.Bounds().Dy() != 30 {
+		t.Fatalf("decoded %s %v, want png 40x30", format, img.Bounds())
+	}
+
+	if _, _, err := DecodeLimited(bytes.NewReader(data), 40*30-1); !errors.Is(err, ErrTooManyPixels) {
+		t.Fatalf("DecodeLimited over the limit: err = %v, want ErrTooManyPixels", err)
+	}
+	if _, _, err := DecodeLimited(bytes.NewReader([]byte("not an image")), 1<<20); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("DecodeLimited on garbage: err = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestResize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+
+	resized, err := Resize(img, 100)
+	if err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+	if b := resized.Bounds(); b.Dx() != 100 || b.Dy() != 75 {
+		t.Fatalf("Resize to 100 = %dx%d, want 100x75", b.Dx(), b.Dy())
+	}
+
+	same, err := Resize(img, 800)
+	if err != nil || same != image.Image(img) {
+		t.Fatalf("Resize wider than the original should return it unchanged, err = %v", err)
+	}
+	if _, err := Resize(img, 0); !errors.Is(err, ErrInvalidWidth) {
+		t.Fatalf("Resize to 0: err = %v, want ErrInvalidWidth", err)
+	}
+}
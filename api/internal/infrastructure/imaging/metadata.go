@@ -0,0 +1,170 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+var ErrNoMetadata = errors.New("image has no EXIF metadata")
+
+// Metadata is the subset of EXIF we persist for a photo
+type Metadata struct {
+	CameraMake   string
+	CameraModel  string
+	LensModel    string
+	FocalLength  *float64 // millimetres
+	Aperture     *float64 // f-number
+	ShutterSpeed string   // exposure time as a fraction, e.g. "1/250"
+	ISO          *int
+	CapturedAt   *time.Time
+	Latitude     *float64
+	Longitude    *float64
+	Altitude     *float64 // metres above sea level
+	Orientation  int
+}
+
+// ExtractMetadata reads EXIF from a JPEG/TIFF stream. It only consumes the
+// header segments, so callers may pass a streaming object reader.
+func ExtractMetadata(r io.Reader) (*Metadata, error) {
+	x, err := exif.Decode(r)
+	if err != nil {
+		if exif.IsCriticalError(err) {
+			return nil, ErrNoMetadata
+		}
+		// Non-critical errors (e.g. a broken GPS sub-IFD) still leave usable tags
+		if x == nil {
+			return nil, ErrNoMetadata
+		}
+	}
+
+	meta := &Metadata{
+		CameraMake:   stringTag(x, exif.Make),
+		CameraModel:  stringTag(x, exif.Model),
+		LensModel:    stringTag(x, exif.LensModel),
+		FocalLength:  ratTag(x, exif.FocalLength),
+		Aperture:     ratTag(x, exif.FNumber),
+		ShutterSpeed: fractionTag(x, exif.ExposureTime),
+		ISO:          intTag(x, exif.ISOSpeedRatings),
+	}
+
+	if o := intTag(x, exif.Orientation); o != nil {
+		meta.Orientation = *o
+	}
+
+	if t, err := x.DateTime(); err == nil {
+		meta.CapturedAt = &t
+	}
+
+	if lat, long, err := x.LatLong(); err == nil {
+		meta.Latitude = &lat
+		meta.Longitude = &long
+		if alt := ratTag(x, exif.GPSAltitude); alt != nil {
+			// GPSAltitudeRef 1 means below sea level
+			if ref := intTag(x, exif.GPSAltitudeRef); ref != nil && *ref == 1 {
+				*alt = -*alt
+			}
+			meta.Altitude = alt
+		}
+	}
+
+	return meta, nil
+}
+
+func stringTag(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil || tag.Format() != tiff.StringVal {
+		return ""
+	}
+	val, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(val, "\x00"))
+}
+
+func ratTag(x *exif.Exif, name exif.FieldName) *float64 {
+	tag, err := x.Get(name)
+	if err != nil {
+		return nil
+	}
+	num, den, err := tag.Rat2(0)
+	if err != nil || den == 0 {
+		return nil
+	}
+	val := float64(num) / float64(den)
+	return &val
+}
+
+func fractionTag(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	num, den, err := tag.Rat2(0)
+	if err != nil || num <= 0 || den <= 0 {
+		return ""
+	}
+	if num >= den {
+		return fmt.Sprintf("%g", float64(num)/float64(den))
+	}
+	// Normalise e.g. 10/2500 to 1/250
+	return fmt.Sprintf("1/%d", (den+num/2)/num)
+}
+
+func intTag(x *exif.Exif, name exif.FieldName) *int {
+	tag, err := x.Get(name)
+	if err != nil {
+		return nil
+	}
+	val, err := tag.Int(0)
+	if err != nil {
+		return nil
+	}
+	return &val
+}
+
+// Orient applies an EXIF orientation (1-8) so the returned image is upright
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirror horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirror vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 CW
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 CCW
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,112 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+var ErrTooManyPixels = errors.New("image dimensions exceed the pixel limit")
+
+// sniffLen covers the longest signature below (RIFF....WEBP)
+const sniffLen = 12
+
+// maxHeaderBytes bounds how much is buffered while reading image headers.
+// JPEG metadata segments come before the frame header, so allow several.
+const maxHeaderBytes = 1 << 20
+
+// Format describes an accepted original image format
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+}
+
+var uploadFormats = []struct {
+	format Format
+	match  func(header []byte) bool
+}{
+	{Format{"jpeg", ".jpg", "image/jpeg"}, func(h []byte) bool {
+		return bytes.HasPrefix(h, []byte{0xFF, 0xD8, 0xFF})
+	}},
+	{Format{"png", ".png", "image/png"}, func(h []byte) bool {
+		return bytes.HasPrefix(h, []byte("\x89PNG\r\n\x1a\n"))
+	}},
+	{Format{"gif", ".gif", "image/gif"}, func(h []byte) bool {
+		return bytes.HasPrefix(h, []byte("GIF87a")) || bytes.HasPrefix(h, []byte("GIF89a"))
+	}},
+	{Format{"webp", ".webp", "image/webp"}, func(h []byte) bool {
+		return len(h) >= sniffLen && bytes.Equal(h[0:4], []byte("RIFF")) && bytes.Equal(h[8:12], []byte("WEBP"))
+	}},
+}
+
+// Sniff identifies an upload by its magic bytes, ignoring any claimed name or type
+func Sniff(header []byte) (Format, bool) {
+	for _, f := range uploadFormats {
+		if f.match(header) {
+			return f.format, true
+		}
+	}
+	return Format{}, false
+}
+
+// Inspection is what Inspect learned from an image's header
+type Inspection struct {
+	Format Format
+	Width  int
+	Height int
+}
+
+// Inspect checks the magic bytes and decodes the image header of r without
+// decoding pixels. Images over maxPixels are rejected before anything
+// allocates their full size. The returned reader replays every byte of r,
+// so the caller can stream the whole file on.
+func Inspect(r io.Reader, maxPixels int64) (*Inspection, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	header, err := br.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+	format, ok := Sniff(header)
+	if !ok {
+		return nil, nil, ErrUnsupportedFormat
+	}
+
+	var head bytes.Buffer
+	src := &readErrReader{r: br}
+	cfg, name, err := image.DecodeConfig(io.TeeReader(io.LimitReader(src, maxHeaderBytes), &head))
+	if err != nil {
+		// A failing reader says nothing about the image
+		if src.err != nil {
+			return nil, nil, src.err
+		}
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if name != format.Name {
+		return nil, nil, ErrUnsupportedFormat
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, nil, ErrTooManyPixels
+	}
+
+	info := &Inspection{Format: format, Width: cfg.Width, Height: cfg.Height}
+	return info, io.MultiReader(&head, br), nil
+}
+
+// readErrReader remembers the first read error other than io.EOF, so
+// Inspect can tell a broken source from a malformed header
+type readErrReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readErrReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
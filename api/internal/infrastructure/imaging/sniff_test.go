@@ -0,0 +1,116 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F', 0, 1}, "jpeg"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d"), "png"},
+		{"gif87a", []byte("GIF87a\x01\x00\x01\x00\x00\x00"), "gif"},
+		{"gif89a", []byte("GIF89a\x01\x00\x01\x00\x00\x00"), "gif"},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBP"), "webp"},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVE"), ""},
+		{"truncated webp", []byte("RIFF\x24\x00\x00\x00WE"), ""},
+		{"svg", []byte(`<svg xmlns="`), ""},
+		{"html", []byte("<!DOCTYPE ht"), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		format, ok := Sniff(tt.header)
+		if ok != (tt.want != "") || format.Name != tt.want {
+			t.Errorf("%s: Sniff = %q, %v; want %q", tt.name, format.Name, ok, tt.want)
+		}
+	}
+}
+
+func TestInspectReplaysEveryByte(t *testing.T) {
+	img := gradient(48, 20)
+	var jpg, gf, wp bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.Encode(&gf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := LookupEncoder("webp")
+	if err := enc.Encode(&wp, img); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"jpeg": jpg.Bytes(),
+		"png":  encodePNG(t, 48, 20),
+		"gif":  gf.Bytes(),
+		"webp": wp.Bytes(),
+		// Metadata segments ahead of the frame header are read through
+		"jpeg with large exif": withAPPSegments(jpg.Bytes(), 8),
+	}
+	for name, data := range files {
+		info, replay, err := Inspect(bytes.NewReader(data), 48*20)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if info.Width != 48 || info.Height != 20 {
+			t.Errorf("%s: %dx%d, want 48x20", name, info.Width, info.Height)
+		}
+		got, err := io.ReadAll(replay)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%s: replayed %d of %d bytes (%v)", name, len(got), len(data), err)
+		}
+	}
+}
+
+func TestInspectRejects(t *testing.T) {
+	png := encodePNG(t, 40, 30)
+
+	if _, _, err := Inspect(bytes.NewReader(png), 40*30-1); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("over the pixel limit: err = %v, want ErrTooManyPixels", err)
+	}
+
+	// Right magic bytes, broken header
+	corrupt := append([]byte(nil), png[:16]...)
+	if _, _, err := Inspect(bytes.NewReader(corrupt), 1<<20); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("truncated png: err = %v, want ErrUnsupportedFormat", err)
+	}
+	if _, _, err := Inspect(bytes.NewReader([]byte("GIF89a")), 1<<20); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("short file: err = %v, want ErrUnsupportedFormat", err)
+	}
+
+	// A read failure is reported as itself, not as a bad image
+	broken := errors.New("connection reset")
+	src := io.MultiReader(bytes.NewReader(png[:20]), &failingReader{err: broken})
+	if _, _, err := Inspect(src, 1<<20); !errors.Is(err, broken) {
+		t.Errorf("failing reader: err = %v, want %v", err, broken)
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// withAPPSegments inserts n maximum-size APP1 segments after the SOI marker
+func withAPPSegments(jpg []byte, n int) []byte {
+	out := append([]byte(nil), jpg[:2]...)
+	segment := make([]byte, 0xFFFF-2)
+	for i := 0; i < n; i++ {
+		out = append(out, 0xFF, 0xE1, 0xFF, 0xFF)
+		out = append(out, segment...)
+	}
+	return append(out, jpg[2:]...)
+}
@@ -0,0 +1,783 @@
+package imaging
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// This file implements a lossy VP8 key frame encoder, the image format inside
+// lossy WebP files, after RFC 6386. It keeps to the simplest valid subset:
+// every macroblock is predicted as one 16x16 luma and one 8x8 chroma region,
+// with a single token partition and the default token probabilities. The
+// encoder reconstructs each macroblock exactly as a decoder will, so later
+// predictions start from the same pixels on both sides.
+
+// vp8MaxDimension is the largest width or height a VP8 frame header can hold
+const vp8MaxDimension = 1<<14 - 1
+
+// Intra prediction modes of 16x16 luma and 8x8 chroma regions, section 12.2
+const (
+	vp8PredDC = iota
+	vp8PredVE
+	vp8PredHE
+	vp8PredTM
+)
+
+type vp8Quant struct {
+	y1AC, y2DC, y2AC, uvDC, uvAC int32
+}
+
+func newVP8Quant(index int) vp8Quant {
+	q := vp8Quant{
+		y1AC: vp8ACQuant[index],
+		y2DC: vp8DCQuant[index] * 2,
+		y2AC: vp8ACQuant[index] * 155 / 100,
+		// Chroma DC steps stop growing at index 117, section 14.1
+		uvDC: vp8DCQuant[min(index, 117)],
+		uvAC: vp8ACQuant[index],
+	}
+	if q.y2AC < 8 {
+		q.y2AC = 8
+	}
+	return q
+}
+
+// vp8Nonzero records which 4x4 blocks along a macroblock edge coded any
+// coefficients; the first token of a block is coded in that context
+type vp8Nonzero struct {
+	y    [4]uint8
+	u, v [2]uint8
+	y2   uint8
+}
+
+type vp8Encoder struct {
+	width, height int
+	mbw, mbh      int
+
+	// Source and reconstructed planes, padded to whole macroblocks
+	srcY, srcU, srcV []uint8
+	recY, recU, recV []uint8
+	yStride, cStride int
+
+	quantIndex  int
+	quant       vp8Quant
+	filterLevel int
+
+	// The first partition holds the frame header and prediction modes,
+	// the second the coefficient tokens
+	modes, tokens boolEncoder
+
+	above []vp8Nonzero
+	left  vp8Nonzero
+}
+
+// encodeVP8 returns img as a VP8 key frame. quality runs from 0 to 100 like
+// JPEG quality; alpha is dropped.
+func encodeVP8(img image.Image, quality int) ([]byte, error) {
+	b := img.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 || b.Dx() > vp8MaxDimension || b.Dy() > vp8MaxDimension {
+		return nil, ErrTooLargeForWebP
+	}
+
+	quality = max(0, min(quality, 100))
+	index := (100 - quality) * 127 / 100
+	e := &vp8Encoder{
+		width:      b.Dx(),
+		height:     b.Dy(),
+		mbw:        (b.Dx() + 15) / 16,
+		mbh:        (b.Dy() + 15) / 16,
+		quantIndex: index,
+		quant:      newVP8Quant(index),
+		// Coarser quantizers leave stronger block edges to smooth
+		filterLevel: min(63, index/2),
+	}
+	e.yStride, e.cStride = e.mbw*16, e.mbw*8
+	e.loadPixels(img)
+	e.encodeFrame()
+	return e.frame(), nil
+}
+
+// loadPixels converts img to limited-range BT.601 YCbCr 4:2:0, the color
+// space WebP decoders assume, repeating the last row and column into the
+// padding. The coefficients are those of libwebp.
+func (e *vp8Encoder) loadPixels(img image.Image) {
+	rgba, ok := img.(*image.RGBA)
+	if !ok || rgba.Rect.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, e.width, e.height))
+		draw.Draw(rgba, rgba.Rect, img, img.Bounds().Min, draw.Src)
+	}
+
+	rows, cols := e.mbh*16, e.mbw*16
+	e.srcY, e.recY = make([]uint8, rows*cols), make([]uint8, rows*cols)
+	e.srcU, e.recU = make([]uint8, rows*cols/4), make([]uint8, rows*cols/4)
+	e.srcV, e.recV = make([]uint8, rows*cols/4), make([]uint8, rows*cols/4)
+
+	pixel := func(x, y int) (r, g, b int) {
+		i := rgba.PixOffset(min(x, e.width-1), min(y, e.height-1))
+		return int(rgba.Pix[i]), int(rgba.Pix[i+1]), int(rgba.Pix[i+2])
+	}
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			r, g, b := pixel(x, y)
+			e.srcY[y*e.yStride+x] = uint8((16839*r + 33059*g + 6420*b + 16<<16 + 1<<15) >> 16)
+		}
+	}
+	for y := 0; y < rows/2; y++ {
+		for x := 0; x < cols/2; x++ {
+			// Chroma is taken from the sum of each 2x2 block
+			var r, g, b int
+			for _, d := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				pr, pg, pb := pixel(2*x+d[0], 2*y+d[1])
+				r, g, b = r+pr, g+pg, b+pb
+			}
+			e.srcU[y*e.cStride+x] = clampUint8((-9719*r - 19081*g + 28800*b + 128<<18 + 1<<17) >> 18)
+			e.srcV[y*e.cStride+x] = clampUint8((28800*r - 24116*g - 4684*b + 128<<18 + 1<<17) >> 18)
+		}
+	}
+}
+
+func (e *vp8Encoder) encodeFrame() {
+	e.writeHeader()
+	e.above = make([]vp8Nonzero, e.mbw)
+	for mby := 0; mby < e.mbh; mby++ {
+		e.left = vp8Nonzero{}
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			e.encodeMacroblock(mbx, mby)
+		}
+	}
+}
+
+// writeHeader writes the key frame header fields of section 9 and 19.2
+func (e *vp8Encoder) writeHeader() {
+	h := &e.modes
+	h.putFlag(false) // color space: YCbCr
+	h.putFlag(false) // decoders clamp reconstructed pixels
+	h.putFlag(false) // no segmentation
+	h.putFlag(false) // normal loop filter
+	h.putLiteral(e.filterLevel, 6)
+	h.putLiteral(0, 3) // sharpness
+	h.putFlag(false)   // no loop filter adjustments
+	h.putLiteral(0, 2) // one token partition
+	h.putLiteral(e.quantIndex, 7)
+	for i := 0; i < 5; i++ {
+		h.putFlag(false) // no quantizer deltas
+	}
+	h.putFlag(false) // refresh_entropy_probs, meaningless for a single frame
+	for i := range vp8TokenUpdateProbs {
+		for j := range vp8TokenUpdateProbs[i] {
+			for k := range vp8TokenUpdateProbs[i][j] {
+				for _, prob := range vp8TokenUpdateProbs[i][j][k] {
+					h.putBit(prob, false) // keep the default token probabilities
+				}
+			}
+		}
+	}
+	h.putFlag(false) // every macroblock codes its coefficients
+}
+
+func (e *vp8Encoder) encodeMacroblock(mbx, mby int) {
+	x, y := mbx*16, mby*16
+	var pred [256]uint8
+	yMode := e.choosePrediction(e.srcY, e.recY, e.yStride, x, y, 16, pred[:])
+
+	// Luma: a DCT per 4x4 block, with the 16 DC coefficients moved into a
+	// Walsh-Hadamard transformed block of their own (Y2)
+	var coeffs [16][16]int32
+	for n := range coeffs {
+		bx, by := x+n%4*4, y+n/4*4
+		var residual [16]int32
+		for j := 0; j < 4; j++ {
+			for i := 0; i < 4; i++ {
+				residual[j*4+i] = int32(e.srcY[(by+j)*e.yStride+bx+i]) - int32(pred[(n/4*4+j)*16+n%4*4+i])
+			}
+		}
+		forwardDCT(&residual, &coeffs[n])
+	}
+	var dcs, y2 [16]int32
+	for n := range coeffs {
+		dcs[n] = coeffs[n][0]
+	}
+	forwardWHT(&dcs, &y2)
+
+	var y2Levels [16]int32
+	var yLevels [16][16]int32
+	quantizeBlock(&y2, &y2Levels, e.quant.y2DC, e.quant.y2AC, 0)
+	for n := range coeffs {
+		quantizeBlock(&coeffs[n], &yLevels[n], 0, e.quant.y1AC, 1)
+	}
+
+	// Reconstruct the luma as a decoder will
+	var y2Dequant [16]int16
+	for k, level := range y2Levels {
+		y2Dequant[k] = int16(level * pick(k, e.quant.y2DC, e.quant.y2AC))
+	}
+	dc := inverseWHT(&y2Dequant)
+	for n := range yLevels {
+		var block [16]int16
+		block[0] = dc[n]
+		for k := 1; k < 16; k++ {
+			block[k] = int16(yLevels[n][k] * e.quant.y1AC)
+		}
+		bx, by := x+n%4*4, y+n/4*4
+		copyBlock(e.recY, e.yStride, bx, by, pred[(n/4*4)*16+n%4*4:], 16)
+		inverseDCT(&block, e.recY, e.yStride, bx, by)
+	}
+
+	// Chroma: both planes share one prediction mode
+	var predU, predV [64]uint8
+	uvMode := e.chooseChromaPrediction(x/2, y/2, predU[:], predV[:])
+	var uLevels, vLevels [4][16]int32
+	e.encodeChroma(e.srcU, e.recU, predU[:], x/2, y/2, &uLevels)
+	e.encodeChroma(e.srcV, e.recV, predV[:], x/2, y/2, &vLevels)
+
+	e.writeModes(yMode, uvMode)
+	e.writeTokens(mbx, &y2Levels, &yLevels, &uLevels, &vLevels)
+}
+
+// encodeChroma transforms, quantizes and reconstructs one 8x8 chroma region
+func (e *vp8Encoder) encodeChroma(src, rec, pred []uint8, x, y int, levels *[4][16]int32) {
+	for n := range levels {
+		bx, by := x+n%2*4, y+n/2*4
+		var residual, coeffs [16]int32
+		for j := 0; j < 4; j++ {
+			for i := 0; i < 4; i++ {
+				residual[j*4+i] = int32(src[(by+j)*e.cStride+bx+i]) - int32(pred[(n/2*4+j)*8+n%2*4+i])
+			}
+		}
+		forwardDCT(&residual, &coeffs)
+		quantizeBlock(&coeffs, &levels[n], e.quant.uvDC, e.quant.uvAC, 0)
+
+		var block [16]int16
+		for k, level := range levels[n] {
+			block[k] = int16(level * pick(k, e.quant.uvDC, e.quant.uvAC))
+		}
+		copyBlock(rec, e.cStride, bx, by, pred[(n/2*4)*8+n%2*4:], 8)
+		inverseDCT(&block, rec, e.cStride, bx, by)
+	}
+}
+
+// choosePrediction fills pred with the mode that is closest to the source.
+// Modes that read outside the frame are not tried, so the encoder never
+// depends on the border values decoders assume there.
+func (e *vp8Encoder) choosePrediction(src, rec []uint8, stride, x, y, size int, pred []uint8) int {
+	best, bestCost := vp8PredDC, -1
+	candidate := make([]uint8, size*size)
+	for _, mode := range []int{vp8PredDC, vp8PredVE, vp8PredHE, vp8PredTM} {
+		if !predictionAvailable(mode, x, y) {
+			continue
+		}
+		predict(rec, stride, x, y, size, mode, candidate)
+		if cost := sad(src, stride, x, y, size, candidate); bestCost < 0 || cost < bestCost {
+			best, bestCost = mode, cost
+			copy(pred, candidate)
+		}
+	}
+	return best
+}
+
+func (e *vp8Encoder) chooseChromaPrediction(x, y int, predU, predV []uint8) int {
+	best, bestCost := vp8PredDC, -1
+	candU, candV := make([]uint8, 64), make([]uint8, 64)
+	for _, mode := range []int{vp8PredDC, vp8PredVE, vp8PredHE, vp8PredTM} {
+		if !predictionAvailable(mode, x, y) {
+			continue
+		}
+		predict(e.recU, e.cStride, x, y, 8, mode, candU)
+		predict(e.recV, e.cStride, x, y, 8, mode, candV)
+		cost := sad(e.srcU, e.cStride, x, y, 8, candU) + sad(e.srcV, e.cStride, x, y, 8, candV)
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = mode, cost
+			copy(predU, candU)
+			copy(predV, candV)
+		}
+	}
+	return best
+}
+
+func predictionAvailable(mode, x, y int) bool {
+	switch mode {
+	case vp8PredVE:
+		return y > 0
+	case vp8PredHE:
+		return x > 0
+	case vp8PredTM:
+		return x > 0 && y > 0
+	}
+	return true
+}
+
+// predict computes a size x size prediction at (x, y) from the reconstructed
+// row above and column left, section 12.2. DC prediction averages whichever
+// edges lie inside the frame.
+func predict(rec []uint8, stride, x, y, size, mode int, out []uint8) {
+	top := func(i int) int32 { return int32(rec[(y-1)*stride+x+i]) }
+	left := func(j int) int32 { return int32(rec[(y+j)*stride+x-1]) }
+
+	switch mode {
+	case vp8PredDC:
+		shift := 3
+		if size == 16 {
+			shift = 4
+		}
+		var sum int32
+		avg := int32(128)
+		switch {
+		case x > 0 && y > 0:
+			for i := 0; i < size; i++ {
+				sum += top(i) + left(i)
+			}
+			avg = (sum + int32(size)) >> (shift + 1)
+		case y > 0:
+			for i := 0; i < size; i++ {
+				sum += top(i)
+			}
+			avg = (sum + int32(size/2)) >> shift
+		case x > 0:
+			for j := 0; j < size; j++ {
+				sum += left(j)
+			}
+			avg = (sum + int32(size/2)) >> shift
+		}
+		for i := range out[:size*size] {
+			out[i] = uint8(avg)
+		}
+	case vp8PredVE:
+		for j := 0; j < size; j++ {
+			for i := 0; i < size; i++ {
+				out[j*size+i] = uint8(top(i))
+			}
+		}
+	case vp8PredHE:
+		for j := 0; j < size; j++ {
+			for i := 0; i < size; i++ {
+				out[j*size+i] = uint8(left(j))
+			}
+		}
+	case vp8PredTM:
+		corner := int32(rec[(y-1)*stride+x-1])
+		for j := 0; j < size; j++ {
+			for i := 0; i < size; i++ {
+				out[j*size+i] = clampUint8(int(left(j) + top(i) - corner))
+			}
+		}
+	}
+}
+
+// sad is the sum of absolute differences between the source and a prediction
+func sad(src []uint8, stride, x, y, size int, pred []uint8) int {
+	total := 0
+	for j := 0; j < size; j++ {
+		for i := 0; i < size; i++ {
+			d := int(src[(y+j)*stride+x+i]) - int(pred[j*size+i])
+			if d < 0 {
+				d = -d
+			}
+			total += d
+		}
+	}
+	return total
+}
+
+func copyBlock(dst []uint8, stride, x, y int, src []uint8, srcStride int) {
+	for j := 0; j < 4; j++ {
+		copy(dst[(y+j)*stride+x:(y+j)*stride+x+4], src[j*srcStride:j*srcStride+4])
+	}
+}
+
+// forwardDCT is the 4x4 integer DCT of libvpx's reference encoder. Output
+// coefficients are in raster order, rows being vertical frequencies.
+func forwardDCT(in, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		row := in[i*4 : i*4+4]
+		a := (row[0] + row[3]) * 8
+		b := (row[1] + row[2]) * 8
+		c := (row[1] - row[2]) * 8
+		d := (row[0] - row[3]) * 8
+		tmp[i*4+0] = a + b
+		tmp[i*4+2] = a - b
+		tmp[i*4+1] = (c*2217 + d*5352 + 14500) >> 12
+		tmp[i*4+3] = (d*2217 - c*5352 + 7500) >> 12
+	}
+	for i := 0; i < 4; i++ {
+		a := tmp[i] + tmp[12+i]
+		b := tmp[4+i] + tmp[8+i]
+		c := tmp[4+i] - tmp[8+i]
+		d := tmp[i] - tmp[12+i]
+		out[i] = (a + b + 7) >> 4
+		out[8+i] = (a - b + 7) >> 4
+		out[4+i] = (c*2217 + d*5352 + 12000) >> 16
+		if d != 0 {
+			out[4+i]++
+		}
+		out[12+i] = (d*2217 - c*5352 + 51000) >> 16
+	}
+}
+
+// forwardWHT is the Walsh-Hadamard transform of the luma DC coefficients
+func forwardWHT(in, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		row := in[i*4 : i*4+4]
+		a := (row[0] + row[2]) * 4
+		d := (row[1] + row[3]) * 4
+		c := (row[1] - row[3]) * 4
+		b := (row[0] - row[2]) * 4
+		tmp[i*4+0] = a + d
+		if a != 0 {
+			tmp[i*4+0]++
+		}
+		tmp[i*4+1] = b + c
+		tmp[i*4+2] = b - c
+		tmp[i*4+3] = a - d
+	}
+	for i := 0; i < 4; i++ {
+		a := tmp[i] + tmp[8+i]
+		d := tmp[4+i] + tmp[12+i]
+		c := tmp[4+i] - tmp[12+i]
+		b := tmp[i] - tmp[8+i]
+		for k, v := range [4]int32{a + d, b + c, b - c, a - d} {
+			if v < 0 {
+				v++
+			}
+			out[k*4+i] = (v + 3) >> 3
+		}
+	}
+}
+
+// quantizeBlock rounds coefficients from first on to multiples of the step
+// sizes. AC coefficients round towards zero a little more than DC ones,
+// which saves bits on detail that barely shows.
+func quantizeBlock(coeffs, levels *[16]int32, dcStep, acStep int32, first int) {
+	for k := first; k < 16; k++ {
+		step, bias := acStep, acStep/3
+		if k == 0 {
+			step, bias = dcStep, dcStep/2
+		}
+		v := coeffs[k]
+		negative := v < 0
+		if negative {
+			v = -v
+		}
+		// DCT_CAT6 tokens carry up to 2048 + 66
+		level := min((v+bias)/step, 2048)
+		if negative {
+			level = -level
+		}
+		levels[k] = level
+	}
+}
+
+func pick(k int, dc, ac int32) int32 {
+	if k == 0 {
+		return dc
+	}
+	return ac
+}
+
+// inverseWHT returns the DC coefficient of each luma block, section 14.3
+func inverseWHT(in *[16]int16) [16]int16 {
+	var m [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := int32(in[i]) + int32(in[12+i])
+		a1 := int32(in[4+i]) + int32(in[8+i])
+		a2 := int32(in[4+i]) - int32(in[8+i])
+		a3 := int32(in[i]) - int32(in[12+i])
+		m[i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	var out [16]int16
+	for i := 0; i < 4; i++ {
+		dc := m[i*4] + 3
+		a0 := dc + m[i*4+3]
+		a1 := m[i*4+1] + m[i*4+2]
+		a2 := m[i*4+1] - m[i*4+2]
+		a3 := dc - m[i*4+3]
+		out[i*4+0] = int16((a0 + a1) >> 3)
+		out[i*4+1] = int16((a3 + a2) >> 3)
+		out[i*4+2] = int16((a0 - a1) >> 3)
+		out[i*4+3] = int16((a3 - a2) >> 3)
+	}
+	return out
+}
+
+// inverseDCT adds the inverse transform of a block to the prediction already
+// in rec, section 14.4. It is bit-exact with decoders.
+func inverseDCT(in *[16]int16, rec []uint8, stride, x, y int) {
+	const (
+		c1 = 85627 // 65536 * cos(pi/8) * sqrt(2)
+		c2 = 35468 // 65536 * sin(pi/8) * sqrt(2)
+	)
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := int32(in[i]) + int32(in[8+i])
+		b := int32(in[i]) - int32(in[8+i])
+		c := (int32(in[4+i])*c2)>>16 - (int32(in[12+i])*c1)>>16
+		d := (int32(in[4+i])*c1)>>16 + (int32(in[12+i])*c2)>>16
+		m[i][0] = a + d
+		m[i][1] = b + c
+		m[i][2] = b - c
+		m[i][3] = a - d
+	}
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		row := rec[(y+j)*stride+x : (y+j)*stride+x+4]
+		row[0] = clampUint8(int(int32(row[0]) + (a+d)>>3))
+		row[1] = clampUint8(int(int32(row[1]) + (b+c)>>3))
+		row[2] = clampUint8(int(int32(row[2]) + (b-c)>>3))
+		row[3] = clampUint8(int(int32(row[3]) + (a-d)>>3))
+	}
+}
+
+// writeModes codes a macroblock's prediction modes with the fixed key frame
+// probabilities of section 11.2
+func (e *vp8Encoder) writeModes(yMode, uvMode int) {
+	h := &e.modes
+	h.putBit(145, true) // 16x16 luma prediction rather than 4x4 blocks
+	switch yMode {
+	case vp8PredDC:
+		h.putBit(156, false)
+		h.putBit(163, false)
+	case vp8PredVE:
+		h.putBit(156, false)
+		h.putBit(163, true)
+	case vp8PredHE:
+		h.putBit(156, true)
+		h.putBit(128, false)
+	case vp8PredTM:
+		h.putBit(156, true)
+		h.putBit(128, true)
+	}
+
+	h.putBit(142, uvMode != vp8PredDC)
+	if uvMode == vp8PredDC {
+		return
+	}
+	h.putBit(114, uvMode != vp8PredVE)
+	if uvMode != vp8PredVE {
+		h.putBit(183, uvMode == vp8PredTM)
+	}
+}
+
+// writeTokens codes a macroblock's coefficients in the order decoders read
+// them: Y2, the 16 luma blocks, then the U and V blocks, section 13
+func (e *vp8Encoder) writeTokens(mbx int, y2 *[16]int32, luma *[16][16]int32, u, v *[4][16]int32) {
+	above := &e.above[mbx]
+	nz := e.writeBlock(y2, vp8PlaneY2, e.left.y2+above.y2, 0)
+	e.left.y2, above.y2 = nz, nz
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			nz := e.writeBlock(&luma[y*4+x], vp8PlaneY1AfterY2, e.left.y[y]+above.y[x], 1)
+			e.left.y[y], above.y[x] = nz, nz
+		}
+	}
+	for _, plane := range []struct {
+		levels      *[4][16]int32
+		left, above *[2]uint8
+	}{{u, &e.left.u, &above.u}, {v, &e.left.v, &above.v}} {
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 2; x++ {
+				nz := e.writeBlock(&plane.levels[y*2+x], vp8PlaneUV, plane.left[y]+plane.above[x], 0)
+				plane.left[y], plane.above[x] = nz, nz
+			}
+		}
+	}
+}
+
+// writeBlock codes the levels of one block from position first on and
+// reports whether any were nonzero, section 13.2
+func (e *vp8Encoder) writeBlock(levels *[16]int32, plane int, context uint8, first int) uint8 {
+	last := -1
+	for i := 15; i >= first; i-- {
+		if levels[vp8Zigzag[i]] != 0 {
+			last = i
+			break
+		}
+	}
+
+	t := &e.tokens
+	probs := &vp8DefaultTokenProbs[plane]
+	p := &probs[vp8Bands[first]][context]
+	t.putBit(p[0], last >= 0)
+	if last < 0 {
+		return 0
+	}
+	for i := first; i <= last; i++ {
+		level := levels[vp8Zigzag[i]]
+		if level == 0 {
+			// No end-of-block check follows a zero
+			t.putBit(p[1], false)
+			p = &probs[vp8Bands[i+1]][0]
+			continue
+		}
+		t.putBit(p[1], true)
+
+		abs := level
+		if abs < 0 {
+			abs = -abs
+		}
+		if abs == 1 {
+			t.putBit(p[2], false)
+			p = &probs[vp8Bands[i+1]][1]
+		} else {
+			t.putBit(p[2], true)
+			writeLargeLevel(t, p, abs)
+			p = &probs[vp8Bands[i+1]][2]
+		}
+		t.putBit(128, level < 0)
+		if i == 15 {
+			break
+		}
+		t.putBit(p[0], i < last)
+	}
+	return 1
+}
+
+// writeLargeLevel codes a level of 2 or more as a token and its extra bits
+func writeLargeLevel(t *boolEncoder, p *[vp8ProbCount]uint8, abs int32) {
+	switch {
+	case abs <= 4:
+		t.putBit(p[3], false)
+		t.putBit(p[4], abs != 2)
+		if abs != 2 {
+			t.putBit(p[5], abs == 4)
+		}
+	case abs <= 10:
+		t.putBit(p[3], true)
+		t.putBit(p[6], false)
+		if abs <= 6 {
+			t.putBit(p[7], false)
+			t.putBit(159, abs == 6)
+		} else {
+			t.putBit(p[7], true)
+			t.putBit(165, (abs-7)&2 != 0)
+			t.putBit(145, (abs-7)&1 != 0)
+		}
+	default:
+		t.putBit(p[3], true)
+		t.putBit(p[6], true)
+		// Categories 3 to 6 start at 11, 19, 35 and 67
+		cat := 0
+		for cat < 3 && abs >= 3+(8<<(cat+1)) {
+			cat++
+		}
+		t.putBit(p[8], cat >= 2)
+		t.putBit(p[9+cat/2], cat&1 != 0)
+		extra := abs - (3 + 8<<cat)
+		bits := vp8CatProbs[cat]
+		for i, prob := range bits {
+			t.putBit(prob, extra>>(len(bits)-1-i)&1 != 0)
+		}
+	}
+}
+
+// frame assembles the key frame: the frame tag and header of section 9.1,
+// then both partitions
+func (e *vp8Encoder) frame() []byte {
+	modes := e.modes.finish()
+	tokens := e.tokens.finish()
+
+	// Key frame, version 0, shown, followed by the first partition's size
+	tag := uint32(len(modes))<<5 | 1<<4
+	out := make([]byte, 0, 10+len(modes)+len(tokens))
+	out = append(out, byte(tag), byte(tag>>8), byte(tag>>16))
+	out = append(out, 0x9d, 0x01, 0x2a)
+	out = append(out, byte(e.width), byte(e.width>>8), byte(e.height), byte(e.height>>8))
+	out = append(out, modes...)
+	return append(out, tokens...)
+}
+
+// boolEncoder is the boolean entropy encoder of RFC 6386 section 7.3
+type boolEncoder struct {
+	buf      []byte
+	rng      uint32
+	bottom   uint32
+	bitCount int
+}
+
+func (e *boolEncoder) putBit(prob uint8, bit bool) {
+	if e.rng == 0 {
+		e.rng, e.bitCount = 255, 24
+	}
+	split := 1 + (e.rng-1)*uint32(prob)>>8
+	if bit {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			e.carry()
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.buf = append(e.buf, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+// putFlag writes a bit with even odds, the L(1) fields of the header
+func (e *boolEncoder) putFlag(bit bool) {
+	e.putBit(128, bit)
+}
+
+// putLiteral writes the n low bits of v, most significant first
+func (e *boolEncoder) putLiteral(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		e.putFlag(v>>i&1 != 0)
+	}
+}
+
+// carry propagates an overflow of bottom into the bytes already written
+func (e *boolEncoder) carry() {
+	i := len(e.buf) - 1
+	for ; i >= 0 && e.buf[i] == 255; i-- {
+		e.buf[i] = 0
+	}
+	if i >= 0 {
+		e.buf[i]++
+	}
+}
+
+// finish flushes the pending bits and returns the partition
+func (e *boolEncoder) finish() []byte {
+	if e.rng == 0 {
+		e.rng, e.bitCount = 255, 24
+	}
+	c, v := e.bitCount, e.bottom
+	if v&(1<<(32-c)) != 0 {
+		e.carry()
+	}
+	v <<= c & 7
+	for c >>= 3; c > 0; c-- {
+		v <<= 8
+	}
+	for i := 0; i < 4; i++ {
+		e.buf = append(e.buf, byte(v>>24))
+		v <<= 8
+	}
+	return e.buf
+}
+
+func clampUint8(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
@@ -0,0 +1,86 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// gradient draws smooth color ramps with a hard edge, the kind of content
+// that exercises every prediction mode
+func gradient(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 96, A: 255}
+			if x > width/2 && y > height/3 {
+				c.B = 220
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestWebPEncoderRoundTrip(t *testing.T) {
+	enc, ok := LookupEncoder("webp")
+	if !ok {
+		t.Fatal("no webp encoder registered")
+	}
+
+	for _, size := range []image.Point{{1, 1}, {16, 16}, {37, 21}, {200, 133}} {
+		src := gradient(size.X, size.Y)
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, src); err != nil {
+			t.Fatalf("%v: Encode: %v", size, err)
+		}
+		if format, ok := Sniff(buf.Bytes()); !ok || format.Name != "webp" {
+			t.Fatalf("%v: Sniff = %+v, %v; want webp", size, format, ok)
+		}
+
+		decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%v: decode: %v", size, err)
+		}
+		if got := decoded.Bounds().Size(); got != size {
+			t.Fatalf("decoded size %v, want %v", got, size)
+		}
+		if psnr := lumaPSNR(src, decoded); psnr < 30 {
+			t.Errorf("%v: luma PSNR %.1f dB, want at least 30", size, psnr)
+		}
+	}
+}
+
+func TestWebPEncoderRejectsOversizedImages(t *testing.T) {
+	enc, _ := LookupEncoder("webp")
+	img := image.NewGray(image.Rect(0, 0, vp8MaxDimension+1, 1))
+	if err := enc.Encode(&bytes.Buffer{}, img); !errors.Is(err, ErrTooLargeForWebP) {
+		t.Fatalf("Encode = %v, want ErrTooLargeForWebP", err)
+	}
+}
+
+// lumaPSNR compares the luma plane of a decoded WebP with the studio-swing
+// BT.601 luma of the source, the color space WebP is encoded in
+func lumaPSNR(src *image.NRGBA, decoded image.Image) float64 {
+	ycbcr := decoded.(*image.YCbCr)
+	var sum float64
+	bounds := src.Bounds()
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := src.NRGBAAt(x, y)
+			want := 16 + (65.481*float64(c.R)+128.553*float64(c.G)+24.966*float64(c.B))/255
+			d := float64(ycbcr.Y[ycbcr.YOffset(x, y)]) - want
+			sum += d * d
+		}
+	}
+	mse := sum / float64(bounds.Dx()*bounds.Dy())
+	if mse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/mse)
+}
@@ -13,50 +13,87 @@ var (
 )
 
 type Claims struct {
-	UserID   uint   `json:"userId"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID    uint   `json:"userId"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID uint   `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// JWTManager signs access tokens with one key and verifies them against that
+// key plus any previous keys, so a rotated key keeps validating the tokens it
+// issued until they expire.
 type JWTManager struct {
-	secretKey     string
+	signingKey    *Key
+	previousKeys  []*Key
+	keys          map[string]*Key
 	tokenDuration time.Duration
 }
 
-func NewJWTManager(secretKey string, tokenDuration time.Duration) *JWTManager {
+func NewJWTManager(signingKey *Key, tokenDuration time.Duration, previousKeys ...*Key) (*JWTManager, error) {
+	if signingKey == nil || !signingKey.CanSign() {
+		return nil, errors.New("jwt signing key must be a private key")
+	}
+
+	keys := map[string]*Key{signingKey.ID: signingKey}
+	for _, key := range previousKeys {
+		if key.Method == jwt.SigningMethodHS256 {
+			return nil, errors.New("previous jwt keys must be RSA or Ed25519 keys")
+		}
+		keys[key.ID] = key
+	}
+
 	return &JWTManager{
-		secretKey:     secretKey,
+		signingKey:    signingKey,
+		previousKeys:  previousKeys,
+		keys:          keys,
 		tokenDuration: tokenDuration,
+	}, nil
+}
+
+// JWKS lists the public verification keys, the signing key first. It is
+// empty in HS256 mode because the shared secret cannot be published.
+func (m *JWTManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range append([]*Key{m.signingKey}, m.previousKeys...) {
+		if key.public != nil {
+			set.Keys = append(set.Keys, key.JWK())
+		}
 	}
+	return set
 }
 
-// GenerateToken creates a new JWT token for a user
-func (m *JWTManager) GenerateToken(userID uint, username, role string) (string, error) {
+// TokenDuration is how long issued access tokens stay valid
+func (m *JWTManager) TokenDuration() time.Duration {
+	return m.tokenDuration
+}
+
+// GenerateToken creates a new access token bound to a login session
+func (m *JWTManager) GenerateToken(userID uint, username, role string, sessionID uint) (string, error) {
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.tokenDuration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(m.secretKey))
+	token := jwt.NewWithClaims(m.signingKey.Method, claims)
+	token.Header["kid"] = m.signingKey.ID
+	return token.SignedString(m.signingKey.signKey)
 }
 
 // ValidateToken validates and parses a JWT token
 func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(m.secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, m.verificationKey, jwt.WithExpirationRequired())
 
 	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
 		return nil, err
 	}
 
@@ -70,4 +107,20 @@ func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	return claims, nil
+}
+
+// verificationKey picks the key named by the kid header. Tokens issued before
+// kids existed carry none and are only accepted in HS256 mode.
+// The algorithm must match the key, which rules out alg confusion attacks.
+func (m *JWTManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	var key *Key
+	if kid, ok := token.Header["kid"].(string); ok {
+		key = m.keys[kid]
+	} else if m.signingKey.Method == jwt.SigningMethodHS256 {
+		key = m.signingKey
+	}
+	if key == nil || token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return key.verifyKey, nil
 }
\ No newline at end of file
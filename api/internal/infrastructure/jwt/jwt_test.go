@@ -0,0 +1,114 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newEd25519Key(t *testing.T) *Key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	key, err := ParseKeyPEM(pemBlock(t, "PRIVATE KEY", der, err))
+	if err != nil {
+		t.Fatalf("parse key: %v", err)
+	}
+	return key
+}
+
+func newManager(t *testing.T, signing *Key, previous ...*Key) *JWTManager {
+	t.Helper()
+	m, err := NewJWTManager(signing, time.Hour, previous...)
+	if err != nil {
+		t.Fatalf("NewJWTManager: %v", err)
+	}
+	return m
+}
+
+func TestRotatedKeysKeepVerifyingTheirTokens(t *testing.T) {
+	oldKey, newKey := newEd25519Key(t), newEd25519Key(t)
+	oldToken, err := newManager(t, oldKey).GenerateToken(1, "alice", "admin", 7)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	rotated := newManager(t, newKey, oldKey)
+	claims, err := rotated.ValidateToken(oldToken)
+	if err != nil || claims.UserID != 1 || claims.SessionID != 7 {
+		t.Fatalf("old token after rotation = %+v, %v", claims, err)
+	}
+	newToken, err := rotated.GenerateToken(2, "bob", "viewer", 8)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	if err != nil || parsed.Header["kid"] != newKey.ID {
+		t.Fatalf("new token kid = %v, %v; want %s", parsed.Header["kid"], err, newKey.ID)
+	}
+
+	jwks := rotated.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != newKey.ID || jwks.Keys[1].Kid != oldKey.ID {
+		t.Fatalf("JWKS = %+v, want the signing key then the previous one", jwks)
+	}
+
+	// Once dropped, the old key no longer verifies anything
+	if _, err := newManager(t, newKey).ValidateToken(oldToken); err == nil {
+		t.Fatal("token of a retired key accepted")
+	}
+}
+
+func TestVerificationKeySelection(t *testing.T) {
+	key := newEd25519Key(t)
+	m := newManager(t, key)
+	claims := Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+
+	sign := func(method jwt.SigningMethod, kid interface{}, signKey interface{}) string {
+		t.Helper()
+		token := jwt.NewWithClaims(method, claims)
+		if kid != nil {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(signKey)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return signed
+	}
+
+	if _, err := m.ValidateToken(sign(jwt.SigningMethodEdDSA, key.ID, key.signKey)); err != nil {
+		t.Fatalf("token with the signing kid: %v", err)
+	}
+	rejected := map[string]string{
+		"without kid":    sign(jwt.SigningMethodEdDSA, nil, key.signKey),
+		"unknown kid":    sign(jwt.SigningMethodEdDSA, "other", key.signKey),
+		"non-string kid": sign(jwt.SigningMethodEdDSA, 1, key.signKey),
+		// HS256 keyed with the published public key must not pass as the Ed25519 key
+		"alg confusion": sign(jwt.SigningMethodHS256, key.ID, []byte(key.public.(ed25519.PublicKey))),
+	}
+	for name, token := range rejected {
+		if _, err := m.ValidateToken(token); err == nil {
+			t.Errorf("token %s accepted", name)
+		}
+	}
+
+	// HS256 mode still accepts tokens issued before kids existed, and publishes nothing
+	hmac := NewHMACKey("secret")
+	legacy := newManager(t, hmac)
+	if _, err := legacy.ValidateToken(sign(jwt.SigningMethodHS256, nil, []byte("secret"))); err != nil {
+		t.Fatalf("legacy HS256 token: %v", err)
+	}
+	if keys := legacy.JWKS().Keys; len(keys) != 0 {
+		t.Fatalf("HS256 JWKS = %+v, want empty", keys)
+	}
+	if _, err := NewJWTManager(key, time.Hour, hmac); err == nil {
+		t.Fatal("HMAC key accepted as a previous key")
+	}
+}
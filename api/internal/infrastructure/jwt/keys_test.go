@@ -0,0 +1,92 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func pemBlock(t *testing.T, blockType string, der []byte, err error) []byte {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("marshal %s: %v", blockType, err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func TestThumbprintMatchesRFCExamples(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+		want string
+	}{
+		{
+			// RFC 7638 section 3.1
+			name: "RSA",
+			jwk: JWK{
+				Kty: "RSA",
+				E:   "AQAB",
+				N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+				// Members outside the required set do not change the thumbprint
+				Use: "sig", Alg: "RS256", Kid: "2011-04-29",
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037 appendix A.3
+			name: "Ed25519",
+			jwk:  JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.jwk.thumbprint(); got != tt.want {
+			t.Errorf("%s thumbprint = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseKeyPEMGivesPrivateAndPublicFormsTheSameKid(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	privateKey, err := ParseKeyPEM(pemBlock(t, "PRIVATE KEY", privDER, err))
+	if err != nil {
+		t.Fatalf("parse private key: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	publicKey, err := ParseKeyPEM(pemBlock(t, "PUBLIC KEY", pubDER, err))
+	if err != nil {
+		t.Fatalf("parse public key: %v", err)
+	}
+
+	if privateKey.ID == "" || privateKey.ID != publicKey.ID {
+		t.Fatalf("kids differ: private %q, public %q", privateKey.ID, publicKey.ID)
+	}
+	if !privateKey.CanSign() || publicKey.CanSign() {
+		t.Fatalf("CanSign: private %v, public %v", privateKey.CanSign(), publicKey.CanSign())
+	}
+	if jwk := publicKey.JWK(); jwk.Kid != publicKey.ID || jwk.Alg != "EdDSA" || jwk.Kty != "OKP" {
+		t.Fatalf("unexpected JWK: %+v", jwk)
+	}
+}
+
+func TestParseKeyPEMRejectsWeakKeys(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pemBlock(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(weak), nil)
+	if _, err := ParseKeyPEM(data); !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("1024-bit RSA key: err = %v, want ErrUnsupportedKey", err)
+	}
+	if _, err := ParseKeyPEM([]byte("not a key")); err == nil {
+		t.Fatal("parsed a non-PEM key")
+	}
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+)
+
+// LogMailer does not deliver anything. It logs who a message was for and,
+// when dir is set, writes it there as a .eml file that mail clients can open.
+// The body is never logged: it carries secrets such as password reset links.
+type LogMailer struct {
+	from string
+	dir  string
+}
+
+func NewLogMailer(from, dir string) (*LogMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create mail directory: %w", err)
+		}
+	}
+	return &LogMailer{from: from, dir: dir}, nil
+}
+
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	data, err := build(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	if m.dir == "" {
+		logger.Info("mail not sent (log mailer); set MAIL_DIR to keep a copy", "to", msg.To, "subject", msg.Subject)
+		return nil
+	}
+
+	name := filepath.Join(m.dir, fmt.Sprintf("%s.eml", time.Now().Format("20060102-150405.000000000")))
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		return fmt.Errorf("write mail file: %w", err)
+	}
+	logger.Info("mail written to file (log mailer)", "to", msg.To, "subject", msg.Subject, "file", name)
+	return nil
+}
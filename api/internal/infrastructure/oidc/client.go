@@ -0,0 +1,157 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrProviderUnavailable = errors.New("oidc provider discovery failed")
+	ErrMissingIDToken      = errors.New("token response did not contain an id_token")
+	ErrNonceMismatch       = errors.New("id_token nonce does not match the login request")
+)
+
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Identity is what the provider asserted about the signed-in user
+type Identity struct {
+	Issuer  string
+	Subject string
+	Claims  map[string]interface{}
+}
+
+// Client runs the authorization code flow with PKCE against one provider.
+// Discovery happens on first use and is retried until it succeeds, so the API
+// can start while the provider is unreachable.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+	provider *gooidc.Provider
+}
+
+func NewClient(cfg Config) *Client {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{gooidc.ScopeOpenID, "profile", "email"}
+	}
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewVerifier returns a fresh PKCE code verifier
+func (c *Client) NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeURL builds the provider URL the browser is sent to
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	if err := c.discover(ctx); err != nil {
+		return "", err
+	}
+	return c.oauth.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange redeems an authorization code and verifies the returned ID token.
+// Claims from the userinfo endpoint fill in what the ID token leaves out.
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	if err := c.discover(ctx); err != nil {
+		return nil, err
+	}
+	ctx = gooidc.ClientContext(ctx, c.httpClient)
+
+	token, err := c.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("decode id_token claims: %w", err)
+	}
+
+	if c.provider.UserInfoEndpoint() != "" {
+		info, err := c.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("fetch userinfo: %w", err)
+		}
+		// userinfo must describe the same subject as the ID token
+		if info.Subject == idToken.Subject {
+			extra := map[string]interface{}{}
+			if err := info.Claims(&extra); err == nil {
+				for key, value := range extra {
+					if _, exists := claims[key]; !exists {
+						claims[key] = value
+					}
+				}
+			}
+		}
+	}
+
+	return &Identity{Issuer: idToken.Issuer, Subject: idToken.Subject, Claims: claims}, nil
+}
+
+func (c *Client) discover(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.provider != nil {
+		return nil
+	}
+
+	// The provider keeps this context for fetching signing keys later, so it
+	// must not be the request context
+	providerCtx := gooidc.ClientContext(context.Background(), c.httpClient)
+	discoverCtx, cancel := context.WithTimeout(providerCtx, c.httpClient.Timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-discoverCtx.Done():
+		}
+	}()
+
+	provider, err := gooidc.NewProvider(discoverCtx, c.cfg.IssuerURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+
+	c.provider = provider
+	c.verifier = provider.Verifier(&gooidc.Config{ClientID: c.cfg.ClientID})
+	c.oauth = &oauth2.Config{
+		ClientID:     c.cfg.ClientID,
+		ClientSecret: c.cfg.ClientSecret,
+		RedirectURL:  c.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       c.cfg.Scopes,
+	}
+	return nil
+}
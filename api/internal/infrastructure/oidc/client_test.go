@@ -0,0 +1,89 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// testIssuerEnv names the provider the flow test runs against, e.g. the
+// docker-compose mock started with --profile sso: http://localhost:8090/default
+const testIssuerEnv = "TEST_OIDC_ISSUER_URL"
+
+func TestAuthorizationCodeFlowAgainstMockProvider(t *testing.T) {
+	issuer := os.Getenv(testIssuerEnv)
+	if issuer == "" {
+		t.Skipf("%s not set", testIssuerEnv)
+	}
+
+	const redirectURL = "http://localhost:3000/admin/oidc/callback"
+	client := NewClient(Config{
+		IssuerURL:    issuer,
+		ClientID:     "atonweb-test",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+	})
+	ctx := context.Background()
+	verifier := client.NewVerifier()
+
+	authURL, err := client.AuthCodeURL(ctx, "test-state", "test-nonce", verifier)
+	if err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+
+	callback := authorize(t, authURL)
+	if state := callback.Get("state"); state != "test-state" {
+		t.Fatalf("state %q came back, want test-state", state)
+	}
+
+	identity, err := client.Exchange(ctx, callback.Get("code"), verifier, "test-nonce")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if identity.Issuer != issuer || identity.Subject == "" {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+
+	// A code bound to another nonce must not yield an identity
+	authURL, err = client.AuthCodeURL(ctx, "test-state", "other-nonce", verifier)
+	if err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+	callback = authorize(t, authURL)
+	if _, err := client.Exchange(ctx, callback.Get("code"), verifier, "test-nonce"); err == nil {
+		t.Fatal("exchange accepted an id_token with a different nonce")
+	}
+}
+
+// authorize plays the browser: it opens authURL, submits the mock's login
+// form when interactive login is on, and returns the callback query
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		resp, err = browser.PostForm(authURL, url.Values{
+			"username": {"alice"},
+			"claims":   {`{"email":"alice@example.com","email_verified":true}`},
+		})
+		if err != nil {
+			t.Fatalf("log in: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	return location.Query()
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("object not found")
+	ErrNoSuchUpload = errors.New("multipart upload not found")
+	ErrInvalidKey   = errors.New("invalid object key")
+	ErrURLExpired   = errors.New("signed url has expired")
+	ErrURLSignature = errors.New("signed url signature is invalid")
+)
+
+// ObjectInfo is what a backend reports about a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// Blob stores objects by key. S3Blob keeps them in an S3-compatible bucket;
+// LocalBlob and MemoryBlob keep them on disk or in memory, and the API
+// serves them itself under URLs built by a URLSigner.
+type Blob interface {
+	// Put stores r under key. size is -1 when the length is not known up front.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get and Stat return ErrNotFound when the key does not exist
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Remove succeeds when the key does not exist
+	Remove(ctx context.Context, key string) error
+	// PresignPut returns a URL that accepts one PUT of the object body until expiry
+	PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// PresignGet returns a URL that reads the object until expiry, for private buckets
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// URL is where clients read the object when reads are public
+	URL(key string) string
+	// BaseURLs are the prefixes URLs and presigned URLs of objects start with;
+	// the key follows after a slash
+	BaseURLs() []string
+}
+
+// Part is one uploaded part of a multipart upload
+type Part struct {
+	Number       int
+	ETag         string
+	Size         int64
+	LastModified time.Time
+}
+
+// MultipartUpload is an unfinished multipart upload
+type MultipartUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+// Multipart is implemented by backends whose clients can send large objects
+// in parts straight to the store, as S3 multipart uploads do
+type Multipart interface {
+	NewMultipartUpload(ctx context.Context, key, contentType string) (string, error)
+	PresignPart(ctx context.Context, key, uploadID string, partNumber int, expiry time.Duration) (string, error)
+	// ListParts returns ErrNoSuchUpload for unknown, completed or aborted uploads
+	ListParts(ctx context.Context, key, uploadID string) ([]Part, error)
+	// CompleteMultipartUpload assembles parts, in order, into the object
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error
+	// AbortMultipartUpload succeeds when the upload is already gone
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+	// ListMultipartUploads returns the unfinished uploads under prefix
+	ListMultipartUploads(ctx context.Context, prefix string) ([]MultipartUpload, error)
+}
+
+// ValidKey rejects keys that are empty, absolute, or not in clean slash form,
+// so a key can never name anything outside the store
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 1024 || strings.HasPrefix(key, "/") || strings.ContainsAny(key, "\\\x00") {
+		return false
+	}
+	if path.Clean(key) != key {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." || strings.HasPrefix(segment, ".") {
+			return false
+		}
+	}
+	return true
+}
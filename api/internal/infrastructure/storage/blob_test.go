@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	cases := map[string]bool{
+		"photos/2025/01/a.jpg":                true,
+		"a.jpg":                               true,
+		"":                                    false,
+		"/photos/a.jpg":                       false,
+		"photos/../a.jpg":                     false,
+		"../a.jpg":                            false,
+		"photos/./a.jpg":                      false,
+		"photos//a.jpg":                       false,
+		"photos/a.jpg/":                       false,
+		"photos/.hidden":                      false,
+		`photos\a.jpg`:                        false,
+		"photos/a\x00.jpg":                    false,
+		strings.Repeat("a", 1025):             false,
+		"photos/" + strings.Repeat("a", 1000): true,
+	}
+	for key, want := range cases {
+		if got := ValidKey(key); got != want {
+			t.Errorf("ValidKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMemoryBlobStoresObjects(t *testing.T) {
+	ctx := context.Background()
+	blob := NewMemoryBlob(NewURLSigner("http://api.test/api/v1/blobs", []byte("secret")))
+
+	if err := blob.Put(ctx, "photos/a.png", strings.NewReader("image"), -1, ""); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	info, err := blob.Stat(ctx, "photos/a.png")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	// Without a declared type the key decides
+	if info.Size != 5 || info.ContentType != "image/png" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+
+	reader, err := blob.Get(ctx, "photos/a.png")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "image" {
+		t.Fatalf("read %q", data)
+	}
+	if _, ok := reader.(io.Seeker); !ok {
+		t.Fatal("reader cannot seek, so range requests would not work")
+	}
+
+	if got := blob.URL("photos/a.png"); got != "http://api.test/api/v1/blobs/photos/a.png" {
+		t.Fatalf("url %q", got)
+	}
+
+	if err := blob.Remove(ctx, "photos/a.png"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := blob.Remove(ctx, "photos/a.png"); err != nil {
+		t.Fatalf("remove twice: %v", err)
+	}
+	if _, err := blob.Stat(ctx, "photos/a.png"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("stat after remove: %v", err)
+	}
+	if _, err := blob.Get(ctx, "photos/a.png"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after remove: %v", err)
+	}
+}
+
+func TestMemoryBlobRejectsInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	blob := NewMemoryBlob(NewURLSigner("http://api.test/api/v1/blobs", []byte("secret")))
+
+	if err := blob.Put(ctx, "../a.jpg", strings.NewReader("x"), -1, ""); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("put: %v", err)
+	}
+	if _, err := blob.PresignPut(ctx, "/a.jpg", 0); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("presign put: %v", err)
+	}
+	if _, err := blob.PresignGet(ctx, "photos/../a.jpg", 0); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("presign get: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the buffer per part when streaming an object of unknown length
+const s3PartSize = 16 << 20
+
+// s3ListPage is how many parts are listed per request
+const s3ListPage = 1000
+
+type S3Config struct {
+	Endpoint        string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+}
+
+// S3Blob keeps objects in an S3-compatible bucket (MinIO, OSS, S3). Clients
+// read objects straight from the bucket, which must either allow public reads
+// or be used with presigned GET URLs.
+type S3Blob struct {
+	client   *minio.Client
+	bucket   string
+	endpoint string
+	useSSL   bool
+}
+
+// NewS3Blob connects to the bucket and checks that it exists
+func NewS3Blob(ctx context.Context, cfg S3Config) (*S3Blob, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 endpoint and bucket are required")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure: cfg.UseSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create minio client: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket existence: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", cfg.Bucket)
+	}
+
+	return &S3Blob{client: client, bucket: cfg.Bucket, endpoint: cfg.Endpoint, useSSL: cfg.UseSSL}, nil
+}
+
+func (b *S3Blob) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	if size < 0 {
+		opts.PartSize = s3PartSize
+	}
+	if _, err := b.client.PutObject(ctx, b.bucket, key, r, size, opts); err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	return nil
+}
+
+func (b *S3Blob) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := b.client.GetObject(ctx, b.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	// The request is only sent on first use; make it now so a missing key is reported here
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if isS3Code(err, "NoSuchKey") {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	return obj, nil
+}
+
+func (b *S3Blob) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := b.client.StatObject(ctx, b.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if isS3Code(err, "NoSuchKey") {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+	return &ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+func (b *S3Blob) Remove(ctx context.Context, key string) error {
+	if err := b.client.RemoveObject(ctx, b.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove object: %w", err)
+	}
+	return nil
+}
+
+func (b *S3Blob) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := b.client.PresignedPutObject(ctx, b.bucket, key, expiry)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+func (b *S3Blob) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := b.client.PresignedGetObject(ctx, b.bucket, key, expiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+func (b *S3Blob) URL(key string) string {
+	return b.pathStyleBase() + "/" + key
+}
+
+// BaseURLs covers both addressing styles: presigned URLs use the virtual
+// host style when the endpoint calls for it, as OSS and AWS endpoints do
+func (b *S3Blob) BaseURLs() []string {
+	return []string{b.pathStyleBase(), fmt.Sprintf("%s://%s.%s", b.protocol(), b.bucket, b.endpoint)}
+}
+
+func (b *S3Blob) pathStyleBase() string {
+	return fmt.Sprintf("%s://%s/%s", b.protocol(), b.endpoint, b.bucket)
+}
+
+func (b *S3Blob) protocol() string {
+	if b.useSSL {
+		return "https"
+	}
+	return "http"
+}
+
+func (b *S3Blob) NewMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	uploadID, err := b.core().NewMultipartUpload(ctx, b.bucket, key, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+	return uploadID, nil
+}
+
+func (b *S3Blob) PresignPart(ctx context.Context, key, uploadID string, partNumber int, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("partNumber", strconv.Itoa(partNumber))
+	params.Set("uploadId", uploadID)
+	u, err := b.client.Presign(ctx, "PUT", b.bucket, key, expiry, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign part: %w", err)
+	}
+	return u.String(), nil
+}
+
+func (b *S3Blob) ListParts(ctx context.Context, key, uploadID string) ([]Part, error) {
+	parts := []Part{}
+	marker := 0
+	for {
+		result, err := b.core().ListObjectParts(ctx, b.bucket, key, uploadID, marker, s3ListPage)
+		if err != nil {
+			if isS3Code(err, "NoSuchUpload") {
+				return nil, ErrNoSuchUpload
+			}
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+		for _, part := range result.ObjectParts {
+			parts = append(parts, Part{
+				Number:       part.PartNumber,
+				ETag:         part.ETag,
+				Size:         part.Size,
+				LastModified: part.LastModified,
+			})
+		}
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+func (b *S3Blob) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error {
+	complete := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		complete = append(complete, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, err := b.core().CompleteMultipartUpload(ctx, b.bucket, key, uploadID, complete, minio.PutObjectOptions{})
+	if err != nil {
+		if isS3Code(err, "NoSuchUpload") {
+			return ErrNoSuchUpload
+		}
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+func (b *S3Blob) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	err := b.core().AbortMultipartUpload(ctx, b.bucket, key, uploadID)
+	if err != nil && !isS3Code(err, "NoSuchUpload") {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+func (b *S3Blob) ListMultipartUploads(ctx context.Context, prefix string) ([]MultipartUpload, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var uploads []MultipartUpload
+	for info := range b.client.ListIncompleteUploads(ctx, b.bucket, prefix, true) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("failed to list multipart uploads: %w", info.Err)
+		}
+		uploads = append(uploads, MultipartUpload{Key: info.Key, UploadID: info.UploadID, Initiated: info.Initiated})
+	}
+	return uploads, nil
+}
+
+func (b *S3Blob) core() minio.Core {
+	return minio.Core{Client: b.client}
+}
+
+func isS3Code(err error, code string) bool {
+	return minio.ToErrorResponse(err).Code == code
+}
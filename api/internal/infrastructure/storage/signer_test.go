@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedQuery signs method on key and returns the query of the signed URL
+func signedQuery(t *testing.T, signer *URLSigner, method, key string, expiry time.Duration) url.Values {
+	t.Helper()
+	u, err := url.Parse(signer.Sign(method, key, expiry))
+	if err != nil {
+		t.Fatalf("parse signed url: %v", err)
+	}
+	return u.Query()
+}
+
+func TestURLSignerVerify(t *testing.T) {
+	signer := NewURLSigner("http://api.test/api/v1/blobs/", []byte("secret"))
+	const key = "photos/a b.jpg"
+
+	u := signer.Sign(http.MethodPut, key, time.Minute)
+	if !strings.HasPrefix(u, "http://api.test/api/v1/blobs/photos/a%20b.jpg?") {
+		t.Fatalf("signed url %q", u)
+	}
+	if err := signer.Verify(http.MethodPut, key, signedQuery(t, signer, http.MethodPut, key, time.Minute)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	valid := signedQuery(t, signer, http.MethodPut, key, time.Minute)
+	tampered := signedQuery(t, signer, http.MethodPut, key, time.Minute)
+	tampered.Set("expires", tampered.Get("expires")+"0")
+	missing := signedQuery(t, signer, http.MethodPut, key, time.Minute)
+	missing.Del("expires")
+
+	cases := []struct {
+		name   string
+		signer *URLSigner
+		method string
+		key    string
+		query  url.Values
+		want   error
+	}{
+		{"other method", signer, http.MethodGet, key, valid, ErrURLSignature},
+		{"other key", signer, http.MethodPut, "photos/b.jpg", valid, ErrURLSignature},
+		{"other secret", NewURLSigner("http://api.test/api/v1/blobs", []byte("other")), http.MethodPut, key, valid, ErrURLSignature},
+		{"extended expiry", signer, http.MethodPut, key, tampered, ErrURLSignature},
+		{"missing expiry", signer, http.MethodPut, key, missing, ErrURLSignature},
+		{"unsigned", signer, http.MethodPut, key, url.Values{}, ErrURLSignature},
+		{"expired", signer, http.MethodPut, key, signedQuery(t, signer, http.MethodPut, key, -time.Minute), ErrURLExpired},
+	}
+	for _, tc := range cases {
+		if err := tc.signer.Verify(tc.method, tc.key, tc.query); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
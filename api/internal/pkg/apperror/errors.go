@@ -10,6 +10,10 @@ type AppError struct {
 	Err        error
 	StatusCode int
 	Message    string
+
+	// Code and Details are optional machine-readable context for clients
+	Code    string
+	Details interface{}
 }
 
 func (e *AppError) Error() string {
@@ -19,6 +23,17 @@ func (e *AppError) Error() string {
 	return e.Err.Error()
 }
 
+// WithDetails attaches a machine-readable code and details to the error
+func (e *AppError) WithDetails(code string, details interface{}) *AppError {
+	e.Code = code
+	e.Details = details
+	return e
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
 // Constructor functions for common errors
 func BadRequest(err error) *AppError {
 	return &AppError{
@@ -41,6 +56,13 @@ func Unauthorized(err error) *AppError {
 	}
 }
 
+func Forbidden(err error) *AppError {
+	return &AppError{
+		Err:        err,
+		StatusCode: http.StatusForbidden,
+	}
+}
+
 func InternalError(err error) *AppError {
 	return &AppError{
 		Err:        err,
@@ -56,6 +78,48 @@ func Conflict(err error) *AppError {
 	}
 }
 
+func PreconditionFailed(err error) *AppError {
+	return &AppError{
+		Err:        err,
+		StatusCode: http.StatusPreconditionFailed,
+	}
+}
+
+func TooManyRequests(err error) *AppError {
+	return &AppError{
+		Err:        err,
+		StatusCode: http.StatusTooManyRequests,
+	}
+}
+
+func ServiceUnavailable(err error) *AppError {
+	return &AppError{
+		Err:        err,
+		StatusCode: http.StatusServiceUnavailable,
+	}
+}
+
+func PayloadTooLarge(err error) *AppError {
+	return &AppError{
+		Err:        err,
+		StatusCode: http.StatusRequestEntityTooLarge,
+	}
+}
+
+func UnsupportedMediaType(err error) *AppError {
+	return &AppError{
+		Err:        err,
+		StatusCode: http.StatusUnsupportedMediaType,
+	}
+}
+
+func NotImplemented(err error) *AppError {
+	return &AppError{
+		Err:        err,
+		StatusCode: http.StatusNotImplemented,
+	}
+}
+
 // IsAppError checks if an error is an AppError
 func IsAppError(err error) (*AppError, bool) {
 	var appErr *AppError
@@ -63,4 +127,4 @@ func IsAppError(err error) (*AppError, bool) {
 		return appErr, true
 	}
 	return nil, false
-}
\ No newline at end of file
+}
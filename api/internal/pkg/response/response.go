@@ -30,7 +30,14 @@ func Error(c *gin.Context, err error) {
 	}
 
 	if appErr, ok := apperror.IsAppError(err); ok {
-		c.JSON(appErr.StatusCode, gin.H{"error": appErr.Error()})
+		body := gin.H{"error": appErr.Error()}
+		if appErr.Code != "" {
+			body["code"] = appErr.Code
+		}
+		if appErr.Details != nil {
+			body["details"] = appErr.Details
+		}
+		c.JSON(appErr.StatusCode, body)
 		return
 	}
 
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+type AlbumRepository interface {
+	Create(album *domain.Album) error
+	GetByID(id uint) (*domain.Album, error)
+	GetBySlug(slug string) (*domain.Album, error)
+
+	// List albums, optionally of one status. With onlyPublished the photo
+	// count leaves out drafts, for listings shown to visitors
+	List(status string, onlyPublished bool) ([]domain.Album, error)
+	Update(album *domain.Album) error
+	Delete(id uint) error
+
+	// Check whether a slug is used by an album other than excludeID
+	SlugExists(slug string, excludeID uint) (bool, error)
+
+	// Get album members ordered by position, optionally only published photos
+	GetPhotos(albumID uint, onlyPublished bool) ([]domain.AlbumPhoto, error)
+
+	// Replace the whole membership, positions follow the slice order
+	SetPhotos(albumID uint, photoIDs []uint) error
+
+	// Append photos after the current last position, skipping existing members
+	AddPhotos(albumID uint, photoIDs []uint) error
+
+	// Remove a single photo from an album
+	RemovePhoto(albumID, photoID uint) error
+}
+
+type albumRepo struct {
+	db *gorm.DB
+}
+
+func NewAlbumRepository(db *gorm.DB) AlbumRepository {
+	return &albumRepo{db: db}
+}
+
+func (r *albumRepo) Create(album *domain.Album) error {
+	return r.db.Create(album).Error
+}
+
+func (r *albumRepo) GetByID(id uint) (*domain.Album, error) {
+	var album domain.Album
+	err := r.db.Preload("CoverPhoto").First(&album, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+func (r *albumRepo) GetBySlug(slug string) (*domain.Album, error) {
+	var album domain.Album
+	err := r.db.Preload("CoverPhoto").Where("slug = ?", slug).First(&album).Error
+	if err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+func (r *albumRepo) List(status string, onlyPublished bool) ([]domain.Album, error) {
+	var albums []domain.Album
+
+	members := r.db.Model(&domain.AlbumPhoto{}).Select("COUNT(*)").
+		Joins("JOIN photos ON photos.id = album_photos.photo_id AND photos.deleted_at IS NULL").
+		Where("album_photos.album_id = albums.id")
+	if onlyPublished {
+		members = members.Where("photos.status = ?", domain.PhotoStatusPublished)
+	}
+	query := r.db.Model(&domain.Album{}).
+		Select("albums.*, (?) AS photo_count", members).
+		Preload("CoverPhoto")
+	if status != "" {
+		query = query.Where("albums.status = ?", status)
+	}
+
+	err := query.Order("created_at DESC").Find(&albums).Error
+	return albums, err
+}
+
+func (r *albumRepo) Update(album *domain.Album) error {
+	return r.db.Select("title", "slug", "description", "cover_photo_id", "status", "updated_at").
+		Updates(album).Error
+}
+
+func (r *albumRepo) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// First delete all memberships
+		if err := tx.Where("album_id = ?", id).Delete(&domain.AlbumPhoto{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&domain.Album{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *albumRepo) SlugExists(slug string, excludeID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.Album{}).
+		Where("slug = ? AND id <> ?", slug, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *albumRepo) GetPhotos(albumID uint, onlyPublished bool) ([]domain.AlbumPhoto, error) {
+	var albumPhotos []domain.AlbumPhoto
+
+	// Trashed photos stay members so a restore brings them back in place
+	photos := r.db.Model(&domain.Photo{}).Select("id")
+	if onlyPublished {
+		photos = photos.Where("status = ?", domain.PhotoStatusPublished)
+	}
+	query := r.db.Preload("Photo").Preload("Photo.Variants", orderVariants).
+		Where("album_id = ? AND photo_id IN (?)", albumID, photos)
+
+	err := query.Order("position ASC").Find(&albumPhotos).Error
+	return albumPhotos, err
+}
+
+func (r *albumRepo) SetPhotos(albumID uint, photoIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("album_id = ?", albumID).Delete(&domain.AlbumPhoto{}).Error; err != nil {
+			return err
+		}
+		if len(photoIDs) == 0 {
+			return nil
+		}
+
+		members := make([]domain.AlbumPhoto, len(photoIDs))
+		for i, photoID := range photoIDs {
+			members[i] = domain.AlbumPhoto{AlbumID: albumID, PhotoID: photoID, Position: i}
+		}
+		return tx.Create(&members).Error
+	})
+}
+
+func (r *albumRepo) AddPhotos(albumID uint, photoIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []uint
+		if err := tx.Model(&domain.AlbumPhoto{}).
+			Where("album_id = ?", albumID).
+			Pluck("photo_id", &existing).Error; err != nil {
+			return err
+		}
+		isMember := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			isMember[id] = true
+		}
+
+		var maxPosition *int
+		if err := tx.Model(&domain.AlbumPhoto{}).
+			Where("album_id = ?", albumID).
+			Select("MAX(position)").
+			Scan(&maxPosition).Error; err != nil {
+			return err
+		}
+		next := 0
+		if maxPosition != nil {
+			next = *maxPosition + 1
+		}
+
+		var members []domain.AlbumPhoto
+		for _, photoID := range photoIDs {
+			if isMember[photoID] {
+				continue
+			}
+			isMember[photoID] = true
+			members = append(members, domain.AlbumPhoto{AlbumID: albumID, PhotoID: photoID, Position: next})
+			next++
+		}
+		if len(members) == 0 {
+			return nil
+		}
+		return tx.Create(&members).Error
+	})
+}
+
+func (r *albumRepo) RemovePhoto(albumID, photoID uint) error {
+	result := r.db.Where("album_id = ? AND photo_id = ?", albumID, photoID).Delete(&domain.AlbumPhoto{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
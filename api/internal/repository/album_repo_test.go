@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+func TestListCountsOnlyPublishedPhotosForVisitors(t *testing.T) {
+	db := openPhotoTestDB(t)
+	photos := NewPhotoRepository(db)
+	albums := NewAlbumRepository(db)
+
+	members := []domain.Photo{
+		{Title: "published", ImageURL: "https://example.com/1.jpg", Status: domain.PhotoStatusPublished},
+		{Title: "draft", ImageURL: "https://example.com/2.jpg", Status: domain.PhotoStatusDraft},
+		{Title: "trashed", ImageURL: "https://example.com/3.jpg", Status: domain.PhotoStatusPublished},
+	}
+	ids := make([]uint, len(members))
+	for i := range members {
+		if err := photos.Create(&members[i]); err != nil {
+			t.Fatalf("create photo: %v", err)
+		}
+		ids[i] = members[i].ID
+	}
+	if err := db.Delete(&domain.Photo{}, ids[2]).Error; err != nil {
+		t.Fatalf("trash photo: %v", err)
+	}
+
+	album := &domain.Album{Title: "Shanghai", Slug: "shanghai", Status: domain.AlbumStatusPublished}
+	if err := albums.Create(album); err != nil {
+		t.Fatalf("create album: %v", err)
+	}
+	if err := albums.SetPhotos(album.ID, ids); err != nil {
+		t.Fatalf("set photos: %v", err)
+	}
+
+	for _, tc := range []struct {
+		onlyPublished bool
+		want          int64
+	}{{false, 2}, {true, 1}} {
+		list, err := albums.List(string(domain.AlbumStatusPublished), tc.onlyPublished)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(list) != 1 || list[0].PhotoCount != tc.want {
+			t.Errorf("List(onlyPublished=%v) = %+v, want one album with %d photos", tc.onlyPublished, list, tc.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+// lastUsedResolution limits last-used bookkeeping to one write per token per minute
+const lastUsedResolution = time.Minute
+
+type APITokenRepository interface {
+	Create(token *domain.APIToken) error
+	// ListByUser returns the user's tokens that have not been revoked, newest first
+	ListByUser(userID uint) ([]domain.APIToken, error)
+	// GetByTokenHash loads a token with its owner, revoked or not
+	GetByTokenHash(hash string) (*domain.APIToken, error)
+	Revoke(id, userID uint) error
+	// TouchLastUsed records a use, skipping the write if one was recorded recently
+	TouchLastUsed(id uint, ip string, at time.Time) error
+}
+
+type apiTokenRepo struct {
+	db *gorm.DB
+}
+
+func NewAPITokenRepository(db *gorm.DB) APITokenRepository {
+	return &apiTokenRepo{db: db}
+}
+
+func (r *apiTokenRepo) Create(token *domain.APIToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *apiTokenRepo) ListByUser(userID uint) ([]domain.APIToken, error) {
+	var tokens []domain.APIToken
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC, id DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *apiTokenRepo) GetByTokenHash(hash string) (*domain.APIToken, error) {
+	var token domain.APIToken
+	if err := r.db.Preload("User").Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *apiTokenRepo) Revoke(id, userID uint) error {
+	result := r.db.Model(&domain.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *apiTokenRepo) TouchLastUsed(id uint, ip string, at time.Time) error {
+	return r.db.Model(&domain.APIToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ? OR last_used_ip <> ?)", id, at.Add(-lastUsedResolution), ip).
+		Updates(map[string]interface{}{
+			"last_used_at": at,
+			"last_used_ip": ip,
+		}).Error
+}
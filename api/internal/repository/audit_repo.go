@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+const maxAuditPage = 200
+
+// AuditFilters narrows the audit log; BeforeID pages backwards from newest
+type AuditFilters struct {
+	ActorID    uint
+	Action     domain.AuditAction
+	EntityType domain.AuditEntityType
+	EntityID   string
+	From       *time.Time
+	To         *time.Time
+	BeforeID   uint
+	Limit      int
+}
+
+// AuditRepository only appends and reads; the table rejects updates and deletes
+type AuditRepository interface {
+	Create(event *domain.AuditEvent) error
+	List(filters AuditFilters) ([]domain.AuditEvent, error)
+}
+
+type auditRepo struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepo{db: db}
+}
+
+func (r *auditRepo) Create(event *domain.AuditEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *auditRepo) List(filters AuditFilters) ([]domain.AuditEvent, error) {
+	var events []domain.AuditEvent
+
+	query := r.db.Model(&domain.AuditEvent{})
+	if filters.ActorID > 0 {
+		query = query.Where("actor_id = ?", filters.ActorID)
+	}
+	if filters.Action != "" {
+		query = query.Where("action = ?", filters.Action)
+	}
+	if filters.EntityType != "" {
+		query = query.Where("entity_type = ?", filters.EntityType)
+	}
+	if filters.EntityID != "" {
+		query = query.Where("entity_id = ?", filters.EntityID)
+	}
+	if filters.From != nil {
+		query = query.Where("created_at >= ?", *filters.From)
+	}
+	if filters.To != nil {
+		query = query.Where("created_at < ?", *filters.To)
+	}
+	if filters.BeforeID > 0 {
+		query = query.Where("id < ?", filters.BeforeID)
+	}
+
+	limit := filters.Limit
+	if limit <= 0 || limit > maxAuditPage {
+		limit = maxAuditPage
+	}
+
+	err := query.Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// MigrateAuditEvents makes audit_events append-only at the database level,
+// so neither the application nor a stray query can rewrite history
+func MigrateAuditEvents(db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_events is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_events_no_modify ON audit_events`,
+		`CREATE TRIGGER audit_events_no_modify BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE FUNCTION audit_events_append_only()`,
+		`DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events`,
+		`CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+			FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only()`,
+	}
+
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -9,8 +9,8 @@ type ComponentPhotoRepository interface {
 	// Assign a photo to a component
 	Assign(componentPhoto *domain.ComponentPhoto) error
 
-	// Update component photo association
-	Update(id uint, componentPhoto *domain.ComponentPhoto) error
+	// Update order and props if the stored version still matches, bumping it
+	Update(componentPhoto *domain.ComponentPhoto) error
 
 	// Remove photo from component
 	Remove(id uint) error
@@ -40,10 +40,23 @@ func (r *componentPhotoRepository) Assign(componentPhoto *domain.ComponentPhoto)
 	return r.db.Create(componentPhoto).Error
 }
 
-func (r *componentPhotoRepository) Update(id uint, componentPhoto *domain.ComponentPhoto) error {
-	return r.db.Model(&domain.ComponentPhoto{}).
-		Where("id = ?", id).
-		Updates(componentPhoto).Error
+func (r *componentPhotoRepository) Update(componentPhoto *domain.ComponentPhoto) error {
+	expected := componentPhoto.Version
+	componentPhoto.Version++
+
+	result := r.db.Model(componentPhoto).
+		Where("version = ?", expected).
+		Select("order", "props", "version", "updated_at").
+		Updates(componentPhoto)
+	if result.Error != nil {
+		componentPhoto.Version = expected
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		componentPhoto.Version = expected
+		return ErrVersionConflict
+	}
+	return nil
 }
 
 func (r *componentPhotoRepository) Remove(id uint) error {
@@ -52,8 +65,10 @@ func (r *componentPhotoRepository) Remove(id uint) error {
 
 func (r *componentPhotoRepository) GetByComponentName(componentName string) ([]domain.ComponentPhoto, error) {
 	var componentPhotos []domain.ComponentPhoto
+	// Skip photos that are in the trash
 	err := r.db.Preload("Photo").
 		Where("component_name = ?", componentName).
+		Where("photo_id IN (?)", r.db.Model(&domain.Photo{}).Select("id")).
 		Order("\"order\" ASC").
 		Find(&componentPhotos).Error
 	return componentPhotos, err
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+const maxLoginAttemptPage = 200
+
+// LoginAttemptFilters narrows the attempt log; BeforeID pages backwards from newest
+type LoginAttemptFilters struct {
+	Username string
+	IP       string
+	Result   domain.LoginAttemptResult
+	BeforeID uint
+	Limit    int
+}
+
+type LoginAttemptRepository interface {
+	Create(attempt *domain.LoginAttempt) error
+	List(filters LoginAttemptFilters) ([]domain.LoginAttempt, error)
+}
+
+type loginAttemptRepo struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepo{db: db}
+}
+
+func (r *loginAttemptRepo) Create(attempt *domain.LoginAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+func (r *loginAttemptRepo) List(filters LoginAttemptFilters) ([]domain.LoginAttempt, error) {
+	var attempts []domain.LoginAttempt
+
+	query := r.db.Model(&domain.LoginAttempt{})
+	if filters.Username != "" {
+		query = query.Where("LOWER(username) = LOWER(?)", filters.Username)
+	}
+	if filters.IP != "" {
+		query = query.Where("ip = ?", filters.IP)
+	}
+	if filters.Result != "" {
+		query = query.Where("result = ?", filters.Result)
+	}
+	if filters.BeforeID > 0 {
+		query = query.Where("id < ?", filters.BeforeID)
+	}
+
+	limit := filters.Limit
+	if limit <= 0 || limit > maxLoginAttemptPage {
+		limit = maxLoginAttemptPage
+	}
+
+	err := query.Order("id DESC").Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
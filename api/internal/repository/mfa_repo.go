@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+type MFARepository interface {
+	// ReplaceRecoveryCodes drops the user's existing codes and stores the new hashes
+	ReplaceRecoveryCodes(userID uint, hashes []string) error
+	// UseRecoveryCode marks an unused code as used, or returns gorm.ErrRecordNotFound
+	UseRecoveryCode(userID uint, hash string) error
+	// DeleteForUser removes the user's recovery codes and pending challenges
+	DeleteForUser(userID uint) error
+
+	CreateChallenge(challenge *domain.MFAChallenge) error
+	GetChallenge(tokenHash string) (*domain.MFAChallenge, error)
+	// IncrementChallengeAttempts counts a wrong code and returns the new total
+	IncrementChallengeAttempts(id uint) (int, error)
+	// DeleteChallenge consumes a challenge; gorm.ErrRecordNotFound means it was already used
+	DeleteChallenge(id uint) error
+	DeleteExpiredChallenges(before time.Time) error
+}
+
+type mfaRepo struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepo{db: db}
+}
+
+func (r *mfaRepo) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(hashes) == 0 {
+			return nil
+		}
+
+		codes := make([]domain.RecoveryCode, len(hashes))
+		for i, hash := range hashes {
+			codes[i] = domain.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *mfaRepo) UseRecoveryCode(userID uint, hash string) error {
+	result := r.db.Model(&domain.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepo) DeleteForUser(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&domain.MFAChallenge{}).Error
+	})
+}
+
+func (r *mfaRepo) CreateChallenge(challenge *domain.MFAChallenge) error {
+	return r.db.Create(challenge).Error
+}
+
+func (r *mfaRepo) GetChallenge(tokenHash string) (*domain.MFAChallenge, error) {
+	var challenge domain.MFAChallenge
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *mfaRepo) IncrementChallengeAttempts(id uint) (int, error) {
+	var challenge domain.MFAChallenge
+	err := r.db.Model(&challenge).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "attempts"}}}).
+		Where("id = ?", id).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+	return challenge.Attempts, err
+}
+
+func (r *mfaRepo) DeleteChallenge(id uint) error {
+	result := r.db.Delete(&domain.MFAChallenge{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepo) DeleteExpiredChallenges(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&domain.MFAChallenge{}).Error
+}
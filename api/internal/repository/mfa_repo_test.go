@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+func openUserTestDB(t *testing.T) (*gorm.DB, *domain.User) {
+	t.Helper()
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&domain.User{}, &domain.RecoveryCode{}, &domain.MFAChallenge{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	user := &domain.User{Username: "alice", Password: "x", Email: "alice@example.com"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return db, user
+}
+
+func TestUseTOTPStepOnlyMovesForward(t *testing.T) {
+	db, user := openUserTestDB(t)
+	users := NewUserRepository(db)
+
+	if err := users.EnableTOTP(user.ID, 100); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	// The code that enabled two-factor login cannot sign in
+	for _, step := range []int64{99, 100} {
+		if err := users.UseTOTPStep(user.ID, step); !errors.Is(err, ErrTOTPStepUsed) {
+			t.Fatalf("step %d: err = %v, want ErrTOTPStepUsed", step, err)
+		}
+	}
+	if err := users.UseTOTPStep(user.ID, 101); err != nil {
+		t.Fatalf("step 101: %v", err)
+	}
+	if err := users.UseTOTPStep(user.ID, 101); !errors.Is(err, ErrTOTPStepUsed) {
+		t.Fatalf("step 101 again: err = %v, want ErrTOTPStepUsed", err)
+	}
+}
+
+func TestUseRecoveryCodeConsumesIt(t *testing.T) {
+	db, user := openUserTestDB(t)
+	mfa := NewMFARepository(db)
+
+	if err := mfa.ReplaceRecoveryCodes(user.ID, []string{"hash-a", "hash-b"}); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if err := mfa.UseRecoveryCode(user.ID, "hash-a"); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := mfa.UseRecoveryCode(user.ID, "hash-a"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("second use: err = %v, want ErrRecordNotFound", err)
+	}
+	if err := mfa.UseRecoveryCode(user.ID+1, "hash-b"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("another user's code: err = %v, want ErrRecordNotFound", err)
+	}
+
+	if err := mfa.ReplaceRecoveryCodes(user.ID, []string{"hash-c"}); err != nil {
+		t.Fatalf("replace again: %v", err)
+	}
+	if err := mfa.UseRecoveryCode(user.ID, "hash-b"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("replaced code: err = %v, want ErrRecordNotFound", err)
+	}
+}
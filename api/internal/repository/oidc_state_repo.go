@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+type OIDCStateRepository interface {
+	Create(state *domain.OIDCLoginState) error
+	// Consume deletes and returns a login state; gorm.ErrRecordNotFound means
+	// it is unknown or was already used
+	Consume(stateHash string) (*domain.OIDCLoginState, error)
+	DeleteExpired(before time.Time) error
+}
+
+type oidcStateRepo struct {
+	db *gorm.DB
+}
+
+func NewOIDCStateRepository(db *gorm.DB) OIDCStateRepository {
+	return &oidcStateRepo{db: db}
+}
+
+func (r *oidcStateRepo) Create(state *domain.OIDCLoginState) error {
+	return r.db.Create(state).Error
+}
+
+func (r *oidcStateRepo) Consume(stateHash string) (*domain.OIDCLoginState, error) {
+	var state domain.OIDCLoginState
+	result := r.db.Clauses(clause.Returning{}).Where("state_hash = ?", stateHash).Delete(&state)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &state, nil
+}
+
+func (r *oidcStateRepo) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&domain.OIDCLoginState{}).Error
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+type PasswordResetRepository interface {
+	Create(token *domain.PasswordResetToken) error
+	GetByTokenHash(hash string) (*domain.PasswordResetToken, error)
+	// MarkUsed consumes an unused token; gorm.ErrRecordNotFound means it was already used
+	MarkUsed(id uint) error
+	// CountSince counts the tokens issued to the user since the given time
+	CountSince(userID uint, since time.Time) (int64, error)
+	// InvalidateForUser marks every unused token of the user as used
+	InvalidateForUser(userID uint) error
+	DeleteExpired(before time.Time) error
+}
+
+type passwordResetRepo struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepo{db: db}
+}
+
+func (r *passwordResetRepo) Create(token *domain.PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *passwordResetRepo) GetByTokenHash(hash string) (*domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+	if err := r.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *passwordResetRepo) MarkUsed(id uint) error {
+	result := r.db.Model(&domain.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *passwordResetRepo) CountSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.PasswordResetToken{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *passwordResetRepo) InvalidateForUser(userID uint) error {
+	return r.db.Model(&domain.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+func (r *passwordResetRepo) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&domain.PasswordResetToken{}).Error
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+type cursorDirection string
+
+const (
+	cursorNext cursorDirection = "next"
+	cursorPrev cursorDirection = "prev"
+)
+
+// sortKey is one column of a keyset ordering. The last key of an ordering
+// must be unique (photos.id) so every row has a distinct position.
+type sortKey struct {
+	Column string        // SQL expression used in ORDER BY and keyset WHERE
+	Args   []interface{} // bind args referenced by Column, if any
+	Desc   bool
+	value  func(p *domain.Photo) interface{}
+	decode func(raw json.RawMessage) (interface{}, error)
+}
+
+// photoCursor is serialized as base64url JSON; clients must treat it as opaque
+type photoCursor struct {
+	Direction cursorDirection   `json:"d"`
+	Values    []json.RawMessage `json:"v"`
+}
+
+var (
+	idKey = sortKey{
+		Column: "photos.id",
+		value:  func(p *domain.Photo) interface{} { return p.ID },
+		decode: decodeAs[uint],
+	}
+	displayOrderKey = sortKey{
+		Column: "photos.display_order",
+		value:  func(p *domain.Photo) interface{} { return p.DisplayOrder },
+		decode: decodeAs[int],
+	}
+)
+
+// searchRankKey orders search results by relevance, best match first
+func searchRankKey(q string) sortKey {
+	column, args := newSearchQuery(q).rank()
+	return sortKey{
+		Column: column,
+		Args:   args,
+		Desc:   true,
+		value:  func(p *domain.Photo) interface{} { return p.SearchRank },
+		decode: decodeAs[float64],
+	}
+}
+
+func decodeAs[T any](raw json.RawMessage) (interface{}, error) {
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func encodeCursor(direction cursorDirection, keys []sortKey, photo *domain.Photo) string {
+	cursor := photoCursor{Direction: direction, Values: make([]json.RawMessage, len(keys))}
+	for i, key := range keys {
+		raw, _ := json.Marshal(key.value(photo))
+		cursor.Values[i] = raw
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string, keys []sortKey) (cursorDirection, []interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, ErrInvalidCursor
+	}
+
+	var cursor photoCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return "", nil, ErrInvalidCursor
+	}
+	if cursor.Direction != cursorNext && cursor.Direction != cursorPrev {
+		return "", nil, ErrInvalidCursor
+	}
+	// A cursor from a differently sorted listing cannot be applied
+	if len(cursor.Values) != len(keys) {
+		return "", nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		v, err := key.decode(cursor.Values[i])
+		if err != nil {
+			return "", nil, ErrInvalidCursor
+		}
+		values[i] = v
+	}
+	return cursor.Direction, values, nil
+}
+
+// keysetCondition builds the WHERE clause selecting rows after (or before)
+// the cursor position:
+//
+//	(k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+//
+// with > / < flipped for descending keys and for backward paging.
+func keysetCondition(keys []sortKey, values []interface{}, direction cursorDirection) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	for i := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j].Column+" = ?")
+			args = append(args, keys[j].Args...)
+			args = append(args, values[j])
+		}
+
+		after := !keys[i].Desc
+		if direction == cursorPrev {
+			after = !after
+		}
+		op := " > ?"
+		if !after {
+			op = " < ?"
+		}
+		parts = append(parts, keys[i].Column+op)
+		args = append(args, keys[i].Args...)
+		args = append(args, values[i])
+
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+// orderExpression renders keys as an ORDER BY list, reversed for backward paging
+func orderExpression(keys []sortKey, reverse bool) (string, []interface{}) {
+	parts := make([]string, len(keys))
+	var args []interface{}
+	for i, key := range keys {
+		desc := key.Desc != reverse
+		dir := " ASC"
+		if desc {
+			dir = " DESC"
+		}
+		parts[i] = key.Column + dir
+		args = append(args, key.Args...)
+	}
+	return strings.Join(parts, ", "), args
+}
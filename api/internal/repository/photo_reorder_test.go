@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+func TestBatchUpdateDisplayOrderChecksInsideTransaction(t *testing.T) {
+	db := openPhotoTestDB(t)
+	repo := NewPhotoRepository(db)
+
+	photos := []domain.Photo{
+		{Title: "a", DisplayOrder: 0, ImageURL: "https://example.com/a.jpg"},
+		{Title: "b", DisplayOrder: 1, ImageURL: "https://example.com/b.jpg"},
+		{Title: "c", DisplayOrder: 2, ImageURL: "https://example.com/c.jpg"},
+	}
+	for i := range photos {
+		if err := repo.Create(&photos[i]); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	a, b, c := photos[0], photos[1], photos[2]
+	stale := a.Version
+
+	// First writer wins and bumps the version
+	result, err := repo.BatchUpdateDisplayOrder([]DisplayOrderUpdate{{ID: a.ID, Order: 5, Version: &stale}}, false)
+	if err != nil {
+		t.Fatalf("first reorder: %v", err)
+	}
+	if got := versionOf(result, a.ID); got != stale+1 {
+		t.Fatalf("version after reorder = %d, want %d", got, stale+1)
+	}
+
+	// A second writer holding the old version is rejected and writes nothing
+	_, err = repo.BatchUpdateDisplayOrder([]DisplayOrderUpdate{
+		{ID: a.ID, Order: 6, Version: &stale},
+		{ID: b.ID, Order: 7},
+	}, false)
+	var rejection *ReorderRejection
+	if !errors.As(err, &rejection) || len(rejection.Stale) != 1 || rejection.Stale[0].ID != a.ID {
+		t.Fatalf("stale reorder: err = %v, want a stale rejection for %d", err, a.ID)
+	}
+	if *rejection.Stale[0].Version != stale+1 {
+		t.Fatalf("stale rejection reports version %d, want %d", *rejection.Stale[0].Version, stale+1)
+	}
+	if got := orderOf(t, repo, b.ID); got != 1 {
+		t.Fatalf("rejected batch moved photo b to %d", got)
+	}
+
+	// Taking an order held outside the request is a conflict
+	_, err = repo.BatchUpdateDisplayOrder([]DisplayOrderUpdate{{ID: b.ID, Order: 2}}, false)
+	if !errors.As(err, &rejection) || len(rejection.Conflicts) != 1 || rejection.Conflicts[0].ID != c.ID {
+		t.Fatalf("conflicting reorder: err = %v, want a conflict with %d", err, c.ID)
+	}
+
+	// Trashed photos count as missing
+	if err := repo.Delete(c.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	_, err = repo.BatchUpdateDisplayOrder([]DisplayOrderUpdate{{ID: c.ID, Order: 9}}, true)
+	if !errors.As(err, &rejection) || len(rejection.Missing) != 1 || rejection.Missing[0] != c.ID {
+		t.Fatalf("reorder of trashed photo: err = %v, want %d missing", err, c.ID)
+	}
+}
+
+func versionOf(sequence []DisplayOrderUpdate, id uint) int {
+	for _, item := range sequence {
+		if item.ID == id && item.Version != nil {
+			return *item.Version
+		}
+	}
+	return -1
+}
+
+func orderOf(t *testing.T, repo PhotoRepository, id uint) int {
+	t.Helper()
+	photo, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("get %d: %v", id, err)
+	}
+	return photo.DisplayOrder
+}
@@ -2,8 +2,13 @@ package repository
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/aton/atonWeb/api/internal/domain"
 )
@@ -11,25 +16,65 @@ import (
 type PhotoRepository interface {
 	Create(photo *domain.Photo) error
 	GetByID(id uint) (*domain.Photo, error)
-	List(filters PhotoFilters) ([]domain.Photo, int64, error)
+	List(filters PhotoFilters) (*PhotoPage, error)
 	Update(photo *domain.Photo) error
+	// Delete moves the photo to the trash; Purge removes it for good
 	Delete(id uint) error
+	ListTrashed(beforeID uint, limit int) ([]domain.Photo, error)
+	Restore(id uint) error
+	ListPurgeable(deletedBefore time.Time, limit int) ([]domain.Photo, error)
+	Purge(id uint, deletedBefore time.Time, removeObjects func(*domain.Photo) error) error
 	UpdateDisplayOrder(id uint, order int) error
-	BatchUpdateDisplayOrder(orders []DisplayOrderUpdate) error
+	BatchUpdateDisplayOrder(orders []DisplayOrderUpdate, renumber bool) ([]DisplayOrderUpdate, error)
+	UpdateProcessingStatus(id uint, status domain.PhotoProcessingStatus) error
+	ReplaceVariants(photo *domain.Photo, variants []domain.PhotoVariant) error
+	SaveMetadata(metadata *domain.PhotoMetadata) error
+	DeleteMetadata(photoID uint) error
+	ExistingIDs(ids []uint) ([]uint, error)
+	ObjectKeyInUse(objectKey string, excludeID uint) (bool, error)
 }
 
 type PhotoFilters struct {
 	Status     string
 	Category   string
 	IsFeatured *bool
-	Limit      int
-	Offset     int
-	OrderBy    string
+
+	// EXIF filters: Camera matches make or model, capture range is inclusive
+	Camera       string
+	CapturedFrom *time.Time
+	CapturedTo   *time.Time
+
+	// Tag slugs; TagsMode "any" matches photos with at least one tag, "all" requires every tag
+	Tags     []string
+	TagsMode domain.TagsMode
+
+	// Full-text query over title, description, location and category
+	Query string
+
+	// Keyset pagination: Cursor comes from a previous page's NextCursor/PrevCursor.
+	// Limit <= 0 returns every matching photo; PhotoService always sets a page size.
+	Limit     int
+	Cursor    string
+	WithCount bool
+
+	// Sort fields from the allowlist; empty means relevance for searches, else display order
+	Sort SortSpec
 }
 
+// PhotoPage is one page of a keyset-paginated photo listing
+type PhotoPage struct {
+	Photos     []domain.Photo
+	Total      *int64 // only set when PhotoFilters.WithCount is true
+	NextCursor string
+	PrevCursor string
+}
+
+// DisplayOrderUpdate moves a photo to an order. Version is optional in
+// requests, like If-Match, and always set in the resulting sequence.
 type DisplayOrderUpdate struct {
-	ID    uint
-	Order int
+	ID      uint `json:"id"`
+	Order   int  `json:"order"`
+	Version *int `json:"version,omitempty"`
 }
 
 type photoRepo struct {
@@ -46,16 +91,15 @@ func (r *photoRepo) Create(photo *domain.Photo) error {
 
 func (r *photoRepo) GetByID(id uint) (*domain.Photo, error) {
 	var photo domain.Photo
-	err := r.db.First(&photo, id).Error
+	err := r.db.Preload("Metadata").Preload("Tags").Preload("Variants", orderVariants).First(&photo, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &photo, nil
 }
 
-func (r *photoRepo) List(filters PhotoFilters) ([]domain.Photo, int64, error) {
-	var photos []domain.Photo
-	var total int64
+func (r *photoRepo) List(filters PhotoFilters) (*PhotoPage, error) {
+	page := &PhotoPage{}
 
 	query := r.db.Model(&domain.Photo{})
 
@@ -69,70 +113,564 @@ func (r *photoRepo) List(filters PhotoFilters) ([]domain.Photo, int64, error) {
 	if filters.IsFeatured != nil {
 		query = query.Where("is_featured = ?", *filters.IsFeatured)
 	}
+	query = applyMetadataFilters(query, filters)
+	query = applyTagFilters(query, filters)
+	if filters.Query != "" {
+		query = matchSearch(query, filters.Query)
+	}
 
-	// Count total
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	// Count is optional: it scans the whole filtered set
+	if filters.WithCount {
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
 	}
 
-	// Apply ordering
-	orderBy := "display_order ASC"
-	if filters.OrderBy != "" {
-		orderBy = filters.OrderBy
+	if filters.Query != "" {
+		query = applySearch(query, filters.Query)
 	}
-	query = query.Order(orderBy)
 
-	// Apply pagination
+	keys, err := sortKeysFor(filters.Sort, filters.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	direction := cursorNext
+	if filters.Cursor != "" {
+		dir, values, err := decodeCursor(filters.Cursor, keys)
+		if err != nil {
+			return nil, err
+		}
+		direction = dir
+		condition, args := keysetCondition(keys, values, direction)
+		query = query.Where(condition, args...)
+	}
+
+	orderSQL, orderArgs := orderExpression(keys, direction == cursorPrev)
+	query = query.Order(clause.OrderBy{Expression: clause.Expr{SQL: orderSQL, Vars: orderArgs, WithoutParentheses: true}})
+
+	// Fetch one extra row to know whether another page exists
 	if filters.Limit > 0 {
-		query = query.Limit(filters.Limit)
+		query = query.Limit(filters.Limit + 1)
 	}
-	if filters.Offset > 0 {
-		query = query.Offset(filters.Offset)
+
+	var photos []domain.Photo
+	if err := query.Preload("Tags").Preload("Variants", orderVariants).Find(&photos).Error; err != nil {
+		return nil, err
 	}
 
-	err := query.Find(&photos).Error
-	return photos, total, err
+	hasMore := filters.Limit > 0 && len(photos) > filters.Limit
+	if hasMore {
+		photos = photos[:filters.Limit]
+	}
+	if direction == cursorPrev {
+		for i, j := 0, len(photos)-1; i < j; i, j = i+1, j-1 {
+			photos[i], photos[j] = photos[j], photos[i]
+		}
+	}
+	page.Photos = photos
+
+	if len(photos) > 0 {
+		first, last := &photos[0], &photos[len(photos)-1]
+		if direction == cursorNext {
+			if hasMore {
+				page.NextCursor = encodeCursor(cursorNext, keys, last)
+			}
+			if filters.Cursor != "" {
+				page.PrevCursor = encodeCursor(cursorPrev, keys, first)
+			}
+		} else {
+			if hasMore {
+				page.PrevCursor = encodeCursor(cursorPrev, keys, first)
+			}
+			page.NextCursor = encodeCursor(cursorNext, keys, last)
+		}
+	}
+
+	return page, nil
 }
 
+// photoEditableColumns are the columns Update writes. Dimensions, the
+// generated thumbnail and the processing status belong to the processor.
+var photoEditableColumns = []string{
+	"title", "description", "image_url", "object_key", "thumbnail_url", "category",
+	"location", "is_featured", "display_order", "status", "version", "updated_at",
+}
+
+// Update writes the editable columns if the stored version still equals
+// photo.Version, then bumps it. Returns ErrVersionConflict when another write
+// got there first.
 func (r *photoRepo) Update(photo *domain.Photo) error {
-	return r.db.Save(photo).Error
+	expected := photo.Version
+	photo.Version++
+
+	result := r.db.Model(photo).Where("version = ?", expected).
+		Select(photoEditableColumns).Updates(photo)
+	if result.Error != nil {
+		photo.Version = expected
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		photo.Version = expected
+		return ErrVersionConflict
+	}
+	return nil
 }
 
 func (r *photoRepo) Delete(id uint) error {
+	result := r.db.Delete(&domain.Photo{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// maxTrashPage bounds one page of the trash listing
+const maxTrashPage = 200
+
+// ListTrashed returns soft-deleted photos, most recently deleted first.
+// Pass the last photo's ID as beforeID to continue after it.
+func (r *photoRepo) ListTrashed(beforeID uint, limit int) ([]domain.Photo, error) {
+	var photos []domain.Photo
+	query := r.db.Unscoped().Preload("Variants", orderVariants).
+		Where("deleted_at IS NOT NULL")
+	if beforeID > 0 {
+		query = query.Where("(deleted_at, id) < (SELECT deleted_at, id FROM photos WHERE id = ?)", beforeID)
+	}
+	if limit <= 0 || limit > maxTrashPage {
+		limit = maxTrashPage
+	}
+	err := query.Order("deleted_at DESC, id DESC").Limit(limit).Find(&photos).Error
+	return photos, err
+}
+
+// Restore takes a photo out of the trash
+func (r *photoRepo) Restore(id uint) error {
+	result := r.db.Unscoped().Model(&domain.Photo{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListPurgeable returns trashed photos deleted before the cutoff, with their
+// variants so the caller can remove the stored objects first
+func (r *photoRepo) ListPurgeable(deletedBefore time.Time, limit int) ([]domain.Photo, error) {
+	var photos []domain.Photo
+	err := r.db.Unscoped().Preload("Variants").
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+		Order("deleted_at ASC, id ASC").
+		Limit(limit).
+		Find(&photos).Error
+	return photos, err
+}
+
+// Purge permanently deletes a photo that is still in the trash and was
+// deleted before the cutoff. The row stays locked from the check to the
+// delete, so a concurrent restore either wins before the purge starts or
+// finds the photo gone; gorm.ErrRecordNotFound means the photo was restored
+// or trashed again. removeObjects runs under the lock with the current
+// variants and rolls the purge back if it fails.
+func (r *photoRepo) Purge(id uint, deletedBefore time.Time, removeObjects func(*domain.Photo) error) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
+		var photo domain.Photo
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			First(&photo, id).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("photo_id = ?", id).Find(&photo.Variants).Error; err != nil {
+			return err
+		}
+		if err := removeObjects(&photo); err != nil {
+			return err
+		}
+
 		// First delete all component_photos associations
 		if err := tx.Where("photo_id = ?", id).Delete(&domain.ComponentPhoto{}).Error; err != nil {
 			return err
 		}
-		// Then delete the photo
-		return tx.Delete(&domain.Photo{}, id).Error
+		// Remove album memberships and unset album covers pointing at this photo
+		if err := tx.Where("photo_id = ?", id).Delete(&domain.AlbumPhoto{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.Album{}).Where("cover_photo_id = ?", id).Update("cover_photo_id", nil).Error; err != nil {
+			return err
+		}
+		// Remove tag links
+		if err := tx.Where("photo_id = ?", id).Delete(&domain.PhotoTag{}).Error; err != nil {
+			return err
+		}
+		// Drop extracted EXIF metadata
+		if err := tx.Where("photo_id = ?", id).Delete(&domain.PhotoMetadata{}).Error; err != nil {
+			return err
+		}
+		// Drop generated variant records
+		if err := tx.Where("photo_id = ?", id).Delete(&domain.PhotoVariant{}).Error; err != nil {
+			return err
+		}
+		// Then delete the locked photo itself
+		return tx.Unscoped().Delete(&domain.Photo{}, id).Error
 	})
 }
 
 func (r *photoRepo) UpdateDisplayOrder(id uint, order int) error {
-	return r.db.Model(&domain.Photo{}).Where("id = ?", id).Update("display_order", order).Error
+	return r.db.Model(&domain.Photo{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"display_order": order,
+		"version":       gorm.Expr("version + 1"),
+	}).Error
 }
 
-func (r *photoRepo) BatchUpdateDisplayOrder(orders []DisplayOrderUpdate) error {
-	if len(orders) == 0 {
-		return nil
-	}
+// displayOrderLock is the advisory lock key held while a batch reorder checks
+// and writes display orders, so concurrent reorders see each other's result
+const displayOrderLock = 0x70686f746f
+
+// ReorderRejection lists why a batch reorder was not applied; nothing was written
+type ReorderRejection struct {
+	Missing   []uint               // photos that do not exist or are in the trash
+	Stale     []DisplayOrderUpdate // current state of photos whose version did not match
+	Conflicts []DisplayOrderUpdate // photos outside the request holding a requested order
+}
+
+func (e *ReorderRejection) Error() string {
+	return fmt.Sprintf("reorder rejected: %d missing, %d stale, %d conflicting", len(e.Missing), len(e.Stale), len(e.Conflicts))
+}
+
+// BatchUpdateDisplayOrder applies the new orders and, when renumber is set,
+// rewrites the whole sequence densely from 0. Photos in the request win ties
+// against untouched photos. Returns the resulting sequence.
+//
+// The checks are part of the UPDATE itself: a row is only written if it
+// exists, its version matches when one is given and, without renumber, no
+// other photo holds the requested order. If any row is skipped the
+// transaction rolls back with a *ReorderRejection.
+func (r *photoRepo) BatchUpdateDisplayOrder(orders []DisplayOrderUpdate, renumber bool) ([]DisplayOrderUpdate, error) {
+	var result []DisplayOrderUpdate
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", displayOrderLock).Error; err != nil {
+			return err
+		}
 
-	// Build CASE WHEN SQL for batch update
-	return r.db.Transaction(func(tx *gorm.DB) error {
 		ids := make([]uint, len(orders))
-		cases := ""
 		for i, update := range orders {
 			ids[i] = update.ID
-			cases += fmt.Sprintf("WHEN id = %d THEN %d ", update.ID, update.Order)
 		}
 
-		// Single SQL: UPDATE photos SET display_order = CASE ... END WHERE id IN (...)
-		sql := fmt.Sprintf(
-			"UPDATE photos SET display_order = CASE %s END WHERE id IN (?)",
-			cases,
-		)
+		if len(orders) > 0 {
+			updated, err := applyDisplayOrders(tx, orders, ids, !renumber)
+			if err != nil {
+				return err
+			}
+			if len(updated) != len(orders) {
+				return reorderRejection(tx, orders, ids, updated)
+			}
+		}
+
+		if renumber {
+			moved := "FALSE"
+			var renumberArgs []interface{}
+			if len(ids) > 0 {
+				moved = "id IN ?"
+				renumberArgs = append(renumberArgs, ids)
+			}
+			sql := "UPDATE photos SET display_order = r.position, version = version + 1, updated_at = NOW() " +
+				"FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY display_order, " + moved + " DESC, id) - 1 AS position FROM photos WHERE deleted_at IS NULL) AS r " +
+				"WHERE photos.id = r.id AND photos.display_order <> r.position"
+			if err := tx.Exec(sql, renumberArgs...).Error; err != nil {
+				return err
+			}
+		}
+
+		var photos []domain.Photo
+		if err := tx.Model(&domain.Photo{}).Select("id", "display_order", "version").
+			Order("display_order ASC, id ASC").Find(&photos).Error; err != nil {
+			return err
+		}
+		result = make([]DisplayOrderUpdate, len(photos))
+		for i, p := range photos {
+			version := p.Version
+			result[i] = DisplayOrderUpdate{ID: p.ID, Order: p.DisplayOrder, Version: &version}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// applyDisplayOrders writes every order whose row passes the checks in a
+// single statement and returns the IDs it updated
+func applyDisplayOrders(tx *gorm.DB, orders []DisplayOrderUpdate, ids []uint, exclusive bool) ([]uint, error) {
+	rows := make([]string, len(orders))
+	args := make([]interface{}, 0, len(orders)*3+1)
+	for i, update := range orders {
+		rows[i] = "(?::bigint, ?::integer, ?::integer)"
+		args = append(args, update.ID, update.Order, update.Version)
+	}
+
+	sql := "UPDATE photos SET display_order = v.display_order, version = photos.version + 1, updated_at = NOW() " +
+		"FROM (VALUES " + strings.Join(rows, ", ") + ") AS v(id, display_order, version) " +
+		"WHERE photos.id = v.id AND photos.deleted_at IS NULL " +
+		"AND (v.version IS NULL OR photos.version = v.version)"
+	if exclusive {
+		sql += " AND NOT EXISTS (SELECT 1 FROM photos AS holder WHERE holder.display_order = v.display_order " +
+			"AND holder.deleted_at IS NULL AND holder.id NOT IN ?)"
+		args = append(args, ids)
+	}
+	sql += " RETURNING photos.id"
 
-		return tx.Exec(sql, ids).Error
+	var updated []uint
+	if err := tx.Raw(sql, args...).Scan(&updated).Error; err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// reorderRejection explains, from inside the reorder transaction, why rows
+// were skipped by applyDisplayOrders
+func reorderRejection(tx *gorm.DB, orders []DisplayOrderUpdate, ids []uint, updated []uint) error {
+	applied := make(map[uint]bool, len(updated))
+	for _, id := range updated {
+		applied[id] = true
+	}
+
+	var current []domain.Photo
+	if err := tx.Model(&domain.Photo{}).Select("id", "display_order", "version").
+		Where("id IN ?", ids).Find(&current).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]domain.Photo, len(current))
+	for _, p := range current {
+		byID[p.ID] = p
+	}
+
+	rejection := &ReorderRejection{}
+	var conflicting []int
+	for _, update := range orders {
+		if applied[update.ID] {
+			continue
+		}
+		p, ok := byID[update.ID]
+		switch {
+		case !ok:
+			rejection.Missing = append(rejection.Missing, update.ID)
+		case update.Version != nil && *update.Version != p.Version:
+			version := p.Version
+			rejection.Stale = append(rejection.Stale, DisplayOrderUpdate{ID: p.ID, Order: p.DisplayOrder, Version: &version})
+		default:
+			conflicting = append(conflicting, update.Order)
+		}
+	}
+
+	if len(conflicting) > 0 {
+		var holders []domain.Photo
+		if err := tx.Model(&domain.Photo{}).Select("id", "display_order").
+			Where("display_order IN ? AND id NOT IN ?", conflicting, ids).
+			Order("display_order ASC, id ASC").Find(&holders).Error; err != nil {
+			return err
+		}
+		for _, p := range holders {
+			rejection.Conflicts = append(rejection.Conflicts, DisplayOrderUpdate{ID: p.ID, Order: p.DisplayOrder})
+		}
+	}
+	return rejection
+}
+
+func (r *photoRepo) UpdateProcessingStatus(id uint, status domain.PhotoProcessingStatus) error {
+	return r.db.Model(&domain.Photo{}).Where("id = ?", id).Update("processing_status", status).Error
+}
+
+// ReplaceVariants swaps the photo's variant set and stores the processing
+// result in one transaction. The result only applies while the photo still
+// points at photo.ObjectKey, otherwise ErrSourceChanged; it bumps the version
+// so edits read before it conflict instead of reverting the thumbnail.
+func (r *photoRepo) ReplaceVariants(photo *domain.Photo, variants []domain.PhotoVariant) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Photo{}).Where("id = ? AND object_key = ?", photo.ID, photo.ObjectKey).
+			Updates(map[string]interface{}{
+				"width":             photo.Width,
+				"height":            photo.Height,
+				"thumbnail_url":     photo.ThumbnailURL,
+				"thumbnail_key":     photo.ThumbnailKey,
+				"processing_status": photo.ProcessingStatus,
+				"version":           gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSourceChanged
+		}
+
+		if err := tx.Where("photo_id = ?", photo.ID).Delete(&domain.PhotoVariant{}).Error; err != nil {
+			return err
+		}
+		if len(variants) > 0 {
+			return tx.Create(&variants).Error
+		}
+		return nil
 	})
 }
+
+// ExistingIDs returns which of the given photo IDs exist
+func (r *photoRepo) ExistingIDs(ids []uint) ([]uint, error) {
+	var existing []uint
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	err := r.db.Model(&domain.Photo{}).Where("id IN ?", ids).Pluck("id", &existing).Error
+	return existing, err
+}
+
+// ObjectKeyInUse reports whether any other photo, trashed or not, references
+// the object by key or, for rows saved by older clients, by a URL ending in it
+func (r *photoRepo) ObjectKeyInUse(objectKey string, excludeID uint) (bool, error) {
+	// Plain URLs end in the key; signed ones continue with a query string
+	suffix := "%/" + escapeLike(objectKey)
+	signed := suffix + "?%"
+
+	var count int64
+	err := r.db.Unscoped().Model(&domain.Photo{}).
+		Where("id <> ?", excludeID).
+		Where("object_key = ? OR thumbnail_key = ? OR image_url LIKE ? OR image_url LIKE ? OR thumbnail_url LIKE ? OR thumbnail_url LIKE ?",
+			objectKey, objectKey, suffix, signed, suffix, signed).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SaveMetadata inserts or replaces the EXIF record of a photo
+func (r *photoRepo) SaveMetadata(metadata *domain.PhotoMetadata) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "photo_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"camera_make", "camera_model", "lens_model", "focal_length", "aperture",
+			"shutter_speed", "iso", "captured_at", "latitude", "longitude", "altitude",
+			"orientation", "updated_at",
+		}),
+	}).Create(metadata).Error
+}
+
+// DeleteMetadata removes the EXIF record of a photo, if it has one
+func (r *photoRepo) DeleteMetadata(photoID uint) error {
+	return r.db.Where("photo_id = ?", photoID).Delete(&domain.PhotoMetadata{}).Error
+}
+
+// applyMetadataFilters narrows photos by their EXIF record without joining,
+// so unqualified column names in other clauses stay unambiguous
+func applyMetadataFilters(query *gorm.DB, filters PhotoFilters) *gorm.DB {
+	if filters.Camera == "" && filters.CapturedFrom == nil && filters.CapturedTo == nil {
+		return query
+	}
+
+	sub := query.Session(&gorm.Session{NewDB: true}).
+		Model(&domain.PhotoMetadata{}).
+		Select("photo_id")
+	if filters.Camera != "" {
+		pattern := "%" + filters.Camera + "%"
+		sub = sub.Where("camera_make ILIKE ? OR camera_model ILIKE ?", pattern, pattern)
+	}
+	if filters.CapturedFrom != nil {
+		sub = sub.Where("captured_at >= ?", *filters.CapturedFrom)
+	}
+	if filters.CapturedTo != nil {
+		sub = sub.Where("captured_at <= ?", *filters.CapturedTo)
+	}
+
+	return query.Where("id IN (?)", sub)
+}
+
+// applyTagFilters narrows photos to those tagged with any/all of the given slugs
+func applyTagFilters(query *gorm.DB, filters PhotoFilters) *gorm.DB {
+	if len(filters.Tags) == 0 {
+		return query
+	}
+
+	sub := query.Session(&gorm.Session{NewDB: true}).
+		Table("photo_tags").
+		Select("photo_tags.photo_id").
+		Joins("JOIN tags ON tags.id = photo_tags.tag_id").
+		Where("tags.slug IN ?", filters.Tags)
+
+	if filters.TagsMode == domain.TagsModeAll {
+		sub = sub.Group("photo_tags.photo_id").
+			Having("COUNT(DISTINCT photo_tags.tag_id) = ?", len(filters.Tags))
+	}
+
+	return query.Where("id IN (?)", sub)
+}
+
+// orderVariants keeps preloaded variants sorted from smallest to largest
+func orderVariants(db *gorm.DB) *gorm.DB {
+	return db.Order("width ASC")
+}
+
+// MigratePhotoObjectURLs converts rows written with absolute storage URLs to
+// object keys, which are resolved to URLs per response. baseURLs are the
+// storage and CDN prefixes such URLs start with; a URL under one of them,
+// signed or not, becomes the key that follows it. This part runs on every
+// start, so rows saved by older clients are converted too. Thumbnails that
+// match a generated variant take the variant's key; that step reads the
+// variant url column, which is then dropped, so it runs only once.
+func MigratePhotoObjectURLs(db *gorm.DB, baseURLs []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&domain.PhotoVariant{}, "url") {
+			if err := tx.Exec(`UPDATE photos SET thumbnail_key = v.object_key, thumbnail_url = ''
+				FROM photo_variants v
+				WHERE v.photo_id = photos.id AND photos.thumbnail_url <> '' AND v.url = photos.thumbnail_url`).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, base := range baseURLs {
+			prefix := strings.TrimRight(base, "/") + "/"
+			pattern := escapeLike(prefix) + "%"
+			imageKey, thumbnailKey := urlKeySQL("image_url", prefix), urlKeySQL("thumbnail_url", prefix)
+			// Percent-encoded keys are left alone rather than decoded in SQL
+			statements := []string{
+				// Image URLs built from the photo's own key are dropped
+				`UPDATE photos SET image_url = ''
+					WHERE object_key <> '' AND image_url LIKE ? AND ` + imageKey + ` = object_key`,
+				`UPDATE photos SET object_key = ` + imageKey + `, image_url = ''
+					WHERE object_key = '' AND image_url LIKE ? AND ` + imageKey + ` <> '' AND strpos(` + imageKey + `, '%') = 0`,
+				`UPDATE photos SET thumbnail_key = ` + thumbnailKey + `, thumbnail_url = ''
+					WHERE thumbnail_url LIKE ? AND ` + thumbnailKey + ` <> '' AND strpos(` + thumbnailKey + `, '%') = 0`,
+			}
+			for _, stmt := range statements {
+				if err := tx.Exec(stmt, pattern).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		// Image URLs built from an object key under a base no longer configured
+		if err := tx.Exec(`UPDATE photos SET image_url = ''
+			WHERE object_key <> '' AND right(image_url, length(object_key) + 1) = '/' || object_key`).Error; err != nil {
+			return err
+		}
+
+		if tx.Migrator().HasColumn(&domain.PhotoVariant{}, "url") {
+			return tx.Exec(`ALTER TABLE photo_variants DROP COLUMN url`).Error
+		}
+		return nil
+	})
+}
+
+// urlKeySQL is the key part of column, a URL starting with prefix: the path
+// after the prefix without a signature query. chr(63) is '?', which gorm would
+// take for a placeholder when written out.
+func urlKeySQL(column, prefix string) string {
+	return "split_part(substr(" + column + ", " + strconv.Itoa(utf8.RuneCountInString(prefix)+1) + "), chr(63), 1)"
+}
@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+func TestProcessingResultIsNotRevertedByStaleEdit(t *testing.T) {
+	db := openPhotoTestDB(t)
+	repo := NewPhotoRepository(db)
+
+	photo := domain.Photo{Title: "Bund", ObjectKey: "photos/a.jpg", ProcessingStatus: domain.PhotoProcessingPending}
+	if err := repo.Create(&photo); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	edit, err := repo.GetByID(photo.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	processed := *edit
+	processed.Width, processed.Height = 4000, 3000
+	processed.ThumbnailKey = "photos/a_640w.jpg"
+	processed.ProcessingStatus = domain.PhotoProcessingReady
+	variants := []domain.PhotoVariant{{PhotoID: photo.ID, Format: "jpeg", Width: 640, Height: 480, ObjectKey: "photos/a_640w.jpg"}}
+	if err := repo.ReplaceVariants(&processed, variants); err != nil {
+		t.Fatalf("replace variants: %v", err)
+	}
+
+	// The edit was read before processing finished
+	edit.Title = "The Bund"
+	if err := repo.Update(edit); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale update: err = %v, want ErrVersionConflict", err)
+	}
+
+	current, err := repo.GetByID(photo.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if current.ThumbnailKey != "photos/a_640w.jpg" || current.ProcessingStatus != domain.PhotoProcessingReady {
+		t.Fatalf("processing result lost: %+v", current)
+	}
+
+	// A fresh edit leaves the processor's columns alone
+	if err := repo.UpdateProcessingStatus(photo.ID, domain.PhotoProcessingProcessing); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	current.Title = "The Bund"
+	current.ProcessingStatus = domain.PhotoProcessingReady
+	current.Width = 1
+	if err := repo.Update(current); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	reloaded, err := repo.GetByID(photo.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if reloaded.Title != "The Bund" || reloaded.ProcessingStatus != domain.PhotoProcessingProcessing || reloaded.Width != 4000 {
+		t.Fatalf("update wrote processor columns: %+v", reloaded)
+	}
+}
+
+func TestReplaceVariantsRejectsSupersededSource(t *testing.T) {
+	db := openPhotoTestDB(t)
+	repo := NewPhotoRepository(db)
+
+	photo := domain.Photo{Title: "Bund", ObjectKey: "photos/b.jpg"}
+	if err := repo.Create(&photo); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	stale := photo
+	stale.ObjectKey = "photos/a.jpg"
+	stale.ThumbnailKey = "photos/a_640w.jpg"
+	err := repo.ReplaceVariants(&stale, []domain.PhotoVariant{{PhotoID: photo.ID, Format: "jpeg", Width: 640, ObjectKey: "photos/a_640w.jpg"}})
+	if !errors.Is(err, ErrSourceChanged) {
+		t.Fatalf("err = %v, want ErrSourceChanged", err)
+	}
+
+	current, err := repo.GetByID(photo.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if current.ThumbnailKey != "" || len(current.Variants) != 0 || current.Version != photo.Version {
+		t.Fatalf("superseded result was stored: %+v", current)
+	}
+}
+
+func TestPurgeLeavesRestoredPhotoIntact(t *testing.T) {
+	db := openPhotoTestDB(t)
+	repo := NewPhotoRepository(db)
+
+	photo := domain.Photo{Title: "Bund", ObjectKey: "photos/a.jpg"}
+	if err := repo.Create(&photo); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	tag := domain.Tag{Name: "Shanghai", Slug: "shanghai"}
+	if err := db.Create(&tag).Error; err != nil {
+		t.Fatalf("create tag: %v", err)
+	}
+	if err := db.Create(&domain.PhotoTag{PhotoID: photo.ID, TagID: tag.ID}).Error; err != nil {
+		t.Fatalf("tag photo: %v", err)
+	}
+	if err := repo.Delete(photo.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	cutoff := time.Now().Add(time.Minute)
+
+	// A failing object removal rolls the purge back
+	failed := errors.New("storage unavailable")
+	if err := repo.Purge(photo.ID, cutoff, func(*domain.Photo) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("purge with failing removal: err = %v", err)
+	}
+	if tagCount(t, db, photo.ID) != 1 {
+		t.Fatal("failed purge removed the tag link")
+	}
+
+	// The purger listed the photo, then it was restored
+	if err := repo.Restore(photo.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	removed := false
+	err := repo.Purge(photo.ID, cutoff, func(*domain.Photo) error { removed = true; return nil })
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("purge of restored photo: err = %v, want ErrRecordNotFound", err)
+	}
+	if removed || tagCount(t, db, photo.ID) != 1 {
+		t.Fatalf("purge touched a restored photo: objects removed = %v", removed)
+	}
+
+	// Trashed again: purged with its associations
+	if err := repo.Delete(photo.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := repo.Purge(photo.ID, cutoff, func(*domain.Photo) error { removed = true; return nil }); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if !removed || tagCount(t, db, photo.ID) != 0 {
+		t.Fatalf("purge left objects or tag links behind: objects removed = %v", removed)
+	}
+}
+
+func tagCount(t *testing.T, db *gorm.DB, photoID uint) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&domain.PhotoTag{}).Where("photo_id = ?", photoID).Count(&count).Error; err != nil {
+		t.Fatalf("count tags: %v", err)
+	}
+	return count
+}
+
+func TestListTrashedPages(t *testing.T) {
+	db := openPhotoTestDB(t)
+	repo := NewPhotoRepository(db)
+
+	var trashed []uint
+	for i := 0; i < 5; i++ {
+		photo := domain.Photo{Title: "trashed"}
+		if err := repo.Create(&photo); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if err := repo.Delete(photo.ID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		trashed = append([]uint{photo.ID}, trashed...)
+	}
+
+	var got []uint
+	var beforeID uint
+	for {
+		page, err := repo.ListTrashed(beforeID, 2)
+		if err != nil {
+			t.Fatalf("list trash: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, p := range page {
+			got = append(got, p.ID)
+		}
+		beforeID = page[len(page)-1].ID
+	}
+	if !reflect.DeepEqual(got, trashed) {
+		t.Fatalf("trash pages = %v, want %v", got, trashed)
+	}
+}
+
+func TestObjectKeyInUseMatchesKeysAndStoredURLs(t *testing.T) {
+	db := openPhotoTestDB(t)
+	repo := NewPhotoRepository(db)
+
+	photos := []domain.Photo{
+		{Title: "by key", ObjectKey: "photos/key.jpg"},
+		{Title: "by thumbnail key", ObjectKey: "photos/other.jpg", ThumbnailKey: "photos/thumb.jpg"},
+		{Title: "by url", ImageURL: "http://localhost:9000/photos/photos/url.jpg"},
+		{Title: "by signed url", ImageURL: "http://localhost:9000/photos/photos/signed.jpg?X-Amz-Signature=abc"},
+		{Title: "by thumbnail url", ImageURL: "https://example.com/a.jpg", ThumbnailURL: "https://cdn.example.com/photos/thumb-url.jpg"},
+	}
+	for i := range photos {
+		if err := repo.Create(&photos[i]); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	// Trashed photos still need their objects until they are purged
+	if err := repo.Delete(photos[0].ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	cases := []struct {
+		key     string
+		exclude uint
+		want    bool
+	}{
+		{"photos/key.jpg", 0, true},
+		{"photos/key.jpg", photos[0].ID, false},
+		{"photos/thumb.jpg", 0, true},
+		{"photos/url.jpg", 0, true},
+		{"photos/signed.jpg", 0, true},
+		{"photos/thumb-url.jpg", 0, true},
+		{"photos/unused.jpg", 0, false},
+		// LIKE wildcards in a key match only themselves
+		{"photos/%.jpg", 0, false},
+	}
+	for _, tc := range cases {
+		inUse, err := repo.ObjectKeyInUse(tc.key, tc.exclude)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.key, err)
+		}
+		if inUse != tc.want {
+			t.Errorf("ObjectKeyInUse(%q, %d) = %v, want %v", tc.key, tc.exclude, inUse, tc.want)
+		}
+	}
+}
+
+func TestMigratePhotoObjectURLsBackfillsKeysAndDropsVariantURLs(t *testing.T) {
+	db := openPhotoTestDB(t)
+	// The column as it was before variants were stored by key only
+	if err := db.Exec(`ALTER TABLE photo_variants ADD COLUMN url varchar(500) NOT NULL DEFAULT ''`).Error; err != nil {
+		t.Fatalf("add variant url: %v", err)
+	}
+
+	const (
+		store = "http://localhost:9000/photos"
+		cdn   = "https://cdn.example.com"
+	)
+	photos := []domain.Photo{
+		{Title: "keyed", ObjectKey: "photos/keyed.jpg", ImageURL: store + "/photos/keyed.jpg"},
+		{Title: "signed url", ImageURL: store + "/photos/signed.jpg?X-Amz-Signature=abc"},
+		{Title: "cdn url", ImageURL: cdn + "/photos/cdn.jpg"},
+		{Title: "external", ImageURL: "https://example.com/a.jpg", ThumbnailURL: "https://example.com/a_thumb.jpg"},
+		{Title: "variant thumbnail", ObjectKey: "photos/v.jpg", ThumbnailURL: store + "/photos/v_320w.webp"},
+		{Title: "stored thumbnail", ObjectKey: "photos/t.jpg", ThumbnailURL: cdn + "/photos/t_thumb.jpg?sig=1"},
+		{Title: "encoded", ImageURL: store + "/photos/a%20b.jpg"},
+	}
+	if err := db.Create(&photos).Error; err != nil {
+		t.Fatalf("seed photos: %v", err)
+	}
+	variant := domain.PhotoVariant{PhotoID: photos[4].ID, Format: "webp", Width: 320, Height: 240, ObjectKey: "photos/variants/v_320w.webp"}
+	if err := db.Create(&variant).Error; err != nil {
+		t.Fatalf("seed variant: %v", err)
+	}
+	if err := db.Exec(`UPDATE photo_variants SET url = ? WHERE id = ?`, store+"/photos/v_320w.webp", variant.ID).Error; err != nil {
+		t.Fatalf("seed variant url: %v", err)
+	}
+
+	want := []struct{ objectKey, imageURL, thumbnailKey, thumbnailURL string }{
+		{"photos/keyed.jpg", "", "", ""},
+		{"photos/signed.jpg", "", "", ""},
+		{"photos/cdn.jpg", "", "", ""},
+		{"", "https://example.com/a.jpg", "", "https://example.com/a_thumb.jpg"},
+		// The variant's key wins over the key the URL spells out
+		{"photos/v.jpg", "", "photos/variants/v_320w.webp", ""},
+		{"photos/t.jpg", "", "photos/t_thumb.jpg", ""},
+		// Percent-encoded paths are not decoded in SQL; the URL is kept
+		{"", store + "/photos/a%20b.jpg", "", ""},
+	}
+	// A second run finds nothing left to change
+	for run := 1; run <= 2; run++ {
+		if err := MigratePhotoObjectURLs(db, []string{store, cdn + "/"}); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		for i, w := range want {
+			var got domain.Photo
+			if err := db.First(&got, photos[i].ID).Error; err != nil {
+				t.Fatalf("load %s: %v", photos[i].Title, err)
+			}
+			if got.ObjectKey != w.objectKey || got.ImageURL != w.imageURL || got.ThumbnailKey != w.thumbnailKey || got.ThumbnailURL != w.thumbnailURL {
+				t.Errorf("run %d, %s: got key %q url %q thumbnail key %q url %q, want %+v",
+					run, photos[i].Title, got.ObjectKey, got.ImageURL, got.ThumbnailKey, got.ThumbnailURL, w)
+			}
+		}
+		if db.Migrator().HasColumn(&domain.PhotoVariant{}, "url") {
+			t.Fatalf("run %d: photo_variants.url was not dropped", run)
+		}
+	}
+}
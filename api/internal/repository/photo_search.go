@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// searchConfig is the text search configuration used for photos. "simple"
+// avoids language-specific stemming so mixed Chinese/English titles index
+// predictably; changing it requires dropping and re-adding search_vector.
+//
+// Text search parsers split words on whitespace and punctuation, so a Chinese
+// title becomes one long token and "外滩" never matches "上海外滩夜景". Search
+// therefore also matches substrings of search_text through a pg_trgm index,
+// which needs no word segmentation.
+const searchConfig = "simple"
+
+// searchSnippetLen bounds the text a substring snippet is taken from
+const searchSnippetLen = 300
+
+// MigratePhotoSearch adds the generated tsvector and search text columns and
+// their GIN indexes. AutoMigrate cannot manage generated columns, so this
+// runs as idempotent SQL.
+func MigratePhotoSearch(db *gorm.DB) error {
+	statements := []string{
+		`ALTER TABLE photos ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('` + searchConfig + `', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('` + searchConfig + `', coalesce(description, '')), 'B') ||
+				setweight(to_tsvector('` + searchConfig + `', coalesce(location, '')), 'C') ||
+				setweight(to_tsvector('` + searchConfig + `', coalesce(category, '')), 'C')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_photos_search_vector ON photos USING GIN (search_vector)`,
+		// pg_trgm ships with PostgreSQL contrib and is a trusted extension
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`ALTER TABLE photos ADD COLUMN IF NOT EXISTS search_text text
+			GENERATED ALWAYS AS (
+				coalesce(title, '') || ' ' || coalesce(description, '') || ' ' ||
+				coalesce(location, '') || ' ' || coalesce(category, '')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_photos_search_text ON photos USING GIN (search_text gin_trgm_ops)`,
+	}
+
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchQuery is a search string prepared for both matching strategies
+type searchQuery struct {
+	text string
+	// ILIKE patterns that must all match search_text; empty when q has no plain terms
+	patterns []string
+	// case-insensitive regex over HTML-escaped text for highlighting substring matches
+	highlight string
+}
+
+// newSearchQuery splits q into plain terms for substring matching. Websearch
+// operators (OR, -exclusion) only apply to the full-text match.
+func newSearchQuery(q string) searchQuery {
+	sq := searchQuery{text: q}
+
+	var alternatives []string
+	for _, field := range strings.Fields(q) {
+		if field == "OR" || strings.HasPrefix(field, "-") {
+			continue
+		}
+		term := strings.Trim(field, `"`)
+		if term == "" {
+			continue
+		}
+		sq.patterns = append(sq.patterns, "%"+escapeLike(term)+"%")
+		alternatives = append(alternatives, regexp.QuoteMeta(escapeHTML(term)))
+	}
+	sq.highlight = strings.Join(alternatives, "|")
+	return sq
+}
+
+// tsQuery is the full-text query expression; it takes the search text as its argument
+const tsQuery = "websearch_to_tsquery('" + searchConfig + "', ?)"
+
+// condition matches either whole words through the tsvector or, for text
+// without word boundaries such as Chinese, every term as a substring
+func (sq searchQuery) condition() (string, []interface{}) {
+	if len(sq.patterns) == 0 {
+		return "search_vector @@ " + tsQuery, []interface{}{sq.text}
+	}
+
+	substrings := make([]string, len(sq.patterns))
+	args := []interface{}{sq.text}
+	for i, pattern := range sq.patterns {
+		substrings[i] = "search_text ILIKE ?"
+		args = append(args, pattern)
+	}
+	return "(search_vector @@ " + tsQuery + " OR (" + strings.Join(substrings, " AND ") + "))", args
+}
+
+// rank scores word matches with ts_rank and substring matches with how well
+// the query matches some part of the text
+func (sq searchQuery) rank() (string, []interface{}) {
+	return "(ts_rank(search_vector, " + tsQuery + ") + word_similarity(?, search_text))", []interface{}{sq.text, sq.text}
+}
+
+// headline highlights expr: ts_headline for word matches, otherwise every
+// substring match of a term
+func (sq searchQuery) headline(expr string) (string, []interface{}) {
+	const headlineOpts = "'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MinWords=5, MaxWords=25'"
+
+	words := "ts_headline('" + searchConfig + "', " + escapeHTMLSQL(expr) + ", " + tsQuery + ", " + headlineOpts + ")"
+	if sq.highlight == "" {
+		return words, []interface{}{sq.text}
+	}
+	substrings := "regexp_replace(" + escapeHTMLSQL("left("+expr+", "+strconv.Itoa(searchSnippetLen)+")") + ", ?, '<mark>\\&</mark>', 'gi')"
+	return "CASE WHEN search_vector @@ " + tsQuery + " THEN " + words + " ELSE " + substrings + " END",
+		[]interface{}{sq.text, sq.text, sq.highlight}
+}
+
+// applySearch selects rank and highlighted snippets for photos matching q
+// (websearch syntax: quoted phrases, OR, -exclusion). Highlights are
+// HTML-escaped before <mark> tags are added.
+func applySearch(query *gorm.DB, q string) *gorm.DB {
+	sq := newSearchQuery(q)
+	rankSQL, rankArgs := sq.rank()
+	titleSQL, titleArgs := sq.headline("title")
+	snippetSQL, snippetArgs := sq.headline("concat_ws(' · ', nullif(description, ''), nullif(location, ''), nullif(category, ''))")
+
+	args := append(append(rankArgs, titleArgs...), snippetArgs...)
+	return query.Select(
+		"photos.*, "+rankSQL+" AS search_rank, "+titleSQL+" AS title_highlight, "+snippetSQL+" AS snippet",
+		args...,
+	)
+}
+
+// matchSearch filters to rows matching q
+func matchSearch(query *gorm.DB, q string) *gorm.DB {
+	condition, args := newSearchQuery(q).condition()
+	return query.Where(condition, args...)
+}
+
+func escapeHTMLSQL(expr string) string {
+	return "replace(replace(replace(" + expr + ", '&', '&amp;'), '<', '&lt;'), '>', '&gt;')"
+}
+
+// escapeHTML mirrors escapeHTMLSQL so terms match the escaped text they highlight
+func escapeHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// escapeLike makes s match literally inside a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+func TestNewSearchQuery(t *testing.T) {
+	sq := newSearchQuery(`上海 "外滩" OR -雨 50%_off <b>`)
+
+	wantPatterns := []string{"%上海%", "%外滩%", `%50\%\_off%`, "%<b>%"}
+	if !reflect.DeepEqual(sq.patterns, wantPatterns) {
+		t.Errorf("patterns = %q, want %q", sq.patterns, wantPatterns)
+	}
+	if want := "上海|外滩|50%_off|&lt;b&gt;"; sq.highlight != want {
+		t.Errorf("highlight = %q, want %q", sq.highlight, want)
+	}
+
+	if only := newSearchQuery("-rain OR"); len(only.patterns) != 0 || only.highlight != "" {
+		t.Errorf("operators only: patterns = %q, highlight = %q", only.patterns, only.highlight)
+	}
+}
+
+func TestSearchMatchesChineseSubstrings(t *testing.T) {
+	db := openPhotoTestDB(t)
+	repo := NewPhotoRepository(db)
+
+	photos := []domain.Photo{
+		{Title: "上海外滩夜景", Description: "黄浦江对岸的灯光", ImageURL: "https://example.com/1.jpg"},
+		{Title: "北京胡同", Location: "东城区", ImageURL: "https://example.com/2.jpg"},
+		{Title: "Sunset over the Bund", Location: "上海", ImageURL: "https://example.com/3.jpg"},
+	}
+	for i := range photos {
+		if err := repo.Create(&photos[i]); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	cases := []struct {
+		query string
+		want  []uint
+	}{
+		{"外滩", []uint{photos[0].ID}},
+		{"上海", []uint{photos[0].ID, photos[2].ID}},
+		{"胡同 东城", []uint{photos[1].ID}},
+		{"sunset", []uint{photos[2].ID}},
+		{"广州", nil},
+	}
+	for _, tc := range cases {
+		page, err := repo.List(PhotoFilters{Query: tc.query, Limit: 10, Sort: SortSpec{{Field: "id"}}})
+		if err != nil {
+			t.Fatalf("search %q: %v", tc.query, err)
+		}
+		var got []uint
+		for _, p := range page.Photos {
+			got = append(got, p.ID)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("search %q = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	page, err := repo.List(PhotoFilters{Query: "外滩", Limit: 10})
+	if err != nil || len(page.Photos) != 1 {
+		t.Fatalf("search 外滩 by relevance: %v, %d photos", err, len(page.Photos))
+	}
+	if want := "上海<mark>外滩</mark>夜景"; page.Photos[0].TitleHighlight != want {
+		t.Errorf("title highlight = %q, want %q", page.Photos[0].TitleHighlight, want)
+	}
+}
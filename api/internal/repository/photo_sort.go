@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+var ErrInvalidSort = errors.New("invalid sort specification")
+
+// SortField is one entry of a sort spec, e.g. "-createdAt"
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// SortSpec is an ordered list of sort fields parsed from ?sort=-createdAt,displayOrder
+type SortSpec []SortField
+
+// SortError lists the offending fields of a rejected sort spec
+type SortError struct {
+	UnknownFields   []string `json:"unknownFields,omitempty"`
+	DuplicateFields []string `json:"duplicateFields,omitempty"`
+	AllowedFields   []string `json:"allowedFields"`
+}
+
+func (e *SortError) Error() string {
+	return ErrInvalidSort.Error()
+}
+
+func (e *SortError) Unwrap() error {
+	return ErrInvalidSort
+}
+
+// sortRelevance orders by full-text rank and is only valid with a search query
+const sortRelevance = "relevance"
+
+// photoSortKeys maps public API field names to the columns they sort by
+var photoSortKeys = map[string]sortKey{
+	"id":           idKey,
+	"displayOrder": displayOrderKey,
+	"createdAt": {
+		Column: "photos.created_at",
+		value:  func(p *domain.Photo) interface{} { return p.CreatedAt },
+		decode: decodeAs[time.Time],
+	},
+	"updatedAt": {
+		Column: "photos.updated_at",
+		value:  func(p *domain.Photo) interface{} { return p.UpdatedAt },
+		decode: decodeAs[time.Time],
+	},
+	"title": {
+		Column: "photos.title",
+		value:  func(p *domain.Photo) interface{} { return p.Title },
+		decode: decodeAs[string],
+	},
+}
+
+// PhotoSortFields returns the allowed field names, including "relevance"
+func PhotoSortFields() []string {
+	fields := make([]string, 0, len(photoSortKeys)+1)
+	for field := range photoSortKeys {
+		fields = append(fields, field)
+	}
+	fields = append(fields, sortRelevance)
+	sort.Strings(fields)
+	return fields
+}
+
+// ParsePhotoSort parses a comma-separated sort spec; a leading "-" sorts descending.
+// Unknown or repeated fields yield a *SortError.
+func ParsePhotoSort(raw string) (SortSpec, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var spec SortSpec
+	var unknown, duplicate []string
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			field = SortField{Field: part[1:], Desc: true}
+		} else if strings.HasPrefix(part, "+") {
+			field.Field = part[1:]
+		}
+
+		if _, ok := photoSortKeys[field.Field]; !ok && field.Field != sortRelevance {
+			unknown = append(unknown, field.Field)
+			continue
+		}
+		if seen[field.Field] {
+			duplicate = append(duplicate, field.Field)
+			continue
+		}
+		seen[field.Field] = true
+		spec = append(spec, field)
+	}
+
+	if len(unknown) > 0 || len(duplicate) > 0 {
+		return nil, &SortError{
+			UnknownFields:   unknown,
+			DuplicateFields: duplicate,
+			AllowedFields:   PhotoSortFields(),
+		}
+	}
+	return spec, nil
+}
+
+// sortKeysFor resolves a sort spec to keyset columns, always ending with id.
+// An empty spec sorts search results by relevance and everything else by display order.
+func sortKeysFor(spec SortSpec, query string) ([]sortKey, error) {
+	if len(spec) == 0 {
+		if query != "" {
+			spec = SortSpec{{Field: sortRelevance, Desc: true}}
+		} else {
+			spec = SortSpec{{Field: "displayOrder"}}
+		}
+	}
+
+	keys := make([]sortKey, 0, len(spec)+1)
+	hasID := false
+	for _, field := range spec {
+		var key sortKey
+		if field.Field == sortRelevance {
+			if query == "" {
+				return nil, &SortError{UnknownFields: []string{sortRelevance}, AllowedFields: PhotoSortFields()}
+			}
+			key = searchRankKey(query)
+		} else {
+			key = photoSortKeys[field.Field]
+		}
+		key.Desc = field.Desc
+		keys = append(keys, key)
+
+		if field.Field == "id" {
+			hasID = true
+			// Nothing after a unique key affects ordering
+			break
+		}
+	}
+
+	if !hasID {
+		keys = append(keys, idKey)
+	}
+	return keys, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePhotoSort(t *testing.T) {
+	spec, err := ParsePhotoSort(" -createdAt, +title,displayOrder ,")
+	if err != nil {
+		t.Fatalf("ParsePhotoSort: %v", err)
+	}
+	want := SortSpec{{Field: "createdAt", Desc: true}, {Field: "title"}, {Field: "displayOrder"}}
+	if !reflect.DeepEqual(spec, want) {
+		t.Fatalf("spec = %+v, want %+v", spec, want)
+	}
+
+	if spec, err := ParsePhotoSort(""); spec != nil || err != nil {
+		t.Fatalf("empty spec = %+v, %v; want nil", spec, err)
+	}
+}
+
+func TestParsePhotoSortRejectsFieldsOutsideTheAllowlist(t *testing.T) {
+	// Column names and SQL never reach ORDER BY; only API field names are known
+	_, err := ParsePhotoSort("title,created_at,-title,id; DROP TABLE photos")
+	if !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("err = %v, want ErrInvalidSort", err)
+	}
+	var sortErr *SortError
+	if !errors.As(err, &sortErr) {
+		t.Fatalf("err = %T, want *SortError", err)
+	}
+	if want := []string{"created_at", "id; DROP TABLE photos"}; !reflect.DeepEqual(sortErr.UnknownFields, want) {
+		t.Errorf("unknown fields = %q, want %q", sortErr.UnknownFields, want)
+	}
+	if want := []string{"title"}; !reflect.DeepEqual(sortErr.DuplicateFields, want) {
+		t.Errorf("duplicate fields = %q, want %q", sortErr.DuplicateFields, want)
+	}
+	if !reflect.DeepEqual(sortErr.AllowedFields, PhotoSortFields()) {
+		t.Errorf("allowed fields = %q", sortErr.AllowedFields)
+	}
+}
+
+func TestSortKeysFor(t *testing.T) {
+	columns := func(keys []sortKey) []string {
+		var got []string
+		for _, key := range keys {
+			column := key.Column
+			if key.Desc {
+				column += " DESC"
+			}
+			got = append(got, column)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name  string
+		spec  SortSpec
+		query string
+		want  []string
+	}{
+		{"default", nil, "", []string{"photos.display_order", "photos.id"}},
+		{"id ends the ordering", SortSpec{{Field: "id", Desc: true}, {Field: "title"}}, "", []string{"photos.id DESC"}},
+		{"id is appended as a tiebreaker", SortSpec{{Field: "createdAt", Desc: true}}, "", []string{"photos.created_at DESC", "photos.id"}},
+	}
+	for _, tt := range tests {
+		keys, err := sortKeysFor(tt.spec, tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := columns(keys); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: columns = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Searches rank by relevance unless told otherwise
+	keys, err := sortKeysFor(nil, "bund")
+	if err != nil || len(keys) != 2 || !keys[0].Desc || keys[1].Column != "photos.id" {
+		t.Fatalf("search default = %+v, %v", keys, err)
+	}
+	if _, err := sortKeysFor(SortSpec{{Field: "relevance"}}, ""); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("relevance without a query: err = %v, want ErrInvalidSort", err)
+	}
+}
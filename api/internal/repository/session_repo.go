@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+// ErrSessionRotated means the refresh token was already exchanged by another request
+var ErrSessionRotated = errors.New("refresh token was already used")
+
+type SessionRepository interface {
+	Create(session *domain.Session) error
+	// GetByTokenHash finds a session by its current refresh token, revoked or not
+	GetByTokenHash(hash string) (*domain.Session, error)
+	// GetByPreviousHash finds the session a rotated-out refresh token belonged to
+	GetByPreviousHash(hash string) (*domain.Session, error)
+	// Rotate swaps the refresh token if it is still oldHash
+	Rotate(id uint, oldHash, newHash string, expiresAt time.Time) error
+	IsActive(id, userID uint) (bool, error)
+	ListActive(userID uint) ([]domain.Session, error)
+	Revoke(id, userID uint) error
+	// RevokeAllForUser revokes every session of the user except exceptID (0 for none)
+	RevokeAllForUser(userID, exceptID uint) error
+	DeleteExpired(before time.Time) error
+}
+
+type sessionRepo struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepo{db: db}
+}
+
+func (r *sessionRepo) Create(session *domain.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *sessionRepo) GetByTokenHash(hash string) (*domain.Session, error) {
+	var session domain.Session
+	if err := r.db.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepo) GetByPreviousHash(hash string) (*domain.Session, error) {
+	var session domain.Session
+	if err := r.db.Where("previous_token_hash = ?", hash).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepo) Rotate(id uint, oldHash, newHash string, expiresAt time.Time) error {
+	result := r.db.Model(&domain.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", id, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": oldHash,
+			"last_used_at":        time.Now(),
+			"expires_at":          expiresAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionRotated
+	}
+	return nil
+}
+
+func (r *sessionRepo) IsActive(id, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", id, userID, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *sessionRepo) ListActive(userID uint) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *sessionRepo) Revoke(id, userID uint) error {
+	result := r.db.Model(&domain.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepo) RevokeAllForUser(userID, exceptID uint) error {
+	return r.db.Model(&domain.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepo) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&domain.Session{}).Error
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+type TagRepository interface {
+	Create(tag *domain.Tag) error
+	GetByID(id uint) (*domain.Tag, error)
+	Update(tag *domain.Tag) error
+	Delete(id uint) error
+
+	// Check whether a slug is used by a tag other than excludeID
+	SlugExists(slug string, excludeID uint) (bool, error)
+
+	// Get tags by slug, creating the missing ones
+	FindOrCreate(tags []domain.Tag) ([]domain.Tag, error)
+
+	// Get tags by slug, ignoring unknown slugs
+	GetBySlugs(slugs []string) ([]domain.Tag, error)
+
+	// List tags with usage counts; onlyPublished counts published photos and hides unused tags
+	ListWithCounts(onlyPublished bool) ([]domain.Tag, error)
+
+	// Attach every tag to every photo, ignoring existing pairs
+	AddToPhotos(photoIDs []uint, tagIDs []uint) error
+
+	// Detach the tags from the photos
+	RemoveFromPhotos(photoIDs []uint, tagIDs []uint) error
+}
+
+type tagRepo struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) TagRepository {
+	return &tagRepo{db: db}
+}
+
+func (r *tagRepo) Create(tag *domain.Tag) error {
+	return r.db.Create(tag).Error
+}
+
+func (r *tagRepo) GetByID(id uint) (*domain.Tag, error) {
+	var tag domain.Tag
+	err := r.db.First(&tag, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func (r *tagRepo) Update(tag *domain.Tag) error {
+	return r.db.Select("name", "slug", "updated_at").Updates(tag).Error
+}
+
+func (r *tagRepo) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// First delete all photo_tags associations
+		if err := tx.Where("tag_id = ?", id).Delete(&domain.PhotoTag{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&domain.Tag{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *tagRepo) SlugExists(slug string, excludeID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.Tag{}).
+		Where("slug = ? AND id <> ?", slug, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *tagRepo) FindOrCreate(tags []domain.Tag) ([]domain.Tag, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	if err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "slug"}},
+		DoNothing: true,
+	}).Create(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	slugs := make([]string, len(tags))
+	for i, tag := range tags {
+		slugs[i] = tag.Slug
+	}
+	return r.GetBySlugs(slugs)
+}
+
+func (r *tagRepo) GetBySlugs(slugs []string) ([]domain.Tag, error) {
+	var tags []domain.Tag
+	if len(slugs) == 0 {
+		return tags, nil
+	}
+	err := r.db.Where("slug IN ?", slugs).Find(&tags).Error
+	return tags, err
+}
+
+func (r *tagRepo) ListWithCounts(onlyPublished bool) ([]domain.Tag, error) {
+	var tags []domain.Tag
+
+	query := r.db.Model(&domain.Tag{}).
+		Select("tags.*, COUNT(photos.id) AS count").
+		Joins("LEFT JOIN photo_tags ON photo_tags.tag_id = tags.id").
+		Group("tags.id")
+
+	if onlyPublished {
+		query = query.
+			Joins("LEFT JOIN photos ON photos.id = photo_tags.photo_id AND photos.deleted_at IS NULL AND photos.status = ?", domain.PhotoStatusPublished).
+			Having("COUNT(photos.id) > 0")
+	} else {
+		query = query.Joins("LEFT JOIN photos ON photos.id = photo_tags.photo_id AND photos.deleted_at IS NULL")
+	}
+
+	err := query.Order("count DESC, tags.name ASC").Find(&tags).Error
+	return tags, err
+}
+
+func (r *tagRepo) AddToPhotos(photoIDs []uint, tagIDs []uint) error {
+	if len(photoIDs) == 0 || len(tagIDs) == 0 {
+		return nil
+	}
+
+	links := make([]domain.PhotoTag, 0, len(photoIDs)*len(tagIDs))
+	for _, photoID := range photoIDs {
+		for _, tagID := range tagIDs {
+			links = append(links, domain.PhotoTag{PhotoID: photoID, TagID: tagID})
+		}
+	}
+
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+func (r *tagRepo) RemoveFromPhotos(photoIDs []uint, tagIDs []uint) error {
+	if len(photoIDs) == 0 || len(tagIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("photo_id IN ? AND tag_id IN ?", photoIDs, tagIDs).Delete(&domain.PhotoTag{}).Error
+}
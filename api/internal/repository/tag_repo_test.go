@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+func TestTagFiltersAndCounts(t *testing.T) {
+	db := openPhotoTestDB(t)
+	photos := NewPhotoRepository(db)
+	tags := NewTagRepository(db)
+
+	created := []domain.Photo{
+		{Title: "street and film", Status: domain.PhotoStatusPublished},
+		{Title: "street", Status: domain.PhotoStatusPublished},
+		{Title: "film draft", Status: domain.PhotoStatusDraft},
+	}
+	for i := range created {
+		if err := photos.Create(&created[i]); err != nil {
+			t.Fatalf("create photo: %v", err)
+		}
+	}
+	found, err := tags.FindOrCreate([]domain.Tag{{Name: "Street", Slug: "street"}, {Name: "Film", Slug: "film"}})
+	if err != nil || len(found) != 2 {
+		t.Fatalf("find or create: %v, %+v", err, found)
+	}
+	ids := map[string]uint{}
+	for _, tag := range found {
+		ids[tag.Slug] = tag.ID
+	}
+	// Existing slugs are found, not duplicated
+	if again, err := tags.FindOrCreate([]domain.Tag{{Name: "street", Slug: "street"}}); err != nil || len(again) != 1 || again[0].ID != ids["street"] {
+		t.Fatalf("find existing: %v, %+v", err, again)
+	}
+
+	links := map[uint][]uint{
+		ids["street"]: {created[0].ID, created[1].ID},
+		ids["film"]:   {created[0].ID, created[2].ID},
+	}
+	for tagID, photoIDs := range links {
+		// Adding twice leaves a single link
+		for i := 0; i < 2; i++ {
+			if err := tags.AddToPhotos(photoIDs, []uint{tagID}); err != nil {
+				t.Fatalf("add to photos: %v", err)
+			}
+		}
+	}
+
+	titles := func(mode domain.TagsMode, slugs ...string) []string {
+		t.Helper()
+		page, err := photos.List(PhotoFilters{Tags: slugs, TagsMode: mode, Sort: SortSpec{{Field: "id"}}})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var got []string
+		for _, photo := range page.Photos {
+			got = append(got, photo.Title)
+		}
+		return got
+	}
+	if got, want := titles(domain.TagsModeAny, "street", "film"), []string{"street and film", "street", "film draft"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("any = %q, want %q", got, want)
+	}
+	if got, want := titles(domain.TagsModeAll, "street", "film"), []string{"street and film"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("all = %q, want %q", got, want)
+	}
+
+	counts := func(onlyPublished bool) map[string]int64 {
+		t.Helper()
+		list, err := tags.ListWithCounts(onlyPublished)
+		if err != nil {
+			t.Fatalf("list with counts: %v", err)
+		}
+		got := map[string]int64{}
+		for _, tag := range list {
+			got[tag.Slug] = tag.Count
+		}
+		return got
+	}
+	if got, want := counts(false), map[string]int64{"street": 2, "film": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("all counts = %v, want %v", got, want)
+	}
+	if got, want := counts(true), map[string]int64{"street": 2, "film": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published counts = %v, want %v", got, want)
+	}
+
+	if err := tags.RemoveFromPhotos([]uint{created[1].ID}, []uint{ids["street"]}); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if got, want := titles(domain.TagsModeAny, "street"), []string{"street and film"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after remove = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+// testDSNEnv names the PostgreSQL database repository tests run against, e.g.
+// the docker-compose one: host=localhost user=webapp password=webapp dbname=webapp sslmode=disable
+const testDSNEnv = "TEST_POSTGRES_DSN"
+
+// openTestDB returns a connection to a fresh schema that is dropped when the
+// test ends. Tests are skipped when no database is configured.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDSNEnv)
+	}
+
+	config := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	// Extensions live in public so every test schema can use them
+	for _, stmt := range []string{"CREATE EXTENSION IF NOT EXISTS pg_trgm SCHEMA public", "CREATE SCHEMA " + schema} {
+		if err := admin.Exec(stmt).Error; err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema+",public")), config)
+	if err != nil {
+		t.Fatalf("connect to %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// openPhotoTestDB migrates the photo tables the way the server does
+func openPhotoTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := openTestDB(t)
+	if err := db.SetupJoinTable(&domain.Photo{}, "Tags", &domain.PhotoTag{}); err != nil {
+		t.Fatalf("setup join table: %v", err)
+	}
+	if err := db.AutoMigrate(&domain.Photo{}, &domain.PhotoVariant{}, &domain.PhotoMetadata{}, &domain.PendingUpload{}, &domain.ComponentPhoto{}, &domain.Album{}, &domain.AlbumPhoto{}, &domain.Tag{}, &domain.PhotoTag{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := MigratePhotoSearch(db); err != nil {
+		t.Fatalf("migrate photo search: %v", err)
+	}
+	return db
+}
+
+// withSearchPath sets the search_path run-time parameter in either DSN form
+func withSearchPath(dsn, searchPath string) string {
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + url.QueryEscape(searchPath)
+	}
+	return dsn + " search_path=" + searchPath
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+type PendingUploadRepository interface {
+	Create(upload *domain.PendingUpload) error
+	GetByObjectKey(objectKey string) (*domain.PendingUpload, error)
+	GetByMultipartUploadID(uploadID string) (*domain.PendingUpload, error)
+	// Consume deletes and returns a pending upload; gorm.ErrRecordNotFound
+	// means it is unknown or was already completed or collected
+	Consume(objectKey string) (*domain.PendingUpload, error)
+	ListExpired(before time.Time, limit int) ([]domain.PendingUpload, error)
+}
+
+type pendingUploadRepo struct {
+	db *gorm.DB
+}
+
+func NewPendingUploadRepository(db *gorm.DB) PendingUploadRepository {
+	return &pendingUploadRepo{db: db}
+}
+
+func (r *pendingUploadRepo) Create(upload *domain.PendingUpload) error {
+	return r.db.Create(upload).Error
+}
+
+func (r *pendingUploadRepo) GetByObjectKey(objectKey string) (*domain.PendingUpload, error) {
+	var upload domain.PendingUpload
+	if err := r.db.Where("object_key = ?", objectKey).First(&upload).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *pendingUploadRepo) GetByMultipartUploadID(uploadID string) (*domain.PendingUpload, error) {
+	var upload domain.PendingUpload
+	if err := r.db.Where("multipart_upload_id = ?", uploadID).First(&upload).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *pendingUploadRepo) Consume(objectKey string) (*domain.PendingUpload, error) {
+	var upload domain.PendingUpload
+	result := r.db.Clauses(clause.Returning{}).Where("object_key = ?", objectKey).Delete(&upload)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &upload, nil
+}
+
+func (r *pendingUploadRepo) ListExpired(before time.Time, limit int) ([]domain.PendingUpload, error) {
+	var uploads []domain.PendingUpload
+	err := r.db.Where("expires_at < ?", before).Order("expires_at").Limit(limit).Find(&uploads).Error
+	return uploads, err
+}
@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+var (
+	ErrUserDisabled = errors.New("user account is disabled")
+	ErrLastOwner    = errors.New("the last active owner cannot be removed, disabled or demoted")
+	ErrTOTPStepUsed = errors.New("totp code was already used")
+)
+
+type UserRepository interface {
+	Create(user *domain.User) error
+	GetByID(id uint) (*domain.User, error)
+	GetByUsername(username string) (*domain.User, error)
+	// GetByEmail matches the address case-insensitively
+	GetByEmail(email string) (*domain.User, error)
+	GetByOIDCIdentity(issuer, subject string) (*domain.User, error)
+	List() ([]domain.User, error)
+	Count() (int64, error)
+	UsernameExists(username string) (bool, error)
+	EmailExists(email string) (bool, error)
+
+	// GetRole returns the current role of an active user, as stored in the database.
+	// Disabled users yield ErrUserDisabled.
+	GetRole(id uint) (domain.Role, error)
+
+	UpdatePassword(id uint, hashedPassword string, mustChange bool) error
+	// MustChangePassword reports whether the user still has a temporary password
+	MustChangePassword(id uint) (bool, error)
+
+	// RecordLoginFailure counts a failed login and locks the account for lockout
+	// once maxFailures is reached; the counter then starts over
+	RecordLoginFailure(id uint, maxFailures int, lockout time.Duration) (*domain.User, error)
+	// ResetLoginFailures clears the failure counter and any lockout
+	ResetLoginFailures(id uint) error
+
+	// SetTOTPSecret stores a secret for setup; it is inactive until EnableTOTP
+	SetTOTPSecret(id uint, secret string) error
+	// EnableTOTP activates the stored secret, recording step as already used
+	EnableTOTP(id uint, step int64) error
+	// DisableTOTP clears the secret and turns two-factor login off
+	DisableTOTP(id uint) error
+	// UseTOTPStep records a TOTP time step as used; ErrTOTPStepUsed means the
+	// code (or a later one) was already accepted
+	UseTOTPStep(id uint, step int64) error
+
+	// LinkOIDCIdentity attaches a provider identity to an account that has none yet;
+	// gorm.ErrRecordNotFound means the account is missing or already linked
+	LinkOIDCIdentity(id uint, issuer, subject string) error
+
+	// UpdateRole, SetDisabled and Delete refuse with ErrLastOwner when they
+	// would leave the site without an active owner
+	UpdateRole(id uint, role domain.Role) error
+	SetDisabled(id uint, disabled bool) error
+	Delete(id uint) error
+}
+
+type userRepo struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepo{db: db}
+}
+
+func (r *userRepo) Create(user *domain.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *userRepo) GetByID(id uint) (*domain.User, error) {
+	var user domain.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepo) GetByUsername(username string) (*domain.User, error) {
+	var user domain.User
+	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepo) GetByEmail(email string) (*domain.User, error) {
+	var user domain.User
+	if err := r.db.Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepo) GetByOIDCIdentity(issuer, subject string) (*domain.User, error) {
+	var user domain.User
+	if err := r.db.Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepo) List() ([]domain.User, error) {
+	var users []domain.User
+	err := r.db.Order("created_at ASC, id ASC").Find(&users).Error
+	return users, err
+}
+
+func (r *userRepo) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.User{}).Count(&count).Error
+	return count, err
+}
+
+func (r *userRepo) UsernameExists(username string) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.User{}).Where("username = ?", username).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *userRepo) EmailExists(email string) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.User{}).Where("LOWER(email) = LOWER(?)", email).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *userRepo) GetRole(id uint) (domain.Role, error) {
+	var user domain.User
+	if err := r.db.Select("id", "role", "disabled_at").First(&user, id).Error; err != nil {
+		return "", err
+	}
+	if user.IsDisabled() {
+		return "", ErrUserDisabled
+	}
+	return user.Role, nil
+}
+
+func (r *userRepo) MustChangePassword(id uint) (bool, error) {
+	var user domain.User
+	if err := r.db.Select("id", "must_change_password").First(&user, id).Error; err != nil {
+		return false, err
+	}
+	return user.MustChangePassword, nil
+}
+
+func (r *userRepo) UpdatePassword(id uint, hashedPassword string, mustChange bool) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password":             hashedPassword,
+		"must_change_password": mustChange,
+	}).Error
+}
+
+func (r *userRepo) RecordLoginFailure(id uint, maxFailures int, lockout time.Duration) (*domain.User, error) {
+	now := time.Now()
+	err := r.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"failed_login_count":   gorm.Expr("CASE WHEN failed_login_count + 1 >= ? THEN 0 ELSE failed_login_count + 1 END", maxFailures),
+		"locked_until":         gorm.Expr("CASE WHEN failed_login_count + 1 >= ? THEN ?::timestamptz ELSE locked_until END", maxFailures, now.Add(lockout)),
+		"last_failed_login_at": now,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+func (r *userRepo) ResetLoginFailures(id uint) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"failed_login_count": 0,
+		"locked_until":       nil,
+	}).Error
+}
+
+func (r *userRepo) SetTOTPSecret(id uint, secret string) error {
+	return updateUser(r.db, id, map[string]interface{}{
+		"totp_secret":     secret,
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	})
+}
+
+func (r *userRepo) EnableTOTP(id uint, step int64) error {
+	return updateUser(r.db, id, map[string]interface{}{
+		"totp_enabled_at": time.Now(),
+		"totp_last_step":  step,
+	})
+}
+
+func (r *userRepo) DisableTOTP(id uint) error {
+	return updateUser(r.db, id, map[string]interface{}{
+		"totp_secret":     "",
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	})
+}
+
+func (r *userRepo) UseTOTPStep(id uint, step int64) error {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTOTPStepUsed
+	}
+	return nil
+}
+
+func (r *userRepo) LinkOIDCIdentity(id uint, issuer, subject string) error {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND oidc_subject IS NULL", id).
+		Updates(map[string]interface{}{"oidc_issuer": issuer, "oidc_subject": subject})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepo) UpdateRole(id uint, role domain.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if role != domain.RoleOwner {
+			if err := guardLastOwner(tx, id); err != nil {
+				return err
+			}
+		}
+		return updateUser(tx, id, map[string]interface{}{"role": role})
+	})
+}
+
+func (r *userRepo) SetDisabled(id uint, disabled bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var disabledAt *time.Time
+		if disabled {
+			if err := guardLastOwner(tx, id); err != nil {
+				return err
+			}
+			now := time.Now()
+			disabledAt = &now
+		}
+		return updateUser(tx, id, map[string]interface{}{"disabled_at": disabledAt})
+	})
+}
+
+func (r *userRepo) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := guardLastOwner(tx, id); err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&domain.Session{}, &domain.RecoveryCode{}, &domain.MFAChallenge{}, &domain.PasswordResetToken{}, &domain.APIToken{}} {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		result := tx.Delete(&domain.User{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// guardLastOwner locks the active owners and fails if id is the only one left.
+// The row locks serialize concurrent demotions of the last two owners.
+func guardLastOwner(tx *gorm.DB, id uint) error {
+	var ownerIDs []uint
+	err := tx.Model(&domain.User{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND disabled_at IS NULL", domain.RoleOwner).
+		Pluck("id", &ownerIDs).Error
+	if err != nil {
+		return err
+	}
+	if len(ownerIDs) == 1 && ownerIDs[0] == id {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func updateUser(tx *gorm.DB, id uint, updates map[string]interface{}) error {
+	result := tx.Model(&domain.User{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MigrateUserRoles promotes the oldest admin to owner when no owner exists yet,
+// so installs from before roles existed keep someone who can manage users
+func MigrateUserRoles(db *gorm.DB) error {
+	return db.Exec(`UPDATE users SET role = ?
+		WHERE id = (SELECT id FROM users WHERE role = ? ORDER BY id LIMIT 1)
+		AND NOT EXISTS (SELECT 1 FROM users WHERE role = ?)`,
+		domain.RoleOwner, domain.RoleAdmin, domain.RoleOwner).Error
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "errors"
+
+// ErrVersionConflict is returned when a versioned row changed since it was read
+var ErrVersionConflict = errors.New("resource was modified by another request")
+
+// ErrSourceChanged is returned when a processing result is stored for an
+// object the photo no longer points at
+var ErrSourceChanged = errors.New("photo source object changed during processing")
@@ -2,9 +2,10 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"fmt"
 	"log"
 	"net/http"
-	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -16,15 +17,22 @@ import (
 	"github.com/aton/atonWeb/api/internal/delivery/http/middleware"
 	"github.com/aton/atonWeb/api/internal/domain"
 	"github.com/aton/atonWeb/api/internal/infrastructure/jwt"
+	"github.com/aton/atonWeb/api/internal/infrastructure/mail"
+	"github.com/aton/atonWeb/api/internal/infrastructure/oidc"
+	"github.com/aton/atonWeb/api/internal/infrastructure/storage"
 	"github.com/aton/atonWeb/api/internal/repository"
 	"github.com/aton/atonWeb/api/internal/usecase"
 )
 
 type Server struct {
-	router *gin.Engine
-	server *http.Server
-	db     *gorm.DB
-	cfg    config.Config
+	router    *gin.Engine
+	server    *http.Server
+	db        *gorm.DB
+	cfg       config.Config
+	processor usecase.PhotoProcessor
+	purger    usecase.PhotoPurger
+	uploads   usecase.UploadService
+	sessions  usecase.SessionCleaner
 }
 
 func New(cfg config.Config) *Server {
@@ -34,37 +42,115 @@ func New(cfg config.Config) *Server {
 		log.Fatalf("Failed to connect database: %v", err)
 	}
 
+	// photo_tags 使用自定义关联表
+	if err := db.SetupJoinTable(&domain.Photo{}, "Tags", &domain.PhotoTag{}); err != nil {
+		log.Fatalf("Failed to setup join table: %v", err)
+	}
+
 	// 自动迁移数据库
-	if err := db.AutoMigrate(&domain.Photo{}, &domain.User{}, &domain.ComponentPhoto{}); err != nil {
+	if err := db.AutoMigrate(&domain.Photo{}, &domain.PhotoVariant{}, &domain.PhotoMetadata{}, &domain.User{}, &domain.Session{}, &domain.LoginAttempt{}, &domain.RecoveryCode{}, &domain.MFAChallenge{}, &domain.PasswordResetToken{}, &domain.APIToken{}, &domain.OIDCLoginState{}, &domain.AuditEvent{}, &domain.PendingUpload{}, &domain.ComponentPhoto{}, &domain.Album{}, &domain.AlbumPhoto{}, &domain.Tag{}, &domain.PhotoTag{}); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
+	if err := repository.MigratePhotoSearch(db); err != nil {
+		log.Fatalf("Failed to migrate photo search: %v", err)
+	}
+	if err := repository.MigrateUserRoles(db); err != nil {
+		log.Fatalf("Failed to migrate user roles: %v", err)
+	}
+	// 审计日志只允许追加, 由触发器拒绝修改和删除
+	if err := repository.MigrateAuditEvents(db); err != nil {
+		log.Fatalf("Failed to migrate audit events: %v", err)
+	}
 
 	// 初始化 JWT Manager
-	jwtManager := jwt.NewJWTManager(cfg.JWTSecret, 24*time.Hour)
+	jwtManager, err := newJWTManager(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// 初始化审计日志, 所有管理操作都会写入
+	auditRepo := repository.NewAuditRepository(db)
+	auditService := usecase.NewAuditService(auditRepo)
+	auditHandler := handler.NewAuditHandler(auditService)
 
 	// 初始化认证服务
-	authService := usecase.NewAuthService(db, jwtManager)
+	userRepo := repository.NewUserRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	oidcStateRepo := repository.NewOIDCStateRepository(db)
+	authService := usecase.NewAuthService(userRepo, sessionRepo, loginAttemptRepo, mfaRepo, oidcStateRepo, newOIDCClient(cfg), jwtManager, auditService, cfg)
 	authHandler := handler.NewAuthHandler(authService)
 
+	// 定期删除过期会话
+	sessionCleaner := usecase.NewSessionCleaner(sessionRepo, cfg)
+
+	// 初始化邮件与密码重置服务
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	passwordResetService := usecase.NewPasswordResetService(userRepo, sessionRepo, passwordResetRepo, auditService, mailer, cfg)
+	passwordResetHandler := handler.NewPasswordResetHandler(passwordResetService)
+
+	// 初始化 API 令牌服务
+	apiTokenRepo := repository.NewAPITokenRepository(db)
+	apiTokenService := usecase.NewAPITokenService(apiTokenRepo, userRepo, auditService)
+	apiTokenHandler := handler.NewAPITokenHandler(apiTokenService)
+
+	// 初始化用户管理服务
+	userService := usecase.NewUserService(userRepo, sessionRepo, loginAttemptRepo, mfaRepo, authService, auditService)
+	userHandler := handler.NewUserHandler(userService)
+
+	// 初始化存储服务; 存储不可用时拒绝启动, 而不是悄悄失去上传功能
+	blob, blobSigner, err := newBlob(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	pendingUploadRepo := repository.NewPendingUploadRepository(db)
+	storageService := usecase.NewStorageService(blob, cfg, pendingUploadRepo, auditService)
+	storageHandler := handler.NewStorageHandler(storageService, cfg.UploadMaxBytes)
+	// 照片只保存对象 key, URL 在返回时生成; 把旧数据中存储/CDN 下的 URL 转成 key
+	if err := repository.MigratePhotoObjectURLs(db, storageService.BaseURLs()); err != nil {
+		log.Fatalf("Failed to migrate photo URLs: %v", err)
+	}
+
 	// 初始化分层架构
 	photoRepo := repository.NewPhotoRepository(db)
-	photoService := usecase.NewPhotoService(photoRepo)
+
+	photoProcessor, err := usecase.NewPhotoProcessor(photoRepo, storageService, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize photo processor: %v", err)
+	}
+
+	// 定期永久删除回收站中超过保留期的照片及其存储对象
+	photoPurger := usecase.NewPhotoPurger(photoRepo, storageService, auditService, cfg)
+
+	// 照片 URL 按当前的存储配置 (CDN 域名或签名链接) 在返回时生成
+	photoURLs := usecase.NewPhotoURLResolver(storageService)
+
+	photoService := usecase.NewPhotoService(photoRepo, storageService, photoProcessor, photoURLs, auditService)
 	photoHandler := handler.NewPhotoHandler(photoService)
 
+	// 上传完成后生成草稿照片, 并定期清理过期未完成的上传
+	uploadService := usecase.NewUploadService(pendingUploadRepo, photoRepo, storageService, photoService, auditService, cfg)
+	uploadHandler := handler.NewUploadHandler(uploadService)
+
 	// 初始化组件照片服务
 	componentPhotoRepo := repository.NewComponentPhotoRepository(db)
-	componentPhotoService := usecase.NewComponentPhotoService(componentPhotoRepo)
+	componentPhotoService := usecase.NewComponentPhotoService(componentPhotoRepo, photoURLs, auditService)
 	componentPhotoHandler := handler.NewComponentPhotoHandler(componentPhotoService)
 
-	// 初始化存储服务
-	storageService, err := usecase.NewStorageService(cfg)
-	if err != nil {
-		log.Printf("Warning: Storage service not available: %v", err)
-	}
-	var storageHandler *handler.StorageHandler
-	if storageService != nil {
-		storageHandler = handler.NewStorageHandler(storageService)
-	}
+	// 初始化相册服务
+	albumRepo := repository.NewAlbumRepository(db)
+	albumService := usecase.NewAlbumService(albumRepo, photoRepo, photoURLs, auditService)
+	albumHandler := handler.NewAlbumHandler(albumService)
+
+	// 初始化标签服务
+	tagRepo := repository.NewTagRepository(db)
+	tagService := usecase.NewTagService(tagRepo, photoRepo, auditService)
+	tagHandler := handler.NewTagHandler(tagService)
 
 	// 设置 Gin 模式
 	if cfg.Env == "production" {
@@ -72,13 +158,17 @@ func New(cfg config.Config) *Server {
 	}
 
 	router := gin.Default()
+	// 只采信受信任代理转发的客户端 IP; 登录限流、会话与审计日志都依赖 ClientIP
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// 配置 CORS
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "If-Match"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "Retry-After"},
 		AllowCredentials: true,
 	}))
 
@@ -90,13 +180,25 @@ func New(cfg config.Config) *Server {
 		})
 	})
 
+	// 公开 JWT 验证公钥, 供前端自行校验令牌
+	jwksHandler := handler.NewJWKSHandler(jwtManager)
+	router.GET("/.well-known/jwks.json", jwksHandler.JWKS)
+
 	// API v1 路由组
 	v1 := router.Group("/api/v1")
 	{
 		// Auth 路由 (公开)
 		auth := v1.Group("/auth")
 		{
+			auth.GET("/methods", authHandler.AuthMethods)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/mfa/verify", authHandler.VerifyMFA)
+			auth.POST("/oidc/authorize", authHandler.OIDCAuthorize)
+			auth.POST("/oidc/callback", authHandler.OIDCCallback)
+			auth.POST("/forgot-password", passwordResetHandler.ForgotPassword)
+			auth.POST("/reset-password", passwordResetHandler.ResetPassword)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authHandler.Logout)
 			// Only allow user creation in non-production environments
 			if cfg.Env != "production" {
 				auth.POST("/create-user", authHandler.CreateUser)
@@ -104,13 +206,55 @@ func New(cfg config.Config) *Server {
 		}
 
 		// 需要认证的路由
-		authMiddleware := middleware.AuthMiddleware(jwtManager)
+		authMiddleware := middleware.AuthMiddleware(jwtManager, sessionRepo, apiTokenService, userRepo)
+
+		// 权限检查: 角色从数据库读取, 不信任 JWT 中的 role
+		perm := func(p domain.Permission) gin.HandlerFunc {
+			return middleware.RequirePermission(userRepo, p)
+		}
+
+		// 修改密码: 临时密码的账号也可访问, 其余路由在改密前返回 403
+		passwordChange := v1.Group("/user")
+		passwordChange.Use(middleware.PasswordChangeAuthMiddleware(jwtManager, sessionRepo, apiTokenService), middleware.RejectAPITokens())
+		{
+			passwordChange.POST("/change-password", authHandler.ChangePassword)
+		}
 
 		// User 路由 (需要认证)
 		user := v1.Group("/user")
-		user.Use(authMiddleware)
+		user.Use(authMiddleware, middleware.RejectAPITokens())
+		{
+			user.GET("/sessions", authHandler.ListSessions)
+			user.DELETE("/sessions/:id", authHandler.RevokeSession)
+			user.POST("/mfa/totp/setup", authHandler.SetupTOTP)
+			user.POST("/mfa/totp/enable", authHandler.EnableTOTP)
+			user.POST("/mfa/totp/disable", authHandler.DisableTOTP)
+			user.POST("/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+			user.GET("/api-tokens", apiTokenHandler.List)
+			user.POST("/api-tokens", apiTokenHandler.Create)
+			user.DELETE("/api-tokens/:id", apiTokenHandler.Revoke)
+		}
+
+		// Users 管理路由 (需要 users:manage 权限)
+		users := v1.Group("/users")
+		users.Use(authMiddleware, perm(domain.PermUsersManage))
 		{
-			user.POST("/change-password", authHandler.ChangePassword)
+			users.GET("", userHandler.List)
+			users.GET("/login-attempts", userHandler.LoginAttempts)
+			users.POST("", userHandler.Invite)
+			users.PUT("/:id/role", userHandler.UpdateRole)
+			users.POST("/:id/disable", userHandler.Disable)
+			users.POST("/:id/enable", userHandler.Enable)
+			users.POST("/:id/unlock", userHandler.Unlock)
+			users.POST("/:id/mfa/reset", userHandler.ResetMFA)
+			users.DELETE("/:id", userHandler.Delete)
+		}
+
+		// Audit 路由 (需要 audit:read 权限)
+		audit := v1.Group("/audit")
+		audit.Use(authMiddleware, perm(domain.PermAuditRead))
+		{
+			audit.GET("", auditHandler.List)
 		}
 
 		// Photos 路由
@@ -118,18 +262,24 @@ func New(cfg config.Config) *Server {
 		{
 			// Public routes - no auth required
 			photos.GET("/published", photoHandler.ListPublished) // Public: only published photos for photo wall
-			photos.GET("/:id", photoHandler.GetByID)             // Public: for photo detail
+			photos.GET("/:id", photoHandler.GetByID)             // Public: for photo detail, without GPS location
 
-			// Protected routes - require auth (admin only)
+			// Protected routes - require auth and a role permission
 			photosAuth := photos.Group("")
 			photosAuth.Use(authMiddleware)
 			{
-				photosAuth.GET("", photoHandler.List) // Admin: all photos with filters
-				photosAuth.POST("", photoHandler.Create)
-				photosAuth.PUT("/:id", photoHandler.Update)
-				photosAuth.DELETE("/:id", photoHandler.Delete)
-				photosAuth.POST("/reorder", photoHandler.BatchUpdateDisplayOrder)
-				photosAuth.GET("/:id/components", componentPhotoHandler.GetComponentsByPhoto)
+				photosAuth.GET("", perm(domain.PermPhotosRead), photoHandler.List) // Admin: all photos with filters
+				photosAuth.GET("/trash", perm(domain.PermPhotosRead), photoHandler.Trash)
+				photosAuth.GET("/:id/metadata", perm(domain.PermPhotosRead), photoHandler.Metadata)
+				photosAuth.POST("", perm(domain.PermPhotosWrite), photoHandler.Create)
+				photosAuth.PUT("/:id", perm(domain.PermPhotosWrite), photoHandler.Update)
+				photosAuth.DELETE("/:id", perm(domain.PermPhotosDelete), photoHandler.Delete)
+				photosAuth.POST("/reorder", perm(domain.PermPhotosWrite), photoHandler.BatchUpdateDisplayOrder)
+				photosAuth.POST("/:id/process", perm(domain.PermPhotosWrite), photoHandler.Process)
+				photosAuth.POST("/:id/restore", perm(domain.PermPhotosDelete), photoHandler.Restore)
+				photosAuth.POST("/tags/add", perm(domain.PermPhotosWrite), tagHandler.AddToPhotos)
+				photosAuth.POST("/tags/remove", perm(domain.PermPhotosWrite), tagHandler.RemoveFromPhotos)
+				photosAuth.GET("/:id/components", perm(domain.PermComponentsRead), componentPhotoHandler.GetComponentsByPhoto)
 			}
 		}
 
@@ -137,9 +287,49 @@ func New(cfg config.Config) *Server {
 		componentPhotos := v1.Group("/component-photos")
 		componentPhotos.Use(authMiddleware)
 		{
-			componentPhotos.POST("", componentPhotoHandler.AssignPhotoToComponent)
-			componentPhotos.PUT("/:id", componentPhotoHandler.UpdateComponentPhoto)
-			componentPhotos.DELETE("/:id", componentPhotoHandler.RemovePhotoFromComponent)
+			componentPhotos.POST("", perm(domain.PermComponentsWrite), componentPhotoHandler.AssignPhotoToComponent)
+			componentPhotos.GET("/:id", perm(domain.PermComponentsRead), componentPhotoHandler.GetComponentPhoto)
+			componentPhotos.PUT("/:id", perm(domain.PermComponentsWrite), componentPhotoHandler.UpdateComponentPhoto)
+			componentPhotos.DELETE("/:id", perm(domain.PermComponentsDelete), componentPhotoHandler.RemovePhotoFromComponent)
+		}
+
+		// Albums 路由
+		albums := v1.Group("/albums")
+		{
+			// Public routes - no auth required
+			albums.GET("/published", albumHandler.ListPublished)
+			albums.GET("/:slug", albumHandler.GetBySlug)
+
+			// Protected routes - require auth and a role permission
+			albumsAuth := albums.Group("")
+			albumsAuth.Use(authMiddleware)
+			{
+				albumsAuth.GET("", perm(domain.PermAlbumsRead), albumHandler.List)
+				albumsAuth.GET("/:slug/preview", perm(domain.PermAlbumsRead), albumHandler.Preview)
+				albumsAuth.POST("", perm(domain.PermAlbumsWrite), albumHandler.Create)
+				albumsAuth.PUT("/:id", perm(domain.PermAlbumsWrite), albumHandler.Update)
+				albumsAuth.DELETE("/:id", perm(domain.PermAlbumsDelete), albumHandler.Delete)
+				albumsAuth.PUT("/:id/photos", perm(domain.PermAlbumsWrite), albumHandler.SetPhotos)
+				albumsAuth.POST("/:id/photos", perm(domain.PermAlbumsWrite), albumHandler.AddPhotos)
+				albumsAuth.DELETE("/:id/photos/:photoId", perm(domain.PermAlbumsWrite), albumHandler.RemovePhoto)
+			}
+		}
+
+		// Tags 路由
+		tags := v1.Group("/tags")
+		{
+			// Public route - tag cloud with published usage counts
+			tags.GET("", tagHandler.ListPublished)
+
+			// Protected routes - require auth and a role permission
+			tagsAuth := tags.Group("")
+			tagsAuth.Use(authMiddleware)
+			{
+				tagsAuth.GET("/all", perm(domain.PermTagsRead), tagHandler.List)
+				tagsAuth.POST("", perm(domain.PermTagsWrite), tagHandler.Create)
+				tagsAuth.PUT("/:id", perm(domain.PermTagsWrite), tagHandler.Update)
+				tagsAuth.DELETE("/:id", perm(domain.PermTagsDelete), tagHandler.Delete)
+			}
 		}
 
 		// Components 路由 (public for photo fetching, auth for admin)
@@ -149,19 +339,39 @@ func New(cfg config.Config) *Server {
 		}
 
 		// Storage 路由 (需要认证)
-		if storageHandler != nil {
-			storage := v1.Group("/storage")
-			storage.Use(authMiddleware)
+		storageRoutes := v1.Group("/storage")
+		storageRoutes.Use(authMiddleware)
+		{
+			storageRoutes.POST("/upload-token", perm(domain.PermStorageUpload), storageHandler.GenerateUploadToken)
+			storageRoutes.POST("/upload", perm(domain.PermStorageUpload), storageHandler.Upload)
+			storageRoutes.POST("/uploads/*objectKey", perm(domain.PermStorageUpload), perm(domain.PermPhotosWrite), uploadHandler.Complete)
+			storageRoutes.POST("/multipart", perm(domain.PermStorageUpload), storageHandler.InitiateMultipart)
+			storageRoutes.POST("/multipart/:uploadId/parts", perm(domain.PermStorageUpload), storageHandler.PresignParts)
+			storageRoutes.GET("/multipart/:uploadId/parts", perm(domain.PermStorageUpload), storageHandler.ListParts)
+			storageRoutes.POST("/multipart/:uploadId/complete", perm(domain.PermStorageUpload), storageHandler.CompleteMultipart)
+			storageRoutes.DELETE("/multipart/:uploadId", perm(domain.PermStorageUpload), storageHandler.AbortMultipart)
+		}
+
+		// local/memory 存储的文件由 API 提供: 读取公开, 上传需要签名地址
+		if blobSigner != nil {
+			blobHandler := handler.NewBlobHandler(blob, blobSigner, cfg.UploadMaxBytes, cfg.StorageSignedURLTTL > 0)
+			blobs := v1.Group("/blobs")
 			{
-				storage.POST("/upload-token", storageHandler.GenerateUploadToken)
+				blobs.GET("/*key", blobHandler.Get)
+				blobs.HEAD("/*key", blobHandler.Get)
+				blobs.PUT("/*key", blobHandler.Put)
 			}
 		}
 	}
 
 	return &Server{
-		router: router,
-		db:     db,
-		cfg:    cfg,
+		router:    router,
+		db:        db,
+		cfg:       cfg,
+		processor: photoProcessor,
+		purger:    photoPurger,
+		uploads:   uploadService,
+		sessions:  sessionCleaner,
 		server: &http.Server{
 			Addr:    cfg.Addr(),
 			Handler: router,
@@ -176,12 +386,117 @@ func (s *Server) Run() error {
 
 func (s *Server) Shutdown(ctx context.Context) error {
 	log.Println("Shutting down server...")
-	
+
+	// 等待后台图片处理任务完成
+	s.processor.Close()
+	s.purger.Close()
+	s.uploads.Close()
+	s.sessions.Close()
+
 	// 关闭数据库连接
 	sqlDB, err := s.db.DB()
 	if err == nil {
 		sqlDB.Close()
 	}
-	
+
 	return s.server.Shutdown(ctx)
 }
+
+// newJWTManager 配置了私钥时使用非对称签名, 否则使用 HS256 共享密钥
+func newJWTManager(cfg config.Config) (*jwt.JWTManager, error) {
+	if cfg.JWTPrivateKeyFile == "" {
+		if len(cfg.JWTPreviousKeyFiles) > 0 {
+			return nil, fmt.Errorf("JWT_PREVIOUS_KEY_FILES requires JWT_PRIVATE_KEY_FILE")
+		}
+		return jwt.NewJWTManager(jwt.NewHMACKey(cfg.JWTSecret), cfg.AccessTokenTTL)
+	}
+
+	signingKey, err := jwt.LoadKeyFile(cfg.JWTPrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	previousKeys := make([]*jwt.Key, 0, len(cfg.JWTPreviousKeyFiles))
+	for _, path := range cfg.JWTPreviousKeyFiles {
+		key, err := jwt.LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		previousKeys = append(previousKeys, key)
+	}
+	return jwt.NewJWTManager(signingKey, cfg.AccessTokenTTL, previousKeys...)
+}
+
+// newOIDCClient 未配置 OIDC_ISSUER_URL 时返回 nil (而非 nil 的 *oidc.Client), 即不启用单点登录
+// 发现文档在首次登录时才获取, 身份提供方暂时不可用不影响启动
+func newOIDCClient(cfg config.Config) usecase.OIDCProvider {
+	if cfg.OIDCIssuerURL == "" {
+		return nil
+	}
+	return oidc.NewClient(oidc.Config{
+		IssuerURL:    cfg.OIDCIssuerURL,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+		Scopes:       cfg.OIDCScopes,
+	})
+}
+
+// newBlob 根据 STORAGE_DRIVER 选择对象存储
+// local 与 memory 没有自己的文件服务, 同时返回 URLSigner 供 API 提供文件
+func newBlob(cfg config.Config) (storage.Blob, *storage.URLSigner, error) {
+	switch cfg.StorageDriver {
+	case "s3":
+		blob, err := storage.NewS3Blob(context.Background(), storage.S3Config{
+			Endpoint:        cfg.OSSEndpoint,
+			Bucket:          cfg.OSSBucket,
+			AccessKeyID:     cfg.OSSAccessKeyID,
+			SecretAccessKey: cfg.OSSAccessKeySecret,
+			UseSSL:          cfg.OSSUseSSL,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return blob, nil, nil
+	case "local", "memory":
+		secret := []byte(cfg.StorageURLSecret)
+		if len(secret) == 0 {
+			// 未配置时随机生成, 重启后之前签发的上传地址失效
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, nil, err
+			}
+		}
+		signer := storage.NewURLSigner(cfg.APIPublicURL+"/api/v1/blobs", secret)
+		if cfg.StorageDriver == "memory" {
+			log.Printf("Warning: STORAGE_DRIVER=memory, uploaded files are lost when the server stops")
+			return storage.NewMemoryBlob(signer), signer, nil
+		}
+		blob, err := storage.NewLocalBlob(cfg.StorageLocalDir, signer)
+		if err != nil {
+			return nil, nil, err
+		}
+		return blob, signer, nil
+	}
+	return nil, nil, fmt.Errorf("unknown STORAGE_DRIVER %q", cfg.StorageDriver)
+}
+
+// newMailer 根据 MAIL_DRIVER 选择邮件实现
+func newMailer(cfg config.Config) (mail.Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+			TLS:      cfg.SMTPTLS,
+		})
+	case "log":
+		if cfg.Env == "production" {
+			log.Printf("Warning: MAIL_DRIVER=log, password reset emails will not be delivered")
+		}
+		return mail.NewLogMailer(cfg.MailFrom, cfg.MailDir)
+	}
+	return nil, fmt.Errorf("unknown MAIL_DRIVER %q", cfg.MailDriver)
+}
@@ -0,0 +1,398 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrAlbumNotFound      = errors.New("album not found")
+	ErrAlbumTitleEmpty    = errors.New("album title cannot be empty")
+	ErrAlbumSlugInvalid   = errors.New("album slug may only contain lowercase letters, digits and hyphens")
+	ErrAlbumSlugTaken     = errors.New("album slug is already in use")
+	ErrAlbumSlugReserved  = errors.New("album slug is reserved")
+	ErrAlbumStatusInvalid = errors.New("album status must be draft or published")
+	ErrAlbumPhotoNotFound = errors.New("photo is not in this album")
+)
+
+var (
+	slugPattern     = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+	slugUnsafeChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+	// Slugs that name a fixed route under /albums and would shadow the album
+	reservedSlugs = map[string]bool{"published": true}
+)
+
+type AlbumService interface {
+	Create(req *domain.CreateAlbumRequest, actor domain.Actor) (*domain.Album, error)
+	Update(id uint, req *domain.UpdateAlbumRequest, actor domain.Actor) (*domain.Album, error)
+	Delete(id uint, actor domain.Actor) error
+
+	// List all albums (admin)
+	List() ([]domain.Album, error)
+
+	// List published albums (public)
+	ListPublished() ([]domain.Album, error)
+
+	// Get a published album with its published photos (public)
+	GetPublishedBySlug(slug string) (*domain.Album, error)
+
+	// Get any album with all of its photos (admin preview)
+	GetBySlug(slug string) (*domain.Album, error)
+
+	SetPhotos(id uint, photoIDs []uint, actor domain.Actor) (*domain.Album, error)
+	AddPhotos(id uint, photoIDs []uint, actor domain.Actor) (*domain.Album, error)
+	RemovePhoto(id, photoID uint, actor domain.Actor) error
+}
+
+type albumService struct {
+	repo      repository.AlbumRepository
+	photoRepo repository.PhotoRepository
+	urls      PhotoURLResolver
+	audit     AuditService
+}
+
+func NewAlbumService(repo repository.AlbumRepository, photoRepo repository.PhotoRepository, urls PhotoURLResolver, audit AuditService) AlbumService {
+	return &albumService{repo: repo, photoRepo: photoRepo, urls: urls, audit: audit}
+}
+
+func (s *albumService) Create(req *domain.CreateAlbumRequest, actor domain.Actor) (*domain.Album, error) {
+	if strings.TrimSpace(req.Title) == "" {
+		return nil, apperror.BadRequest(ErrAlbumTitleEmpty)
+	}
+
+	status := req.Status
+	if status == "" {
+		status = domain.AlbumStatusDraft
+	}
+	if !isValidAlbumStatus(status) {
+		return nil, apperror.BadRequest(ErrAlbumStatusInvalid)
+	}
+
+	slug, err := s.resolveSlug(req.Slug, req.Title, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CoverPhotoID != nil {
+		if err := ensurePhotosExist(s.photoRepo, []uint{*req.CoverPhotoID}); err != nil {
+			return nil, err
+		}
+	}
+
+	album := &domain.Album{
+		Title:        req.Title,
+		Slug:         slug,
+		Description:  req.Description,
+		CoverPhotoID: req.CoverPhotoID,
+		Status:       status,
+	}
+
+	if err := s.repo.Create(album); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditAlbumCreate, domain.AuditEntityAlbum, auditID(album.ID), nil, album)
+	return album, nil
+}
+
+func (s *albumService) Update(id uint, req *domain.UpdateAlbumRequest, actor domain.Actor) (*domain.Album, error) {
+	if !req.HasUpdates() {
+		return nil, apperror.BadRequest(ErrNoFieldsToUpdate)
+	}
+
+	album, err := s.getByID(id)
+	if err != nil {
+		return nil, err
+	}
+	before := *album
+
+	if req.Title != nil {
+		if strings.TrimSpace(*req.Title) == "" {
+			return nil, apperror.BadRequest(ErrAlbumTitleEmpty)
+		}
+		album.Title = *req.Title
+	}
+	if req.Slug != nil {
+		slug, err := s.resolveSlug(*req.Slug, album.Title, album.ID)
+		if err != nil {
+			return nil, err
+		}
+		album.Slug = slug
+	}
+	updateStringField(&album.Description, req.Description)
+	if req.Status != nil {
+		if !isValidAlbumStatus(*req.Status) {
+			return nil, apperror.BadRequest(ErrAlbumStatusInvalid)
+		}
+		album.Status = *req.Status
+	}
+
+	// coverPhotoId 0 clears the cover
+	if req.CoverPhotoID != nil {
+		if *req.CoverPhotoID == 0 {
+			album.CoverPhotoID = nil
+		} else {
+			if err := ensurePhotosExist(s.photoRepo, []uint{*req.CoverPhotoID}); err != nil {
+				return nil, err
+			}
+			album.CoverPhotoID = req.CoverPhotoID
+		}
+		album.CoverPhoto = nil
+	}
+
+	if err := s.repo.Update(album); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	updated, err := s.getByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(actor, domain.AuditAlbumUpdate, domain.AuditEntityAlbum, auditID(id), &before, updated)
+	s.resolveURLs(updated)
+	return updated, nil
+}
+
+func (s *albumService) Delete(id uint, actor domain.Actor) error {
+	album, err := s.getByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(ErrAlbumNotFound)
+		}
+		return apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditAlbumDelete, domain.AuditEntityAlbum, auditID(id), album, nil)
+	return nil
+}
+
+func (s *albumService) List() ([]domain.Album, error) {
+	albums, err := s.repo.List("", false)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	for i := range albums {
+		s.resolveURLs(&albums[i])
+	}
+	return albums, nil
+}
+
+func (s *albumService) ListPublished() ([]domain.Album, error) {
+	albums, err := s.repo.List(string(domain.AlbumStatusPublished), true)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	for i := range albums {
+		hideUnpublishedCover(&albums[i])
+		s.resolveURLs(&albums[i])
+	}
+	return albums, nil
+}
+
+func (s *albumService) GetPublishedBySlug(slug string) (*domain.Album, error) {
+	album, err := s.repo.GetBySlug(slug)
+	if err != nil || album.Status != domain.AlbumStatusPublished {
+		return nil, apperror.NotFound(ErrAlbumNotFound)
+	}
+	hideUnpublishedCover(album)
+
+	if err := s.loadPhotos(album, true); err != nil {
+		return nil, err
+	}
+	s.resolveURLs(album)
+	return album, nil
+}
+
+func (s *albumService) GetBySlug(slug string) (*domain.Album, error) {
+	album, err := s.repo.GetBySlug(slug)
+	if err != nil {
+		return nil, apperror.NotFound(ErrAlbumNotFound)
+	}
+
+	if err := s.loadPhotos(album, false); err != nil {
+		return nil, err
+	}
+	s.resolveURLs(album)
+	return album, nil
+}
+
+func (s *albumService) SetPhotos(id uint, photoIDs []uint, actor domain.Actor) (*domain.Album, error) {
+	album, err := s.getByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	photoIDs = uniquePhotoIDs(photoIDs)
+	if err := ensurePhotosExist(s.photoRepo, photoIDs); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetPhotos(album.ID, photoIDs); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	s.audit.Record(actor, domain.AuditAlbumSetPhotos, domain.AuditEntityAlbum, auditID(album.ID), nil, auditPhotoIDs{PhotoIDs: photoIDs})
+
+	if err := s.loadPhotos(album, false); err != nil {
+		return nil, err
+	}
+	s.resolveURLs(album)
+	return album, nil
+}
+
+func (s *albumService) AddPhotos(id uint, photoIDs []uint, actor domain.Actor) (*domain.Album, error) {
+	album, err := s.getByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	photoIDs = uniquePhotoIDs(photoIDs)
+	if err := ensurePhotosExist(s.photoRepo, photoIDs); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.AddPhotos(album.ID, photoIDs); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	s.audit.Record(actor, domain.AuditAlbumAddPhotos, domain.AuditEntityAlbum, auditID(album.ID), nil, auditPhotoIDs{PhotoIDs: photoIDs})
+
+	if err := s.loadPhotos(album, false); err != nil {
+		return nil, err
+	}
+	s.resolveURLs(album)
+	return album, nil
+}
+
+func (s *albumService) RemovePhoto(id, photoID uint, actor domain.Actor) error {
+	if err := s.repo.RemovePhoto(id, photoID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(ErrAlbumPhotoNotFound)
+		}
+		return apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditAlbumRemovePhoto, domain.AuditEntityAlbum, auditID(id), auditPhotoIDs{PhotoIDs: []uint{photoID}}, nil)
+	return nil
+}
+
+// Helper methods
+
+func (s *albumService) getByID(id uint) (*domain.Album, error) {
+	album, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound(ErrAlbumNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	return album, nil
+}
+
+func (s *albumService) loadPhotos(album *domain.Album, onlyPublished bool) error {
+	photos, err := s.repo.GetPhotos(album.ID, onlyPublished)
+	if err != nil {
+		return apperror.InternalError(err)
+	}
+	album.Photos = photos
+	album.PhotoCount = int64(len(photos))
+	return nil
+}
+
+// resolveSlug validates an explicit slug or derives one from the title,
+// appending a numeric suffix until it is unique and not reserved
+func (s *albumService) resolveSlug(requested, title string, albumID uint) (string, error) {
+	slug := strings.TrimSpace(requested)
+	if slug != "" {
+		if !slugPattern.MatchString(slug) {
+			return "", apperror.BadRequest(ErrAlbumSlugInvalid)
+		}
+		if reservedSlugs[slug] {
+			return "", apperror.BadRequest(ErrAlbumSlugReserved)
+		}
+		taken, err := s.repo.SlugExists(slug, albumID)
+		if err != nil {
+			return "", apperror.InternalError(err)
+		}
+		if taken {
+			return "", apperror.Conflict(ErrAlbumSlugTaken)
+		}
+		return slug, nil
+	}
+
+	base := slugify(title)
+	if base == "" {
+		// Titles without latin characters (e.g. Chinese) get a random slug
+		base = "album-" + uuid.New().String()[:8]
+	}
+
+	slug = base
+	for i := 2; ; i++ {
+		if !reservedSlugs[slug] {
+			taken, err := s.repo.SlugExists(slug, albumID)
+			if err != nil {
+				return "", apperror.InternalError(err)
+			}
+			if !taken {
+				return slug, nil
+			}
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+func slugify(title string) string {
+	slug := slugUnsafeChars.ReplaceAllString(strings.ToLower(title), "-")
+	slug = strings.Trim(slug, "-")
+	// Leave room for a uniqueness suffix within the 200 char column
+	if len(slug) > 190 {
+		slug = strings.TrimRight(slug[:190], "-")
+	}
+	return slug
+}
+
+func uniquePhotoIDs(photoIDs []uint) []uint {
+	seen := make(map[uint]bool, len(photoIDs))
+	result := make([]uint, 0, len(photoIDs))
+	for _, id := range photoIDs {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func isValidAlbumStatus(status domain.AlbumStatus) bool {
+	return status == domain.AlbumStatusDraft || status == domain.AlbumStatusPublished
+}
+
+// resolveURLs fills in the photo URLs of an album about to be returned
+func (s *albumService) resolveURLs(album *domain.Album) {
+	if album.CoverPhoto != nil {
+		s.urls.Resolve(album.CoverPhoto)
+	}
+	for i := range album.Photos {
+		s.urls.Resolve(&album.Photos[i].Photo)
+	}
+}
+
+// hideUnpublishedCover keeps draft photos off public album responses
+func hideUnpublishedCover(album *domain.Album) {
+	if album.CoverPhoto != nil && album.CoverPhoto.Status != domain.PhotoStatusPublished {
+		album.CoverPhoto = nil
+		album.CoverPhotoID = nil
+	}
+}
@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// takenSlugs reports the listed slugs as used by other albums
+type takenSlugs struct {
+	repository.AlbumRepository
+	taken map[string]bool
+}
+
+func (r takenSlugs) SlugExists(slug string, _ uint) (bool, error) {
+	return r.taken[slug], nil
+}
+
+func TestResolveSlugAvoidsReservedSlugs(t *testing.T) {
+	s := &albumService{repo: takenSlugs{taken: map[string]bool{"published-2": true}}}
+
+	_, err := s.resolveSlug("published", "Anything", 0)
+	appErr, ok := apperror.IsAppError(err)
+	if !ok || appErr.StatusCode != http.StatusBadRequest || !errors.Is(err, ErrAlbumSlugReserved) {
+		t.Fatalf("explicit reserved slug: err = %v, want ErrAlbumSlugReserved", err)
+	}
+
+	// A title that slugifies to a reserved slug gets a suffix like a taken one
+	slug, err := s.resolveSlug("", "Published", 0)
+	if err != nil || slug != "published-3" {
+		t.Fatalf("slug from title = %q, %v; want published-3", slug, err)
+	}
+}
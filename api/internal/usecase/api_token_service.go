@@ -0,0 +1,183 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrAPITokenNameInvalid   = errors.New("token name must be between 1 and 100 characters")
+	ErrAPITokenScopesEmpty   = errors.New("at least one scope is required")
+	ErrAPITokenScopeInvalid  = errors.New("some scopes are unknown or exceed your role")
+	ErrAPITokenExpiryInvalid = errors.New("expiresAt must be in the future")
+	ErrAPITokenNotFound      = errors.New("api token not found")
+	ErrInvalidAPIToken       = errors.New("invalid, expired or revoked api token")
+)
+
+// apiTokenDisplaySize is how much of a token is kept for users to recognise it
+const apiTokenDisplaySize = len(domain.APITokenPrefix) + 6
+
+// scopeErrorDetails accompanies invalid_scope errors
+type scopeErrorDetails struct {
+	InvalidScopes []domain.Permission `json:"invalidScopes"`
+	AllowedScopes []domain.Permission `json:"allowedScopes"`
+}
+
+type APITokenService interface {
+	// Create issues a token limited to scopes the user's role already grants
+	Create(userID uint, req *domain.CreateAPITokenRequest, actor domain.Actor) (*domain.CreateAPITokenResponse, error)
+	List(userID uint) ([]domain.APIToken, error)
+	Revoke(userID, id uint, actor domain.Actor) error
+
+	// Authenticate resolves a presented token and records its use.
+	// Unknown, revoked and expired tokens, and tokens of disabled users, yield ErrInvalidAPIToken.
+	Authenticate(token, ip string) (*domain.APIToken, error)
+}
+
+type apiTokenService struct {
+	tokens repository.APITokenRepository
+	users  repository.UserRepository
+	audit  AuditService
+}
+
+func NewAPITokenService(tokens repository.APITokenRepository, users repository.UserRepository, audit AuditService) APITokenService {
+	return &apiTokenService{tokens: tokens, users: users, audit: audit}
+}
+
+func (s *apiTokenService) Create(userID uint, req *domain.CreateAPITokenRequest, actor domain.Actor) (*domain.CreateAPITokenResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return nil, apperror.BadRequest(ErrAPITokenNameInvalid)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, apperror.BadRequest(ErrAPITokenExpiryInvalid)
+	}
+
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound(ErrUserNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	scopes, err := validateScopes(user.Role, req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	random, _, err := newOpaqueToken()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	secret := domain.APITokenPrefix + random
+
+	token := &domain.APIToken{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    secret[:apiTokenDisplaySize],
+		TokenHash: hashToken(secret),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.tokens.Create(token); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	s.audit.Record(actor, domain.AuditAPITokenCreate, domain.AuditEntityAPIToken, auditID(token.ID), nil, token)
+
+	return &domain.CreateAPITokenResponse{APIToken: token, Token: secret}, nil
+}
+
+func (s *apiTokenService) List(userID uint) ([]domain.APIToken, error) {
+	tokens, err := s.tokens.ListByUser(userID)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return tokens, nil
+}
+
+func (s *apiTokenService) Revoke(userID, id uint, actor domain.Actor) error {
+	if err := s.tokens.Revoke(id, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(ErrAPITokenNotFound)
+		}
+		return apperror.InternalError(err)
+	}
+	s.audit.Record(actor, domain.AuditAPITokenRevoke, domain.AuditEntityAPIToken, auditID(id), nil, nil)
+	return nil
+}
+
+func (s *apiTokenService) Authenticate(token, ip string) (*domain.APIToken, error) {
+	apiToken, err := s.tokens.GetByTokenHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if apiToken.RevokedAt != nil ||
+		(apiToken.ExpiresAt != nil && now.After(*apiToken.ExpiresAt)) ||
+		apiToken.User == nil || apiToken.User.IsDisabled() {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if err := s.tokens.TouchLastUsed(apiToken.ID, ip, now); err != nil {
+		logger.Warn("failed to record api token use", "tokenId", apiToken.ID, "error", err)
+	}
+	return apiToken, nil
+}
+
+// validateScopes de-duplicates the requested scopes and rejects any that are
+// unknown, reserved for interactive sessions, or not granted by role
+func validateScopes(role domain.Role, requested []domain.Permission) ([]domain.Permission, error) {
+	if len(requested) == 0 {
+		return nil, apperror.BadRequest(ErrAPITokenScopesEmpty)
+	}
+
+	allowed := make(map[domain.Permission]bool)
+	for _, scope := range domain.APITokenScopes() {
+		allowed[scope] = role.Can(scope)
+	}
+
+	seen := make(map[domain.Permission]bool, len(requested))
+	scopes := make([]domain.Permission, 0, len(requested))
+	var invalid []domain.Permission
+	for _, scope := range requested {
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		if !allowed[scope] {
+			invalid = append(invalid, scope)
+			continue
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(invalid) > 0 {
+		return nil, apperror.BadRequest(ErrAPITokenScopeInvalid).WithDetails("invalid_scope", scopeErrorDetails{
+			InvalidScopes: invalid,
+			AllowedScopes: grantedScopes(role),
+		})
+	}
+	return scopes, nil
+}
+
+func grantedScopes(role domain.Role) []domain.Permission {
+	scopes := []domain.Permission{}
+	for _, scope := range domain.APITokenScopes() {
+		if role.Can(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
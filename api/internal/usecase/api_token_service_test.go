@@ -0,0 +1,158 @@
+package usecase
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// memoryAPITokens keeps tokens by hash and records uses
+type memoryAPITokens struct {
+	repository.APITokenRepository
+	byHash  map[string]*domain.APIToken
+	touched []uint
+}
+
+func (r *memoryAPITokens) Create(token *domain.APIToken) error {
+	token.ID = uint(len(r.byHash) + 1)
+	r.byHash[token.TokenHash] = token
+	return nil
+}
+
+func (r *memoryAPITokens) GetByTokenHash(hash string) (*domain.APIToken, error) {
+	token, ok := r.byHash[hash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return token, nil
+}
+
+func (r *memoryAPITokens) TouchLastUsed(id uint, _ string, _ time.Time) error {
+	r.touched = append(r.touched, id)
+	return nil
+}
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		role      domain.Role
+		requested []domain.Permission
+		want      []domain.Permission
+		invalid   []domain.Permission
+	}{
+		{
+			name:      "duplicates collapse",
+			role:      domain.RoleEditor,
+			requested: []domain.Permission{domain.PermPhotosWrite, domain.PermPhotosRead, domain.PermPhotosWrite},
+			want:      []domain.Permission{domain.PermPhotosWrite, domain.PermPhotosRead},
+		},
+		{
+			name:      "beyond the role",
+			role:      domain.RoleEditor,
+			requested: []domain.Permission{domain.PermPhotosRead, domain.PermPhotosDelete},
+			invalid:   []domain.Permission{domain.PermPhotosDelete},
+		},
+		{
+			name:      "user management stays with sessions",
+			role:      domain.RoleOwner,
+			requested: []domain.Permission{domain.PermUsersManage, domain.PermAdminsManage},
+			invalid:   []domain.Permission{domain.PermUsersManage, domain.PermAdminsManage},
+		},
+		{
+			name:      "unknown",
+			role:      domain.RoleAdmin,
+			requested: []domain.Permission{"photos:*"},
+			invalid:   []domain.Permission{"photos:*"},
+		},
+	}
+	for _, tt := range tests {
+		scopes, err := validateScopes(tt.role, tt.requested)
+		if tt.invalid == nil {
+			if err != nil || !reflect.DeepEqual(scopes, tt.want) {
+				t.Errorf("%s: scopes = %v, %v; want %v", tt.name, scopes, err, tt.want)
+			}
+			continue
+		}
+
+		appErr, ok := apperror.IsAppError(err)
+		if !ok || !errors.Is(err, ErrAPITokenScopeInvalid) {
+			t.Errorf("%s: err = %v, want ErrAPITokenScopeInvalid", tt.name, err)
+			continue
+		}
+		details, ok := appErr.Details.(scopeErrorDetails)
+		if !ok || !reflect.DeepEqual(details.InvalidScopes, tt.invalid) {
+			t.Errorf("%s: details = %+v, want invalid scopes %v", tt.name, appErr.Details, tt.invalid)
+			continue
+		}
+		for _, scope := range details.AllowedScopes {
+			if !tt.role.Can(scope) || scope == domain.PermUsersManage {
+				t.Errorf("%s: allowed scopes include %s", tt.name, scope)
+			}
+		}
+	}
+
+	if _, err := validateScopes(domain.RoleAdmin, nil); !errors.Is(err, ErrAPITokenScopesEmpty) {
+		t.Errorf("no scopes: err = %v, want ErrAPITokenScopesEmpty", err)
+	}
+}
+
+func TestAPITokenLifecycle(t *testing.T) {
+	users := &memoryUserRepo{users: map[uint]*domain.User{7: {ID: 7, Username: "bot-owner", Role: domain.RoleEditor}}}
+	tokens := &memoryAPITokens{byHash: map[string]*domain.APIToken{}}
+	svc := NewAPITokenService(tokens, users, discardAudit{})
+
+	created, err := svc.Create(7, &domain.CreateAPITokenRequest{Name: " deploy ", Scopes: []domain.Permission{domain.PermStorageUpload}}, domain.Actor{UserID: 7})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	secret := created.Token
+	if !strings.HasPrefix(secret, domain.APITokenPrefix) || created.APIToken.Name != "deploy" {
+		t.Fatalf("unexpected token %q named %q", secret, created.APIToken.Name)
+	}
+	if !strings.HasPrefix(secret, created.APIToken.Prefix) || len(created.APIToken.Prefix) >= len(secret) {
+		t.Fatalf("display prefix %q reveals too much of the token", created.APIToken.Prefix)
+	}
+	if _, ok := tokens.byHash[secret]; ok || created.APIToken.TokenHash != hashToken(secret) {
+		t.Fatal("token stored in plain text")
+	}
+
+	stored := created.APIToken
+	stored.User = users.users[7]
+	if token, err := svc.Authenticate(secret, "203.0.113.5"); err != nil || token.ID != stored.ID {
+		t.Fatalf("authenticate = %+v, %v", token, err)
+	}
+	if len(tokens.touched) != 1 {
+		t.Fatalf("uses recorded = %d, want 1", len(tokens.touched))
+	}
+
+	past := time.Now().Add(-time.Minute)
+	unusable := map[string]func(){
+		"expired":       func() { stored.ExpiresAt = &past },
+		"revoked":       func() { stored.RevokedAt = &past },
+		"disabled user": func() { stored.User = &domain.User{ID: 7, DisabledAt: &past} },
+	}
+	for name, spoil := range unusable {
+		saved := *stored
+		spoil()
+		if _, err := svc.Authenticate(secret, ""); !errors.Is(err, ErrInvalidAPIToken) {
+			t.Errorf("%s token: err = %v, want ErrInvalidAPIToken", name, err)
+		}
+		*stored = saved
+	}
+	if _, err := svc.Authenticate(domain.APITokenPrefix+"unknown", ""); !errors.Is(err, ErrInvalidAPIToken) {
+		t.Errorf("unknown token: err = %v, want ErrInvalidAPIToken", err)
+	}
+
+	future := time.Now().Add(-time.Second)
+	if _, err := svc.Create(7, &domain.CreateAPITokenRequest{Name: "x", Scopes: []domain.Permission{domain.PermPhotosRead}, ExpiresAt: &future}, domain.Actor{}); !errors.Is(err, ErrAPITokenExpiryInvalid) {
+		t.Errorf("expiry in the past: err = %v, want ErrAPITokenExpiryInvalid", err)
+	}
+}
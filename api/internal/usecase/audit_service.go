@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"gorm.io/datatypes"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// auditIgnoredFields change on every write or are derived, so they only add noise to diffs
+var auditIgnoredFields = map[string]bool{
+	"createdAt": true,
+	"updatedAt": true,
+	"srcSet":    true,
+	"variants":  true,
+}
+
+type AuditService interface {
+	// Record appends an event describing a mutation. before and after are
+	// marshalled to JSON and reduced to the fields that differ; pass nil for
+	// the side that does not exist. Recording is best-effort: failures are
+	// logged and never fail the mutation itself.
+	Record(actor domain.Actor, action domain.AuditAction, entityType domain.AuditEntityType, entityID string, before, after interface{})
+	// List returns events newest first
+	List(filters repository.AuditFilters) ([]domain.AuditEvent, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) Record(actor domain.Actor, action domain.AuditAction, entityType domain.AuditEntityType, entityID string, before, after interface{}) {
+	beforeJSON, afterJSON, err := auditDiff(before, after)
+	if err != nil {
+		logger.Error("failed to encode audit event", "action", action, "entityId", entityID, "error", err)
+	}
+
+	event := &domain.AuditEvent{
+		ActorName:  truncate(actor.Username, 150),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   truncate(entityID, 255),
+		Before:     beforeJSON,
+		After:      afterJSON,
+		IP:         actor.IP,
+		UserAgent:  truncate(actor.UserAgent, 500),
+	}
+	if actor.UserID != 0 {
+		event.ActorID = &actor.UserID
+	}
+	if actor.APITokenID != 0 {
+		event.APITokenID = &actor.APITokenID
+	}
+
+	if err := s.repo.Create(event); err != nil {
+		logger.Error("failed to record audit event", "action", action, "entityId", entityID, "error", err)
+	}
+}
+
+func (s *auditService) List(filters repository.AuditFilters) ([]domain.AuditEvent, error) {
+	events, err := s.repo.List(filters)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return events, nil
+}
+
+// auditDiff keeps the top-level JSON fields whose values differ between
+// before and after. When one side is nil the other is kept whole.
+func auditDiff(before, after interface{}) (datatypes.JSON, datatypes.JSON, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for key, value := range beforeFields {
+			if other, ok := afterFields[key]; ok && bytes.Equal(value, other) {
+				delete(beforeFields, key)
+				delete(afterFields, key)
+			}
+		}
+	}
+
+	beforeJSON, err := marshalAuditFields(beforeFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := marshalAuditFields(afterFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+func auditFields(value interface{}) (map[string]json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key := range auditIgnoredFields {
+		delete(fields, key)
+	}
+	return fields, nil
+}
+
+func marshalAuditFields(fields map[string]json.RawMessage) (datatypes.JSON, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(data), nil
+}
+
+// auditPhotoIDs is the payload of events that attach or detach photos
+type auditPhotoIDs struct {
+	PhotoIDs []uint `json:"photoIds"`
+}
+
+// auditID formats a numeric primary key as an audit entity ID
+func auditID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
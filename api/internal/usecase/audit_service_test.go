@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// capturedAudit keeps the events it is asked to store
+type capturedAudit struct {
+	repository.AuditRepository
+	events []*domain.AuditEvent
+	err    error
+}
+
+func (r *capturedAudit) Create(event *domain.AuditEvent) error {
+	r.events = append(r.events, event)
+	return r.err
+}
+
+func decodeAuditJSON(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	if data == nil {
+		return nil
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	return fields
+}
+
+func TestAuditDiffKeepsOnlyChangedFields(t *testing.T) {
+	before := domain.Tag{ID: 3, Name: "Night", Slug: "night", UpdatedAt: time.Unix(1, 0)}
+	after := domain.Tag{ID: 3, Name: "Night Street", Slug: "night-street", UpdatedAt: time.Unix(2, 0)}
+
+	beforeJSON, afterJSON, err := auditDiff(&before, &after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"name": "Night", "slug": "night"}
+	if got := decodeAuditJSON(t, beforeJSON); !reflect.DeepEqual(got, want) {
+		t.Errorf("before = %v, want %v", got, want)
+	}
+	want = map[string]interface{}{"name": "Night Street", "slug": "night-street"}
+	if got := decodeAuditJSON(t, afterJSON); !reflect.DeepEqual(got, want) {
+		t.Errorf("after = %v, want %v", got, want)
+	}
+
+	// Nothing but timestamps changed: both sides are empty objects, not null
+	touched := before
+	touched.UpdatedAt = time.Unix(5, 0)
+	beforeJSON, afterJSON, err = auditDiff(&before, &touched)
+	if err != nil || string(beforeJSON) != "{}" || string(afterJSON) != "{}" {
+		t.Errorf("timestamp-only diff = %s, %s, %v; want {}, {}", beforeJSON, afterJSON, err)
+	}
+}
+
+func TestAuditDiffKeepsCreatedAndDeletedEntitiesWhole(t *testing.T) {
+	tag := domain.Tag{ID: 3, Name: "Night", Slug: "night"}
+
+	beforeJSON, afterJSON, err := auditDiff(nil, &tag)
+	if err != nil || beforeJSON != nil {
+		t.Fatalf("create: before = %s, %v; want nil", beforeJSON, err)
+	}
+	if got := decodeAuditJSON(t, afterJSON); got["name"] != "Night" || got["id"] != float64(3) {
+		t.Errorf("create: after = %v, want the whole tag", got)
+	}
+	if _, ok := decodeAuditJSON(t, afterJSON)["createdAt"]; ok {
+		t.Error("create: timestamps are recorded")
+	}
+
+	beforeJSON, afterJSON, err = auditDiff(&tag, nil)
+	if err != nil || afterJSON != nil || decodeAuditJSON(t, beforeJSON)["slug"] != "night" {
+		t.Errorf("delete: = %s, %s, %v; want the whole tag before and nil after", beforeJSON, afterJSON, err)
+	}
+}
+
+func TestAuditDiffNeverRecordsSecrets(t *testing.T) {
+	before := domain.User{ID: 7, Username: "ada", Password: "old-hash", TOTPSecret: "JBSWY3DPEHPK3PXP"}
+	after := before
+	after.Password = "new-hash"
+
+	beforeJSON, afterJSON, err := auditDiff(&before, &after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{beforeJSON, afterJSON} {
+		if strings.Contains(string(data), "hash") || strings.Contains(string(data), "JBSWY3DPEHPK3PXP") {
+			t.Errorf("audit payload leaks a secret: %s", data)
+		}
+	}
+}
+
+func TestAuditRecordAttributesTheActor(t *testing.T) {
+	repo := &capturedAudit{}
+	svc := NewAuditService(repo)
+	actor := domain.Actor{UserID: 7, Username: "ada", APITokenID: 2, IP: "203.0.113.5", UserAgent: strings.Repeat("x", 600)}
+
+	svc.Record(actor, domain.AuditTagCreate, domain.AuditEntityTag, auditID(3), nil, auditPhotoIDs{PhotoIDs: []uint{1, 2}})
+	if len(repo.events) != 1 {
+		t.Fatalf("events = %d, want 1", len(repo.events))
+	}
+	event := repo.events[0]
+	if event.ActorID == nil || *event.ActorID != 7 || event.APITokenID == nil || *event.APITokenID != 2 {
+		t.Errorf("actor = %v / token %v, want 7 / 2", event.ActorID, event.APITokenID)
+	}
+	if event.EntityID != "3" || event.ActorName != "ada" || len(event.UserAgent) != 500 {
+		t.Errorf("event = %q by %q with a %d byte user agent", event.EntityID, event.ActorName, len(event.UserAgent))
+	}
+
+	// Background jobs have no user or token; a failed write never panics or blocks
+	repo.err = errors.New("database is down")
+	svc.Record(domain.SystemActor("purge"), domain.AuditTagDelete, domain.AuditEntityTag, "3", nil, nil)
+	if event := repo.events[1]; event.ActorID != nil || event.APITokenID != nil {
+		t.Errorf("system event attributed to %v / %v", event.ActorID, event.APITokenID)
+	}
+}
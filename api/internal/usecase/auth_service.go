@@ -1,85 +1,277 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"math"
+	"sync"
+	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"github.com/aton/atonWeb/api/internal/config"
 	"github.com/aton/atonWeb/api/internal/domain"
 	"github.com/aton/atonWeb/api/internal/infrastructure/jwt"
 	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
 )
 
 var (
 	ErrInvalidCredentials = errors.New("invalid username or password")
 	ErrUserExists         = errors.New("user already exists")
+	ErrAccountDisabled    = errors.New("this account has been disabled")
+	ErrTooManyAttempts    = errors.New("too many login attempts, try again later")
 )
 
+// RetryAfter is attached to 429 responses so clients know how long to back off
+type RetryAfter struct {
+	Seconds int `json:"retryAfter"`
+}
+
+// dummyPasswordHash lets unknown usernames cost one bcrypt comparison, like real ones
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy-password-for-timing"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
 type AuthService interface {
-	Login(username, password string) (string, error)
-	CreateUser(username, password, email string) (*domain.User, error)
-	ChangePasswordByUserID(userID uint, oldPassword, newPassword string) (*domain.User, error)
+	// Login returns tokens, or an MFA challenge when the account uses two-factor login
+	Login(username, password string, client domain.SessionClient) (*domain.LoginResult, error)
+	// VerifyMFA exchanges an MFA challenge and a TOTP or recovery code for tokens
+	VerifyMFA(challengeToken, code string, client domain.SessionClient) (*domain.TokenPair, error)
+	CreateUser(username, password, email string, actor domain.Actor) (*domain.User, error)
+	// ChangePasswordByUserID also revokes every session except sessionID
+	ChangePasswordByUserID(userID, sessionID uint, oldPassword, newPassword string, actor domain.Actor) (*domain.User, error)
+
+	// Refresh exchanges a refresh token for a new token pair, rotating the refresh token
+	Refresh(refreshToken string, client domain.SessionClient) (*domain.TokenPair, error)
+	Logout(refreshToken string) error
+	ListSessions(userID, currentSessionID uint) ([]domain.Session, error)
+	RevokeSession(userID, sessionID uint, actor domain.Actor) error
+
+	// SetupTOTP generates a new, still inactive, TOTP secret for the user
+	SetupTOTP(userID uint) (*domain.TOTPSetupResponse, error)
+	EnableTOTP(userID uint, code string, actor domain.Actor) (*domain.RecoveryCodesResponse, error)
+	DisableTOTP(userID uint, password, code string, actor domain.Actor) error
+	RegenerateRecoveryCodes(userID uint, code string, actor domain.Actor) (*domain.RecoveryCodesResponse, error)
+
+	// UnlockLogin lifts this instance's login backoff for a username
+	UnlockLogin(username string)
+
+	// AuthMethods reports which sign-in options are enabled
+	AuthMethods() domain.AuthMethods
+	// StartOIDCLogin begins a single sign-on with the configured provider
+	StartOIDCLogin(ctx context.Context) (*domain.OIDCAuthorization, error)
+	// CompleteOIDCLogin finishes a single sign-on; like Login it may return an MFA challenge
+	CompleteOIDCLogin(ctx context.Context, code, state string, client domain.SessionClient) (*domain.LoginResult, error)
 }
 
 type authService struct {
-	db         *gorm.DB
+	users      repository.UserRepository
+	sessions   repository.SessionRepository
+	attempts   repository.LoginAttemptRepository
+	mfa        repository.MFARepository
+	oidcStates repository.OIDCStateRepository
 	jwtManager *jwt.JWTManager
+	throttle   *loginThrottle
+	audit      AuditService
+
+	// sso is nil when single sign-on is not configured
+	sso           OIDCProvider
+	ssoSettings   oidcSettings
+	passwordLogin bool
+
+	refreshTTL  time.Duration
+	maxFailures int
+	lockout     time.Duration
+	mfaIssuer   string
 }
 
-func NewAuthService(db *gorm.DB, jwtManager *jwt.JWTManager) AuthService {
+func NewAuthService(users repository.UserRepository, sessions repository.SessionRepository, attempts repository.LoginAttemptRepository, mfa repository.MFARepository, oidcStates repository.OIDCStateRepository, sso OIDCProvider, jwtManager *jwt.JWTManager, audit AuditService, cfg config.Config) AuthService {
+	roleMapping := make(map[string]domain.Role, len(cfg.OIDCRoleMapping))
+	for group, role := range cfg.OIDCRoleMapping {
+		roleMapping[group] = domain.Role(role)
+	}
+
 	return &authService{
-		db:         db,
+		users:      users,
+		sessions:   sessions,
+		attempts:   attempts,
+		mfa:        mfa,
+		oidcStates: oidcStates,
 		jwtManager: jwtManager,
+		throttle:   newLoginThrottle(),
+		audit:      audit,
+		sso:        sso,
+		ssoSettings: oidcSettings{
+			providerName:  cfg.OIDCProviderName,
+			usernameClaim: cfg.OIDCUsernameClaim,
+			roleClaim:     cfg.OIDCRoleClaim,
+			roleMapping:   roleMapping,
+			defaultRole:   domain.Role(cfg.OIDCDefaultRole),
+			autoCreate:    cfg.OIDCAutoCreate,
+			linkByEmail:   cfg.OIDCLinkByEmail,
+		},
+		passwordLogin: cfg.PasswordLoginEnabled,
+		refreshTTL:    cfg.RefreshTokenTTL,
+		maxFailures:   cfg.LoginMaxFailures,
+		lockout:       cfg.LoginLockoutDuration,
+		mfaIssuer:     cfg.MFAIssuer,
 	}
 }
 
-func (s *authService) Login(username, password string) (string, error) {
-	var user domain.User
-	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
+// Login checks the throttle before touching the database, and every path that
+// reaches the database performs exactly one bcrypt comparison, so response
+// time does not reveal whether a username exists. A locked account answers
+// like a throttled client: 429 with a retry delay. Accounts with two-factor
+// login get a challenge instead of tokens; see VerifyMFA.
+func (s *authService) Login(username, password string, client domain.SessionClient) (*domain.LoginResult, error) {
+	if !s.passwordLogin {
+		return nil, apperror.Forbidden(ErrPasswordLoginDisabled)
+	}
+	if wait := s.throttle.Wait(client.IP, username); wait > 0 {
+		s.recordAttempt(username, nil, client, domain.LoginRateLimited)
+		return nil, tooManyAttempts(wait)
+	}
+
+	user, err := s.users.GetByUsername(username)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", apperror.Unauthorized(ErrInvalidCredentials)
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+			s.throttle.Failure(client.IP, username)
+			s.recordAttempt(username, nil, client, domain.LoginInvalidCredentials)
+			return nil, apperror.Unauthorized(ErrInvalidCredentials)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	passwordOK := user.CheckPassword(password)
+
+	now := time.Now()
+	if user.IsLocked(now) {
+		s.recordAttempt(username, &user.ID, client, domain.LoginLocked)
+		return nil, tooManyAttempts(user.LockedUntil.Sub(now))
+	}
+
+	if !passwordOK {
+		s.throttle.Failure(client.IP, username)
+		s.recordAttempt(username, &user.ID, client, domain.LoginInvalidCredentials)
+		updated, err := s.users.RecordLoginFailure(user.ID, s.maxFailures, s.lockout)
+		if err != nil {
+			logger.Error("failed to record login failure", "userId", user.ID, "error", err)
+		} else if updated.IsLocked(now) {
+			logger.Warn("account locked after repeated login failures", "userId", user.ID, "ip", client.IP)
 		}
-		return "", apperror.InternalError(err)
+		return nil, apperror.Unauthorized(ErrInvalidCredentials)
 	}
 
-	if !user.CheckPassword(password) {
-		return "", apperror.Unauthorized(ErrInvalidCredentials)
+	if user.IsDisabled() {
+		s.recordAttempt(username, &user.ID, client, domain.LoginDisabled)
+		return nil, apperror.Unauthorized(ErrAccountDisabled)
 	}
 
-	token, err := s.jwtManager.GenerateToken(user.ID, user.Username, user.Role)
+	if user.MFAEnabled() {
+		return s.startMFAChallenge(user, client)
+	}
+
+	tokens, err := s.completeLogin(user, client)
 	if err != nil {
-		return "", apperror.InternalError(err)
+		return nil, err
 	}
+	return &domain.LoginResult{TokenPair: tokens}, nil
+}
 
-	return token, nil
+// completeLogin runs once every factor has been checked
+func (s *authService) completeLogin(user *domain.User, client domain.SessionClient) (*domain.TokenPair, error) {
+	s.throttle.Success(user.Username)
+	s.recordAttempt(user.Username, &user.ID, client, domain.LoginSucceeded)
+	if user.FailedLoginCount > 0 || user.LockedUntil != nil {
+		if err := s.users.ResetLoginFailures(user.ID); err != nil {
+			logger.Error("failed to reset login failures", "userId", user.ID, "error", err)
+		}
+	}
+
+	tokens, err := s.openSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	tokens.MustChangePassword = user.MustChangePassword
+	return tokens, nil
+}
+
+func (s *authService) UnlockLogin(username string) {
+	s.throttle.Reset(username)
 }
 
-func (s *authService) CreateUser(username, password, email string) (*domain.User, error) {
-	var existingUser domain.User
-	if err := s.db.Where("username = ?", username).First(&existingUser).Error; err == nil {
+// recordAttempt is best-effort: a logging failure must not block a login
+func (s *authService) recordAttempt(username string, userID *uint, client domain.SessionClient, result domain.LoginAttemptResult) {
+	attempt := &domain.LoginAttempt{
+		Username:  truncate(username, 150),
+		UserID:    userID,
+		IP:        client.IP,
+		UserAgent: truncate(client.UserAgent, 500),
+		Result:    result,
+	}
+	if err := s.attempts.Create(attempt); err != nil {
+		logger.Error("failed to record login attempt", "username", username, "error", err)
+	}
+}
+
+func tooManyAttempts(wait time.Duration) *apperror.AppError {
+	return apperror.TooManyRequests(ErrTooManyAttempts).WithDetails("too_many_attempts", retryAfter(wait))
+}
+
+// retryAfter rounds wait up to whole seconds, at least one
+func retryAfter(wait time.Duration) RetryAfter {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return RetryAfter{Seconds: seconds}
+}
+
+func (s *authService) CreateUser(username, password, email string, actor domain.Actor) (*domain.User, error) {
+	exists, err := s.users.UsernameExists(username)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if exists {
 		return nil, apperror.Conflict(ErrUserExists)
 	}
 
+	// The first account owns the site; later ones start read-only until promoted
+	userCount, err := s.users.Count()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	role := domain.InitialRole(userCount)
+
 	user := &domain.User{
 		Username: username,
 		Email:    email,
-		Role:     "admin",
+		Role:     role,
 	}
 
 	if err := user.HashPassword(password); err != nil {
 		return nil, apperror.InternalError(err)
 	}
 
-	if err := s.db.Create(user).Error; err != nil {
+	if err := s.users.Create(user); err != nil {
 		return nil, apperror.InternalError(err)
 	}
 
+	s.audit.Record(actor, domain.AuditUserCreate, domain.AuditEntityUser, auditID(user.ID), nil, user)
 	return user, nil
 }
 
-func (s *authService) ChangePasswordByUserID(userID uint, oldPassword, newPassword string) (*domain.User, error) {
-	var currentUser domain.User
-	if err := s.db.First(&currentUser, userID).Error; err != nil {
+func (s *authService) ChangePasswordByUserID(userID, sessionID uint, oldPassword, newPassword string, actor domain.Actor) (*domain.User, error) {
+	currentUser, err := s.users.GetByID(userID)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, apperror.NotFound(ErrInvalidCredentials)
 		}
@@ -94,9 +286,16 @@ func (s *authService) ChangePasswordByUserID(userID uint, oldPassword, newPasswo
 		return nil, apperror.InternalError(err)
 	}
 
-	if err := s.db.Model(&currentUser).Update("password", currentUser.Password).Error; err != nil {
+	if err := s.users.UpdatePassword(currentUser.ID, currentUser.Password, false); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	currentUser.MustChangePassword = false
+
+	// Sign out every other device that may have known the old password
+	if err := s.sessions.RevokeAllForUser(currentUser.ID, sessionID); err != nil {
 		return nil, apperror.InternalError(err)
 	}
 
-	return &currentUser, nil
+	s.audit.Record(actor, domain.AuditPasswordChange, domain.AuditEntityUser, auditID(currentUser.ID), nil, nil)
+	return currentUser, nil
 }
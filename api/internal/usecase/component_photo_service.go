@@ -4,19 +4,27 @@ import (
 	"encoding/json"
 	"errors"
 
+	"gorm.io/gorm"
+
 	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
 	"github.com/aton/atonWeb/api/internal/repository"
 )
 
+var ErrComponentPhotoNotFound = errors.New("component photo not found")
+
 type ComponentPhotoService interface {
 	// Assign photo to component
-	AssignPhotoToComponent(req domain.AssignPhotoToComponentRequest) error
+	AssignPhotoToComponent(req domain.AssignPhotoToComponentRequest, actor domain.Actor) error
+
+	// Get a single assignment
+	GetComponentPhoto(id uint) (*domain.ComponentPhotoResponse, error)
 
-	// Update component photo
-	UpdateComponentPhoto(id uint, req domain.UpdateComponentPhotoRequest) error
+	// Update component photo; ifMatch lists acceptable versions, nil skips the check
+	UpdateComponentPhoto(id uint, req domain.UpdateComponentPhotoRequest, ifMatch []int, actor domain.Actor) (*domain.ComponentPhotoResponse, error)
 
 	// Remove photo from component
-	RemovePhotoFromComponent(id uint) error
+	RemovePhotoFromComponent(id uint, actor domain.Actor) error
 
 	// Get photos by component name
 	GetPhotosByComponent(componentName string) ([]domain.ComponentPhotoResponse, error)
@@ -26,14 +34,16 @@ type ComponentPhotoService interface {
 }
 
 type componentPhotoService struct {
-	repo repository.ComponentPhotoRepository
+	repo  repository.ComponentPhotoRepository
+	urls  PhotoURLResolver
+	audit AuditService
 }
 
-func NewComponentPhotoService(repo repository.ComponentPhotoRepository) ComponentPhotoService {
-	return &componentPhotoService{repo: repo}
+func NewComponentPhotoService(repo repository.ComponentPhotoRepository, urls PhotoURLResolver, audit AuditService) ComponentPhotoService {
+	return &componentPhotoService{repo: repo, urls: urls, audit: audit}
 }
 
-func (s *componentPhotoService) AssignPhotoToComponent(req domain.AssignPhotoToComponentRequest) error {
+func (s *componentPhotoService) AssignPhotoToComponent(req domain.AssignPhotoToComponentRequest, actor domain.Actor) error {
 	// Check if already exists
 	exists, err := s.repo.Exists(req.ComponentName, req.PhotoID)
 	if err != nil {
@@ -56,35 +66,89 @@ func (s *componentPhotoService) AssignPhotoToComponent(req domain.AssignPhotoToC
 		Props:         propsJSON,
 	}
 
-	return s.repo.Assign(componentPhoto)
+	if err := s.repo.Assign(componentPhoto); err != nil {
+		return err
+	}
+
+	s.audit.Record(actor, domain.AuditComponentPhotoAssign, domain.AuditEntityComponentPhoto, auditID(componentPhoto.ID), nil, s.toResponse(*componentPhoto))
+	return nil
 }
 
-func (s *componentPhotoService) UpdateComponentPhoto(id uint, req domain.UpdateComponentPhotoRequest) error {
+func (s *componentPhotoService) GetComponentPhoto(id uint) (*domain.ComponentPhotoResponse, error) {
+	componentPhoto, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound(ErrComponentPhotoNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	return s.resolve(s.toResponse(*componentPhoto)), nil
+}
+
+func (s *componentPhotoService) UpdateComponentPhoto(id uint, req domain.UpdateComponentPhotoRequest, ifMatch []int, actor domain.Actor) (*domain.ComponentPhotoResponse, error) {
 	// Get existing record
 	existing, err := s.repo.GetByID(id)
 	if err != nil {
-		return err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound(ErrComponentPhotoNotFound)
+		}
+		return nil, apperror.InternalError(err)
 	}
+	if !versionMatches(existing.Version, ifMatch) {
+		return nil, s.staleError(existing)
+	}
+
+	before := s.toResponse(*existing)
 
-	// Prepare update
-	updateData := &domain.ComponentPhoto{}
+	// Apply changes on top of the current record
 	if req.Order != nil {
-		updateData.Order = *req.Order
+		existing.Order = *req.Order
 	}
 	if req.Props != nil {
 		propsJSON, err := json.Marshal(req.Props)
 		if err != nil {
-			return err
+			return nil, apperror.BadRequest(err)
 		}
-		updateData.Props = propsJSON
+		existing.Props = propsJSON
 	}
 
-	_ = existing // avoid unused warning
-	return s.repo.Update(id, updateData)
+	if err := s.repo.Update(existing); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			current, getErr := s.repo.GetByID(id)
+			if getErr != nil {
+				return nil, apperror.NotFound(ErrComponentPhotoNotFound)
+			}
+			return nil, s.staleError(current)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	updated := s.toResponse(*existing)
+	s.audit.Record(actor, domain.AuditComponentPhotoUpdate, domain.AuditEntityComponentPhoto, auditID(id), before, updated)
+	return s.resolve(updated), nil
 }
 
-func (s *componentPhotoService) RemovePhotoFromComponent(id uint) error {
-	return s.repo.Remove(id)
+// staleError is the 412 returned for a lost update; it carries the current assignment
+func (s *componentPhotoService) staleError(current *domain.ComponentPhoto) *apperror.AppError {
+	return apperror.PreconditionFailed(ErrVersionMismatch).WithDetails("version_conflict", s.resolve(s.toResponse(*current)))
+}
+
+func (s *componentPhotoService) RemovePhotoFromComponent(id uint, actor domain.Actor) error {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(ErrComponentPhotoNotFound)
+		}
+		return apperror.InternalError(err)
+	}
+
+	if err := s.repo.Remove(id); err != nil {
+		return apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditComponentPhotoRemove, domain.AuditEntityComponentPhoto, auditID(id), s.toResponse(*existing), nil)
+	return nil
 }
 
 func (s *componentPhotoService) GetPhotosByComponent(componentName string) ([]domain.ComponentPhotoResponse, error) {
@@ -110,24 +174,39 @@ func (s *componentPhotoService) GetComponentsByPhoto(photoID uint) ([]domain.Com
 func (s *componentPhotoService) toResponseList(componentPhotos []domain.ComponentPhoto) []domain.ComponentPhotoResponse {
 	responses := make([]domain.ComponentPhotoResponse, len(componentPhotos))
 	for i, cp := range componentPhotos {
-		var props domain.ComponentPhotoProps
-		if len(cp.Props) > 0 {
-			_ = json.Unmarshal(cp.Props, &props)
-		}
+		responses[i] = *s.resolve(s.toResponse(cp))
+	}
+	return responses
+}
 
-		responses[i] = domain.ComponentPhotoResponse{
-			ID:            cp.ID,
-			ComponentName: cp.ComponentName,
-			PhotoID:       cp.PhotoID,
-			Order:         cp.Order,
-			Props:         props,
-			CreatedAt:     cp.CreatedAt,
-			UpdatedAt:     cp.UpdatedAt,
-		}
+func (s *componentPhotoService) toResponse(cp domain.ComponentPhoto) *domain.ComponentPhotoResponse {
+	var props domain.ComponentPhotoProps
+	if len(cp.Props) > 0 {
+		_ = json.Unmarshal(cp.Props, &props)
+	}
 
-		if cp.Photo.ID != 0 {
-			responses[i].Photo = &cp.Photo
-		}
+	response := &domain.ComponentPhotoResponse{
+		ID:            cp.ID,
+		ComponentName: cp.ComponentName,
+		PhotoID:       cp.PhotoID,
+		Order:         cp.Order,
+		Props:         props,
+		Version:       cp.Version,
+		CreatedAt:     cp.CreatedAt,
+		UpdatedAt:     cp.UpdatedAt,
 	}
-	return responses
+
+	if cp.Photo.ID != 0 {
+		response.Photo = &cp.Photo
+	}
+	return response
+}
+
+// resolve fills in the URLs of the assigned photo once the response is no
+// longer needed for the audit log
+func (s *componentPhotoService) resolve(response *domain.ComponentPhotoResponse) *domain.ComponentPhotoResponse {
+	if response.Photo != nil {
+		s.urls.Resolve(response.Photo)
+	}
+	return response
 }
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttlePolicy allows freeFailures failures, then blocks for baseDelay,
+// doubling with every further failure up to maxDelay
+type throttlePolicy struct {
+	freeFailures int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+}
+
+var (
+	// An IP may try many usernames (shared NAT, typos), so it gets more slack
+	ipThrottlePolicy       = throttlePolicy{freeFailures: 10, baseDelay: time.Second, maxDelay: 15 * time.Minute}
+	usernameThrottlePolicy = throttlePolicy{freeFailures: 3, baseDelay: time.Second, maxDelay: 15 * time.Minute}
+	// Every reset request costs a lookup and maybe a mail, so it counts whatever its outcome
+	resetIPThrottlePolicy = throttlePolicy{freeFailures: 5, baseDelay: time.Minute, maxDelay: time.Hour}
+)
+
+// sweepThreshold bounds memory: above it, idle entries are dropped on the next failure
+const sweepThreshold = 10000
+
+type throttleEntry struct {
+	failures     int
+	blockedUntil time.Time
+	lastFailure  time.Time
+	policy       *throttlePolicy
+}
+
+// loginThrottle applies exponential backoff per IP and per username.
+// State lives in memory, so each API instance throttles independently;
+// the persistent lockout on domain.User covers the multi-instance case.
+type loginThrottle struct {
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+}
+
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{entries: make(map[string]*throttleEntry)}
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+func usernameThrottleKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// Wait returns how long the caller must wait before trying again, or 0
+func (t *loginThrottle) Wait(ip, username string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{ipThrottleKey(ip), usernameThrottleKey(username)} {
+		if entry, ok := t.entries[key]; ok && entry.blockedUntil.After(now) {
+			if d := entry.blockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// Failure records a failed attempt against both the IP and the username
+func (t *loginThrottle) Failure(ip, username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if len(t.entries) > sweepThreshold {
+		t.sweep(now)
+	}
+	t.fail(ipThrottleKey(ip), &ipThrottlePolicy, now)
+	t.fail(usernameThrottleKey(username), &usernameThrottlePolicy, now)
+}
+
+// Success clears the username's backoff. The IP keeps its history so an
+// attacker cannot reset it by logging into an account of their own.
+func (t *loginThrottle) Success(username string) {
+	t.Reset(username)
+}
+
+// Reset clears the username's backoff, e.g. when an admin unlocks the account
+func (t *loginThrottle) Reset(username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, usernameThrottleKey(username))
+}
+
+// Take counts a request from ip under policy, for endpoints where every
+// request counts rather than only failed ones. It returns how long ip must
+// wait, or 0 when the request may proceed; blocked requests are not counted.
+func (t *loginThrottle) Take(ip string, policy *throttlePolicy) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	key := ipThrottleKey(ip)
+	if entry, ok := t.entries[key]; ok {
+		if entry.blockedUntil.After(now) {
+			return entry.blockedUntil.Sub(now)
+		}
+		// A quiet spell as long as the longest block starts over
+		if now.Sub(entry.lastFailure) > policy.maxDelay {
+			delete(t.entries, key)
+		}
+	}
+	if len(t.entries) > sweepThreshold {
+		t.sweep(now)
+	}
+	t.fail(key, policy, now)
+	return 0
+}
+
+func (t *loginThrottle) fail(key string, policy *throttlePolicy, now time.Time) {
+	entry, ok := t.entries[key]
+	if !ok {
+		entry = &throttleEntry{policy: policy}
+		t.entries[key] = entry
+	}
+	entry.failures++
+	entry.lastFailure = now
+
+	if over := entry.failures - policy.freeFailures; over > 0 {
+		delay := policy.maxDelay
+		if over <= 30 {
+			if d := policy.baseDelay << (over - 1); d < policy.maxDelay {
+				delay = d
+			}
+		}
+		entry.blockedUntil = now.Add(delay)
+	}
+}
+
+// sweep forgets entries idle for longer than their maximum delay
+func (t *loginThrottle) sweep(now time.Time) {
+	for key, entry := range t.entries {
+		if now.Sub(entry.lastFailure) > entry.policy.maxDelay {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package usecase
+
+import "testing"
+
+func TestLoginThrottleResetLiftsUsernameBackoff(t *testing.T) {
+	throttle := newLoginThrottle()
+	for i := 0; i <= usernameThrottlePolicy.freeFailures; i++ {
+		throttle.Failure("203.0.113.7", "alice")
+	}
+	if throttle.Wait("198.51.100.1", "alice") == 0 {
+		t.Fatal("username is not throttled after repeated failures")
+	}
+
+	throttle.Reset("Alice ")
+	if wait := throttle.Wait("198.51.100.1", "alice"); wait != 0 {
+		t.Fatalf("wait after reset = %v, want 0", wait)
+	}
+}
@@ -0,0 +1,345 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrInvalidMFAChallenge = errors.New("login challenge is invalid or has expired, please sign in again")
+	ErrInvalidMFACode      = errors.New("invalid verification code")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrTOTPNotSetUp        = errors.New("start two-factor setup before enabling it")
+)
+
+const (
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+
+	totpPeriod = 30
+	// Accept the previous and next code as well, to allow for clock drift
+	totpSkew = 1
+
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+// startMFAChallenge stores a single-use challenge for a user whose password was correct
+func (s *authService) startMFAChallenge(user *domain.User, client domain.SessionClient) (*domain.LoginResult, error) {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	now := time.Now()
+	challenge := &domain.MFAChallenge{
+		UserID:    user.ID,
+		TokenHash: hash,
+		IP:        client.IP,
+		ExpiresAt: now.Add(mfaChallengeTTL),
+	}
+	if err := s.mfa.CreateChallenge(challenge); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if err := s.mfa.DeleteExpiredChallenges(now); err != nil {
+		logger.Warn("failed to delete expired mfa challenges", "error", err)
+	}
+
+	s.recordAttempt(user.Username, &user.ID, client, domain.LoginMFARequired)
+	return &domain.LoginResult{
+		MFARequired:        true,
+		ChallengeToken:     token,
+		ChallengeExpiresIn: int(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// VerifyMFA is the second login step. Wrong codes count towards the same
+// throttle and lockout as wrong passwords, and burn the challenge after
+// mfaChallengeMaxAttempts tries.
+func (s *authService) VerifyMFA(challengeToken, code string, client domain.SessionClient) (*domain.TokenPair, error) {
+	challenge, err := s.mfa.GetChallenge(hashToken(challengeToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.Unauthorized(ErrInvalidMFAChallenge)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	now := time.Now()
+	user, err := s.users.GetByID(challenge.UserID)
+	if err != nil || now.After(challenge.ExpiresAt) || user.IsDisabled() || !user.MFAEnabled() {
+		s.dropChallenge(challenge.ID)
+		return nil, apperror.Unauthorized(ErrInvalidMFAChallenge)
+	}
+
+	if wait := s.throttle.Wait(client.IP, user.Username); wait > 0 {
+		s.recordAttempt(user.Username, &user.ID, client, domain.LoginRateLimited)
+		return nil, tooManyAttempts(wait)
+	}
+	if user.IsLocked(now) {
+		s.dropChallenge(challenge.ID)
+		s.recordAttempt(user.Username, &user.ID, client, domain.LoginLocked)
+		return nil, tooManyAttempts(user.LockedUntil.Sub(now))
+	}
+
+	ok, err := s.checkSecondFactor(user, code)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if !ok {
+		s.throttle.Failure(client.IP, user.Username)
+		s.recordAttempt(user.Username, &user.ID, client, domain.LoginInvalidMFACode)
+		if attempts, err := s.mfa.IncrementChallengeAttempts(challenge.ID); err != nil || attempts >= mfaChallengeMaxAttempts {
+			s.dropChallenge(challenge.ID)
+		}
+		if _, err := s.users.RecordLoginFailure(user.ID, s.maxFailures, s.lockout); err != nil {
+			logger.Error("failed to record login failure", "userId", user.ID, "error", err)
+		}
+		return nil, apperror.Unauthorized(ErrInvalidMFACode)
+	}
+
+	// Consuming the challenge makes a second concurrent verify fail
+	if err := s.mfa.DeleteChallenge(challenge.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.Unauthorized(ErrInvalidMFAChallenge)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	return s.completeLogin(user, client)
+}
+
+func (s *authService) dropChallenge(id uint) {
+	if err := s.mfa.DeleteChallenge(id); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("failed to delete mfa challenge", "challengeId", id, "error", err)
+	}
+}
+
+func (s *authService) SetupTOTP(userID uint) (*domain.TOTPSetupResponse, error) {
+	user, err := s.currentUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, apperror.Conflict(ErrMFAAlreadyEnabled)
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.mfaIssuer,
+		AccountName: user.Username,
+	})
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if err := s.users.SetTOTPSecret(user.ID, key.Secret()); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	return &domain.TOTPSetupResponse{Secret: key.Secret(), OTPAuthURL: key.URL()}, nil
+}
+
+// EnableTOTP turns two-factor login on once the user proves their
+// authenticator works, and issues the first set of recovery codes
+func (s *authService) EnableTOTP(userID uint, code string, actor domain.Actor) (*domain.RecoveryCodesResponse, error) {
+	user, err := s.currentUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, apperror.Conflict(ErrMFAAlreadyEnabled)
+	}
+	if user.TOTPSecret == "" {
+		return nil, apperror.BadRequest(ErrTOTPNotSetUp)
+	}
+
+	step, ok := matchTOTP(user.TOTPSecret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, apperror.BadRequest(ErrInvalidMFACode)
+	}
+	if err := s.users.EnableTOTP(user.ID, step); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	s.audit.Record(actor, domain.AuditMFAEnable, domain.AuditEntityUser, auditID(user.ID), nil, nil)
+
+	return s.issueRecoveryCodes(user.ID)
+}
+
+func (s *authService) DisableTOTP(userID uint, password, code string, actor domain.Actor) error {
+	user, err := s.currentUser(userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled() {
+		return apperror.BadRequest(ErrMFANotEnabled)
+	}
+	if !user.CheckPassword(password) {
+		return apperror.Unauthorized(ErrInvalidCredentials)
+	}
+	if err := s.requireSecondFactor(user, code); err != nil {
+		return err
+	}
+
+	if err := s.users.DisableTOTP(user.ID); err != nil {
+		return apperror.InternalError(err)
+	}
+	if err := s.mfa.DeleteForUser(user.ID); err != nil {
+		return apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditMFADisable, domain.AuditEntityUser, auditID(user.ID), nil, nil)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, invalidating the old ones
+func (s *authService) RegenerateRecoveryCodes(userID uint, code string, actor domain.Actor) (*domain.RecoveryCodesResponse, error) {
+	user, err := s.currentUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MFAEnabled() {
+		return nil, apperror.BadRequest(ErrMFANotEnabled)
+	}
+	if err := s.requireSecondFactor(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.issueRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(actor, domain.AuditMFARecoveryCodes, domain.AuditEntityUser, auditID(user.ID), nil, nil)
+	return codes, nil
+}
+
+func (s *authService) currentUser(userID uint) (*domain.User, error) {
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound(ErrUserNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	return user, nil
+}
+
+func (s *authService) requireSecondFactor(user *domain.User, code string) error {
+	ok, err := s.checkSecondFactor(user, code)
+	if err != nil {
+		return apperror.InternalError(err)
+	}
+	if !ok {
+		return apperror.BadRequest(ErrInvalidMFACode)
+	}
+	return nil
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery code,
+// consuming either so it cannot be replayed
+func (s *authService) checkSecondFactor(user *domain.User, code string) (bool, error) {
+	code = normalizeCode(code)
+
+	if isTOTPCode(code) {
+		step, ok := matchTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		if err := s.users.UseTOTPStep(user.ID, step); err != nil {
+			if errors.Is(err, repository.ErrTOTPStepUsed) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	if err := s.mfa.UseRecoveryCode(user.ID, hashToken(code)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	logger.Info("recovery code used", "userId", user.ID)
+	return true, nil
+}
+
+func (s *authService) issueRecoveryCodes(userID uint) (*domain.RecoveryCodesResponse, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, apperror.InternalError(err)
+		}
+		codes[i] = code
+		hashes[i] = hashToken(normalizeCode(code))
+	}
+
+	if err := s.mfa.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return &domain.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// matchTOTP compares code against the TOTP codes around now and returns the
+// matching time step
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	if secret == "" {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := hotp.GenerateCodeCustom(secret, uint64(step), hotp.ValidateOpts{
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeCode strips the separators users may type or paste
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))[:recoveryCodeLength]
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:], nil
+}
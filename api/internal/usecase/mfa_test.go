@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+func totpCode(t *testing.T, step int64) string {
+	t.Helper()
+	code, err := hotp.GenerateCodeCustom(testTOTPSecret, uint64(step), hotp.ValidateOpts{Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+	if err != nil {
+		t.Fatalf("generate code: %v", err)
+	}
+	return code
+}
+
+// lastStepUsers accepts a TOTP step only when it is later than the last one
+// used, like the conditional update in the user repository
+type lastStepUsers struct {
+	repository.UserRepository
+	lastStep int64
+}
+
+func (r *lastStepUsers) UseTOTPStep(_ uint, step int64) error {
+	if step <= r.lastStep {
+		return repository.ErrTOTPStepUsed
+	}
+	r.lastStep = step
+	return nil
+}
+
+// recoveryCodeStore keeps recovery code hashes and whether each was used
+type recoveryCodeStore struct {
+	repository.MFARepository
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+func (r *recoveryCodeStore) ReplaceRecoveryCodes(_ uint, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.used = make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		r.used[hash] = false
+	}
+	return nil
+}
+
+func (r *recoveryCodeStore) UseRecoveryCode(_ uint, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if used, ok := r.used[hash]; !ok || used {
+		return gorm.ErrRecordNotFound
+	}
+	r.used[hash] = true
+	return nil
+}
+
+func TestMatchTOTPAllowsOneStepOfClockDrift(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	current := now.Unix() / totpPeriod
+
+	for _, step := range []int64{current - 1, current, current + 1} {
+		got, ok := matchTOTP(testTOTPSecret, totpCode(t, step), now)
+		if !ok || got != step {
+			t.Errorf("code of step %+d = %d, %v; want accepted as that step", step-current, got, ok)
+		}
+	}
+	for _, step := range []int64{current - 2, current + 2} {
+		if _, ok := matchTOTP(testTOTPSecret, totpCode(t, step), now); ok {
+			t.Errorf("code of step %+d accepted", step-current)
+		}
+	}
+	if _, ok := matchTOTP("", totpCode(t, current), now); ok {
+		t.Error("code accepted without a secret")
+	}
+}
+
+func TestSecondFactorRejectsReplayedTOTPCodes(t *testing.T) {
+	users := &lastStepUsers{}
+	s := &authService{users: users, mfa: &recoveryCodeStore{}}
+	user := &domain.User{ID: 1, TOTPSecret: testTOTPSecret}
+
+	current := time.Now().Unix() / totpPeriod
+	code := totpCode(t, current)
+	if ok, err := s.checkSecondFactor(user, code[:3]+" "+code[3:]); !ok || err != nil {
+		t.Fatalf("first use = %v, %v; want accepted", ok, err)
+	}
+	if ok, err := s.checkSecondFactor(user, code); ok || err != nil {
+		t.Fatalf("replay = %v, %v; want rejected", ok, err)
+	}
+	// An older code that is still inside the drift window is spent as well
+	if ok, err := s.checkSecondFactor(user, totpCode(t, current-1)); ok || err != nil {
+		t.Fatalf("earlier step after a later one = %v, %v; want rejected", ok, err)
+	}
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	codes := &recoveryCodeStore{}
+	s := &authService{users: &lastStepUsers{}, mfa: codes}
+	user := &domain.User{ID: 1, TOTPSecret: testTOTPSecret}
+
+	issued, err := s.issueRecoveryCodes(user.ID)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if len(issued.RecoveryCodes) != recoveryCodeCount || len(codes.used) != recoveryCodeCount {
+		t.Fatalf("issued %d codes, stored %d hashes; want %d", len(issued.RecoveryCodes), len(codes.used), recoveryCodeCount)
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	for _, code := range issued.RecoveryCodes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q does not look like xxxxx-xxxxx", code)
+		}
+		// Only hashes are stored
+		if _, ok := codes.used[code]; ok {
+			t.Errorf("code %q stored in plain text", code)
+		}
+	}
+
+	// Codes may be typed in capitals and without the hyphen
+	code := issued.RecoveryCodes[0]
+	typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+	if ok, err := s.checkSecondFactor(user, typed); !ok || err != nil {
+		t.Fatalf("first use of %q = %v, %v; want accepted", typed, ok, err)
+	}
+	if ok, err := s.checkSecondFactor(user, code); ok || err != nil {
+		t.Fatalf("second use = %v, %v; want rejected", ok, err)
+	}
+	if ok, err := s.checkSecondFactor(user, "aaaaa-aaaaa"); ok || err != nil {
+		t.Fatalf("unknown code = %v, %v; want rejected", ok, err)
+	}
+
+	// New codes replace the old ones
+	if _, err := s.issueRecoveryCodes(user.ID); err != nil {
+		t.Fatalf("reissue: %v", err)
+	}
+	if ok, _ := s.checkSecondFactor(user, issued.RecoveryCodes[1]); ok {
+		t.Fatal("code from the replaced set accepted")
+	}
+}
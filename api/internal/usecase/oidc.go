@@ -0,0 +1,379 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/oidc"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrPasswordLoginDisabled = errors.New("password login is disabled, sign in with single sign-on")
+	ErrOIDCNotConfigured     = errors.New("single sign-on is not configured")
+	ErrOIDCUnavailable       = errors.New("the identity provider is unavailable, try again later")
+	ErrInvalidOIDCState      = errors.New("single sign-on request is invalid or has expired, please try again")
+	ErrOIDCLoginFailed       = errors.New("single sign-on failed, please try again")
+	ErrOIDCAccountNotFound   = errors.New("no account is linked to this identity")
+	ErrOIDCEmailMissing      = errors.New("the identity provider did not share an email address")
+	ErrOIDCEmailConflict     = errors.New("an account with this email already exists but cannot be linked automatically")
+)
+
+const (
+	oidcStateTTL = 10 * time.Minute
+	// Attempts at a free username before falling back to a random suffix
+	oidcUsernameTries = 20
+)
+
+// OIDCProvider runs the authorization code flow with one identity provider;
+// *oidc.Client implements it
+type OIDCProvider interface {
+	NewVerifier() string
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, code, verifier, nonce string) (*oidc.Identity, error)
+}
+
+// oidcRolePriority picks the strongest role when claim values map to several
+var oidcRolePriority = []domain.Role{domain.RoleOwner, domain.RoleAdmin, domain.RoleEditor, domain.RoleViewer}
+
+// oidcSettings is the claim mapping for single sign-on users
+type oidcSettings struct {
+	providerName  string
+	usernameClaim string
+	roleClaim     string
+	roleMapping   map[string]domain.Role
+	defaultRole   domain.Role
+	autoCreate    bool
+	linkByEmail   bool
+}
+
+func (s *authService) AuthMethods() domain.AuthMethods {
+	methods := domain.AuthMethods{Password: s.passwordLogin, OIDC: s.sso != nil}
+	if s.sso != nil {
+		methods.OIDCName = s.ssoSettings.providerName
+	}
+	return methods
+}
+
+// StartOIDCLogin stores the state, nonce and PKCE verifier of a new single
+// sign-on and returns the provider URL to send the browser to
+func (s *authService) StartOIDCLogin(ctx context.Context) (*domain.OIDCAuthorization, error) {
+	if s.sso == nil {
+		return nil, apperror.NotFound(ErrOIDCNotConfigured)
+	}
+
+	state, stateHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	nonce, _, err := newOpaqueToken()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	verifier := s.sso.NewVerifier()
+
+	authURL, err := s.sso.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		logger.Error("failed to reach oidc provider", "error", err)
+		return nil, apperror.ServiceUnavailable(ErrOIDCUnavailable)
+	}
+
+	now := time.Now()
+	loginState := &domain.OIDCLoginState{
+		StateHash:    stateHash,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(oidcStateTTL),
+	}
+	if err := s.oidcStates.Create(loginState); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if err := s.oidcStates.DeleteExpired(now); err != nil {
+		logger.Warn("failed to delete expired oidc login states", "error", err)
+	}
+
+	return &domain.OIDCAuthorization{
+		AuthorizationURL: authURL,
+		State:            state,
+		ExpiresIn:        int(oidcStateTTL.Seconds()),
+	}, nil
+}
+
+// CompleteOIDCLogin redeems the code the provider redirected back with. The
+// identity is matched to an account by provider subject, then, when linking
+// by email is on, by verified email, and is otherwise provisioned when
+// auto-create is on. Accounts with TOTP still get an MFA challenge.
+func (s *authService) CompleteOIDCLogin(ctx context.Context, code, state string, client domain.SessionClient) (*domain.LoginResult, error) {
+	if s.sso == nil {
+		return nil, apperror.NotFound(ErrOIDCNotConfigured)
+	}
+
+	loginState, err := s.oidcStates.Consume(hashToken(state))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.BadRequest(ErrInvalidOIDCState)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	if time.Now().After(loginState.ExpiresAt) {
+		return nil, apperror.BadRequest(ErrInvalidOIDCState)
+	}
+
+	identity, err := s.sso.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrProviderUnavailable) {
+			logger.Error("failed to reach oidc provider", "error", err)
+			return nil, apperror.ServiceUnavailable(ErrOIDCUnavailable)
+		}
+		logger.Warn("oidc login rejected", "ip", client.IP, "error", err)
+		return nil, apperror.Unauthorized(ErrOIDCLoginFailed)
+	}
+
+	user, err := s.resolveOIDCUser(identity, client)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDisabled() {
+		s.recordAttempt(user.Username, &user.ID, client, domain.LoginDisabled)
+		return nil, apperror.Unauthorized(ErrAccountDisabled)
+	}
+	if err := s.syncOIDCRole(user, identity, client); err != nil {
+		return nil, err
+	}
+
+	if user.MFAEnabled() {
+		return s.startMFAChallenge(user, client)
+	}
+
+	tokens, err := s.completeLogin(user, client)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.LoginResult{TokenPair: tokens}, nil
+}
+
+func (s *authService) resolveOIDCUser(identity *oidc.Identity, client domain.SessionClient) (*domain.User, error) {
+	user, err := s.users.GetByOIDCIdentity(identity.Issuer, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.InternalError(err)
+	}
+
+	// Only a verified address proves the identity owns the existing account
+	email := strings.TrimSpace(claimString(identity.Claims, "email"))
+	// Some providers send email_verified as a string
+	verified := identity.Claims["email_verified"] == true || identity.Claims["email_verified"] == "true"
+	if s.ssoSettings.linkByEmail && email != "" && verified {
+		existing, err := s.users.GetByEmail(email)
+		switch {
+		case err == nil:
+			return s.linkOIDCUser(existing, identity, client)
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, apperror.InternalError(err)
+		}
+	}
+
+	if !s.ssoSettings.autoCreate {
+		s.recordAttempt(firstNonEmpty(email, identity.Subject), nil, client, domain.LoginUnknownIdentity)
+		return nil, apperror.Forbidden(ErrOIDCAccountNotFound)
+	}
+	if email == "" {
+		return nil, apperror.Forbidden(ErrOIDCEmailMissing)
+	}
+	exists, err := s.users.EmailExists(email)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if exists {
+		return nil, apperror.Conflict(ErrOIDCEmailConflict)
+	}
+	return s.createOIDCUser(identity, email, client)
+}
+
+func (s *authService) linkOIDCUser(user *domain.User, identity *oidc.Identity, client domain.SessionClient) (*domain.User, error) {
+	if user.OIDCLinked() {
+		return nil, apperror.Conflict(ErrOIDCEmailConflict)
+	}
+	// Whoever controls the address at the provider would take over the
+	// account, so accounts that manage users are never linked automatically
+	if user.Role.Privileged() {
+		logger.Warn("refused to link oidc identity to privileged account", "userId", user.ID, "issuer", identity.Issuer, "ip", client.IP)
+		return nil, apperror.Conflict(ErrOIDCEmailConflict)
+	}
+	if err := s.users.LinkOIDCIdentity(user.ID, identity.Issuer, identity.Subject); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.Conflict(ErrOIDCEmailConflict)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	user.OIDCIssuer, user.OIDCSubject = &identity.Issuer, &identity.Subject
+
+	logger.Info("linked oidc identity to existing account", "userId", user.ID, "issuer", identity.Issuer)
+	s.audit.Record(oidcActor(user, client), domain.AuditUserLinkOIDC, domain.AuditEntityUser, auditID(user.ID), nil, oidcLinkAudit{Issuer: identity.Issuer})
+	return user, nil
+}
+
+// createOIDCUser provisions an account that can only sign in through the
+// provider: its password is random and never shown
+func (s *authService) createOIDCUser(identity *oidc.Identity, email string, client domain.SessionClient) (*domain.User, error) {
+	username, err := s.oidcUsername(identity, email)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	password, err := temporaryPassword()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	// As with CreateUser, the first account owns the site
+	userCount, err := s.users.Count()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	role := s.oidcRole(identity)
+	if userCount == 0 {
+		role = domain.RoleOwner
+	}
+
+	user := &domain.User{
+		Username:    username,
+		Email:       email,
+		Role:        role,
+		OIDCIssuer:  &identity.Issuer,
+		OIDCSubject: &identity.Subject,
+	}
+	if err := user.HashPassword(password); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if err := s.users.Create(user); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	logger.Info("created account from oidc login", "userId", user.ID, "issuer", identity.Issuer)
+	s.audit.Record(oidcActor(user, client), domain.AuditUserCreate, domain.AuditEntityUser, auditID(user.ID), nil, user)
+	return user, nil
+}
+
+// oidcUsername takes the configured claim, else the email's local part, and
+// appends a number while the name is taken
+func (s *authService) oidcUsername(identity *oidc.Identity, email string) (string, error) {
+	localPart, _, _ := strings.Cut(email, "@")
+	base := truncate(strings.TrimSpace(firstNonEmpty(claimString(identity.Claims, s.ssoSettings.usernameClaim), localPart, identity.Subject)), 100)
+
+	for i := 1; i <= oidcUsernameTries; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+		exists, err := s.users.UsernameExists(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+
+	suffix, _, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return base + "-" + suffix[:8], nil
+}
+
+// oidcRole maps the role claim to the strongest configured role
+func (s *authService) oidcRole(identity *oidc.Identity) domain.Role {
+	if s.ssoSettings.roleClaim == "" {
+		return s.ssoSettings.defaultRole
+	}
+	mapped := make(map[domain.Role]bool)
+	for _, value := range claimStrings(identity.Claims[s.ssoSettings.roleClaim]) {
+		if role, ok := s.ssoSettings.roleMapping[value]; ok {
+			mapped[role] = true
+		}
+	}
+	for _, role := range oidcRolePriority {
+		if mapped[role] {
+			return role
+		}
+	}
+	return s.ssoSettings.defaultRole
+}
+
+// syncOIDCRole applies the provider's role on every login when a role claim
+// is configured, so removing someone from a group at the provider takes effect
+func (s *authService) syncOIDCRole(user *domain.User, identity *oidc.Identity, client domain.SessionClient) error {
+	if s.ssoSettings.roleClaim == "" {
+		return nil
+	}
+	role := s.oidcRole(identity)
+	if role == user.Role {
+		return nil
+	}
+	if err := s.users.UpdateRole(user.ID, role); err != nil {
+		if errors.Is(err, repository.ErrLastOwner) {
+			logger.Warn("kept last owner role despite oidc role claim", "userId", user.ID, "claimedRole", role)
+			return nil
+		}
+		return apperror.InternalError(err)
+	}
+	logger.Info("updated role from oidc claim", "userId", user.ID, "from", user.Role, "to", role)
+	s.audit.Record(oidcActor(user, client), domain.AuditUserUpdateRole, domain.AuditEntityUser, auditID(user.ID), roleAudit{Role: user.Role}, roleAudit{Role: role})
+	user.Role = role
+	return nil
+}
+
+// oidcLinkAudit records which provider an account was linked to
+type oidcLinkAudit struct {
+	Issuer string `json:"oidcIssuer"`
+}
+
+type roleAudit struct {
+	Role domain.Role `json:"role"`
+}
+
+// oidcActor attributes changes made during a single sign-on to the signing-in user
+func oidcActor(user *domain.User, client domain.SessionClient) domain.Actor {
+	return domain.Actor{UserID: user.ID, Username: user.Username, IP: client.IP, UserAgent: client.UserAgent}
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// claimStrings accepts a single string or an array of strings
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/oidc"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// discardAttempts drops recorded login attempts
+type discardAttempts struct {
+	repository.LoginAttemptRepository
+}
+
+func (discardAttempts) Create(*domain.LoginAttempt) error { return nil }
+
+func verifiedIdentity(email string) *oidc.Identity {
+	return &oidc.Identity{
+		Issuer:  "http://localhost:8090/default",
+		Subject: "subject-" + email,
+		Claims:  map[string]interface{}{"email": email, "email_verified": true},
+	}
+}
+
+func newOIDCTestService(repo *memoryUserRepo, cfg config.Config) *authService {
+	cfg.OIDCDefaultRole = string(domain.RoleViewer)
+	return NewAuthService(repo, nil, discardAttempts{}, nil, nil, nil, nil, discardAudit{}, cfg).(*authService)
+}
+
+func TestOIDCDoesNotLinkByEmailByDefault(t *testing.T) {
+	repo := &memoryUserRepo{users: map[uint]*domain.User{
+		1: {ID: 1, Username: "alice", Email: "alice@example.com", Role: domain.RoleViewer},
+	}}
+	svc := newOIDCTestService(repo, config.Load())
+
+	_, err := svc.resolveOIDCUser(verifiedIdentity("alice@example.com"), domain.SessionClient{})
+	var appErr *apperror.AppError
+	if !errors.As(err, &appErr) || appErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("resolve: got %v, want 403", err)
+	}
+	if repo.users[1].OIDCLinked() {
+		t.Fatal("account was linked although linking by email is off")
+	}
+}
+
+func TestOIDCLinksOnlyUnprivilegedAccountsByEmail(t *testing.T) {
+	cases := []struct {
+		role   domain.Role
+		linked bool
+	}{
+		{domain.RoleViewer, true},
+		{domain.RoleEditor, true},
+		{domain.RoleAdmin, false},
+		{domain.RoleOwner, false},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.role), func(t *testing.T) {
+			repo := &memoryUserRepo{users: map[uint]*domain.User{
+				1: {ID: 1, Username: "alice", Email: "alice@example.com", Role: tc.role},
+			}}
+			svc := newOIDCTestService(repo, config.Config{OIDCLinkByEmail: true, OIDCAutoCreate: true})
+
+			user, err := svc.resolveOIDCUser(verifiedIdentity("alice@example.com"), domain.SessionClient{})
+			if tc.linked {
+				if err != nil {
+					t.Fatalf("resolve: %v", err)
+				}
+				if user.ID != 1 || !repo.users[1].OIDCLinked() {
+					t.Fatalf("account was not linked: %+v", user)
+				}
+				return
+			}
+
+			var appErr *apperror.AppError
+			if !errors.As(err, &appErr) || appErr.StatusCode != http.StatusConflict {
+				t.Fatalf("resolve: got %v, want 409", err)
+			}
+			if repo.users[1].OIDCLinked() {
+				t.Fatal("privileged account was linked")
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/mail"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrInvalidResetToken  = errors.New("password reset link is invalid or has expired")
+	ErrTooManyResetEmails = errors.New("too many password reset requests, try again later")
+)
+
+const (
+	// Caps reset mails per account so the endpoint cannot be used to flood an inbox
+	maxResetsPerHour = 3
+	resetMailTimeout = 30 * time.Second
+	// Bounds the lookups and mails in flight; requests beyond it are dropped
+	maxPendingResets = 32
+)
+
+type PasswordResetService interface {
+	// ForgotPassword mails a reset link when email belongs to an active account.
+	// It returns the same result either way so accounts cannot be enumerated.
+	ForgotPassword(email string, client domain.SessionClient) error
+	// ResetPassword sets a new password, unlocks the account and signs out every session.
+	// Two-factor login still applies afterwards.
+	ResetPassword(token, newPassword string, client domain.SessionClient) error
+}
+
+type passwordResetService struct {
+	users    repository.UserRepository
+	sessions repository.SessionRepository
+	resets   repository.PasswordResetRepository
+	audit    AuditService
+	mailer   mail.Mailer
+	resetURL string
+	ttl      time.Duration
+	// Resets are refused while password login is disabled
+	enabled bool
+	// Limits requests per IP; the per-account cap alone lets one client
+	// spray addresses without bound
+	throttle *loginThrottle
+	pending  chan struct{}
+}
+
+func NewPasswordResetService(users repository.UserRepository, sessions repository.SessionRepository, resets repository.PasswordResetRepository, audit AuditService, mailer mail.Mailer, cfg config.Config) PasswordResetService {
+	return &passwordResetService{
+		users:    users,
+		sessions: sessions,
+		resets:   resets,
+		audit:    audit,
+		mailer:   mailer,
+		resetURL: cfg.PasswordResetURL,
+		ttl:      cfg.PasswordResetTTL,
+		enabled:  cfg.PasswordLoginEnabled,
+		throttle: newLoginThrottle(),
+		pending:  make(chan struct{}, maxPendingResets),
+	}
+}
+
+func (s *passwordResetService) ForgotPassword(email string, client domain.SessionClient) error {
+	if !s.enabled {
+		return apperror.Forbidden(ErrPasswordLoginDisabled)
+	}
+	if wait := s.throttle.Take(client.IP, &resetIPThrottlePolicy); wait > 0 {
+		return apperror.TooManyRequests(ErrTooManyResetEmails).WithDetails("too_many_attempts", retryAfter(wait))
+	}
+
+	// The lookup and every write happen in the background: doing them before
+	// responding would make known addresses answer measurably slower. The
+	// answer stays the same when the request is dropped, for the same reason.
+	select {
+	case s.pending <- struct{}{}:
+		go func() {
+			defer func() { <-s.pending }()
+			s.sendReset(email, client)
+		}()
+	default:
+		logger.Warn("password reset queue is full, request dropped", "ip", client.IP)
+	}
+	return nil
+}
+
+// sendReset issues and mails a reset link when email belongs to an active
+// account. It runs detached from the request, so failures are only logged.
+func (s *passwordResetService) sendReset(email string, client domain.SessionClient) {
+	user, err := s.users.GetByEmail(email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Error("failed to look up password reset account", "error", err)
+		}
+		return
+	}
+	if user.IsDisabled() {
+		return
+	}
+
+	now := time.Now()
+	recent, err := s.resets.CountSince(user.ID, now.Add(-time.Hour))
+	if err != nil {
+		logger.Error("failed to count password resets", "userId", user.ID, "error", err)
+		return
+	}
+	if recent >= maxResetsPerHour {
+		logger.Warn("password reset limit reached", "userId", user.ID, "ip", client.IP)
+		return
+	}
+
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		logger.Error("failed to generate password reset token", "userId", user.ID, "error", err)
+		return
+	}
+	// Only the newest link works
+	if err := s.resets.InvalidateForUser(user.ID); err != nil {
+		logger.Error("failed to invalidate password reset tokens", "userId", user.ID, "error", err)
+		return
+	}
+	reset := &domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		IP:        client.IP,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	if err := s.resets.Create(reset); err != nil {
+		logger.Error("failed to store password reset token", "userId", user.ID, "error", err)
+		return
+	}
+	if err := s.resets.DeleteExpired(now); err != nil {
+		logger.Warn("failed to delete expired password reset tokens", "error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resetMailTimeout)
+	defer cancel()
+	if err := s.mailer.Send(ctx, s.resetMessage(user, token)); err != nil {
+		logger.Error("failed to send password reset mail", "userId", user.ID, "error", err)
+	}
+}
+
+func (s *passwordResetService) ResetPassword(token, newPassword string, client domain.SessionClient) error {
+	if !s.enabled {
+		return apperror.Forbidden(ErrPasswordLoginDisabled)
+	}
+	reset, err := s.resets.GetByTokenHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.BadRequest(ErrInvalidResetToken)
+		}
+		return apperror.InternalError(err)
+	}
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return apperror.BadRequest(ErrInvalidResetToken)
+	}
+
+	user, err := s.users.GetByID(reset.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.BadRequest(ErrInvalidResetToken)
+		}
+		return apperror.InternalError(err)
+	}
+	if user.IsDisabled() {
+		return apperror.BadRequest(ErrInvalidResetToken)
+	}
+
+	if err := s.resets.MarkUsed(reset.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.BadRequest(ErrInvalidResetToken)
+		}
+		return apperror.InternalError(err)
+	}
+
+	if err := user.HashPassword(newPassword); err != nil {
+		return apperror.InternalError(err)
+	}
+	if err := s.users.UpdatePassword(user.ID, user.Password, false); err != nil {
+		return apperror.InternalError(err)
+	}
+	if err := s.users.ResetLoginFailures(user.ID); err != nil {
+		return apperror.InternalError(err)
+	}
+	if err := s.resets.InvalidateForUser(user.ID); err != nil {
+		return apperror.InternalError(err)
+	}
+	// Whoever knew the old password is signed out
+	if err := s.sessions.RevokeAllForUser(user.ID, 0); err != nil {
+		return apperror.InternalError(err)
+	}
+
+	logger.Info("password reset", "userId", user.ID)
+	// The link proves ownership of the account, so the reset is attributed to its owner
+	actor := domain.Actor{UserID: user.ID, Username: user.Username, IP: client.IP, UserAgent: client.UserAgent}
+	s.audit.Record(actor, domain.AuditPasswordReset, domain.AuditEntityUser, auditID(user.ID), nil, nil)
+	return nil
+}
+
+func (s *passwordResetService) resetMessage(user *domain.User, token string) mail.Message {
+	link := s.resetURL + "?token=" + url.QueryEscape(token)
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password for your account. If it was you, open
+the link below to choose a new password. It expires in %s and works once.
+
+%s
+
+If you did not ask for this, you can ignore this email; your password stays the same.
+`, user.Username, s.ttl, link),
+	}
+}
@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/mail"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// gatedUserRepo holds every email lookup until release is closed
+type gatedUserRepo struct {
+	repository.UserRepository
+	looked  chan string
+	release chan struct{}
+	user    *domain.User
+}
+
+func (r *gatedUserRepo) GetByEmail(email string) (*domain.User, error) {
+	r.looked <- email
+	<-r.release
+	copied := *r.user
+	return &copied, nil
+}
+
+// memoryResetRepo keeps issued reset tokens in a slice
+type memoryResetRepo struct {
+	repository.PasswordResetRepository
+	tokens []*domain.PasswordResetToken
+}
+
+func (r *memoryResetRepo) CountSince(uint, time.Time) (int64, error) {
+	return int64(len(r.tokens)), nil
+}
+
+func (r *memoryResetRepo) InvalidateForUser(uint) error { return nil }
+
+func (r *memoryResetRepo) Create(token *domain.PasswordResetToken) error {
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *memoryResetRepo) DeleteExpired(time.Time) error { return nil }
+
+// outbox hands sent messages to the test
+type outbox chan mail.Message
+
+func (o outbox) Send(_ context.Context, msg mail.Message) error {
+	o <- msg
+	return nil
+}
+
+func TestForgotPasswordAnswersBeforeLookingUpTheAccount(t *testing.T) {
+	users := &gatedUserRepo{
+		looked:  make(chan string, 1),
+		release: make(chan struct{}),
+		user:    &domain.User{ID: 7, Username: "alice", Email: "alice@example.com"},
+	}
+	resets := &memoryResetRepo{}
+	sent := make(outbox, 1)
+	svc := NewPasswordResetService(users, nil, resets, discardAudit{}, sent, config.Config{
+		PasswordLoginEnabled: true,
+		PasswordResetURL:     "http://localhost:3000/admin/reset-password",
+		PasswordResetTTL:     30 * time.Minute,
+	})
+
+	// The lookup cannot finish until release is closed, so returning at all
+	// shows the response does not depend on whether the account exists
+	if err := svc.ForgotPassword("alice@example.com", domain.SessionClient{IP: "203.0.113.5"}); err != nil {
+		t.Fatalf("forgot password: %v", err)
+	}
+	if email := <-users.looked; email != "alice@example.com" {
+		t.Fatalf("looked up %q", email)
+	}
+	close(users.release)
+
+	select {
+	case msg := <-sent:
+		if msg.To != "alice@example.com" || !strings.Contains(msg.Body, "?token=") {
+			t.Fatalf("unexpected reset mail: %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reset mail was not sent")
+	}
+	if len(resets.tokens) != 1 || resets.tokens[0].UserID != 7 || resets.tokens[0].IP != "203.0.113.5" {
+		t.Fatalf("unexpected reset tokens: %+v", resets.tokens)
+	}
+}
+
+func newGatedResetService(users *gatedUserRepo) PasswordResetService {
+	return NewPasswordResetService(users, nil, &memoryResetRepo{}, discardAudit{}, make(outbox, 1), config.Config{
+		PasswordLoginEnabled: true,
+		PasswordResetTTL:     30 * time.Minute,
+	})
+}
+
+func TestForgotPasswordThrottlesEachIP(t *testing.T) {
+	disabled := time.Now()
+	users := &gatedUserRepo{
+		looked:  make(chan string, 16),
+		release: make(chan struct{}),
+		// A disabled account ends each lookup before any token is written
+		user: &domain.User{ID: 7, Email: "alice@example.com", DisabledAt: &disabled},
+	}
+	close(users.release)
+	svc := newGatedResetService(users)
+
+	client := domain.SessionClient{IP: "203.0.113.5"}
+	for i := 0; i <= resetIPThrottlePolicy.freeFailures; i++ {
+		if err := svc.ForgotPassword("alice@example.com", client); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	err := svc.ForgotPassword("alice@example.com", client)
+	appErr, ok := apperror.IsAppError(err)
+	if !ok || appErr.StatusCode != http.StatusTooManyRequests || !errors.Is(err, ErrTooManyResetEmails) {
+		t.Fatalf("request over the limit: err = %v, want 429", err)
+	}
+	if retry, ok := appErr.Details.(RetryAfter); !ok || retry.Seconds < 1 {
+		t.Fatalf("details = %#v, want a retry delay", appErr.Details)
+	}
+
+	if err := svc.ForgotPassword("bob@example.com", domain.SessionClient{IP: "198.51.100.1"}); err != nil {
+		t.Fatalf("another IP: %v", err)
+	}
+}
+
+func TestForgotPasswordDropsRequestsWhenTheQueueIsFull(t *testing.T) {
+	users := &gatedUserRepo{
+		looked:  make(chan string, maxPendingResets+1),
+		release: make(chan struct{}),
+		user:    &domain.User{ID: 7, Email: "alice@example.com"},
+	}
+	svc := newGatedResetService(users)
+
+	// Each request comes from its own IP and holds a slot until release
+	request := func(i int) {
+		t.Helper()
+		client := domain.SessionClient{IP: fmt.Sprintf("203.0.113.%d", i)}
+		if err := svc.ForgotPassword("alice@example.com", client); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	for i := 0; i < maxPendingResets; i++ {
+		request(i)
+	}
+	for i := 0; i < maxPendingResets; i++ {
+		<-users.looked
+	}
+
+	request(maxPendingResets)
+	select {
+	case <-users.looked:
+		t.Fatal("request beyond the queue was processed")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Released lookups find the user disabled and stop there
+	disabled := time.Now()
+	users.user.DisabledAt = &disabled
+	close(users.release)
+}
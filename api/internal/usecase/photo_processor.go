@@ -0,0 +1,237 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/imaging"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrPhotoNoObjectKey      = errors.New("photo has no uploaded object to process")
+	ErrProcessingQueueFull   = errors.New("photo processing queue is full, retry later")
+	ErrProcessorClosed       = errors.New("photo processor is shutting down")
+	ErrNoImageVariantFormats = errors.New("no image variant formats configured")
+)
+
+const (
+	processingWorkers   = 2
+	processingQueueSize = 256
+)
+
+// PhotoProcessor generates thumbnails and responsive variants in the background
+type PhotoProcessor interface {
+	// Enqueue schedules variant generation for a photo with an object key.
+	// The photo is only marked pending once the job is queued.
+	Enqueue(photoID uint) error
+
+	// Close stops accepting jobs and waits for in-flight jobs to finish
+	Close()
+}
+
+type photoProcessor struct {
+	repo      repository.PhotoRepository
+	storage   StorageService
+	widths    []int
+	encoders  []imaging.Encoder
+	maxPixels int64
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan uint
+	wg     sync.WaitGroup
+}
+
+// NewPhotoProcessor starts the workers. Every configured variant format must
+// have a registered encoder; see imaging.RegisterEncoder for what is built in.
+func NewPhotoProcessor(repo repository.PhotoRepository, storage StorageService, cfg config.Config) (PhotoProcessor, error) {
+	widths := append([]int(nil), cfg.ImageVariantWidths...)
+	sort.Ints(widths)
+
+	encoders := make([]imaging.Encoder, 0, len(cfg.ImageVariantFormats))
+	for _, format := range cfg.ImageVariantFormats {
+		enc, ok := imaging.LookupEncoder(format)
+		if !ok {
+			return nil, fmt.Errorf("no encoder for image variant format %q (available: %s)", format, strings.Join(imaging.Formats(), ", "))
+		}
+		encoders = append(encoders, enc)
+	}
+	if len(encoders) == 0 {
+		return nil, ErrNoImageVariantFormats
+	}
+
+	p := &photoProcessor{
+		repo:      repo,
+		storage:   storage,
+		widths:    widths,
+		encoders:  encoders,
+		maxPixels: cfg.UploadMaxPixels,
+		queue:     make(chan uint, processingQueueSize),
+	}
+
+	for i := 0; i < processingWorkers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+
+	return p, nil
+}
+
+func (p *photoProcessor) Enqueue(photoID uint) error {
+	// Enqueue is the only sender and holds mu, so a free slot stays free until
+	// the send below; the photo is never marked pending for a dropped job
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrProcessorClosed
+	}
+	if len(p.queue) == cap(p.queue) {
+		logger.Warn("photo processing queue is full, job rejected", "photoId", photoID)
+		return ErrProcessingQueueFull
+	}
+
+	// Marked before sending so a fast worker cannot finish before the write
+	if err := p.repo.UpdateProcessingStatus(photoID, domain.PhotoProcessingPending); err != nil {
+		return err
+	}
+	p.queue <- photoID
+	return nil
+}
+
+func (p *photoProcessor) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *photoProcessor) worker() {
+	defer p.wg.Done()
+	for photoID := range p.queue {
+		err := p.process(photoID)
+		if errors.Is(err, repository.ErrSourceChanged) {
+			// The photo was given a new object, which queued its own job
+			logger.Info("photo processing superseded", "photoId", photoID)
+			continue
+		}
+		if err != nil {
+			logger.Error("photo processing failed", "photoId", photoID, "error", err)
+			if err := p.repo.UpdateProcessingStatus(photoID, domain.PhotoProcessingFailed); err != nil {
+				logger.Error("failed to mark photo failed", "photoId", photoID, "error", err)
+			}
+			continue
+		}
+		logger.Info("photo processed", "photoId", photoID)
+	}
+}
+
+func (p *photoProcessor) process(photoID uint) error {
+	photo, err := p.repo.GetByID(photoID)
+	if err != nil {
+		return err
+	}
+	if photo.ObjectKey == "" {
+		return ErrPhotoNoObjectKey
+	}
+
+	if err := p.repo.UpdateProcessingStatus(photoID, domain.PhotoProcessingProcessing); err != nil {
+		return err
+	}
+
+	reader, err := p.storage.GetObject(photo.ObjectKey)
+	if err != nil {
+		return err
+	}
+	img, _, err := imaging.DecodeLimited(reader, p.maxPixels)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	// Bake the EXIF orientation into the pixels; encoded variants carry no EXIF
+	if photo.Metadata != nil {
+		img = imaging.Orient(img, photo.Metadata.Orientation)
+	}
+
+	bounds := img.Bounds()
+	photo.Width = bounds.Dx()
+	photo.Height = bounds.Dy()
+
+	// Variants live next to the original: photos/2025/01/<uuid>_640w.jpg
+	base := strings.TrimSuffix(photo.ObjectKey, path.Ext(photo.ObjectKey))
+
+	var variants []domain.PhotoVariant
+	for _, width := range p.targetWidths(photo.Width) {
+		resized, err := imaging.Resize(img, width)
+		if err != nil {
+			return err
+		}
+
+		for _, enc := range p.encoders {
+			var buf bytes.Buffer
+			if err := enc.Encode(&buf, resized); err != nil {
+				return fmt.Errorf("failed to encode %s variant: %w", enc.Format(), err)
+			}
+
+			key := fmt.Sprintf("%s_%dw%s", base, width, enc.Extension())
+			size := int64(buf.Len())
+			if err := p.storage.PutObject(key, &buf, size, enc.ContentType()); err != nil {
+				return err
+			}
+
+			variants = append(variants, domain.PhotoVariant{
+				PhotoID:   photo.ID,
+				Format:    enc.Format(),
+				Width:     resized.Bounds().Dx(),
+				Height:    resized.Bounds().Dy(),
+				Size:      size,
+				ObjectKey: key,
+			})
+		}
+	}
+
+	// Smallest variant of the primary format doubles as the thumbnail
+	if len(variants) > 0 {
+		photo.ThumbnailKey = variants[0].ObjectKey
+		photo.ThumbnailURL = ""
+	}
+	photo.ProcessingStatus = domain.PhotoProcessingReady
+
+	err = p.repo.ReplaceVariants(photo, variants)
+	if errors.Is(err, repository.ErrSourceChanged) {
+		for _, variant := range variants {
+			if err := p.storage.RemoveObject(variant.ObjectKey); err != nil {
+				logger.Warn("failed to remove superseded variant", "key", variant.ObjectKey, "error", err)
+			}
+		}
+	}
+	return err
+}
+
+// targetWidths returns the configured widths that are smaller than the original,
+// falling back to the original width so tiny images still get one variant
+func (p *photoProcessor) targetWidths(originalWidth int) []int {
+	widths := make([]int, 0, len(p.widths))
+	for _, w := range p.widths {
+		if w < originalWidth {
+			widths = append(widths, w)
+		}
+	}
+	if len(widths) == 0 {
+		widths = append(widths, originalWidth)
+	}
+	return widths
+}
@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// statusRecorder records processing status writes; other repository methods panic
+type statusRecorder struct {
+	repository.PhotoRepository
+	statuses map[uint]domain.PhotoProcessingStatus
+}
+
+func (r *statusRecorder) UpdateProcessingStatus(id uint, status domain.PhotoProcessingStatus) error {
+	r.statuses[id] = status
+	return nil
+}
+
+func TestNewPhotoProcessorRejectsUnknownFormats(t *testing.T) {
+	for _, formats := range [][]string{{"webp", "avif"}, {"avif"}, nil} {
+		cfg := config.Config{ImageVariantWidths: []int{320}, ImageVariantFormats: formats}
+		if _, err := NewPhotoProcessor(nil, nil, cfg); err == nil {
+			t.Errorf("NewPhotoProcessor with formats %v succeeded", formats)
+		}
+	}
+}
+
+func TestEnqueueDoesNotMarkDroppedJobsPending(t *testing.T) {
+	repo := &statusRecorder{statuses: map[uint]domain.PhotoProcessingStatus{}}
+	// No workers run, so the single slot stays taken
+	p := &photoProcessor{repo: repo, queue: make(chan uint, 1)}
+
+	if err := p.Enqueue(1); err != nil {
+		t.Fatalf("Enqueue(1): %v", err)
+	}
+	if err := p.Enqueue(2); !errors.Is(err, ErrProcessingQueueFull) {
+		t.Fatalf("Enqueue(2) on a full queue: err = %v, want ErrProcessingQueueFull", err)
+	}
+	if repo.statuses[1] != domain.PhotoProcessingPending {
+		t.Errorf("queued photo status = %q, want pending", repo.statuses[1])
+	}
+	if status, ok := repo.statuses[2]; ok {
+		t.Errorf("dropped photo was marked %q", status)
+	}
+
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	if err := p.Enqueue(3); !errors.Is(err, ErrProcessorClosed) {
+		t.Fatalf("Enqueue after Close: err = %v, want ErrProcessorClosed", err)
+	}
+}
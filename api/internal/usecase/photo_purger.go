@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+const purgeBatchSize = 100
+
+// PhotoPurger permanently deletes photos that stayed in the trash past the retention period
+type PhotoPurger interface {
+	// PurgeExpired runs one pass and returns how many photos were removed
+	PurgeExpired() (int, error)
+
+	// Close stops the background schedule and waits for a running pass
+	Close()
+}
+
+type photoPurger struct {
+	repo      repository.PhotoRepository
+	storage   StorageService
+	retention time.Duration
+	audit     AuditService
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewPhotoPurger starts purging on cfg.PhotoPurgeInterval
+func NewPhotoPurger(repo repository.PhotoRepository, storage StorageService, audit AuditService, cfg config.Config) PhotoPurger {
+	p := &photoPurger{
+		repo:      repo,
+		storage:   storage,
+		retention: cfg.PhotoTrashRetention,
+		audit:     audit,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go p.run(cfg.PhotoPurgeInterval)
+	return p
+}
+
+func (p *photoPurger) run(interval time.Duration) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := p.PurgeExpired(); err != nil {
+			logger.Error("photo purge failed", "error", err)
+		} else if n > 0 {
+			logger.Info("purged trashed photos", "count", n)
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *photoPurger) Close() {
+	p.once.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+func (p *photoPurger) PurgeExpired() (int, error) {
+	cutoff := time.Now().Add(-p.retention)
+	purged := 0
+
+	for {
+		photos, err := p.repo.ListPurgeable(cutoff, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+
+		batchPurged := 0
+		for i := range photos {
+			photo := &photos[i]
+			// The row is kept if its objects could not be removed, so the next pass retries
+			err := p.repo.Purge(photo.ID, cutoff, p.removeObjects)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.Info("skipped purge of restored photo", "photoId", photo.ID)
+				continue
+			}
+			if err != nil {
+				logger.Error("failed to purge photo", "photoId", photo.ID, "error", err)
+				continue
+			}
+			p.audit.Record(domain.SystemActor("trash_purge"), domain.AuditPhotoPurge, domain.AuditEntityPhoto, auditID(photo.ID), photo, nil)
+			batchPurged++
+		}
+		purged += batchPurged
+
+		// Stop on the last page, or when nothing in this page could be purged
+		if len(photos) < purgeBatchSize || batchPurged == 0 {
+			return purged, nil
+		}
+	}
+}
+
+// removeObjects deletes the original, its variants and a separately stored
+// thumbnail. Photos that share an object key also share the variants derived
+// from it, so none of them are removed while another photo uses the original.
+func (p *photoPurger) removeObjects(photo *domain.Photo) error {
+	if photo.ObjectKey != "" {
+		shared, err := p.repo.ObjectKeyInUse(photo.ObjectKey, photo.ID)
+		if err != nil {
+			return err
+		}
+		if !shared {
+			for _, variant := range photo.Variants {
+				if variant.ObjectKey == "" {
+					continue
+				}
+				if err := p.storage.RemoveObject(variant.ObjectKey); err != nil {
+					return err
+				}
+			}
+			if err := p.storage.RemoveObject(photo.ObjectKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	if photo.ThumbnailKey == "" || isVariantKey(photo.Variants, photo.ThumbnailKey) {
+		return nil
+	}
+	shared, err := p.repo.ObjectKeyInUse(photo.ThumbnailKey, photo.ID)
+	if err != nil || shared {
+		return err
+	}
+	return p.storage.RemoveObject(photo.ThumbnailKey)
+}
+
+func isVariantKey(variants []domain.PhotoVariant, key string) bool {
+	for _, variant := range variants {
+		if variant.ObjectKey == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+func TestPurgeRemovesObjectsNoOtherPhotoUses(t *testing.T) {
+	variants := []domain.PhotoVariant{
+		{ObjectKey: "photos/a_320w.webp"},
+		{ObjectKey: "photos/a_640w.webp"},
+	}
+	cases := []struct {
+		name   string
+		photo  domain.Photo
+		inUse  map[string]bool
+		remove []string
+	}{
+		{
+			name:   "own objects",
+			photo:  domain.Photo{ObjectKey: "photos/a.jpg", ThumbnailKey: "photos/a_320w.webp", Variants: variants},
+			remove: []string{"photos/a_320w.webp", "photos/a_640w.webp", "photos/a.jpg"},
+		},
+		{
+			// Variants are derived from the original's key, so they are shared too
+			name:   "shared original",
+			photo:  domain.Photo{ObjectKey: "photos/a.jpg", ThumbnailKey: "photos/a_320w.webp", Variants: variants},
+			inUse:  map[string]bool{"photos/a.jpg": true},
+			remove: nil,
+		},
+		{
+			name:   "separate thumbnail",
+			photo:  domain.Photo{ObjectKey: "photos/a.jpg", ThumbnailKey: "photos/cover.jpg", Variants: variants},
+			inUse:  map[string]bool{"photos/a.jpg": true},
+			remove: []string{"photos/cover.jpg"},
+		},
+		{
+			name:   "shared thumbnail",
+			photo:  domain.Photo{ImageURL: "https://example.com/a.jpg", ThumbnailKey: "photos/cover.jpg"},
+			inUse:  map[string]bool{"photos/cover.jpg": true},
+			remove: nil,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := &removalRecorder{}
+			purger := &photoPurger{repo: keysInUse{keys: tc.inUse}, storage: storage}
+
+			if err := purger.removeObjects(&tc.photo); err != nil {
+				t.Fatalf("remove objects: %v", err)
+			}
+			if !reflect.DeepEqual(storage.removed, tc.remove) {
+				t.Fatalf("removed %v, want %v", storage.removed, tc.remove)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrReorderEmpty         = errors.New("reorder request has no updates")
+	ErrReorderInvalid       = errors.New("reorder request contains invalid items")
+	ErrDisplayOrderConflict = errors.New("display order is already used by another photo")
+)
+
+// ReorderItemError explains why one entry of a reorder request was rejected
+type ReorderItemError struct {
+	Index         int    `json:"index"`
+	ID            uint   `json:"id"`
+	Order         int    `json:"order"`
+	Reason        string `json:"reason"`
+	ConflictsWith uint   `json:"conflictsWith,omitempty"`
+	// CurrentVersion is the photo's version when the request's did not match
+	CurrentVersion int `json:"currentVersion,omitempty"`
+}
+
+// BatchUpdateDisplayOrder validates the request on its own first: repeated or
+// negative entries produce a 400 with one error per item. The repository then
+// checks and writes every item in one transaction; unknown photos are a 400,
+// items whose version no longer matches a 412 and, without renumber, orders
+// held by photos outside the request a 409. With renumber the whole sequence
+// is rewritten densely instead.
+func (s *photoService) BatchUpdateDisplayOrder(orders []repository.DisplayOrderUpdate, renumber bool, actor domain.Actor) ([]repository.DisplayOrderUpdate, error) {
+	if len(orders) == 0 && !renumber {
+		return nil, apperror.BadRequest(ErrReorderEmpty)
+	}
+
+	if itemErrors := validateReorder(orders); len(itemErrors) > 0 {
+		return nil, reorderError(itemErrors)
+	}
+
+	result, err := s.repo.BatchUpdateDisplayOrder(orders, renumber)
+	if err != nil {
+		var rejection *repository.ReorderRejection
+		if errors.As(err, &rejection) {
+			return nil, reorderRejectionError(orders, rejection)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	// One event for the whole batch; a renumber can touch every photo
+	s.audit.Record(actor, domain.AuditPhotoReorder, domain.AuditEntityPhoto, "", nil, reorderAudit{Items: result, Renumber: renumber})
+	return result, nil
+}
+
+// reorderRejectionError reports the most fundamental problem first: missing
+// photos, then stale versions, then order conflicts
+func reorderRejectionError(orders []repository.DisplayOrderUpdate, rejection *repository.ReorderRejection) *apperror.AppError {
+	switch {
+	case len(rejection.Missing) > 0:
+		missing := make(map[uint]bool, len(rejection.Missing))
+		for _, id := range rejection.Missing {
+			missing[id] = true
+		}
+		var itemErrors []ReorderItemError
+		for i, update := range orders {
+			if missing[update.ID] {
+				itemErrors = append(itemErrors, ReorderItemError{Index: i, ID: update.ID, Order: update.Order, Reason: "photo not found"})
+			}
+		}
+		return reorderError(itemErrors)
+	case len(rejection.Stale) > 0:
+		return apperror.PreconditionFailed(ErrVersionMismatch).
+			WithDetails("version_conflict", reorderDetails{Items: staleErrors(orders, rejection.Stale)})
+	default:
+		return apperror.Conflict(ErrDisplayOrderConflict).
+			WithDetails("display_order_conflict", reorderDetails{Items: conflictErrors(orders, rejection.Conflicts)})
+	}
+}
+
+type reorderAudit struct {
+	Items    []repository.DisplayOrderUpdate `json:"items"`
+	Renumber bool                            `json:"renumber"`
+}
+
+type reorderDetails struct {
+	Items []ReorderItemError `json:"items"`
+}
+
+func reorderError(items []ReorderItemError) *apperror.AppError {
+	return apperror.BadRequest(ErrReorderInvalid).WithDetails("invalid_reorder", reorderDetails{Items: items})
+}
+
+// validateReorder checks the request on its own, without looking at the database
+func validateReorder(orders []repository.DisplayOrderUpdate) []ReorderItemError {
+	var itemErrors []ReorderItemError
+	seenIDs := make(map[uint]bool, len(orders))
+	seenOrders := make(map[int]bool, len(orders))
+
+	for i, update := range orders {
+		item := ReorderItemError{Index: i, ID: update.ID, Order: update.Order}
+		switch {
+		case update.ID == 0:
+			item.Reason = "invalid photo id"
+		case update.Order < 0:
+			item.Reason = "order must not be negative"
+		case seenIDs[update.ID]:
+			item.Reason = "duplicate photo id"
+		case seenOrders[update.Order]:
+			item.Reason = "duplicate order"
+		default:
+			seenIDs[update.ID] = true
+			seenOrders[update.Order] = true
+			continue
+		}
+		itemErrors = append(itemErrors, item)
+	}
+	return itemErrors
+}
+
+// conflictErrors pairs each requested order with the photo already holding it
+func conflictErrors(orders []repository.DisplayOrderUpdate, conflicts []repository.DisplayOrderUpdate) []ReorderItemError {
+	holders := make(map[int]uint, len(conflicts))
+	for _, c := range conflicts {
+		if _, ok := holders[c.Order]; !ok {
+			holders[c.Order] = c.ID
+		}
+	}
+
+	var itemErrors []ReorderItemError
+	for i, update := range orders {
+		if holder, ok := holders[update.Order]; ok {
+			itemErrors = append(itemErrors, ReorderItemError{
+				Index:         i,
+				ID:            update.ID,
+				Order:         update.Order,
+				Reason:        "order already used by another photo",
+				ConflictsWith: holder,
+			})
+		}
+	}
+	return itemErrors
+}
+
+// staleErrors reports the current version of each item whose version did not match
+func staleErrors(orders []repository.DisplayOrderUpdate, stale []repository.DisplayOrderUpdate) []ReorderItemError {
+	current := make(map[uint]int, len(stale))
+	for _, photo := range stale {
+		if photo.Version != nil {
+			current[photo.ID] = *photo.Version
+		}
+	}
+
+	var itemErrors []ReorderItemError
+	for i, update := range orders {
+		if version, ok := current[update.ID]; ok {
+			itemErrors = append(itemErrors, ReorderItemError{
+				Index:          i,
+				ID:             update.ID,
+				Order:          update.Order,
+				Reason:         "photo has been modified",
+				CurrentVersion: version,
+			})
+		}
+	}
+	return itemErrors
+}
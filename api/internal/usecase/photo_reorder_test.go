@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// rejectingReorderRepo fails every batch reorder with a fixed rejection
+type rejectingReorderRepo struct {
+	repository.PhotoRepository
+	rejection *repository.ReorderRejection
+}
+
+func (r *rejectingReorderRepo) BatchUpdateDisplayOrder([]repository.DisplayOrderUpdate, bool) ([]repository.DisplayOrderUpdate, error) {
+	return nil, r.rejection
+}
+
+func TestBatchUpdateDisplayOrderMapsRejections(t *testing.T) {
+	current := 4
+	orders := []repository.DisplayOrderUpdate{{ID: 1, Order: 0}, {ID: 2, Order: 1}}
+
+	cases := []struct {
+		name      string
+		rejection *repository.ReorderRejection
+		status    int
+		code      string
+	}{
+		{"missing", &repository.ReorderRejection{Missing: []uint{2}}, http.StatusBadRequest, "invalid_reorder"},
+		{"stale", &repository.ReorderRejection{Stale: []repository.DisplayOrderUpdate{{ID: 1, Order: 3, Version: &current}}}, http.StatusPreconditionFailed, "version_conflict"},
+		{"conflict", &repository.ReorderRejection{Conflicts: []repository.DisplayOrderUpdate{{ID: 9, Order: 1}}}, http.StatusConflict, "display_order_conflict"},
+	}
+	for _, tc := range cases {
+		svc := NewPhotoService(&rejectingReorderRepo{rejection: tc.rejection}, nil, nil, keepURLs{}, discardAudit{})
+		_, err := svc.BatchUpdateDisplayOrder(orders, false, domain.Actor{})
+
+		var appErr *apperror.AppError
+		if !errors.As(err, &appErr) || appErr.StatusCode != tc.status || appErr.Code != tc.code {
+			t.Fatalf("%s: err = %v, want %d %s", tc.name, err, tc.status, tc.code)
+		}
+		items := appErr.Details.(reorderDetails).Items
+		if len(items) != 1 {
+			t.Fatalf("%s: %d item errors, want 1: %+v", tc.name, len(items), items)
+		}
+		if tc.name == "stale" && items[0].CurrentVersion != current {
+			t.Fatalf("stale: current version = %d, want %d", items[0].CurrentVersion, current)
+		}
+		if tc.name == "conflict" && (items[0].ID != 2 || items[0].ConflictsWith != 9) {
+			t.Fatalf("conflict: item = %+v, want photo 2 conflicting with 9", items[0])
+		}
+	}
+}
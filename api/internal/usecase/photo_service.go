@@ -2,10 +2,15 @@ package usecase
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
+	"gorm.io/gorm"
+
 	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/imaging"
 	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
 	"github.com/aton/atonWeb/api/internal/repository"
 )
 
@@ -13,39 +18,62 @@ var (
 	ErrPhotoNotFound      = errors.New("photo not found")
 	ErrPhotoTitleEmpty    = errors.New("photo title cannot be empty")
 	ErrPhotoImageURLEmpty = errors.New("photo image URL cannot be empty")
+	ErrPhotoStoredURL     = errors.New("imageUrl and thumbnailUrl are for external images; send the objectKey of uploaded files")
 	ErrNoFieldsToUpdate   = errors.New("no fields to update")
+	ErrVersionMismatch    = errors.New("resource has been modified; reload and retry")
+	ErrPhotoNotInTrash    = errors.New("photo not found in trash")
+	ErrPhotoNoMetadata    = errors.New("photo has no EXIF metadata")
+)
+
+const (
+	// defaultPageSize applies when a listing sends no limit, so no request is unbounded
+	defaultPageSize = 50
+	// maxPageSize caps the limit of a single photo listing page
+	maxPageSize = 200
 )
 
 type PhotoService interface {
-	Create(req *domain.CreatePhotoRequest) (*domain.Photo, error)
+	Create(req *domain.CreatePhotoRequest, actor domain.Actor) (*domain.Photo, error)
+	// GetByID is the public photo detail; EXIF GPS coordinates are left out
 	GetByID(id uint) (*domain.Photo, error)
-	List(filters repository.PhotoFilters) ([]domain.Photo, int64, error)
-	Update(id uint, req *domain.UpdatePhotoRequest) (*domain.Photo, error)
-	Delete(id uint) error
-	UpdateDisplayOrder(id uint, order int) error
-	BatchUpdateDisplayOrder(orders []repository.DisplayOrderUpdate) error
+	// GetMetadata returns the full EXIF record, including location, for admins
+	GetMetadata(id uint) (*domain.PhotoMetadata, error)
+	List(filters repository.PhotoFilters) (*repository.PhotoPage, error)
+	// Update applies req; ifMatch lists acceptable versions, nil skips the check
+	Update(id uint, req *domain.UpdatePhotoRequest, ifMatch []int, actor domain.Actor) (*domain.Photo, error)
+	Delete(id uint, actor domain.Actor) error
+	// ListTrash pages through trashed photos, newest first, after beforeID
+	ListTrash(beforeID uint, limit int) ([]domain.Photo, error)
+	Restore(id uint, actor domain.Actor) (*domain.Photo, error)
+	UpdateDisplayOrder(id uint, order int, actor domain.Actor) error
+	BatchUpdateDisplayOrder(orders []repository.DisplayOrderUpdate, renumber bool, actor domain.Actor) ([]repository.DisplayOrderUpdate, error)
+	Reprocess(id uint, actor domain.Actor) error
 }
 
 type photoService struct {
-	repo repository.PhotoRepository
+	repo      repository.PhotoRepository
+	storage   StorageService
+	processor PhotoProcessor
+	urls      PhotoURLResolver
+	audit     AuditService
 }
 
-func NewPhotoService(repo repository.PhotoRepository) PhotoService {
-	return &photoService{repo: repo}
+// NewPhotoService wires the photo usecase. Returned photos have their URLs
+// resolved by urls.
+func NewPhotoService(repo repository.PhotoRepository, storage StorageService, processor PhotoProcessor, urls PhotoURLResolver, audit AuditService) PhotoService {
+	return &photoService{repo: repo, storage: storage, processor: processor, urls: urls, audit: audit}
 }
 
-func (s *photoService) Create(req *domain.CreatePhotoRequest) (*domain.Photo, error) {
+func (s *photoService) Create(req *domain.CreatePhotoRequest, actor domain.Actor) (*domain.Photo, error) {
 	if strings.TrimSpace(req.Title) == "" {
 		return nil, apperror.BadRequest(ErrPhotoTitleEmpty)
 	}
-	if strings.TrimSpace(req.ImageURL) == "" {
-		return nil, apperror.BadRequest(ErrPhotoImageURLEmpty)
-	}
 
 	photo := &domain.Photo{
 		Title:        req.Title,
 		Description:  req.Description,
 		ImageURL:     req.ImageURL,
+		ObjectKey:    req.ObjectKey,
 		ThumbnailURL: req.ThumbnailURL,
 		Category:     req.Category,
 		Location:     req.Location,
@@ -53,11 +81,23 @@ func (s *photoService) Create(req *domain.CreatePhotoRequest) (*domain.Photo, er
 		DisplayOrder: req.DisplayOrder,
 		Status:       domain.PhotoStatusDraft,
 	}
+	if err := s.keyStoredURLs(photo); err != nil {
+		return nil, err
+	}
+	// Photos in storage are addressed by key; imageUrl is for externally hosted images
+	if photo.ObjectKey == "" && strings.TrimSpace(photo.ImageURL) == "" {
+		return nil, apperror.BadRequest(ErrPhotoImageURLEmpty)
+	}
 
 	if err := s.repo.Create(photo); err != nil {
 		return nil, apperror.InternalError(err)
 	}
 
+	s.extractMetadata(photo)
+	s.enqueueProcessing(photo)
+
+	s.audit.Record(actor, domain.AuditPhotoCreate, domain.AuditEntityPhoto, auditID(photo.ID), nil, photo)
+	s.urls.Resolve(photo)
 	return photo, nil
 }
 
@@ -66,18 +106,49 @@ func (s *photoService) GetByID(id uint) (*domain.Photo, error) {
 	if err != nil {
 		return nil, apperror.NotFound(ErrPhotoNotFound)
 	}
+	if photo.Metadata != nil {
+		photo.Metadata = photo.Metadata.WithoutLocation()
+	}
+	s.urls.Resolve(photo)
 	return photo, nil
 }
 
-func (s *photoService) List(filters repository.PhotoFilters) ([]domain.Photo, int64, error) {
-	photos, total, err := s.repo.List(filters)
+func (s *photoService) GetMetadata(id uint) (*domain.PhotoMetadata, error) {
+	photo, err := s.repo.GetByID(id)
 	if err != nil {
-		return nil, 0, apperror.InternalError(err)
+		return nil, apperror.NotFound(ErrPhotoNotFound)
 	}
-	return photos, total, nil
+	if photo.Metadata == nil {
+		return nil, apperror.NotFound(ErrPhotoNoMetadata)
+	}
+	return photo.Metadata, nil
 }
 
-func (s *photoService) Update(id uint, req *domain.UpdatePhotoRequest) (*domain.Photo, error) {
+func (s *photoService) List(filters repository.PhotoFilters) (*repository.PhotoPage, error) {
+	switch {
+	case filters.Limit <= 0:
+		filters.Limit = defaultPageSize
+	case filters.Limit > maxPageSize:
+		filters.Limit = maxPageSize
+	}
+
+	page, err := s.repo.List(filters)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, apperror.BadRequest(err)
+		}
+		if errors.Is(err, repository.ErrInvalidSort) {
+			return nil, InvalidSortError(err)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	for i := range page.Photos {
+		s.urls.Resolve(&page.Photos[i])
+	}
+	return page, nil
+}
+
+func (s *photoService) Update(id uint, req *domain.UpdatePhotoRequest, ifMatch []int, actor domain.Actor) (*domain.Photo, error) {
 	// Check at least one field to update
 	if !req.HasUpdates() {
 		return nil, apperror.BadRequest(ErrNoFieldsToUpdate)
@@ -88,21 +159,39 @@ func (s *photoService) Update(id uint, req *domain.UpdatePhotoRequest) (*domain.
 	if err != nil {
 		return nil, apperror.NotFound(ErrPhotoNotFound)
 	}
+	if !versionMatches(photo.Version, ifMatch) {
+		return nil, s.stalePhotoError(photo)
+	}
 
 	// Validate before update
 	if req.Title != nil && strings.TrimSpace(*req.Title) == "" {
 		return nil, apperror.BadRequest(ErrPhotoTitleEmpty)
 	}
-	if req.ImageURL != nil && strings.TrimSpace(*req.ImageURL) == "" {
-		return nil, apperror.BadRequest(ErrPhotoImageURLEmpty)
+
+	before := *photo
+	objectKeyChanged := req.ObjectKey != nil && *req.ObjectKey != photo.ObjectKey
+	if objectKeyChanged && *req.ObjectKey != "" && req.ImageURL == nil {
+		// The new object replaces any external image; its URL is resolved from the key
+		photo.ImageURL = ""
 	}
 
 	// Update fields using helper function
 	updateStringField(&photo.Title, req.Title)
 	updateStringField(&photo.Description, req.Description)
 	updateStringField(&photo.ImageURL, req.ImageURL)
+	updateStringField(&photo.ObjectKey, req.ObjectKey)
 	updateStringField(&photo.ThumbnailURL, req.ThumbnailURL)
 	updateStringField(&photo.Category, req.Category)
+	// Clients often send back the URLs a photo was returned with
+	thumbnailKey := photo.ThumbnailKey
+	if err := s.keyStoredURLs(photo); err != nil {
+		return nil, err
+	}
+	if photo.ThumbnailKey != thumbnailKey {
+		// Thumbnails of uploaded images are generated, not edited
+		return nil, apperror.BadRequest(ErrPhotoStoredURL)
+	}
+	objectKeyChanged = photo.ObjectKey != before.ObjectKey
 	updateStringField(&photo.Location, req.Location)
 
 	if req.IsFeatured != nil {
@@ -114,46 +203,276 @@ func (s *photoService) Update(id uint, req *domain.UpdatePhotoRequest) (*domain.
 	if req.Status != nil {
 		photo.Status = *req.Status
 	}
+	if photo.ObjectKey == "" && strings.TrimSpace(photo.ImageURL) == "" {
+		return nil, apperror.BadRequest(ErrPhotoImageURLEmpty)
+	}
 
 	if err := s.repo.Update(photo); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			current, getErr := s.repo.GetByID(id)
+			if getErr != nil {
+				return nil, apperror.NotFound(ErrPhotoNotFound)
+			}
+			return nil, s.stalePhotoError(current)
+		}
 		return nil, apperror.InternalError(err)
 	}
 
+	if objectKeyChanged {
+		// The old image's EXIF must not outlive it, even if the new one has none
+		s.clearMetadata(photo)
+		s.extractMetadata(photo)
+		s.enqueueProcessing(photo)
+	}
+
+	s.audit.Record(actor, photoUpdateAction(before.Status, photo.Status), domain.AuditEntityPhoto, auditID(photo.ID), &before, photo)
+	s.urls.Resolve(photo)
 	return photo, nil
 }
 
-func (s *photoService) Delete(id uint) error {
-	// Delete directly and check affected rows
-	err := s.repo.Delete(id)
+// keyStoredURLs replaces URLs of uploaded objects with their keys. Such URLs
+// expire when signed and would pin the current storage or CDN host into the
+// row; keys are resolved to URLs per response instead.
+func (s *photoService) keyStoredURLs(photo *domain.Photo) error {
+	if photo.ImageURL != "" {
+		if key, stored := s.storage.ObjectKeyFromURL(photo.ImageURL); stored {
+			if key == "" || (photo.ObjectKey != "" && photo.ObjectKey != key) {
+				return apperror.BadRequest(ErrPhotoStoredURL)
+			}
+			photo.ObjectKey, photo.ImageURL = key, ""
+		}
+	}
+	if photo.ThumbnailURL != "" {
+		if key, stored := s.storage.ObjectKeyFromURL(photo.ThumbnailURL); stored {
+			if key == "" {
+				return apperror.BadRequest(ErrPhotoStoredURL)
+			}
+			photo.ThumbnailKey, photo.ThumbnailURL = key, ""
+		}
+	}
+	return nil
+}
+
+// photoUpdateAction tells publishing and unpublishing apart from other edits
+func photoUpdateAction(from, to domain.PhotoStatus) domain.AuditAction {
+	switch {
+	case from != to && to == domain.PhotoStatusPublished:
+		return domain.AuditPhotoPublish
+	case from != to && from == domain.PhotoStatusPublished:
+		return domain.AuditPhotoUnpublish
+	}
+	return domain.AuditPhotoUpdate
+}
+
+// Delete moves the photo to the trash; the purge job removes it after the retention period
+func (s *photoService) Delete(id uint, actor domain.Actor) error {
+	photo, err := s.repo.GetByID(id)
 	if err != nil {
-		// Check if it's a not found error
-		if err.Error() == "record not found" {
+		return apperror.NotFound(ErrPhotoNotFound)
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return apperror.NotFound(ErrPhotoNotFound)
 		}
 		return apperror.InternalError(err)
 	}
+
+	s.audit.Record(actor, domain.AuditPhotoDelete, domain.AuditEntityPhoto, auditID(id), photo, nil)
 	return nil
 }
 
-func (s *photoService) UpdateDisplayOrder(id uint, order int) error {
+func (s *photoService) ListTrash(beforeID uint, limit int) ([]domain.Photo, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	photos, err := s.repo.ListTrashed(beforeID, limit)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	for i := range photos {
+		s.urls.Resolve(&photos[i])
+	}
+	return photos, nil
+}
+
+func (s *photoService) Restore(id uint, actor domain.Actor) (*domain.Photo, error) {
+	if err := s.repo.Restore(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound(ErrPhotoNotInTrash)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	photo, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, apperror.NotFound(ErrPhotoNotFound)
+	}
+	s.audit.Record(actor, domain.AuditPhotoRestore, domain.AuditEntityPhoto, auditID(id), nil, photo)
+	s.urls.Resolve(photo)
+	return photo, nil
+}
+
+func (s *photoService) UpdateDisplayOrder(id uint, order int, actor domain.Actor) error {
 	err := s.repo.UpdateDisplayOrder(id, order)
 	if err != nil {
 		return apperror.InternalError(err)
 	}
+
+	s.audit.Record(actor, domain.AuditPhotoReorder, domain.AuditEntityPhoto, auditID(id), nil, map[string]int{"displayOrder": order})
 	return nil
 }
 
-func (s *photoService) BatchUpdateDisplayOrder(orders []repository.DisplayOrderUpdate) error {
-	err := s.repo.BatchUpdateDisplayOrder(orders)
+// Reprocess schedules variant generation again, e.g. after a failed run
+func (s *photoService) Reprocess(id uint, actor domain.Actor) error {
+	photo, err := s.repo.GetByID(id)
 	if err != nil {
+		return apperror.NotFound(ErrPhotoNotFound)
+	}
+	if photo.ObjectKey == "" {
+		return apperror.BadRequest(ErrPhotoNoObjectKey)
+	}
+
+	if err := s.processor.Enqueue(photo.ID); err != nil {
+		if errors.Is(err, ErrProcessingQueueFull) || errors.Is(err, ErrProcessorClosed) {
+			return apperror.ServiceUnavailable(err)
+		}
 		return apperror.InternalError(err)
 	}
+	s.audit.Record(actor, domain.AuditPhotoReprocess, domain.AuditEntityPhoto, auditID(photo.ID), nil, nil)
 	return nil
 }
 
+// extractMetadata reads EXIF from the original object and stores it on the photo.
+// Failures are logged but never fail the request: metadata is best-effort.
+func (s *photoService) extractMetadata(photo *domain.Photo) {
+	if photo.ObjectKey == "" {
+		return
+	}
+
+	reader, err := s.storage.GetObject(photo.ObjectKey)
+	if err != nil {
+		logger.Warn("failed to open photo object for EXIF", "photoId", photo.ID, "error", err)
+		return
+	}
+	defer reader.Close()
+
+	meta, err := imaging.ExtractMetadata(reader)
+	if err != nil {
+		if !errors.Is(err, imaging.ErrNoMetadata) {
+			logger.Warn("failed to extract EXIF", "photoId", photo.ID, "error", err)
+		}
+		return
+	}
+
+	record := toPhotoMetadata(photo.ID, meta)
+	if err := s.repo.SaveMetadata(record); err != nil {
+		logger.Error("failed to save photo metadata", "photoId", photo.ID, "error", err)
+		return
+	}
+	photo.Metadata = record
+}
+
+// clearMetadata drops the EXIF record of the photo's previous source, so a
+// new image without EXIF is not described, filtered or rotated by the old one
+func (s *photoService) clearMetadata(photo *domain.Photo) {
+	if err := s.repo.DeleteMetadata(photo.ID); err != nil {
+		logger.Error("failed to delete photo metadata", "photoId", photo.ID, "error", err)
+		return
+	}
+	photo.Metadata = nil
+}
+
+func toPhotoMetadata(photoID uint, meta *imaging.Metadata) *domain.PhotoMetadata {
+	orientation := meta.Orientation
+	if orientation == 0 {
+		orientation = 1
+	}
+	return &domain.PhotoMetadata{
+		PhotoID:      photoID,
+		CameraMake:   meta.CameraMake,
+		CameraModel:  meta.CameraModel,
+		LensModel:    meta.LensModel,
+		FocalLength:  meta.FocalLength,
+		Aperture:     meta.Aperture,
+		ShutterSpeed: meta.ShutterSpeed,
+		ISO:          meta.ISO,
+		CapturedAt:   meta.CapturedAt,
+		Latitude:     meta.Latitude,
+		Longitude:    meta.Longitude,
+		Altitude:     meta.Altitude,
+		Orientation:  orientation,
+	}
+}
+
+func (s *photoService) enqueueProcessing(photo *domain.Photo) {
+	if photo.ObjectKey == "" {
+		return
+	}
+	if err := s.processor.Enqueue(photo.ID); err != nil {
+		// The photo keeps its status; an admin can retry via POST /photos/:id/process
+		logger.Warn("failed to schedule photo processing", "photoId", photo.ID, "error", err)
+		return
+	}
+	photo.ProcessingStatus = domain.PhotoProcessingPending
+}
+
+// stalePhotoError is the 412 returned for a lost update; it carries the current photo
+func (s *photoService) stalePhotoError(current *domain.Photo) *apperror.AppError {
+	s.urls.Resolve(current)
+	return apperror.PreconditionFailed(ErrVersionMismatch).WithDetails("version_conflict", current)
+}
+
+// versionMatches reports whether current satisfies an If-Match precondition.
+// A nil list means no precondition was sent.
+func versionMatches(current int, ifMatch []int) bool {
+	if ifMatch == nil {
+		return true
+	}
+	for _, v := range ifMatch {
+		if v == current {
+			return true
+		}
+	}
+	return false
+}
+
+// ensurePhotosExist returns a 400 listing any photo IDs that do not exist
+func ensurePhotosExist(repo repository.PhotoRepository, photoIDs []uint) error {
+	existing, err := repo.ExistingIDs(photoIDs)
+	if err != nil {
+		return apperror.InternalError(err)
+	}
+	if len(existing) == len(photoIDs) {
+		return nil
+	}
+
+	found := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		found[id] = true
+	}
+	missing := make([]string, 0, len(photoIDs)-len(existing))
+	for _, id := range photoIDs {
+		if !found[id] {
+			missing = append(missing, fmt.Sprint(id))
+		}
+	}
+	return apperror.BadRequest(fmt.Errorf("%w: %s", ErrPhotoNotFound, strings.Join(missing, ", ")))
+}
+
 // Helper function to update string pointer fields
 func updateStringField(target *string, source *string) {
 	if source != nil {
 		*target = *source
 	}
 }
+
+// InvalidSortError turns a rejected sort spec into a 400 listing the offending fields
+func InvalidSortError(err error) *apperror.AppError {
+	appErr := apperror.BadRequest(err)
+	var sortErr *repository.SortError
+	if errors.As(err, &sortErr) {
+		return appErr.WithDetails("invalid_sort", sortErr)
+	}
+	return appErr
+}
@@ -0,0 +1,293 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/storage"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// memoryPhotoRepo keeps photos in a map; methods a test does not need panic
+type memoryPhotoRepo struct {
+	repository.PhotoRepository
+	photos map[uint]*domain.Photo
+}
+
+func (r *memoryPhotoRepo) GetByID(id uint) (*domain.Photo, error) {
+	photo, ok := r.photos[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *photo
+	if photo.Metadata != nil {
+		metadata := *photo.Metadata
+		copied.Metadata = &metadata
+	}
+	return &copied, nil
+}
+
+// discardAudit drops every event
+type discardAudit struct {
+	AuditService
+}
+
+func (discardAudit) Record(domain.Actor, domain.AuditAction, domain.AuditEntityType, string, interface{}, interface{}) {
+}
+
+// keepURLs leaves photo URLs as stored
+type keepURLs struct{}
+
+func (keepURLs) Resolve(*domain.Photo) {}
+
+func TestGetByIDHidesLocation(t *testing.T) {
+	lat, lon := 31.23, 121.47
+	repo := &memoryPhotoRepo{photos: map[uint]*domain.Photo{
+		1: {ID: 1, Title: "Bund", Metadata: &domain.PhotoMetadata{PhotoID: 1, CameraModel: "X100V", Latitude: &lat, Longitude: &lon}},
+	}}
+	svc := NewPhotoService(repo, nil, nil, keepURLs{}, discardAudit{})
+
+	photo, err := svc.GetByID(1)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if photo.Metadata.Latitude != nil || photo.Metadata.Longitude != nil {
+		t.Fatalf("public photo exposes location: %+v", photo.Metadata)
+	}
+	if photo.Metadata.CameraModel != "X100V" {
+		t.Fatalf("camera model = %q, want X100V", photo.Metadata.CameraModel)
+	}
+
+	metadata, err := svc.GetMetadata(1)
+	if err != nil {
+		t.Fatalf("GetMetadata: %v", err)
+	}
+	if metadata.Latitude == nil || *metadata.Latitude != lat {
+		t.Fatalf("admin metadata lost the latitude: %+v", metadata)
+	}
+}
+
+// filterRecorder records the filters a listing reaches the repository with
+type filterRecorder struct {
+	repository.PhotoRepository
+	filters repository.PhotoFilters
+}
+
+func (r *filterRecorder) List(filters repository.PhotoFilters) (*repository.PhotoPage, error) {
+	r.filters = filters
+	return &repository.PhotoPage{}, nil
+}
+
+func TestListBoundsPageSize(t *testing.T) {
+	cases := map[int]int{0: defaultPageSize, -5: defaultPageSize, 20: 20, maxPageSize + 1: maxPageSize}
+	for limit, want := range cases {
+		repo := &filterRecorder{}
+		svc := NewPhotoService(repo, nil, nil, keepURLs{}, discardAudit{})
+		if _, err := svc.List(repository.PhotoFilters{Limit: limit}); err != nil {
+			t.Fatalf("List(limit %d): %v", limit, err)
+		}
+		if repo.filters.Limit != want {
+			t.Errorf("limit %d reached the repository as %d, want %d", limit, repo.filters.Limit, want)
+		}
+	}
+}
+
+// racingPhotoRepo lets another writer bump the photo between the service's
+// read and its compare-and-set
+type racingPhotoRepo struct {
+	*memoryPhotoRepo
+}
+
+func (r *racingPhotoRepo) Update(photo *domain.Photo) error {
+	stored := r.photos[photo.ID]
+	stored.ThumbnailKey = "photos/a_640w.jpg"
+	stored.Version++
+	if stored.Version != photo.Version {
+		return repository.ErrVersionConflict
+	}
+	*stored = *photo
+	stored.Version++
+	return nil
+}
+
+func TestUpdateReportsConcurrentWriteAsVersionConflict(t *testing.T) {
+	repo := &racingPhotoRepo{&memoryPhotoRepo{photos: map[uint]*domain.Photo{
+		1: {ID: 1, Title: "Bund", ObjectKey: "photos/a.jpg", Version: 1},
+	}}}
+	svc := NewPhotoService(repo, nil, nil, keepURLs{}, discardAudit{})
+
+	title := "The Bund"
+	_, err := svc.Update(1, &domain.UpdatePhotoRequest{Title: &title}, []int{1}, domain.Actor{})
+
+	var appErr *apperror.AppError
+	if !errors.As(err, &appErr) || appErr.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("err = %v, want 412", err)
+	}
+	current, ok := appErr.Details.(*domain.Photo)
+	if !ok || current.Version != 2 || current.ThumbnailKey == "" {
+		t.Fatalf("conflict details = %+v, want the concurrently written photo", appErr.Details)
+	}
+	if repo.photos[1].Title != "Bund" {
+		t.Fatalf("stale update was written: title = %q", repo.photos[1].Title)
+	}
+}
+
+// savingPhotoRepo also stores created and updated photos
+type savingPhotoRepo struct {
+	*memoryPhotoRepo
+}
+
+func (r savingPhotoRepo) Create(photo *domain.Photo) error {
+	photo.ID = uint(len(r.photos) + 1)
+	copied := *photo
+	r.photos[photo.ID] = &copied
+	return nil
+}
+
+func (r savingPhotoRepo) Update(photo *domain.Photo) error {
+	copied := *photo
+	r.photos[photo.ID] = &copied
+	return nil
+}
+
+func (r savingPhotoRepo) SaveMetadata(metadata *domain.PhotoMetadata) error {
+	r.photos[metadata.PhotoID].Metadata = metadata
+	return nil
+}
+
+func (r savingPhotoRepo) DeleteMetadata(photoID uint) error {
+	r.photos[photoID].Metadata = nil
+	return nil
+}
+
+// queuedPhotos accepts every processing job
+type queuedPhotos struct {
+	PhotoProcessor
+}
+
+func (queuedPhotos) Enqueue(uint) error { return nil }
+
+const testCDN = "https://cdn.example.com"
+
+// newStoredPhotoService serves objects from memory under a CDN base URL
+func newStoredPhotoService(photos map[uint]*domain.Photo) (PhotoService, *storage.MemoryBlob) {
+	blob := storage.NewMemoryBlob(storage.NewURLSigner("http://api.test/api/v1/blobs", []byte("secret")))
+	storageService := NewStorageService(blob, config.Config{StoragePublicURL: testCDN}, nil, discardAudit{})
+	repo := savingPhotoRepo{&memoryPhotoRepo{photos: photos}}
+	return NewPhotoService(repo, storageService, queuedPhotos{}, keepURLs{}, discardAudit{}), blob
+}
+
+func TestCreateStoresUploadedImagesByKey(t *testing.T) {
+	svc, blob := newStoredPhotoService(map[uint]*domain.Photo{})
+	signed, err := blob.PresignGet(context.Background(), "photos/signed.jpg", time.Minute)
+	if err != nil {
+		t.Fatalf("presign get: %v", err)
+	}
+
+	cases := []struct {
+		name                string
+		req                 domain.CreatePhotoRequest
+		objectKey, imageURL string
+	}{
+		{"signed storage url", domain.CreatePhotoRequest{ImageURL: signed}, "photos/signed.jpg", ""},
+		{"cdn url", domain.CreatePhotoRequest{ImageURL: testCDN + "/photos/cdn.jpg"}, "photos/cdn.jpg", ""},
+		{"cdn url of the object key", domain.CreatePhotoRequest{ObjectKey: "photos/a.jpg", ImageURL: testCDN + "/photos/a.jpg"}, "photos/a.jpg", ""},
+		{"external url", domain.CreatePhotoRequest{ImageURL: "https://example.com/a.jpg"}, "", "https://example.com/a.jpg"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.req.Title = tc.name
+			photo, err := svc.Create(&tc.req, domain.Actor{})
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if photo.ObjectKey != tc.objectKey || photo.ImageURL != tc.imageURL {
+				t.Fatalf("stored key %q url %q, want key %q url %q", photo.ObjectKey, photo.ImageURL, tc.objectKey, tc.imageURL)
+			}
+		})
+	}
+}
+
+func TestCreateRejectsStoredURLsThatDoNotMatchAKey(t *testing.T) {
+	svc, _ := newStoredPhotoService(map[uint]*domain.Photo{})
+
+	for name, req := range map[string]domain.CreatePhotoRequest{
+		"another object's url":  {ObjectKey: "photos/a.jpg", ImageURL: testCDN + "/photos/b.jpg"},
+		"invalid key":           {ImageURL: testCDN + "/photos/../secret.jpg"},
+		"thumbnail invalid key": {ObjectKey: "photos/a.jpg", ThumbnailURL: testCDN + "/"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req.Title = name
+			_, err := svc.Create(&req, domain.Actor{})
+			var appErr *apperror.AppError
+			if !errors.As(err, &appErr) || appErr.StatusCode != http.StatusBadRequest || !errors.Is(err, ErrPhotoStoredURL) {
+				t.Fatalf("create: got %v, want 400 %v", err, ErrPhotoStoredURL)
+			}
+		})
+	}
+}
+
+func TestUpdateAcceptsTheURLsAPhotoWasReturnedWith(t *testing.T) {
+	photos := map[uint]*domain.Photo{
+		1: {ID: 1, Title: "Bund", ObjectKey: "photos/a.jpg", ThumbnailKey: "photos/a_thumb.webp", Version: 1},
+	}
+	svc, _ := newStoredPhotoService(photos)
+
+	title, imageURL, thumbnailURL := "The Bund", testCDN+"/photos/a.jpg", testCDN+"/photos/a_thumb.webp"
+	photo, err := svc.Update(1, &domain.UpdatePhotoRequest{Title: &title, ImageURL: &imageURL, ThumbnailURL: &thumbnailURL}, nil, domain.Actor{})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if photo.ObjectKey != "photos/a.jpg" || photo.ImageURL != "" || photo.ThumbnailKey != "photos/a_thumb.webp" || photo.ThumbnailURL != "" {
+		t.Fatalf("stored %+v, want the keys unchanged and no URLs", photo)
+	}
+	if photo.ProcessingStatus == domain.PhotoProcessingPending {
+		t.Fatal("unchanged object was queued for processing again")
+	}
+
+	// Thumbnails of uploaded images are generated, not pointed elsewhere
+	thumbnailURL = testCDN + "/photos/other.webp"
+	_, err = svc.Update(1, &domain.UpdatePhotoRequest{ThumbnailURL: &thumbnailURL}, nil, domain.Actor{})
+	if !errors.Is(err, ErrPhotoStoredURL) {
+		t.Fatalf("update thumbnail: got %v, want %v", err, ErrPhotoStoredURL)
+	}
+}
+
+func TestUpdateDropsMetadataOfTheReplacedImage(t *testing.T) {
+	lat, lon := 31.23, 121.47
+	photos := map[uint]*domain.Photo{
+		1: {ID: 1, Title: "Bund", ObjectKey: "photos/a.jpg", Version: 1, Metadata: &domain.PhotoMetadata{
+			PhotoID: 1, CameraModel: "X100V", Orientation: 6, Latitude: &lat, Longitude: &lon,
+		}},
+	}
+	svc, blob := newStoredPhotoService(photos)
+
+	// A PNG carries no EXIF
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if err := blob.Put(context.Background(), "photos/b.png", &buf, int64(buf.Len()), "image/png"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	objectKey := "photos/b.png"
+	photo, err := svc.Update(1, &domain.UpdatePhotoRequest{ObjectKey: &objectKey}, nil, domain.Actor{})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if photo.Metadata != nil || photos[1].Metadata != nil {
+		t.Fatalf("metadata of the old image survived: %+v", photos[1].Metadata)
+	}
+}
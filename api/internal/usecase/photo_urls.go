@@ -0,0 +1,33 @@
+package usecase
+
+import "github.com/aton/atonWeb/api/internal/domain"
+
+// PhotoURLResolver fills in the URLs of photos as they are returned. Rows only
+// keep object keys, so a new CDN domain or signing setup applies to every
+// photo without a data migration. Resolved photos must not be saved.
+type PhotoURLResolver interface {
+	Resolve(photo *domain.Photo)
+}
+
+type photoURLResolver struct {
+	storage StorageService
+}
+
+func NewPhotoURLResolver(storage StorageService) PhotoURLResolver {
+	return &photoURLResolver{storage: storage}
+}
+
+// Resolve keeps stored URLs of externally hosted images and builds the rest,
+// including the srcset, from object keys
+func (r *photoURLResolver) Resolve(photo *domain.Photo) {
+	if photo.ImageURL == "" && photo.ObjectKey != "" {
+		photo.ImageURL = r.storage.ObjectURL(photo.ObjectKey)
+	}
+	if photo.ThumbnailURL == "" && photo.ThumbnailKey != "" {
+		photo.ThumbnailURL = r.storage.ObjectURL(photo.ThumbnailKey)
+	}
+	for i := range photo.Variants {
+		photo.Variants[i].URL = r.storage.ObjectURL(photo.Variants[i].ObjectKey)
+	}
+	photo.SrcSet = domain.BuildSrcSet(photo.Variants)
+}
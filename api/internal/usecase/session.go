@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+const opaqueTokenBytes = 32
+
+// openSession stores a new session for user and issues its first token pair
+func (s *authService) openSession(user *domain.User, client domain.SessionClient) (*domain.TokenPair, error) {
+	refreshToken, hash, err := newOpaqueToken()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	now := time.Now()
+	session := &domain.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		UserAgent:        truncate(client.UserAgent, 500),
+		IP:               client.IP,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(s.refreshTTL),
+	}
+	if err := s.sessions.Create(session); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	return s.tokenPair(user, session.ID, refreshToken)
+}
+
+func (s *authService) Refresh(refreshToken string, client domain.SessionClient) (*domain.TokenPair, error) {
+	hash := hashToken(refreshToken)
+
+	session, err := s.sessions.GetByTokenHash(hash)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.detectReuse(hash, client)
+			return nil, apperror.Unauthorized(ErrInvalidRefreshToken)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, apperror.Unauthorized(ErrInvalidRefreshToken)
+	}
+
+	user, err := s.users.GetByID(session.UserID)
+	if err != nil || user.IsDisabled() {
+		if revokeErr := s.sessions.Revoke(session.ID, session.UserID); revokeErr != nil && !errors.Is(revokeErr, gorm.ErrRecordNotFound) {
+			logger.Error("failed to revoke session", "sessionId", session.ID, "error", revokeErr)
+		}
+		return nil, apperror.Unauthorized(ErrInvalidRefreshToken)
+	}
+
+	newToken, newHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if err := s.sessions.Rotate(session.ID, hash, newHash, time.Now().Add(s.refreshTTL)); err != nil {
+		if errors.Is(err, repository.ErrSessionRotated) {
+			// Lost a race with another refresh of the same token
+			return nil, apperror.Unauthorized(ErrInvalidRefreshToken)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	return s.tokenPair(user, session.ID, newToken)
+}
+
+// detectReuse revokes a session whose already-rotated refresh token is presented
+// again: either the client or an attacker holds a stolen copy, so neither may continue.
+func (s *authService) detectReuse(hash string, client domain.SessionClient) {
+	session, err := s.sessions.GetByPreviousHash(hash)
+	if err != nil {
+		return
+	}
+	if session.RevokedAt == nil {
+		logger.Warn("refresh token reuse detected, revoking session",
+			"sessionId", session.ID, "userId", session.UserID, "ip", client.IP)
+		if err := s.sessions.Revoke(session.ID, session.UserID); err != nil {
+			logger.Error("failed to revoke session", "sessionId", session.ID, "error", err)
+		}
+	}
+}
+
+func (s *authService) Logout(refreshToken string) error {
+	session, err := s.sessions.GetByTokenHash(hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Nothing to revoke; logging out twice is not an error
+			return nil
+		}
+		return apperror.InternalError(err)
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+	if err := s.sessions.Revoke(session.ID, session.UserID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.InternalError(err)
+	}
+	return nil
+}
+
+func (s *authService) ListSessions(userID, currentSessionID uint) ([]domain.Session, error) {
+	sessions, err := s.sessions.ListActive(userID)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *authService) RevokeSession(userID, sessionID uint, actor domain.Actor) error {
+	if err := s.sessions.Revoke(sessionID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(ErrSessionNotFound)
+		}
+		return apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditSessionRevoke, domain.AuditEntitySession, auditID(sessionID), nil, nil)
+	return nil
+}
+
+func (s *authService) tokenPair(user *domain.User, sessionID uint, refreshToken string) (*domain.TokenPair, error) {
+	accessToken, err := s.jwtManager.GenerateToken(user.ID, user.Username, string(user.Role), sessionID)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return &domain.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.jwtManager.TokenDuration().Seconds()),
+	}, nil
+}
+
+// newOpaqueToken returns a random token for refresh tokens and MFA challenges, and the hash stored for it
+func newOpaqueToken() (string, string, error) {
+	buf := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+// hashToken is a plain SHA-256: opaque tokens are high-entropy, so no salt or KDF is needed
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	return value[:max]
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// SessionCleaner deletes expired sessions in the background, so logins do not
+// pay for the cleanup. Until then expired sessions still serve reuse detection.
+type SessionCleaner interface {
+	// CleanupExpired runs one pass
+	CleanupExpired() error
+
+	// Close stops the background schedule and waits for a running pass
+	Close()
+}
+
+type sessionCleaner struct {
+	sessions repository.SessionRepository
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewSessionCleaner starts cleaning up on cfg.SessionPurgeInterval
+func NewSessionCleaner(sessions repository.SessionRepository, cfg config.Config) SessionCleaner {
+	c := &sessionCleaner{
+		sessions: sessions,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go c.run(cfg.SessionPurgeInterval)
+	return c
+}
+
+func (c *sessionCleaner) run(interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.CleanupExpired(); err != nil {
+			logger.Error("session cleanup failed", "error", err)
+		}
+
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *sessionCleaner) Close() {
+	c.once.Do(func() { close(c.stop) })
+	<-c.done
+}
+
+func (c *sessionCleaner) CleanupExpired() error {
+	return c.sessions.DeleteExpired(time.Now())
+}
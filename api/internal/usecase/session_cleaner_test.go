@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// expiryRecorder records the cutoffs expired sessions are deleted with
+type expiryRecorder struct {
+	repository.SessionRepository
+	mu      sync.Mutex
+	cutoffs []time.Time
+}
+
+func (r *expiryRecorder) DeleteExpired(before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cutoffs = append(r.cutoffs, before)
+	return nil
+}
+
+func TestSessionCleanerRunsOnSchedule(t *testing.T) {
+	repo := &expiryRecorder{}
+	start := time.Now()
+
+	cleaner := NewSessionCleaner(repo, config.Config{SessionPurgeInterval: time.Hour})
+	cleaner.Close()
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.cutoffs) != 1 {
+		t.Fatalf("cleanup ran %d times before Close, want once at start", len(repo.cutoffs))
+	}
+	if repo.cutoffs[0].Before(start) {
+		t.Fatalf("cutoff %v is before the cleaner started", repo.cutoffs[0])
+	}
+}
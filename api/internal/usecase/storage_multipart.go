@@ -0,0 +1,358 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/storage"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+)
+
+var (
+	ErrMultipartUploadNotFound = errors.New("multipart upload not found or expired")
+	ErrMultipartNoParts        = errors.New("no parts have been uploaded")
+	ErrMultipartPartMismatch   = errors.New("some parts are missing or have a different ETag")
+	ErrMultipartPartTooSmall   = errors.New("every part except the last must be at least 5 MiB")
+	ErrMultipartTooManyParts   = errors.New("a multipart upload has at most 10000 parts")
+	ErrMultipartUnsupported    = errors.New("the storage backend does not support multipart uploads; use a single upload")
+)
+
+const (
+	// multipartPartExpiry bounds each presigned part URL; resuming clients ask for fresh ones
+	multipartPartExpiry = 15 * time.Minute
+	// S3 caps a multipart upload at 10000 parts of at least 5 MiB, except the last
+	multipartMaxParts   = 10000
+	multipartMinPart    = 5 << 20
+	multipartObjectsDir = "photos/"
+)
+
+// MultipartUploadResponse starts a multipart upload. Parts should be
+// PartSize bytes, except the last, and numbered from 1.
+type MultipartUploadResponse struct {
+	UploadID  string    `json:"uploadId"`
+	ObjectKey string    `json:"objectKey"`
+	PartSize  int64     `json:"partSize"`
+	MinPart   int64     `json:"minPartSize"`
+	MaxParts  int       `json:"maxParts"`
+	MaxBytes  int64     `json:"maxBytes"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type PresignedPart struct {
+	PartNumber int    `json:"partNumber"`
+	UploadURL  string `json:"uploadUrl"`
+}
+
+// PresignedPartsResponse holds one PUT URL per requested part; the ETag
+// header of each PUT response is needed to complete the upload
+type PresignedPartsResponse struct {
+	Parts     []PresignedPart `json:"parts"`
+	ExpiresIn int             `json:"expiresIn"`
+}
+
+// UploadedPart is a part the bucket has received, so a client can resume
+type UploadedPart struct {
+	PartNumber   int       `json:"partNumber"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// MultipartCompleteResponse describes the assembled object. It still has to
+// be completed as an upload to become a photo.
+type MultipartCompleteResponse struct {
+	ObjectKey string `json:"objectKey"`
+	FileURL   string `json:"fileUrl"`
+	Size      int64  `json:"size"`
+	Parts     int    `json:"parts"`
+}
+
+// smallPart accompanies ErrMultipartPartTooSmall so clients can re-upload the part
+type smallPart struct {
+	PartNumber int   `json:"partNumber"`
+	Size       int64 `json:"size"`
+	MinSize    int64 `json:"minSize"`
+}
+
+// multipartAudit records a multipart upload without any signed URL
+type multipartAudit struct {
+	UploadID string `json:"uploadId"`
+	Filename string `json:"filename,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Parts    int    `json:"parts,omitempty"`
+}
+
+// InitiateMultipartUpload 开始分片上传, 同时登记为待完成的上传
+func (s *storageService) InitiateMultipartUpload(filename, contentType string, actor domain.Actor) (*MultipartUploadResponse, error) {
+	multipart, err := s.multipart()
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !isValidImageExtension(ext) {
+		return nil, apperror.BadRequest(ErrInvalidFileExtension)
+	}
+	// Content-Type 由扩展名决定, 完成后仍会校验真实格式
+	if byExt := mime.TypeByExtension(ext); byExt != "" {
+		contentType = byExt
+	}
+
+	objectKey := newObjectKey(ext)
+	uploadID, err := multipart.NewMultipartUpload(context.Background(), objectKey, contentType)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	upload := s.pendingUpload(objectKey, filename, actor)
+	upload.MultipartUploadID = &uploadID
+	if err := s.uploads.Create(upload); err != nil {
+		s.abortMultipart(multipart, objectKey, uploadID)
+		return nil, apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditStorageMultipartInitiate, domain.AuditEntityStorageObject, objectKey, nil, multipartAudit{
+		UploadID: uploadID,
+		Filename: filename,
+	})
+
+	return &MultipartUploadResponse{
+		UploadID:  uploadID,
+		ObjectKey: objectKey,
+		PartSize:  uploadPartSize,
+		MinPart:   multipartMinPart,
+		MaxParts:  multipartMaxParts,
+		MaxBytes:  s.multipartMax,
+		ExpiresAt: upload.ExpiresAt,
+	}, nil
+}
+
+// PresignMultipartParts 为指定分片生成预签名 PUT URL
+func (s *storageService) PresignMultipartParts(uploadID string, partNumbers []int, actor domain.Actor) (*PresignedPartsResponse, error) {
+	multipart, upload, err := s.multipartUpload(uploadID, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]PresignedPart, 0, len(partNumbers))
+	for _, number := range partNumbers {
+		u, err := multipart.PresignPart(context.Background(), upload.ObjectKey, uploadID, number, multipartPartExpiry)
+		if err != nil {
+			return nil, apperror.InternalError(err)
+		}
+		parts = append(parts, PresignedPart{PartNumber: number, UploadURL: u})
+	}
+
+	return &PresignedPartsResponse{Parts: parts, ExpiresIn: int(multipartPartExpiry.Seconds())}, nil
+}
+
+// ListMultipartParts 列出已上传的分片, 供客户端断点续传
+func (s *storageService) ListMultipartParts(uploadID string, actor domain.Actor) ([]UploadedPart, error) {
+	multipart, upload, err := s.multipartUpload(uploadID, actor)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := s.listParts(multipart, upload.ObjectKey, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// CompleteMultipartUpload 合并分片; 未指定分片时使用所有已上传的分片
+func (s *storageService) CompleteMultipartUpload(uploadID string, requested []domain.CompletedPart, actor domain.Actor) (*MultipartCompleteResponse, error) {
+	multipart, upload, err := s.multipartUpload(uploadID, actor)
+	if err != nil {
+		return nil, err
+	}
+	uploaded, err := s.listParts(multipart, upload.ObjectKey, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, size, err := selectParts(uploaded, requested)
+	if err != nil {
+		return nil, err
+	}
+	if size > s.multipartMax {
+		// 超出上限的上传无法再完成, 直接放弃
+		s.abortMultipart(multipart, upload.ObjectKey, uploadID)
+		if _, err := s.uploads.Consume(upload.ObjectKey); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Error("failed to delete oversized multipart upload", "objectKey", upload.ObjectKey, "error", err)
+		}
+		return nil, fileTooLargeError(s.multipartMax)
+	}
+
+	if err := multipart.CompleteMultipartUpload(context.Background(), upload.ObjectKey, uploadID, parts); err != nil {
+		if errors.Is(err, storage.ErrNoSuchUpload) {
+			return nil, apperror.NotFound(ErrMultipartUploadNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditStorageMultipartComplete, domain.AuditEntityStorageObject, upload.ObjectKey, nil, multipartAudit{
+		UploadID: uploadID,
+		Filename: upload.Filename,
+		Size:     size,
+		Parts:    len(parts),
+	})
+
+	return &MultipartCompleteResponse{
+		ObjectKey: upload.ObjectKey,
+		FileURL:   s.ObjectURL(upload.ObjectKey),
+		Size:      size,
+		Parts:     len(parts),
+	}, nil
+}
+
+// AbortMultipartUpload 放弃分片上传并删除已上传的分片
+func (s *storageService) AbortMultipartUpload(uploadID string, actor domain.Actor) error {
+	multipart, upload, err := s.multipartUpload(uploadID, actor)
+	if err != nil {
+		return err
+	}
+	if err := multipart.AbortMultipartUpload(context.Background(), upload.ObjectKey, uploadID); err != nil {
+		return apperror.InternalError(err)
+	}
+	if _, err := s.uploads.Consume(upload.ObjectKey); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditStorageMultipartAbort, domain.AuditEntityStorageObject, upload.ObjectKey, nil, multipartAudit{UploadID: uploadID})
+	return nil
+}
+
+// AbortStaleMultipartUploads 放弃过早开始且仍未完成的分片上传, 释放其占用的存储
+func (s *storageService) AbortStaleMultipartUploads(cutoff time.Time) (int, error) {
+	multipart, ok := s.blob.(storage.Multipart)
+	if !ok {
+		return 0, nil
+	}
+	ctx := context.Background()
+	uploads, err := multipart.ListMultipartUploads(ctx, multipartObjectsDir)
+	if err != nil {
+		return 0, err
+	}
+
+	aborted := 0
+	for _, info := range uploads {
+		if !info.Initiated.Before(cutoff) {
+			continue
+		}
+		if err := multipart.AbortMultipartUpload(ctx, info.Key, info.UploadID); err != nil {
+			logger.Error("failed to abort stale multipart upload", "objectKey", info.Key, "uploadId", info.UploadID, "error", err)
+			continue
+		}
+		aborted++
+	}
+	return aborted, nil
+}
+
+// multipart 返回支持分片上传的存储后端, 不支持时返回 501
+func (s *storageService) multipart() (storage.Multipart, error) {
+	multipart, ok := s.blob.(storage.Multipart)
+	if !ok {
+		return nil, apperror.NotImplemented(ErrMultipartUnsupported)
+	}
+	return multipart, nil
+}
+
+// multipartUpload 查找当前用户未过期的分片上传
+func (s *storageService) multipartUpload(uploadID string, actor domain.Actor) (storage.Multipart, *domain.PendingUpload, error) {
+	multipart, err := s.multipart()
+	if err != nil {
+		return nil, nil, err
+	}
+	upload, err := s.uploads.GetByMultipartUploadID(uploadID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, apperror.NotFound(ErrMultipartUploadNotFound)
+		}
+		return nil, nil, apperror.InternalError(err)
+	}
+	if upload.UserID != actor.UserID || time.Now().After(upload.ExpiresAt) {
+		return nil, nil, apperror.NotFound(ErrMultipartUploadNotFound)
+	}
+	return multipart, upload, nil
+}
+
+func (s *storageService) listParts(multipart storage.Multipart, objectKey, uploadID string) ([]UploadedPart, error) {
+	stored, err := multipart.ListParts(context.Background(), objectKey, uploadID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoSuchUpload) {
+			return nil, apperror.NotFound(ErrMultipartUploadNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	parts := make([]UploadedPart, 0, len(stored))
+	for _, part := range stored {
+		parts = append(parts, UploadedPart{
+			PartNumber:   part.Number,
+			ETag:         part.ETag,
+			Size:         part.Size,
+			LastModified: part.LastModified,
+		})
+	}
+	return parts, nil
+}
+
+func (s *storageService) abortMultipart(multipart storage.Multipart, objectKey, uploadID string) {
+	if err := multipart.AbortMultipartUpload(context.Background(), objectKey, uploadID); err != nil {
+		logger.Error("failed to abort multipart upload", "objectKey", objectKey, "uploadId", uploadID, "error", err)
+	}
+}
+
+// selectParts checks the requested parts against the uploaded ones and
+// returns them in order with their total size. Parts S3 would refuse to
+// assemble are rejected here, so the client gets a 400 instead of a 500.
+func selectParts(uploaded []UploadedPart, requested []domain.CompletedPart) ([]storage.Part, int64, error) {
+	if len(uploaded) == 0 {
+		return nil, 0, apperror.BadRequest(ErrMultipartNoParts)
+	}
+
+	byNumber := make(map[int]UploadedPart, len(uploaded))
+	for _, part := range uploaded {
+		byNumber[part.PartNumber] = part
+	}
+	if len(requested) == 0 {
+		for _, part := range uploaded {
+			requested = append(requested, domain.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag})
+		}
+	}
+
+	parts := make([]storage.Part, 0, len(requested))
+	seen := make(map[int]bool, len(requested))
+	var size int64
+	for _, req := range requested {
+		part, ok := byNumber[req.PartNumber]
+		if !ok || seen[req.PartNumber] || strings.Trim(req.ETag, `"`) != strings.Trim(part.ETag, `"`) {
+			return nil, 0, apperror.BadRequest(ErrMultipartPartMismatch)
+		}
+		seen[req.PartNumber] = true
+		size += part.Size
+		parts = append(parts, storage.Part{Number: part.PartNumber, ETag: part.ETag, Size: part.Size})
+	}
+	if len(parts) > multipartMaxParts {
+		return nil, 0, apperror.BadRequest(ErrMultipartTooManyParts)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	for _, part := range parts[:len(parts)-1] {
+		if part.Size < multipartMinPart {
+			return nil, 0, apperror.BadRequest(ErrMultipartPartTooSmall).WithDetails("part_too_small", smallPart{
+				PartNumber: part.Number,
+				Size:       part.Size,
+				MinSize:    multipartMinPart,
+			})
+		}
+	}
+	return parts, size, nil
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+)
+
+func TestSelectPartsOrdersRequestedParts(t *testing.T) {
+	uploaded := []UploadedPart{
+		{PartNumber: 2, ETag: `"b"`, Size: 1 << 20},
+		{PartNumber: 1, ETag: `"a"`, Size: multipartMinPart},
+	}
+	// Clients may send ETags with or without quotes, in any order
+	parts, size, err := selectParts(uploaded, []domain.CompletedPart{
+		{PartNumber: 2, ETag: "b"},
+		{PartNumber: 1, ETag: `"a"`},
+	})
+	if err != nil {
+		t.Fatalf("select parts: %v", err)
+	}
+	if len(parts) != 2 || parts[0].Number != 1 || parts[1].Number != 2 {
+		t.Fatalf("parts out of order: %+v", parts)
+	}
+	if size != multipartMinPart+1<<20 {
+		t.Fatalf("size = %d", size)
+	}
+}
+
+func TestSelectPartsDefaultsToEveryUploadedPart(t *testing.T) {
+	uploaded := []UploadedPart{
+		{PartNumber: 1, ETag: "a", Size: multipartMinPart},
+		{PartNumber: 2, ETag: "b", Size: 10},
+	}
+	parts, _, err := selectParts(uploaded, nil)
+	if err != nil {
+		t.Fatalf("select parts: %v", err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("selected %d parts, want 2", len(parts))
+	}
+}
+
+func TestSelectPartsRejectsInvalidParts(t *testing.T) {
+	full := UploadedPart{PartNumber: 1, ETag: "a", Size: multipartMinPart}
+
+	cases := []struct {
+		name      string
+		uploaded  []UploadedPart
+		requested []domain.CompletedPart
+		want      error
+	}{
+		{
+			name: "nothing uploaded",
+			want: ErrMultipartNoParts,
+		},
+		{
+			name:      "unknown part",
+			uploaded:  []UploadedPart{full},
+			requested: []domain.CompletedPart{{PartNumber: 2, ETag: "a"}},
+			want:      ErrMultipartPartMismatch,
+		},
+		{
+			name:      "different etag",
+			uploaded:  []UploadedPart{full},
+			requested: []domain.CompletedPart{{PartNumber: 1, ETag: "stale"}},
+			want:      ErrMultipartPartMismatch,
+		},
+		{
+			name:      "duplicate part",
+			uploaded:  []UploadedPart{full},
+			requested: []domain.CompletedPart{{PartNumber: 1, ETag: "a"}, {PartNumber: 1, ETag: "a"}},
+			want:      ErrMultipartPartMismatch,
+		},
+		{
+			name: "small part before the last",
+			uploaded: []UploadedPart{
+				{PartNumber: 1, ETag: "a", Size: multipartMinPart - 1},
+				{PartNumber: 2, ETag: "b", Size: multipartMinPart},
+			},
+			want: ErrMultipartPartTooSmall,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := selectParts(tc.uploaded, tc.requested)
+			var appErr *apperror.AppError
+			if !errors.As(err, &appErr) || appErr.StatusCode != http.StatusBadRequest {
+				t.Fatalf("got %v, want 400", err)
+			}
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelectPartsAllowsSmallLastPart(t *testing.T) {
+	uploaded := []UploadedPart{
+		{PartNumber: 1, ETag: "a", Size: multipartMinPart},
+		{PartNumber: 2, ETag: "b", Size: 1},
+	}
+	// A single small part is also its own last part
+	for _, requested := range [][]domain.CompletedPart{nil, {{PartNumber: 2, ETag: "b"}}} {
+		if _, _, err := selectParts(uploaded, requested); err != nil {
+			t.Fatalf("select parts %v: %v", requested, err)
+		}
+	}
+}
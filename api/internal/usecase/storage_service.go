@@ -4,24 +4,68 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 
 	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/imaging"
+	"github.com/aton/atonWeb/api/internal/infrastructure/storage"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
 )
 
 var (
 	ErrStorageNotConfigured = errors.New("storage service not configured")
 	ErrInvalidFileExtension = errors.New("invalid file extension")
+	ErrFileTooLarge         = errors.New("file exceeds the upload size limit")
+	ErrUnsupportedImage     = errors.New("only JPEG, PNG, WebP and GIF images are accepted")
+	ErrImageTooManyPixels   = errors.New("image dimensions exceed the pixel limit")
+	ErrObjectNotFound       = errors.New("object not found in storage")
 )
 
+// uploadPartSize is the part size suggested to multipart clients
+const uploadPartSize = 16 << 20
+
 type StorageService interface {
-	GeneratePresignedUploadURL(filename string, contentType string) (*PresignedUploadResponse, error)
-	GetPublicURL(objectName string) string
+	GeneratePresignedUploadURL(filename string, contentType string, actor domain.Actor) (*PresignedUploadResponse, error)
+	// Upload streams an image to the bucket after checking its magic bytes,
+	// header and pixel count; the claimed filename only appears in the audit log
+	Upload(ctx context.Context, file io.Reader, filename string, actor domain.Actor) (*UploadResult, error)
+	// ObjectURL is the URL clients load an object from: a presigned GET when
+	// STORAGE_SIGNED_URL_TTL is set, otherwise under the CDN or storage base URL.
+	// It is resolved per response, so stored rows only keep object keys.
+	ObjectURL(objectKey string) string
+	// BaseURLs are the prefixes object URLs are built under: the storage
+	// endpoint and, when set, the CDN base URL
+	BaseURLs() []string
+	// ObjectKeyFromURL returns the key of an object URL built by ObjectURL,
+	// signed or not. stored is false for URLs of externally hosted images; key
+	// is empty when a stored URL does not name a valid key.
+	ObjectKeyFromURL(rawURL string) (key string, stored bool)
+	// Multipart uploads let clients send large files in parts straight to the
+	// bucket and resume after a dropped connection. Backends without multipart
+	// support answer 501 and clients fall back to a single upload.
+	InitiateMultipartUpload(filename, contentType string, actor domain.Actor) (*MultipartUploadResponse, error)
+	PresignMultipartParts(uploadID string, partNumbers []int, actor domain.Actor) (*PresignedPartsResponse, error)
+	ListMultipartParts(uploadID string, actor domain.Actor) ([]UploadedPart, error)
+	CompleteMultipartUpload(uploadID string, parts []domain.CompletedPart, actor domain.Actor) (*MultipartCompleteResponse, error)
+	AbortMultipartUpload(uploadID string, actor domain.Actor) error
+	// AbortStaleMultipartUploads aborts every unfinished multipart upload
+	// initiated before cutoff, whether or not it is tracked
+	AbortStaleMultipartUploads(cutoff time.Time) (int, error)
+
+	// StatObject and GetObject return ErrObjectNotFound when the key does not exist
+	StatObject(objectKey string) (*ObjectInfo, error)
+	GetObject(objectKey string) (io.ReadCloser, error)
+	PutObject(objectKey string, reader io.Reader, size int64, contentType string) error
+	RemoveObject(objectKey string) error
 }
 
 type PresignedUploadResponse struct {
@@ -31,86 +75,299 @@ type PresignedUploadResponse struct {
 	ExpiresIn int    `json:"expiresIn"`
 }
 
-type storageService struct {
-	client   *minio.Client
-	bucket   string
-	endpoint string
-	useSSL   bool
+// ObjectInfo is what the bucket reports about a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
 }
 
-func NewStorageService(cfg config.Config) (StorageService, error) {
-	if cfg.OSSEndpoint == "" || cfg.OSSBucket == "" {
-		return nil, ErrStorageNotConfigured
-	}
+// UploadResult describes an object stored by Upload
+type UploadResult struct {
+	ObjectKey   string `json:"objectKey"`
+	FileURL     string `json:"fileUrl"`
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+}
 
-	client, err := minio.New(cfg.OSSEndpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.OSSAccessKeyID, cfg.OSSAccessKeySecret, ""),
-		Secure: cfg.OSSUseSSL,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create minio client: %w", err)
-	}
+// uploadLimits accompanies upload errors so clients can explain the rejection
+type uploadLimits struct {
+	MaxBytes  int64 `json:"maxBytes,omitempty"`
+	MaxPixels int64 `json:"maxPixels,omitempty"`
+}
 
-	// 检查 bucket 是否存在
-	ctx := context.Background()
-	exists, err := client.BucketExists(ctx, cfg.OSSBucket)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check bucket existence: %w", err)
-	}
-	if !exists {
-		return nil, fmt.Errorf("bucket %s does not exist", cfg.OSSBucket)
-	}
+type storageService struct {
+	blob         storage.Blob
+	publicURL    string
+	signedURLTTL time.Duration
+	maxBytes     int64
+	multipartMax int64
+	maxPixels    int64
+	audit        AuditService
+
+	// Every issued key is tracked until it is completed or expires
+	uploads    repository.PendingUploadRepository
+	pendingTTL time.Duration
+}
+
+// storageUploadAudit records an issued upload URL without its signature
+type storageUploadAudit struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	ObjectKey   string `json:"objectKey"`
+	ExpiresIn   int    `json:"expiresIn"`
+}
 
+// storageObjectAudit records an object stored through the API
+type storageObjectAudit struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+}
+
+// NewStorageService stores objects in blob, which is chosen by STORAGE_DRIVER
+func NewStorageService(blob storage.Blob, cfg config.Config, uploads repository.PendingUploadRepository, audit AuditService) StorageService {
 	return &storageService{
-		client:   client,
-		bucket:   cfg.OSSBucket,
-		endpoint: cfg.OSSEndpoint,
-		useSSL:   cfg.OSSUseSSL,
-	}, nil
+		blob:         blob,
+		publicURL:    cfg.StoragePublicURL,
+		signedURLTTL: cfg.StorageSignedURLTTL,
+		maxBytes:     cfg.UploadMaxBytes,
+		multipartMax: cfg.MultipartMaxBytes,
+		maxPixels:    cfg.UploadMaxPixels,
+		audit:        audit,
+		uploads:      uploads,
+		pendingTTL:   cfg.UploadPendingTTL,
+	}
 }
 
 // GeneratePresignedUploadURL 生成预签名上传 URL
-func (s *storageService) GeneratePresignedUploadURL(filename string, contentType string) (*PresignedUploadResponse, error) {
+func (s *storageService) GeneratePresignedUploadURL(filename string, contentType string, actor domain.Actor) (*PresignedUploadResponse, error) {
 	// 生成唯一的对象名
 	ext := filepath.Ext(filename)
 	if !isValidImageExtension(ext) {
 		return nil, ErrInvalidFileExtension
 	}
 
-	// 使用 UUID + 原始扩展名
-	objectKey := fmt.Sprintf("photos/%s/%s%s",
-		time.Now().Format("2006/01"),  // 按年月分目录
-		uuid.New().String(),
-		ext,
-	)
+	objectKey := newObjectKey(ext)
+
+	// 先登记待完成的上传, 过期未完成的对象会被清理
+	if err := s.trackUpload(objectKey, filename, actor); err != nil {
+		return nil, err
+	}
 
 	// 生成预签名 URL (有效期 15 分钟)
 	expiresIn := 15 * time.Minute
-	presignedURL, err := s.client.PresignedPutObject(
-		context.Background(),
-		s.bucket,
-		objectKey,
-		expiresIn,
-	)
+	presignedURL, err := s.blob.PresignPut(context.Background(), objectKey, expiresIn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate presigned URL: %w", err)
+		return nil, err
 	}
 
+	// 记录签发的上传地址 (不含签名)
+	s.audit.Record(actor, domain.AuditStorageUploadURL, domain.AuditEntityStorageObject, objectKey, nil, storageUploadAudit{
+		Filename:    filename,
+		ContentType: contentType,
+		ObjectKey:   objectKey,
+		ExpiresIn:   int(expiresIn.Seconds()),
+	})
+
 	return &PresignedUploadResponse{
-		UploadURL: presignedURL.String(),
-		FileURL:   s.GetPublicURL(objectKey),
+		UploadURL: presignedURL,
+		FileURL:   s.ObjectURL(objectKey),
 		ObjectKey: objectKey,
 		ExpiresIn: int(expiresIn.Seconds()),
 	}, nil
 }
 
-// GetPublicURL 获取对象的公开访问 URL
-func (s *storageService) GetPublicURL(objectName string) string {
-	protocol := "http"
-	if s.useSSL {
-		protocol = "https"
+// Upload 校验并流式上传图片: 先读文件头确认真实格式与尺寸, 再把完整内容转发到 bucket
+func (s *storageService) Upload(ctx context.Context, file io.Reader, filename string, actor domain.Actor) (*UploadResult, error) {
+	limited := &uploadLimitReader{r: file, max: s.maxBytes}
+
+	info, body, err := imaging.Inspect(limited, s.maxPixels)
+	if err != nil {
+		if limited.exceeded() {
+			return nil, fileTooLargeError(s.maxBytes)
+		}
+		return nil, inspectError(err, s.maxPixels)
+	}
+
+	// 扩展名与 Content-Type 取自实际格式, 不信任客户端声明
+	objectKey := newObjectKey(info.Format.Extension)
+	if err := s.blob.Put(ctx, objectKey, body, -1, info.Format.ContentType); err != nil {
+		if limited.exceeded() {
+			return nil, fileTooLargeError(s.maxBytes)
+		}
+		return nil, apperror.InternalError(err)
 	}
-	return fmt.Sprintf("%s://%s/%s/%s", protocol, s.endpoint, s.bucket, objectName)
+	if err := s.trackUpload(objectKey, filename, actor); err != nil {
+		if removeErr := s.RemoveObject(objectKey); removeErr != nil {
+			logger.Error("failed to remove untracked upload", "objectKey", objectKey, "error", removeErr)
+		}
+		return nil, err
+	}
+
+	result := &UploadResult{
+		ObjectKey:   objectKey,
+		FileURL:     s.ObjectURL(objectKey),
+		ContentType: info.Format.ContentType,
+		Width:       info.Width,
+		Height:      info.Height,
+		Size:        limited.n,
+	}
+	s.audit.Record(actor, domain.AuditStorageUpload, domain.AuditEntityStorageObject, objectKey, nil, storageObjectAudit{
+		Filename:    filename,
+		ContentType: result.ContentType,
+		Width:       result.Width,
+		Height:      result.Height,
+		Size:        result.Size,
+	})
+	return result, nil
+}
+
+// trackUpload 登记待完成的上传
+func (s *storageService) trackUpload(objectKey, filename string, actor domain.Actor) error {
+	if err := s.uploads.Create(s.pendingUpload(objectKey, filename, actor)); err != nil {
+		return apperror.InternalError(err)
+	}
+	return nil
+}
+
+func (s *storageService) pendingUpload(objectKey, filename string, actor domain.Actor) *domain.PendingUpload {
+	return &domain.PendingUpload{
+		ObjectKey: objectKey,
+		UserID:    actor.UserID,
+		Filename:  truncate(filepath.Base(filename), 255),
+		ExpiresAt: time.Now().Add(s.pendingTTL),
+	}
+}
+
+// ObjectURL 生成对象的访问地址: 私有存储返回预签名地址, 否则优先使用 CDN 域名
+func (s *storageService) ObjectURL(objectKey string) string {
+	if s.signedURLTTL > 0 {
+		u, err := s.blob.PresignGet(context.Background(), objectKey, s.signedURLTTL)
+		if err != nil {
+			logger.Error("failed to presign object url", "objectKey", objectKey, "error", err)
+			return ""
+		}
+		return u
+	}
+	if s.publicURL != "" {
+		return storage.KeyURL(s.publicURL, objectKey)
+	}
+	return s.blob.URL(objectKey)
+}
+
+// BaseURLs 返回对象地址的前缀: 存储自身的地址, 以及配置的 CDN 域名
+func (s *storageService) BaseURLs() []string {
+	bases := s.blob.BaseURLs()
+	if s.publicURL != "" {
+		bases = append(bases, s.publicURL)
+	}
+	return bases
+}
+
+// ObjectKeyFromURL 从存储或 CDN 地址中取出对象 key, 忽略预签名参数
+func (s *storageService) ObjectKeyFromURL(rawURL string) (string, bool) {
+	for _, base := range s.BaseURLs() {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(rawURL), base+"/")
+		if !ok {
+			continue
+		}
+		rest, _, _ = strings.Cut(rest, "?")
+		key, err := url.PathUnescape(rest)
+		if err != nil || !storage.ValidKey(key) {
+			return "", true
+		}
+		return key, true
+	}
+	return "", false
+}
+
+// StatObject 查询对象大小与类型
+func (s *storageService) StatObject(objectKey string) (*ObjectInfo, error) {
+	info, err := s.blob.Stat(context.Background(), objectKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return &ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// GetObject 读取对象内容，调用方负责关闭
+func (s *storageService) GetObject(objectKey string) (io.ReadCloser, error) {
+	reader, err := s.blob.Get(context.Background(), objectKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return reader, nil
+}
+
+// PutObject 上传对象 (size 为 -1 时使用分片流式上传)
+func (s *storageService) PutObject(objectKey string, reader io.Reader, size int64, contentType string) error {
+	return s.blob.Put(context.Background(), objectKey, reader, size, contentType)
+}
+
+// RemoveObject 删除对象 (对象不存在时不报错)
+func (s *storageService) RemoveObject(objectKey string) error {
+	return s.blob.Remove(context.Background(), objectKey)
+}
+
+// newObjectKey 使用 UUID + 扩展名, 按年月分目录
+func newObjectKey(ext string) string {
+	return fmt.Sprintf("photos/%s/%s%s",
+		time.Now().Format("2006/01"),
+		uuid.New().String(),
+		ext,
+	)
+}
+
+func fileTooLargeError(maxBytes int64) error {
+	return apperror.PayloadTooLarge(ErrFileTooLarge).WithDetails("file_too_large", uploadLimits{MaxBytes: maxBytes})
+}
+
+// inspectError 把图片头校验失败转换为客户端错误
+func inspectError(err error, maxPixels int64) error {
+	switch {
+	case errors.Is(err, imaging.ErrTooManyPixels):
+		return apperror.BadRequest(ErrImageTooManyPixels).WithDetails("too_many_pixels", uploadLimits{MaxPixels: maxPixels})
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		return apperror.UnsupportedMediaType(ErrUnsupportedImage)
+	}
+	return apperror.BadRequest(err)
+}
+
+// uploadLimitReader 读取超过 max 字节后返回 ErrFileTooLarge, 中止流式上传
+type uploadLimitReader struct {
+	r   io.Reader
+	max int64
+	n   int64
+}
+
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.exceeded() {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+func (l *uploadLimitReader) exceeded() bool {
+	return l.n > l.max
 }
 
 // 验证图片扩展名
@@ -124,4 +381,4 @@ func isValidImageExtension(ext string) bool {
 		".bmp":  true,
 	}
 	return validExtensions[ext]
-}
\ No newline at end of file
+}
@@ -0,0 +1,251 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrTagNotFound  = errors.New("tag not found")
+	ErrTagNameEmpty = errors.New("tag name cannot be empty")
+	ErrTagNameTaken = errors.New("a tag with this name already exists")
+)
+
+type TagService interface {
+	Create(req *domain.CreateTagRequest, actor domain.Actor) (*domain.Tag, error)
+	Update(id uint, req *domain.UpdateTagRequest, actor domain.Actor) (*domain.Tag, error)
+	Delete(id uint, actor domain.Actor) error
+
+	// List tags used by published photos, with counts for a tag cloud (public)
+	ListPublished() ([]domain.Tag, error)
+
+	// List every tag with usage counts across all photos (admin)
+	List() ([]domain.Tag, error)
+
+	// Bulk add/remove tags (by name) on photos
+	AddToPhotos(req *domain.BulkPhotoTagsRequest, actor domain.Actor) ([]domain.Tag, error)
+	RemoveFromPhotos(req *domain.BulkPhotoTagsRequest, actor domain.Actor) error
+}
+
+type tagService struct {
+	repo      repository.TagRepository
+	photoRepo repository.PhotoRepository
+	audit     AuditService
+}
+
+func NewTagService(repo repository.TagRepository, photoRepo repository.PhotoRepository, audit AuditService) TagService {
+	return &tagService{repo: repo, photoRepo: photoRepo, audit: audit}
+}
+
+func (s *tagService) Create(req *domain.CreateTagRequest, actor domain.Actor) (*domain.Tag, error) {
+	name := strings.TrimSpace(req.Name)
+	slug := TagSlug(name)
+	if slug == "" {
+		return nil, apperror.BadRequest(ErrTagNameEmpty)
+	}
+
+	taken, err := s.repo.SlugExists(slug, 0)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if taken {
+		return nil, apperror.Conflict(ErrTagNameTaken)
+	}
+
+	tag := &domain.Tag{Name: name, Slug: slug}
+	if err := s.repo.Create(tag); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditTagCreate, domain.AuditEntityTag, auditID(tag.ID), nil, tag)
+	return tag, nil
+}
+
+func (s *tagService) Update(id uint, req *domain.UpdateTagRequest, actor domain.Actor) (*domain.Tag, error) {
+	tag, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound(ErrTagNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	slug := TagSlug(name)
+	if slug == "" {
+		return nil, apperror.BadRequest(ErrTagNameEmpty)
+	}
+
+	taken, err := s.repo.SlugExists(slug, tag.ID)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if taken {
+		return nil, apperror.Conflict(ErrTagNameTaken)
+	}
+
+	before := *tag
+	tag.Name = name
+	tag.Slug = slug
+	if err := s.repo.Update(tag); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditTagUpdate, domain.AuditEntityTag, auditID(tag.ID), &before, tag)
+	return tag, nil
+}
+
+func (s *tagService) Delete(id uint, actor domain.Actor) error {
+	tag, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(ErrTagNotFound)
+		}
+		return apperror.InternalError(err)
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(ErrTagNotFound)
+		}
+		return apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditTagDelete, domain.AuditEntityTag, auditID(id), tag, nil)
+	return nil
+}
+
+func (s *tagService) ListPublished() ([]domain.Tag, error) {
+	tags, err := s.repo.ListWithCounts(true)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return tags, nil
+}
+
+func (s *tagService) List() ([]domain.Tag, error) {
+	tags, err := s.repo.ListWithCounts(false)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return tags, nil
+}
+
+func (s *tagService) AddToPhotos(req *domain.BulkPhotoTagsRequest, actor domain.Actor) ([]domain.Tag, error) {
+	photoIDs := uniquePhotoIDs(req.PhotoIDs)
+	if err := ensurePhotosExist(s.photoRepo, photoIDs); err != nil {
+		return nil, err
+	}
+
+	wanted := tagsFromNames(req.Tags)
+	if len(wanted) == 0 {
+		return nil, apperror.BadRequest(ErrTagNameEmpty)
+	}
+
+	tags, err := s.repo.FindOrCreate(wanted)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	if err := s.repo.AddToPhotos(photoIDs, tagIDs(tags)); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	for _, tag := range tags {
+		s.audit.Record(actor, domain.AuditTagAddToPhotos, domain.AuditEntityTag, auditID(tag.ID), nil, auditPhotoIDs{PhotoIDs: photoIDs})
+	}
+	return tags, nil
+}
+
+func (s *tagService) RemoveFromPhotos(req *domain.BulkPhotoTagsRequest, actor domain.Actor) error {
+	photoIDs := uniquePhotoIDs(req.PhotoIDs)
+
+	slugs := TagSlugs(req.Tags)
+	tags, err := s.repo.GetBySlugs(slugs)
+	if err != nil {
+		return apperror.InternalError(err)
+	}
+	// Removing tags that don't exist is a no-op
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if err := s.repo.RemoveFromPhotos(photoIDs, tagIDs(tags)); err != nil {
+		return apperror.InternalError(err)
+	}
+
+	for _, tag := range tags {
+		s.audit.Record(actor, domain.AuditTagRemoveFromPhotos, domain.AuditEntityTag, auditID(tag.ID), auditPhotoIDs{PhotoIDs: photoIDs}, nil)
+	}
+	return nil
+}
+
+// TagSlug normalises a tag name: lowercase, unicode letters and digits kept,
+// everything else collapsed to single hyphens ("Night Street" -> "night-street")
+func TagSlug(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		if !hyphen && b.Len() > 0 {
+			b.WriteRune('-')
+			hyphen = true
+		}
+	}
+	slug := strings.TrimRight(b.String(), "-")
+	if len(slug) > 60 {
+		slug = strings.ToValidUTF8(slug[:60], "")
+	}
+	return slug
+}
+
+// TagSlugs normalises and de-duplicates a list of tag names or slugs
+func TagSlugs(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	slugs := make([]string, 0, len(names))
+	for _, name := range names {
+		slug := TagSlug(name)
+		if slug != "" && !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
+
+func tagsFromNames(names []string) []domain.Tag {
+	seen := make(map[string]bool, len(names))
+	tags := make([]domain.Tag, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := TagSlug(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		if len(name) > 50 {
+			name = strings.ToValidUTF8(name[:50], "")
+		}
+		tags = append(tags, domain.Tag{Name: name, Slug: slug})
+	}
+	return tags
+}
+
+func tagIDs(tags []domain.Tag) []uint {
+	ids := make([]uint, len(tags))
+	for i, tag := range tags {
+		ids[i] = tag.ID
+	}
+	return ids
+}
@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+)
+
+func TestTagSlug(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"Night Street", "night-street"},
+		{"  B&W -- film!  ", "b-w-film"},
+		{"上海 外滩", "上海-外滩"},
+		{"2024", "2024"},
+		{"!!!", ""},
+	}
+	for _, tt := range tests {
+		if got := TagSlug(tt.name); got != tt.want {
+			t.Errorf("TagSlug(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Long names are cut to the column size without splitting a character
+	long := TagSlug(strings.Repeat("夜", 30))
+	if len(long) > 60 || !utf8.ValidString(long) || long != strings.Repeat("夜", 20) {
+		t.Errorf("long slug = %q (%d bytes)", long, len(long))
+	}
+}
+
+func TestTagsFromNamesMergesNamesWithTheSameSlug(t *testing.T) {
+	got := tagsFromNames([]string{" Night Street ", "night-street", "", "Film", "?"})
+	want := []domain.Tag{{Name: "Night Street", Slug: "night-street"}, {Name: "Film", Slug: "film"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tagsFromNames = %+v, want %+v", got, want)
+	}
+
+	if slugs := TagSlugs([]string{"Film", "FILM", "night street"}); !reflect.DeepEqual(slugs, []string{"film", "night-street"}) {
+		t.Fatalf("TagSlugs = %q", slugs)
+	}
+}
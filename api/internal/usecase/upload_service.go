@@ -0,0 +1,271 @@
+package usecase
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/infrastructure/imaging"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/pkg/logger"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrUploadNotFound   = errors.New("upload not found or expired")
+	ErrUploadIncomplete = errors.New("object has not been uploaded yet")
+)
+
+const uploadCleanupBatchSize = 100
+
+// UploadService turns uploaded objects into photos and removes the ones
+// that are never completed
+type UploadService interface {
+	// Complete checks the object behind a pending upload and creates a draft photo for it.
+	// Objects that are too large or not real images are deleted.
+	Complete(objectKey string, req *domain.CompleteUploadRequest, actor domain.Actor) (*domain.Photo, error)
+
+	// CleanupExpired runs one pass and returns how many uploads were removed.
+	// It also aborts unfinished multipart uploads older than the pending TTL.
+	CleanupExpired() (int, error)
+
+	// Close stops the background schedule and waits for a running pass
+	Close()
+}
+
+type uploadService struct {
+	uploads    repository.PendingUploadRepository
+	photoRepo  repository.PhotoRepository
+	storage    StorageService
+	photos     PhotoService
+	audit      AuditService
+	maxBytes   int64
+	maxPixels  int64
+	pendingTTL time.Duration
+	// Objects assembled from multipart uploads have their own size limit
+	multipartMax int64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewUploadService starts collecting expired uploads on cfg.UploadCleanupInterval
+func NewUploadService(uploads repository.PendingUploadRepository, photoRepo repository.PhotoRepository, storage StorageService, photos PhotoService, audit AuditService, cfg config.Config) UploadService {
+	s := &uploadService{
+		uploads:    uploads,
+		photoRepo:  photoRepo,
+		storage:    storage,
+		photos:     photos,
+		audit:      audit,
+		maxBytes:   cfg.UploadMaxBytes,
+		maxPixels:  cfg.UploadMaxPixels,
+		pendingTTL: cfg.UploadPendingTTL,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+
+		multipartMax: cfg.MultipartMaxBytes,
+	}
+	go s.run(cfg.UploadCleanupInterval)
+	return s
+}
+
+func (s *uploadService) Complete(objectKey string, req *domain.CompleteUploadRequest, actor domain.Actor) (*domain.Photo, error) {
+	pending, err := s.uploads.GetByObjectKey(objectKey)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound(ErrUploadNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	// Uploads can only be completed by whoever started them
+	if pending.UserID != actor.UserID || time.Now().After(pending.ExpiresAt) {
+		return nil, apperror.NotFound(ErrUploadNotFound)
+	}
+
+	info, err := s.storage.StatObject(objectKey)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil, apperror.Conflict(ErrUploadIncomplete)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	maxBytes := s.maxBytes
+	if pending.MultipartUploadID != nil {
+		maxBytes = s.multipartMax
+	}
+	if err := s.validateObject(info, maxBytes); err != nil {
+		// Storage errors are not the upload's fault; keep it for a retry
+		if rejectedUpload(err) {
+			s.discard(objectKey)
+		}
+		return nil, err
+	}
+
+	// Claim the upload so concurrent completions cannot create two photos
+	if _, err := s.uploads.Consume(objectKey); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound(ErrUploadNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+
+	photo, err := s.photos.Create(&domain.CreatePhotoRequest{
+		Title:       uploadTitle(req.Title, pending.Filename, objectKey),
+		Description: req.Description,
+		ObjectKey:   objectKey,
+		Category:    req.Category,
+		Location:    req.Location,
+	}, actor)
+	if err != nil {
+		// Put the claim back so the client can retry
+		pending.ID = 0
+		if restoreErr := s.uploads.Create(pending); restoreErr != nil {
+			logger.Error("failed to restore pending upload", "objectKey", objectKey, "error", restoreErr)
+		}
+		return nil, err
+	}
+	return photo, nil
+}
+
+// validateObject checks the stored size, then the magic bytes and header of the object itself
+func (s *uploadService) validateObject(info *ObjectInfo, maxBytes int64) error {
+	if info.Size > maxBytes {
+		return fileTooLargeError(maxBytes)
+	}
+
+	reader, err := s.storage.GetObject(info.Key)
+	if err != nil {
+		return apperror.InternalError(err)
+	}
+	defer reader.Close()
+
+	if _, _, err := imaging.Inspect(reader, s.maxPixels); err != nil {
+		if errors.Is(err, imaging.ErrTooManyPixels) || errors.Is(err, imaging.ErrUnsupportedFormat) {
+			return inspectError(err, s.maxPixels)
+		}
+		return apperror.InternalError(err)
+	}
+	return nil
+}
+
+// rejectedUpload reports whether validateObject refused the object itself,
+// as opposed to failing to read it
+func rejectedUpload(err error) bool {
+	return errors.Is(err, ErrFileTooLarge) || errors.Is(err, ErrImageTooManyPixels) || errors.Is(err, ErrUnsupportedImage)
+}
+
+// discard drops an upload whose object was rejected
+func (s *uploadService) discard(objectKey string) {
+	if err := s.storage.RemoveObject(objectKey); err != nil {
+		logger.Error("failed to remove rejected upload", "objectKey", objectKey, "error", err)
+		return
+	}
+	if _, err := s.uploads.Consume(objectKey); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("failed to delete rejected upload", "objectKey", objectKey, "error", err)
+	}
+}
+
+func (s *uploadService) run(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.CleanupExpired(); err != nil {
+			logger.Error("upload cleanup failed", "error", err)
+		} else if n > 0 {
+			logger.Info("removed expired uploads", "count", n)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *uploadService) Close() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+func (s *uploadService) CleanupExpired() (int, error) {
+	// Parts of abandoned multipart uploads are invisible in the bucket but still stored
+	if n, err := s.storage.AbortStaleMultipartUploads(time.Now().Add(-s.pendingTTL)); err != nil {
+		logger.Error("failed to abort stale multipart uploads", "error", err)
+	} else if n > 0 {
+		logger.Info("aborted stale multipart uploads", "count", n)
+	}
+
+	removed := 0
+
+	for {
+		uploads, err := s.uploads.ListExpired(time.Now(), uploadCleanupBatchSize)
+		if err != nil {
+			return removed, err
+		}
+
+		batchRemoved := 0
+		for i := range uploads {
+			upload := &uploads[i]
+			// Keep the row if the object could not be removed, so the next pass retries
+			if err := s.removeExpired(upload); err != nil {
+				logger.Error("failed to remove expired upload", "objectKey", upload.ObjectKey, "error", err)
+				continue
+			}
+			if _, err := s.uploads.Consume(upload.ObjectKey); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.Error("failed to delete expired upload", "objectKey", upload.ObjectKey, "error", err)
+				continue
+			}
+			s.audit.Record(domain.SystemActor("upload_cleanup"), domain.AuditStorageExpire, domain.AuditEntityStorageObject, upload.ObjectKey, upload, nil)
+			batchRemoved++
+		}
+		removed += batchRemoved
+
+		// Stop on the last page, or when nothing in this page could be removed
+		if len(uploads) < uploadCleanupBatchSize || batchRemoved == 0 {
+			return removed, nil
+		}
+	}
+}
+
+// removeExpired deletes the object unless a photo was created for it by hand
+func (s *uploadService) removeExpired(upload *domain.PendingUpload) error {
+	inUse, err := s.photoRepo.ObjectKeyInUse(upload.ObjectKey, 0)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return nil
+	}
+	return s.storage.RemoveObject(upload.ObjectKey)
+}
+
+// uploadTitle falls back to the uploaded file name, then the object key, without extension
+func uploadTitle(title, filename, objectKey string) string {
+	if title = strings.TrimSpace(title); title != "" {
+		return title
+	}
+	name := filename
+	if name == "" {
+		name = path.Base(objectKey)
+	}
+	name = strings.TrimSpace(strings.TrimSuffix(name, path.Ext(name)))
+	if name == "" {
+		return "Untitled"
+	}
+	if utf8.RuneCountInString(name) > 200 {
+		name = string([]rune(name)[:200])
+	}
+	return name
+}
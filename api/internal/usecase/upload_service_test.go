@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/config"
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// expiredUploads serves a fixed set of expired uploads until they are consumed
+type expiredUploads struct {
+	repository.PendingUploadRepository
+	mu      sync.Mutex
+	pending []domain.PendingUpload
+}
+
+func (r *expiredUploads) ListExpired(time.Time, int) ([]domain.PendingUpload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.PendingUpload(nil), r.pending...), nil
+}
+
+func (r *expiredUploads) Consume(objectKey string) (*domain.PendingUpload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, upload := range r.pending {
+		if upload.ObjectKey == objectKey {
+			r.pending = append(r.pending[:i], r.pending[i+1:]...)
+			return &upload, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// keysInUse reports the listed keys as referenced by photos
+type keysInUse struct {
+	repository.PhotoRepository
+	keys map[string]bool
+}
+
+func (r keysInUse) ObjectKeyInUse(objectKey string, _ uint) (bool, error) {
+	return r.keys[objectKey], nil
+}
+
+// removalRecorder records which objects are deleted from storage
+type removalRecorder struct {
+	StorageService
+	mu      sync.Mutex
+	removed []string
+}
+
+func (s *removalRecorder) AbortStaleMultipartUploads(time.Time) (int, error) { return 0, nil }
+
+func (s *removalRecorder) RemoveObject(objectKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removed = append(s.removed, objectKey)
+	return nil
+}
+
+func TestCleanupKeepsObjectsReferencedByPhotos(t *testing.T) {
+	uploads := &expiredUploads{pending: []domain.PendingUpload{
+		{ObjectKey: "photos/abandoned.jpg"},
+		{ObjectKey: "photos/saved-by-url.jpg"},
+	}}
+	storage := &removalRecorder{}
+	photos := keysInUse{keys: map[string]bool{"photos/saved-by-url.jpg": true}}
+
+	svc := NewUploadService(uploads, photos, storage, nil, discardAudit{}, config.Config{UploadCleanupInterval: time.Hour})
+	svc.Close()
+
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	if want := []string{"photos/abandoned.jpg"}; !reflect.DeepEqual(storage.removed, want) {
+		t.Fatalf("removed %v, want %v", storage.removed, want)
+	}
+	// Both rows are done with: one object is gone, the other belongs to a photo
+	if len(uploads.pending) != 0 {
+		t.Fatalf("pending uploads left: %+v", uploads.pending)
+	}
+}
+
+func (r *expiredUploads) GetByObjectKey(objectKey string) (*domain.PendingUpload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, upload := range r.pending {
+		if upload.ObjectKey == objectKey {
+			return &upload, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// storedObject serves one object, or fails to read it when body is nil
+type storedObject struct {
+	*removalRecorder
+	body io.Reader
+}
+
+func (s storedObject) StatObject(objectKey string) (*ObjectInfo, error) {
+	return &ObjectInfo{Key: objectKey, Size: 1024}, nil
+}
+
+func (s storedObject) GetObject(string) (io.ReadCloser, error) {
+	if s.body == nil {
+		return nil, errors.New("connection reset")
+	}
+	return io.NopCloser(s.body), nil
+}
+
+func TestCompleteDiscardsOnlyRejectedObjects(t *testing.T) {
+	// A JPEG signature whose header cannot be read to the end
+	brokenRead := io.MultiReader(strings.NewReader("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"), iotest.ErrReader(errors.New("connection reset")))
+
+	cases := []struct {
+		name      string
+		body      io.Reader
+		status    int
+		discarded bool
+	}{
+		{"not an image", strings.NewReader("plain text, not an image"), http.StatusUnsupportedMediaType, true},
+		{"object cannot be opened", nil, http.StatusInternalServerError, false},
+		{"object read fails", brokenRead, http.StatusInternalServerError, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			const key = "photos/a.jpg"
+			uploads := &expiredUploads{pending: []domain.PendingUpload{
+				{ObjectKey: key, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)},
+			}}
+			storage := storedObject{removalRecorder: &removalRecorder{}, body: tc.body}
+			svc := &uploadService{uploads: uploads, storage: storage, maxBytes: 1 << 20, maxPixels: 1 << 20}
+
+			_, err := svc.Complete(key, &domain.CompleteUploadRequest{}, domain.Actor{UserID: 1})
+			var appErr *apperror.AppError
+			if !errors.As(err, &appErr) || appErr.StatusCode != tc.status {
+				t.Fatalf("complete: got %v, want %d", err, tc.status)
+			}
+			if discarded := len(storage.removed) == 1 && len(uploads.pending) == 0; discarded != tc.discarded {
+				t.Fatalf("discarded = %v, want %v (removed %v, pending %d)", discarded, tc.discarded, storage.removed, len(uploads.pending))
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUsernameEmpty      = errors.New("username cannot be empty")
+	ErrEmailExists        = errors.New("a user with this email already exists")
+	ErrRoleInvalid        = errors.New("role must be owner, admin, editor or viewer")
+	ErrOwnerRequired      = errors.New("only owners can manage admin and owner accounts or grant those roles")
+	ErrCannotDisableSelf  = errors.New("you cannot disable or delete your own account")
+	ErrActorNotAuthorized = errors.New("you do not have permission to manage users")
+)
+
+// temporaryPasswordBytes gives a 22-character URL-safe password
+const temporaryPasswordBytes = 16
+
+// UserService manages accounts on behalf of an authenticated actor. Every
+// method takes the actor so owner-only rules are checked against the actor's
+// current role in the database and the change is attributed in the audit log.
+type UserService interface {
+	List() ([]domain.User, error)
+	Invite(actor domain.Actor, req *domain.InviteUserRequest) (*domain.InviteUserResponse, error)
+	UpdateRole(actor domain.Actor, userID uint, role domain.Role) (*domain.User, error)
+	SetDisabled(actor domain.Actor, userID uint, disabled bool) (*domain.User, error)
+	Delete(actor domain.Actor, userID uint) error
+
+	// ListLoginAttempts returns the password login log, newest first
+	ListLoginAttempts(filters repository.LoginAttemptFilters) ([]domain.LoginAttempt, error)
+	// Unlock lifts a lockout caused by repeated login failures
+	Unlock(actor domain.Actor, userID uint) (*domain.User, error)
+	// ResetMFA turns off two-factor login for a user who lost their authenticator
+	ResetMFA(actor domain.Actor, userID uint) (*domain.User, error)
+}
+
+type userService struct {
+	repo     repository.UserRepository
+	sessions repository.SessionRepository
+	attempts repository.LoginAttemptRepository
+	mfa      repository.MFARepository
+	logins   LoginUnlocker
+	audit    AuditService
+}
+
+// LoginUnlocker lifts the in-memory login backoff AuthService keeps per username
+type LoginUnlocker interface {
+	UnlockLogin(username string)
+}
+
+func NewUserService(repo repository.UserRepository, sessions repository.SessionRepository, attempts repository.LoginAttemptRepository, mfa repository.MFARepository, logins LoginUnlocker, audit AuditService) UserService {
+	return &userService{repo: repo, sessions: sessions, attempts: attempts, mfa: mfa, logins: logins, audit: audit}
+}
+
+func (s *userService) List() ([]domain.User, error) {
+	users, err := s.repo.List()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return users, nil
+}
+
+func (s *userService) Invite(actor domain.Actor, req *domain.InviteUserRequest) (*domain.InviteUserResponse, error) {
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		return nil, apperror.BadRequest(ErrUsernameEmpty)
+	}
+	if !req.Role.Valid() {
+		return nil, apperror.BadRequest(ErrRoleInvalid)
+	}
+	acting, err := s.actingUser(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !mayManageRole(acting, req.Role) {
+		return nil, apperror.Forbidden(ErrOwnerRequired)
+	}
+
+	exists, err := s.repo.UsernameExists(username)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if exists {
+		return nil, apperror.Conflict(ErrUserExists)
+	}
+	exists, err = s.repo.EmailExists(req.Email)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if exists {
+		return nil, apperror.Conflict(ErrEmailExists)
+	}
+
+	password, err := temporaryPassword()
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	user := &domain.User{
+		Username:           username,
+		Email:              req.Email,
+		Role:               req.Role,
+		MustChangePassword: true,
+	}
+	if err := user.HashPassword(password); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	if err := s.repo.Create(user); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+
+	s.audit.Record(actor, domain.AuditUserInvite, domain.AuditEntityUser, auditID(user.ID), nil, user)
+	return &domain.InviteUserResponse{User: user, TemporaryPassword: password}, nil
+}
+
+func (s *userService) UpdateRole(actor domain.Actor, userID uint, role domain.Role) (*domain.User, error) {
+	if !role.Valid() {
+		return nil, apperror.BadRequest(ErrRoleInvalid)
+	}
+	acting, target, err := s.actorAndTarget(actor.UserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	// Only owners may touch admins and owners or create new ones
+	if !mayManageRole(acting, target.Role) || !mayManageRole(acting, role) {
+		return nil, apperror.Forbidden(ErrOwnerRequired)
+	}
+
+	if err := s.repo.UpdateRole(userID, role); err != nil {
+		return nil, s.mapWriteError(err)
+	}
+	return s.reloadAudited(actor, domain.AuditUserUpdateRole, target)
+}
+
+func (s *userService) SetDisabled(actor domain.Actor, userID uint, disabled bool) (*domain.User, error) {
+	if disabled && actor.UserID == userID {
+		return nil, apperror.BadRequest(ErrCannotDisableSelf)
+	}
+	acting, target, err := s.actorAndTarget(actor.UserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !mayManageRole(acting, target.Role) {
+		return nil, apperror.Forbidden(ErrOwnerRequired)
+	}
+
+	if err := s.repo.SetDisabled(userID, disabled); err != nil {
+		return nil, s.mapWriteError(err)
+	}
+	if disabled {
+		if err := s.sessions.RevokeAllForUser(userID, 0); err != nil {
+			return nil, apperror.InternalError(err)
+		}
+	}
+	action := domain.AuditUserEnable
+	if disabled {
+		action = domain.AuditUserDisable
+	}
+	return s.reloadAudited(actor, action, target)
+}
+
+func (s *userService) Delete(actor domain.Actor, userID uint) error {
+	if actor.UserID == userID {
+		return apperror.BadRequest(ErrCannotDisableSelf)
+	}
+	acting, target, err := s.actorAndTarget(actor.UserID, userID)
+	if err != nil {
+		return err
+	}
+	if !mayManageRole(acting, target.Role) {
+		return apperror.Forbidden(ErrOwnerRequired)
+	}
+
+	if err := s.repo.Delete(userID); err != nil {
+		return s.mapWriteError(err)
+	}
+	s.audit.Record(actor, domain.AuditUserDelete, domain.AuditEntityUser, auditID(target.ID), target, nil)
+	return nil
+}
+
+func (s *userService) ListLoginAttempts(filters repository.LoginAttemptFilters) ([]domain.LoginAttempt, error) {
+	attempts, err := s.attempts.List(filters)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return attempts, nil
+}
+
+func (s *userService) Unlock(actor domain.Actor, userID uint) (*domain.User, error) {
+	acting, target, err := s.actorAndTarget(actor.UserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !mayManageRole(acting, target.Role) {
+		return nil, apperror.Forbidden(ErrOwnerRequired)
+	}
+
+	if err := s.repo.ResetLoginFailures(userID); err != nil {
+		return nil, s.mapWriteError(err)
+	}
+	// Otherwise the next login would still be refused with 429 until the backoff ran out
+	s.logins.UnlockLogin(target.Username)
+	return s.reloadAudited(actor, domain.AuditUserUnlock, target)
+}
+
+func (s *userService) ResetMFA(actor domain.Actor, userID uint) (*domain.User, error) {
+	acting, target, err := s.actorAndTarget(actor.UserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !mayManageRole(acting, target.Role) {
+		return nil, apperror.Forbidden(ErrOwnerRequired)
+	}
+
+	if err := s.repo.DisableTOTP(userID); err != nil {
+		return nil, s.mapWriteError(err)
+	}
+	if err := s.mfa.DeleteForUser(userID); err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return s.reloadAudited(actor, domain.AuditUserResetMFA, target)
+}
+
+// actingUser loads the acting user and re-checks that they may manage users
+func (s *userService) actingUser(actorID uint) (*domain.User, error) {
+	acting, err := s.repo.GetByID(actorID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.Unauthorized(ErrUserNotFound)
+		}
+		return nil, apperror.InternalError(err)
+	}
+	if acting.IsDisabled() || !acting.Role.Can(domain.PermUsersManage) {
+		return nil, apperror.Forbidden(ErrActorNotAuthorized)
+	}
+	return acting, nil
+}
+
+// mayManageRole reports whether acting may manage accounts holding role;
+// admin and owner accounts need admins:manage
+func mayManageRole(acting *domain.User, role domain.Role) bool {
+	return !role.Privileged() || acting.Role.Can(domain.PermAdminsManage)
+}
+
+func (s *userService) actorAndTarget(actorID, userID uint) (*domain.User, *domain.User, error) {
+	acting, err := s.actingUser(actorID)
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := s.repo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, apperror.NotFound(ErrUserNotFound)
+		}
+		return nil, nil, apperror.InternalError(err)
+	}
+	return acting, target, nil
+}
+
+func (s *userService) reload(userID uint) (*domain.User, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, apperror.InternalError(err)
+	}
+	return user, nil
+}
+
+// reloadAudited reloads a changed user and records the change against its state before
+func (s *userService) reloadAudited(actor domain.Actor, action domain.AuditAction, before *domain.User) (*domain.User, error) {
+	user, err := s.reload(before.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(actor, action, domain.AuditEntityUser, auditID(user.ID), before, user)
+	return user, nil
+}
+
+func (s *userService) mapWriteError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrLastOwner):
+		return apperror.Conflict(err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apperror.NotFound(ErrUserNotFound)
+	}
+	return apperror.InternalError(err)
+}
+
+// temporaryPassword generates a random password for invited users
+func temporaryPassword() (string, error) {
+	buf := make([]byte, temporaryPasswordBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aton/atonWeb/api/internal/domain"
+	"github.com/aton/atonWeb/api/internal/pkg/apperror"
+	"github.com/aton/atonWeb/api/internal/repository"
+)
+
+// memoryUserRepo keeps users in a map; methods a test does not need panic
+type memoryUserRepo struct {
+	repository.UserRepository
+	users map[uint]*domain.User
+}
+
+func (r *memoryUserRepo) GetByID(id uint) (*domain.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *memoryUserRepo) GetByEmail(email string) (*domain.User, error) {
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryUserRepo) EmailExists(email string) (bool, error) {
+	_, err := r.GetByEmail(email)
+	return err == nil, nil
+}
+
+func (r *memoryUserRepo) GetByOIDCIdentity(issuer, subject string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.OIDCLinked() && *user.OIDCIssuer == issuer && *user.OIDCSubject == subject {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryUserRepo) LinkOIDCIdentity(id uint, issuer, subject string) error {
+	user, ok := r.users[id]
+	if !ok || user.OIDCLinked() {
+		return gorm.ErrRecordNotFound
+	}
+	user.OIDCIssuer, user.OIDCSubject = &issuer, &subject
+	return nil
+}
+
+func (r *memoryUserRepo) UpdateRole(id uint, role domain.Role) error {
+	r.users[id].Role = role
+	return nil
+}
+
+func (r *memoryUserRepo) ResetLoginFailures(id uint) error {
+	r.users[id].FailedLoginCount = 0
+	r.users[id].LockedUntil = nil
+	return nil
+}
+
+// unlockRecorder records which usernames had their login backoff lifted
+type unlockRecorder []string
+
+func (r *unlockRecorder) UnlockLogin(username string) {
+	*r = append(*r, username)
+}
+
+func TestUnlockLiftsLoginThrottle(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Hour)
+	repo := &memoryUserRepo{users: map[uint]*domain.User{
+		1: {ID: 1, Username: "owner", Role: domain.RoleOwner},
+		2: {ID: 2, Username: "alice", Role: domain.RoleEditor, FailedLoginCount: 5, LockedUntil: &lockedUntil},
+	}}
+	unlocked := &unlockRecorder{}
+	svc := NewUserService(repo, nil, nil, nil, unlocked, discardAudit{})
+
+	user, err := svc.Unlock(domain.Actor{UserID: 1}, 2)
+	if err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if user.LockedUntil != nil || user.FailedLoginCount != 0 {
+		t.Fatalf("account still locked: %+v", user)
+	}
+	if len(*unlocked) != 1 || (*unlocked)[0] != "alice" {
+		t.Fatalf("login backoff lifted for %v, want [alice]", *unlocked)
+	}
+}
+
+func TestOnlyOwnersChangeAdminRoles(t *testing.T) {
+	repo := &memoryUserRepo{users: map[uint]*domain.User{
+		1: {ID: 1, Username: "owner", Role: domain.RoleOwner},
+		2: {ID: 2, Username: "admin", Role: domain.RoleAdmin},
+		3: {ID: 3, Username: "other-admin", Role: domain.RoleAdmin},
+		4: {ID: 4, Username: "editor", Role: domain.RoleEditor},
+	}}
+	svc := NewUserService(repo, nil, nil, nil, nil, discardAudit{})
+
+	forbidden := []struct {
+		name   string
+		target uint
+		role   domain.Role
+	}{
+		{"demote another admin", 3, domain.RoleViewer},
+		{"promote to admin", 4, domain.RoleAdmin},
+		{"promote to owner", 4, domain.RoleOwner},
+	}
+	for _, tc := range forbidden {
+		_, err := svc.UpdateRole(domain.Actor{UserID: 2}, tc.target, tc.role)
+		var appErr *apperror.AppError
+		if !errors.As(err, &appErr) || appErr.StatusCode != http.StatusForbidden {
+			t.Fatalf("admin may %s: err = %v", tc.name, err)
+		}
+	}
+
+	if _, err := svc.UpdateRole(domain.Actor{UserID: 2}, 4, domain.RoleViewer); err != nil {
+		t.Fatalf("admin demoting an editor: %v", err)
+	}
+	if _, err := svc.UpdateRole(domain.Actor{UserID: 1}, 3, domain.RoleEditor); err != nil {
+		t.Fatalf("owner demoting an admin: %v", err)
+	}
+	if repo.users[3].Role != domain.RoleEditor {
+		t.Fatalf("role = %s, want editor", repo.users[3].Role)
+	}
+}
@@ -3,6 +3,7 @@
 import { useState } from "react";
 import { useRouter } from "next/navigation";
 import * as authAPI from "@/lib/api/auth";
+import { apiClient } from "@/lib/api/client";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
@@ -46,8 +47,7 @@ export default function ChangePasswordPage() {
     try {
       if (typeof window === "undefined") return;
 
-      const token = localStorage.getItem("token");
-      if (!token) {
+      if (!apiClient.isAuthenticated()) {
         router.push("/admin/login");
         return;
       }
@@ -56,8 +56,8 @@ export default function ChangePasswordPage() {
 
       setSuccess("Password changed successfully! Redirecting to login...");
 
-      // 清除 token,要求重新登录
-      localStorage.removeItem("token");
+      // 结束当前会话,要求重新登录
+      await apiClient.logout();
 
       // 2 秒后跳转到登录页
       setTimeout(() => {
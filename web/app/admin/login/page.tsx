@@ -3,6 +3,11 @@
 import { useState } from "react";
 import { useRouter } from "next/navigation";
 import * as authAPI from "@/lib/api/auth";
+import {
+  InputOTP,
+  InputOTPGroup,
+  InputOTPSlot,
+} from "@/components/ui/input-otp";
 
 export default function LoginPage() {
   const router = useRouter();
@@ -10,6 +15,10 @@ export default function LoginPage() {
   const [password, setPassword] = useState("");
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState("");
+  // Set after a correct password for accounts with two-factor authentication
+  const [challengeToken, setChallengeToken] = useState("");
+  const [code, setCode] = useState("");
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -18,10 +27,12 @@ export default function LoginPage() {
 
     try {
       const data = await authAPI.login(username, password);
-      if (typeof window !== "undefined") {
-        localStorage.setItem("token", data.token);
+      if (data.mfaRequired) {
+        setChallengeToken(data.challengeToken);
+        setCode("");
+        return;
       }
-      router.push("/admin/photos");
+      router.push(data.mustChangePassword ? "/admin/change-password" : "/admin/photos");
     } catch (err: any) {
       setError(err.message || "Invalid username or password");
     } finally {
@@ -29,6 +40,31 @@ export default function LoginPage() {
     }
   };
 
+  const handleVerify = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setLoading(true);
+    setError("");
+
+    try {
+      const data = await authAPI.verifyMFA(challengeToken, code.trim());
+      router.push(data.mustChangePassword ? "/admin/change-password" : "/admin/photos");
+    } catch (err: any) {
+      setError(err.message || "Invalid verification code");
+      setCode("");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  // Challenges expire; signing in again issues a new one
+  const restartLogin = () => {
+    setChallengeToken("");
+    setCode("");
+    setUseRecoveryCode(false);
+    setPassword("");
+    setError("");
+  };
+
   return (
     <div className="min-h-screen grid lg:grid-cols-2">
       {/* Left side with illustration */}
@@ -80,45 +116,118 @@ export default function LoginPage() {
             </div>
           )}
 
-          <form onSubmit={handleSubmit} className="space-y-6">
-            <div className="space-y-2">
-              <label className="text-sm text-gray-600" htmlFor="username">
-                Username
-              </label>
-              <input
-                id="username"
-                type="text"
-                value={username}
-                onChange={(e) => setUsername(e.target.value)}
-                className="w-full px-4 py-2.5 border border-gray-300 rounded-lg focus:ring-2 focus:ring-[#B5CCBE] focus:border-transparent transition-all outline-none"
-                placeholder="Enter your username"
-                required
-              />
-            </div>
+          {challengeToken ? (
+            <form onSubmit={handleVerify} className="space-y-6">
+              <div className="space-y-2">
+                <label className="text-sm text-gray-600" htmlFor="code">
+                  {useRecoveryCode ? "Recovery code" : "Authentication code"}
+                </label>
+                {useRecoveryCode ? (
+                  <input
+                    id="code"
+                    type="text"
+                    value={code}
+                    onChange={(e) => setCode(e.target.value)}
+                    className="w-full px-4 py-2.5 border border-gray-300 rounded-lg focus:ring-2 focus:ring-[#B5CCBE] focus:border-transparent transition-all outline-none"
+                    placeholder="Enter one of your recovery codes"
+                    autoComplete="off"
+                    autoFocus
+                    required
+                  />
+                ) : (
+                  <InputOTP
+                    id="code"
+                    maxLength={6}
+                    value={code}
+                    onChange={setCode}
+                    inputMode="numeric"
+                    autoComplete="one-time-code"
+                    autoFocus
+                    containerClassName="justify-center"
+                  >
+                    <InputOTPGroup>
+                      {Array.from({ length: 6 }, (_, i) => (
+                        <InputOTPSlot key={i} index={i} />
+                      ))}
+                    </InputOTPGroup>
+                  </InputOTP>
+                )}
+                <p className="text-xs text-gray-500">
+                  {useRecoveryCode
+                    ? "Each recovery code can be used once."
+                    : "Enter the 6-digit code from your authenticator app."}
+                </p>
+              </div>
 
-            <div className="space-y-2">
-              <label className="text-sm text-gray-600" htmlFor="password">
-                Password
-              </label>
-              <input
-                id="password"
-                type="password"
-                value={password}
-                onChange={(e) => setPassword(e.target.value)}
-                className="w-full px-4 py-2.5 border border-gray-300 rounded-lg focus:ring-2 focus:ring-[#B5CCBE] focus:border-transparent transition-all outline-none"
-                placeholder="Enter your password"
-                required
-              />
-            </div>
+              <button
+                type="submit"
+                disabled={loading || (!useRecoveryCode && code.length < 6)}
+                className="w-full bg-gray-700 hover:bg-gray-800 text-white py-2.5 rounded-lg transition-colors disabled:opacity-50 disabled:cursor-not-allowed font-medium"
+              >
+                {loading ? "Verifying..." : "Verify"}
+              </button>
 
-            <button
-              type="submit"
-              disabled={loading}
-              className="w-full bg-gray-700 hover:bg-gray-800 text-white py-2.5 rounded-lg transition-colors disabled:opacity-50 disabled:cursor-not-allowed font-medium"
-            >
-              {loading ? "Signing in..." : "Sign In"}
-            </button>
-          </form>
+              <div className="flex justify-between text-sm">
+                <button
+                  type="button"
+                  onClick={() => {
+                    setUseRecoveryCode(!useRecoveryCode);
+                    setCode("");
+                  }}
+                  className="text-gray-600 hover:text-gray-900"
+                >
+                  {useRecoveryCode ? "Use authenticator app" : "Use a recovery code"}
+                </button>
+                <button
+                  type="button"
+                  onClick={restartLogin}
+                  className="text-gray-600 hover:text-gray-900"
+                >
+                  Back to sign in
+                </button>
+              </div>
+            </form>
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-6">
+              <div className="space-y-2">
+                <label className="text-sm text-gray-600" htmlFor="username">
+                  Username
+                </label>
+                <input
+                  id="username"
+                  type="text"
+                  value={username}
+                  onChange={(e) => setUsername(e.target.value)}
+                  className="w-full px-4 py-2.5 border border-gray-300 rounded-lg focus:ring-2 focus:ring-[#B5CCBE] focus:border-transparent transition-all outline-none"
+                  placeholder="Enter your username"
+                  required
+                />
+              </div>
+
+              <div className="space-y-2">
+                <label className="text-sm text-gray-600" htmlFor="password">
+                  Password
+                </label>
+                <input
+                  id="password"
+                  type="password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  className="w-full px-4 py-2.5 border border-gray-300 rounded-lg focus:ring-2 focus:ring-[#B5CCBE] focus:border-transparent transition-all outline-none"
+                  placeholder="Enter your password"
+                  required
+                />
+              </div>
+
+              <button
+                type="submit"
+                disabled={loading}
+                className="w-full bg-gray-700 hover:bg-gray-800 text-white py-2.5 rounded-lg transition-colors disabled:opacity-50 disabled:cursor-not-allowed font-medium"
+              >
+                {loading ? "Signing in..." : "Sign In"}
+              </button>
+            </form>
+          )}
 
           <div className="text-center text-xs text-gray-500 pt-4 border-t border-gray-100">
             Protected admin area
@@ -3,22 +3,20 @@
 import { useEffect } from "react";
 import { useRouter } from "next/navigation";
 import { Camera, ArrowLeft } from "lucide-react";
+import { apiClient } from "@/lib/api/client";
 
 export default function AdminDashboard() {
   const router = useRouter();
 
   useEffect(() => {
     if (typeof window === "undefined") return;
-    const token = localStorage.getItem("token");
-    if (!token) {
+    if (!apiClient.isAuthenticated()) {
       router.push("/admin/login");
     }
   }, [router]);
 
-  const handleLogout = () => {
-    if (typeof window !== "undefined") {
-      localStorage.removeItem("token");
-    }
+  const handleLogout = async () => {
+    await apiClient.logout();
     router.push("/admin/login");
   };
 
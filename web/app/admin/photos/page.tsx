@@ -7,7 +7,7 @@ import { PhotoList } from "@/components/admin/PhotoList";
 import { ArrowLeft } from "lucide-react";
 import { ToastProvider } from "@/components/ui/ToastProvider";
 import { apiClient, API_ENDPOINTS } from "@/lib/api/client";
-import type { Photo } from "@/lib/types/photo";
+import type { PaginatedResponse, Photo } from "@/lib/types/photo";
 
 export default function PhotoManagementPage() {
   const router = useRouter();
@@ -25,11 +25,20 @@ export default function PhotoManagementPage() {
 
   const loadPhotos = async () => {
     try {
-      const data = await apiClient.get<{ data: Photo[] }>(
-        API_ENDPOINTS.photos
-        // requireAuth = true (默认)
-      );
-      setPhotos(data.data || []);
+      // 排序需要完整列表, 按游标逐页加载
+      const all: Photo[] = [];
+      let cursor = "";
+      do {
+        const params = new URLSearchParams({ limit: "200" });
+        if (cursor) params.set("cursor", cursor);
+        const page = await apiClient.get<PaginatedResponse<Photo>>(
+          `${API_ENDPOINTS.photos}?${params}`
+          // requireAuth = true (默认)
+        );
+        all.push(...(page.data || []));
+        cursor = page.nextCursor;
+      } while (cursor);
+      setPhotos(all);
     } catch (error) {
       console.error("Failed to load photos:", error);
     } finally {
@@ -3,23 +3,34 @@
 import { useState, useEffect } from "react"
 import { motion } from "framer-motion"
 import { apiClient, API_ENDPOINTS } from "@/lib/api/client"
-import type { Photo } from "@/lib/types/photo"
+import type { PaginatedResponse, Photo } from "@/lib/types/photo"
+
+const PAGE_SIZE = 30
 
 export default function PhotoWall() {
   const [photos, setPhotos] = useState<Photo[]>([])
+  const [nextCursor, setNextCursor] = useState("")
   const [loading, setLoading] = useState(true)
+  const [loadingMore, setLoadingMore] = useState(false)
 
   useEffect(() => {
     loadPhotos()
   }, [])
 
+  const fetchPage = async (cursor: string) => {
+    const params = new URLSearchParams({ limit: String(PAGE_SIZE) })
+    if (cursor) params.set("cursor", cursor)
+    const page = await apiClient.get<PaginatedResponse<Photo>>(
+      `${API_ENDPOINTS.photosPublished}?${params}`,
+      false // 前台不需要 token
+    )
+    setNextCursor(page.nextCursor)
+    return page.data || []
+  }
+
   const loadPhotos = async () => {
     try {
-      const data = await apiClient.get<{ data: Photo[] }>(
-        API_ENDPOINTS.photosPublished,
-        false // 前台不需要 token
-      )
-      setPhotos(data.data || [])
+      setPhotos(await fetchPage(""))
     } catch (error) {
       console.error("Failed to load photos:", error)
     } finally {
@@ -27,6 +38,18 @@ export default function PhotoWall() {
     }
   }
 
+  const loadMore = async () => {
+    setLoadingMore(true)
+    try {
+      const more = await fetchPage(nextCursor)
+      setPhotos((current) => [...current, ...more])
+    } catch (error) {
+      console.error("Failed to load more photos:", error)
+    } finally {
+      setLoadingMore(false)
+    }
+  }
+
   if (loading) {
     return (
       <div className="min-h-screen flex items-center justify-center bg-gray-50">
@@ -104,6 +127,18 @@ export default function PhotoWall() {
             </motion.div>
           ))}
         </div>
+
+        {nextCursor && (
+          <div className="mt-8 text-center">
+            <button
+              onClick={loadMore}
+              disabled={loadingMore}
+              className="px-6 py-2 rounded-md bg-gray-900 text-white text-sm hover:bg-gray-700 disabled:opacity-50 transition-colors"
+            >
+              {loadingMore ? "Loading..." : "Load more"}
+            </button>
+          </div>
+        )}
       </div>
     </div>
   )
@@ -94,11 +94,14 @@ export function PhotoList({ photos, onUpdate }: PhotoListProps) {
     }
 
     try {
-      // Swap display orders
-      await Promise.all([
-        apiClient.put(API_ENDPOINTS.photo(draggedId), { displayOrder: targetPhoto.displayOrder }),
-        apiClient.put(API_ENDPOINTS.photo(targetPhotoId), { displayOrder: draggedPhoto.displayOrder }),
-      ]);
+      // Swap display orders in one batch; the versions make it fail if
+      // either photo changed since the list was loaded
+      await apiClient.post(API_ENDPOINTS.photosReorder, {
+        updates: [
+          { id: draggedId, order: targetPhoto.displayOrder, version: draggedPhoto.version },
+          { id: targetPhotoId, order: draggedPhoto.displayOrder, version: targetPhoto.version },
+        ],
+      });
 
       showToast("Photo order updated successfully", "success");
       onUpdate();
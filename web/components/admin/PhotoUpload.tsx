@@ -63,7 +63,7 @@ export function PhotoUpload({ onUploadSuccess }: PhotoUploadProps) {
 
     try {
       // 1. Get upload token
-      const tokenData = await apiClient.post<{ uploadUrl: string; fileUrl: string }>(
+      const tokenData = await apiClient.post<{ uploadUrl: string; objectKey: string }>(
         API_ENDPOINTS.uploadToken,
         {
           filename: selectedFile.name,
@@ -71,7 +71,7 @@ export function PhotoUpload({ onUploadSuccess }: PhotoUploadProps) {
         }
       );
 
-      const { uploadUrl, fileUrl } = tokenData;
+      const { uploadUrl, objectKey } = tokenData;
 
       // 2. Upload to MinIO
       const uploadRes = await fetch(uploadUrl, {
@@ -86,10 +86,10 @@ export function PhotoUpload({ onUploadSuccess }: PhotoUploadProps) {
         throw new Error("Failed to upload file");
       }
 
-      // 3. Save photo record
+      // 3. Save photo record; the API resolves the image URL from the key
       await apiClient.post(API_ENDPOINTS.photos, {
         ...formData,
-        imageUrl: fileUrl,
+        objectKey,
       });
 
       showToast("Photo uploaded successfully!", "success");
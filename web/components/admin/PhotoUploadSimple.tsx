@@ -65,13 +65,13 @@ export function PhotoUploadSimple({ onUploadSuccess }: PhotoUploadProps) {
       // 1. Get upload token
       const tokenData = await apiClient.post<{
         uploadUrl: string;
-        fileUrl: string;
+        objectKey: string;
       }>(API_ENDPOINTS.uploadToken, {
         filename: selectedFile.name,
         contentType: selectedFile.type,
       });
 
-      const { uploadUrl, fileUrl } = tokenData;
+      const { uploadUrl, objectKey } = tokenData;
 
       // 2. Upload to MinIO
       const uploadRes = await fetch(uploadUrl, {
@@ -86,10 +86,10 @@ export function PhotoUploadSimple({ onUploadSuccess }: PhotoUploadProps) {
         throw new Error("Failed to upload file");
       }
 
-      // 3. Save photo record
+      // 3. Save photo record; the API resolves the image URL from the key
       await apiClient.post(API_ENDPOINTS.photos, {
         ...formData,
-        imageUrl: fileUrl,
+        objectKey,
       });
 
       showToast("Photo uploaded successfully!", "success");
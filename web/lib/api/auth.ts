@@ -1,9 +1,21 @@
-import { apiClient, API_ENDPOINTS } from "./client";
+import { apiClient, API_ENDPOINTS, type TokenPair } from "./client";
 
-interface LoginResponse {
-  token: string;
+interface LoginResponse extends TokenPair {
+  // Set while the account has a temporary password; every other endpoint
+  // answers 403 until it is changed
+  mustChangePassword?: boolean;
 }
 
+// Accounts with two-factor authentication get a challenge instead of tokens;
+// it is completed with verifyMFA
+export interface MFAChallenge {
+  mfaRequired: true;
+  challengeToken: string;
+  challengeExpiresIn: number;
+}
+
+export type LoginResult = (LoginResponse & { mfaRequired?: false }) | MFAChallenge;
+
 interface UserResponse {
   id: number;
   username: string;
@@ -13,11 +25,30 @@ interface UserResponse {
 export async function login(
   username: string,
   password: string
+): Promise<LoginResult> {
+  const data = await apiClient.post<LoginResult>(
+    API_ENDPOINTS.login,
+    { username, password },
+    false
+  );
+  if (!data.mfaRequired) {
+    apiClient.setSession(data);
+  }
+  return data;
+}
+
+// code is a 6-digit authenticator code or a recovery code
+export async function verifyMFA(
+  challengeToken: string,
+  code: string
 ): Promise<LoginResponse> {
-  return apiClient.post<LoginResponse>(API_ENDPOINTS.login, {
-    username,
-    password,
-  });
+  const data = await apiClient.post<LoginResponse>(
+    API_ENDPOINTS.mfaVerify,
+    { challengeToken, code },
+    false
+  );
+  apiClient.setSession(data);
+  return data;
 }
 
 export async function changePassword(
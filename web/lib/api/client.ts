@@ -15,13 +15,29 @@ export class ApiError extends Error {
   }
 }
 
+/**
+ * 登录或刷新返回的令牌对
+ * 对应后端 domain.TokenPair
+ */
+export interface TokenPair {
+  token: string;
+  refreshToken: string;
+  expiresIn: number;
+}
+
+const TOKEN_KEY = "token";
+const REFRESH_TOKEN_KEY = "refreshToken";
+
 /**
  * 统一的 API 客户端
  *
  * 前台接口不需要 token
- * 后台管理接口需要 token
+ * 后台管理接口需要 token; 访问令牌过期 (401) 时用刷新令牌换取新的令牌对并重试一次
  */
 class ApiClient {
+  // 进行中的刷新请求, 并发的 401 共用同一次刷新 (刷新令牌只能使用一次)
+  private refreshing: Promise<boolean> | null = null;
+
   /**
    * 统一响应处理
    * 检查状态码、Content-Type,提取错误信息
@@ -80,7 +96,7 @@ class ApiClient {
 
     // 只在需要认证时添加 token
     if (requireAuth && typeof window !== "undefined") {
-      const token = localStorage.getItem("token");
+      const token = localStorage.getItem(TOKEN_KEY);
       if (token) {
         headers.Authorization = `Bearer ${token}`;
       }
@@ -90,16 +106,26 @@ class ApiClient {
   }
 
   /**
-   * GET 请求
-   * @param url 请求地址
-   * @param requireAuth 是否需要认证(默认: true)
+   * 发送请求; 需要认证的请求遇到 401 时刷新令牌后重试一次
    */
-  async get<T>(url: string, requireAuth = true): Promise<T> {
-    try {
-      const response = await fetch(url, {
-        method: "GET",
+  private async request<T>(
+    method: string,
+    url: string,
+    data: unknown,
+    requireAuth: boolean
+  ): Promise<T> {
+    const send = () =>
+      fetch(url, {
+        method,
         headers: this.getHeaders(requireAuth),
+        ...(data !== undefined ? { body: JSON.stringify(data) } : {}),
       });
+
+    try {
+      let response = await send();
+      if (response.status === 401 && requireAuth && (await this.refresh())) {
+        response = await send();
+      }
       return this.handleResponse<T>(response);
     } catch (error) {
       // 网络错误或其他非 HTTP 错误
@@ -115,31 +141,59 @@ class ApiClient {
   }
 
   /**
-   * POST 请求
-   * @param url 请求地址
-   * @param data 请求体
-   * @param requireAuth 是否需要认证(默认: true)
+   * 用刷新令牌换取新的令牌对; 服务端每次刷新都会轮换刷新令牌
+   * 刷新失败时清除本地会话
    */
-  async post<T>(url: string, data?: unknown, requireAuth = true): Promise<T> {
+  private refresh(): Promise<boolean> {
+    if (!this.refreshing) {
+      this.refreshing = this.rotateTokens().finally(() => {
+        this.refreshing = null;
+      });
+    }
+    return this.refreshing;
+  }
+
+  private async rotateTokens(): Promise<boolean> {
+    if (typeof window === "undefined") return false;
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+    if (!refreshToken) return false;
+
     try {
-      const response = await fetch(url, {
+      const response = await fetch(API_ENDPOINTS.refresh, {
         method: "POST",
-        headers: this.getHeaders(requireAuth),
-        ...(data ? { body: JSON.stringify(data) } : {}),
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ refreshToken }),
       });
-      return this.handleResponse<T>(response);
-    } catch (error) {
-      if (error instanceof ApiError) {
-        throw error;
+      if (!response.ok) {
+        this.clearSession();
+        return false;
       }
-      throw new ApiError(
-        `Network error: ${error instanceof Error ? error.message : "Unknown error"}`,
-        0,
-        url
-      );
+      this.setSession(await response.json());
+      return true;
+    } catch {
+      return false;
     }
   }
 
+  /**
+   * GET 请求
+   * @param url 请求地址
+   * @param requireAuth 是否需要认证(默认: true)
+   */
+  async get<T>(url: string, requireAuth = true): Promise<T> {
+    return this.request<T>("GET", url, undefined, requireAuth);
+  }
+
+  /**
+   * POST 请求
+   * @param url 请求地址
+   * @param data 请求体
+   * @param requireAuth 是否需要认证(默认: true)
+   */
+  async post<T>(url: string, data?: unknown, requireAuth = true): Promise<T> {
+    return this.request<T>("POST", url, data, requireAuth);
+  }
+
   /**
    * PUT 请求
    * @param url 请求地址
@@ -147,23 +201,7 @@ class ApiClient {
    * @param requireAuth 是否需要认证(默认: true)
    */
   async put<T>(url: string, data: unknown, requireAuth = true): Promise<T> {
-    try {
-      const response = await fetch(url, {
-        method: "PUT",
-        headers: this.getHeaders(requireAuth),
-        body: JSON.stringify(data),
-      });
-      return this.handleResponse<T>(response);
-    } catch (error) {
-      if (error instanceof ApiError) {
-        throw error;
-      }
-      throw new ApiError(
-        `Network error: ${error instanceof Error ? error.message : "Unknown error"}`,
-        0,
-        url
-      );
-    }
+    return this.request<T>("PUT", url, data, requireAuth);
   }
 
   /**
@@ -172,22 +210,25 @@ class ApiClient {
    * @param requireAuth 是否需要认证(默认: true)
    */
   async delete<T>(url: string, requireAuth = true): Promise<T> {
-    try {
-      const response = await fetch(url, {
-        method: "DELETE",
-        headers: this.getHeaders(requireAuth),
-      });
-      return this.handleResponse<T>(response);
-    } catch (error) {
-      if (error instanceof ApiError) {
-        throw error;
-      }
-      throw new ApiError(
-        `Network error: ${error instanceof Error ? error.message : "Unknown error"}`,
-        0,
-        url
-      );
-    }
+    return this.request<T>("DELETE", url, undefined, requireAuth);
+  }
+
+  /**
+   * 保存登录或刷新返回的令牌对
+   */
+  setSession(tokens: TokenPair): void {
+    if (typeof window === "undefined") return;
+    localStorage.setItem(TOKEN_KEY, tokens.token);
+    localStorage.setItem(REFRESH_TOKEN_KEY, tokens.refreshToken);
+  }
+
+  /**
+   * 清除本地保存的令牌
+   */
+  clearSession(): void {
+    if (typeof window === "undefined") return;
+    localStorage.removeItem(TOKEN_KEY);
+    localStorage.removeItem(REFRESH_TOKEN_KEY);
   }
 
   /**
@@ -195,15 +236,26 @@ class ApiClient {
    */
   isAuthenticated(): boolean {
     if (typeof window === "undefined") return false;
-    return !!localStorage.getItem("token");
+    return !!localStorage.getItem(TOKEN_KEY);
   }
 
   /**
-   * 退出登录
+   * 退出登录: 在服务端吊销会话并清除本地令牌
    */
-  logout(): void {
-    if (typeof window !== "undefined") {
-      localStorage.removeItem("token");
+  async logout(): Promise<void> {
+    if (typeof window === "undefined") return;
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+    this.clearSession();
+    if (refreshToken) {
+      try {
+        await fetch(API_ENDPOINTS.logout, {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ refreshToken }),
+        });
+      } catch {
+        // 会话在刷新令牌过期后自然失效
+      }
     }
   }
 }
@@ -5,6 +5,9 @@ export const config = {
 export const API_ENDPOINTS = {
   // Auth
   login: `${config.apiBaseUrl}/api/v1/auth/login`,
+  mfaVerify: `${config.apiBaseUrl}/api/v1/auth/mfa/verify`,
+  refresh: `${config.apiBaseUrl}/api/v1/auth/refresh`,
+  logout: `${config.apiBaseUrl}/api/v1/auth/logout`,
   createUser: `${config.apiBaseUrl}/api/v1/auth/create-user`,
   changePassword: `${config.apiBaseUrl}/api/v1/user/change-password`,
 
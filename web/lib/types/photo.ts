@@ -19,6 +19,7 @@ export interface Photo {
   isFeatured: boolean;
   displayOrder: number;
   status: PhotoStatus;
+  version: number; // 每次修改递增，用于并发冲突检测
   createdAt: string; // ISO 8601 时间字符串
   updatedAt: string;
 }
@@ -99,11 +100,12 @@ export interface ApiResponse<T> {
 }
 
 /**
- * 分页响应
+ * 游标分页响应
+ * 对应后端 pageResponse; 游标为空表示没有更多数据, total 仅在 with_count=true 时返回
  */
 export interface PaginatedResponse<T> {
   data: T[];
-  total: number;
-  page: number;
-  pageSize: number;
+  nextCursor: string;
+  prevCursor: string;
+  total?: number;
 }